@@ -73,7 +73,7 @@ const RetireAccount = (props) => {
   function handleClick () {
     setIsDisabled(true)
     props.onRetire(
-      { accountId: account.accountId },
+      { accountId: account.accountId, accountName: account.name },
       __('The account <em class="%s">"%s"</em> has been retired successfully. Log in again to continue.', 'i tracked', account.name),
       __('There was an error retiring the account, please try again.')
     )
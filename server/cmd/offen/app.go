@@ -35,6 +35,7 @@ func newApp(populateMissing, quiet bool, envFileOverride string) *app {
 	}
 
 	logger.SetLevel(cfg.App.LogLevel.LogLevel())
+	logger.SetFormatter(cfg.App.LogFormat.Formatter())
 	if !quiet && !cfg.SMTPConfigured() {
 		logger.Warn("SMTP for transactional email is not configured right now, mail delivery will be unreliable")
 		logger.Warn("Refer to the documentation to find out how to configure SMTP")
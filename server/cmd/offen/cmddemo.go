@@ -88,13 +88,13 @@ func cmdDemo(subcommand string, flags []string) {
 		a.logger.WithError(err).Fatal("Unable to establish database connection")
 	}
 	db, err := persistence.New(
-		relational.NewRelationalDAL(gormDB),
+		relational.NewRelationalDAL(gormDB, relational.WithEventPartitions(a.config.Database.PartitionEvents)),
 	)
 	if err != nil {
 		a.logger.WithError(err).Fatal("Unable to create persistence layer")
 	}
 
-	if err := db.Migrate(); err != nil {
+	if err := db.Migrate(context.Background()); err != nil {
 		a.logger.WithError(err).Fatal("Error applying initial database migrations")
 	}
 	if err := db.Bootstrap(persistence.BootstrapConfig{
@@ -167,6 +167,8 @@ func cmdDemo(subcommand string, flags []string) {
 						userID,
 						accountID.String(),
 						event.Marshal(),
+						"",
+						false,
 						&eventID,
 					); err != nil {
 						done <- err
@@ -228,7 +230,7 @@ func cmdDemo(subcommand string, flags []string) {
 	a.logger.Infof("in your browser. Please make sure to use the `localhost`")
 	a.logger.Infof("hostname so a secure context is available.")
 
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
@@ -4,12 +4,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"time"
 
 	"github.com/offen/offen/server/config"
 	"github.com/offen/offen/server/persistence"
 	"github.com/offen/offen/server/persistence/relational"
+	"github.com/sirupsen/logrus"
 )
 
 var expireUsage = `
@@ -38,15 +42,36 @@ func cmdExpire(subcommand string, flags []string) {
 	}
 
 	db, err := persistence.New(
-		relational.NewRelationalDAL(gormDB),
+		relational.NewRelationalDAL(gormDB, relational.WithEventPartitions(a.config.Database.PartitionEvents)),
+		persistence.WithExpireThreshold(a.config.App.ExpireThreshold),
+		persistence.WithExpireBatchSize(a.config.App.ExpireBatchSize),
+		persistence.WithSecureDelete(a.config.App.SecureDelete),
 	)
 	if err != nil {
 		a.logger.WithError(err).Fatalf("Error setting up database")
 	}
 
-	affected, err := db.Expire(config.EventRetention)
+	result, err := db.Expire(context.Background(), config.EventRetention)
 	if err != nil {
-		a.logger.WithError(err).Fatalf("Error pruning expired events")
+		entry := a.logger.WithError(err).WithFields(purgeResultFields(result))
+		message := "Error pruning expired events"
+		if errors.Is(err, persistence.ErrExpireThresholdExceeded) {
+			entry = entry.WithField("threshold", a.config.App.ExpireThreshold)
+			message = "Pruned more expired events than expected, check your retention settings"
+		}
+		// Events that have been deleted before the error occurred are gone,
+		// so the partial counts are logged before exiting with a failure.
+		entry.Fatal(message)
+	}
+	a.logger.WithFields(purgeResultFields(result)).Info("Successfully expired events")
+}
+
+func purgeResultFields(result persistence.PurgeResult) logrus.Fields {
+	return logrus.Fields{
+		"removed":          result.Removed,
+		"removedByAccount": result.RemovedByAccount,
+		"cutoff":           result.Cutoff.Format(time.RFC3339),
+		"duration":         result.Duration.String(),
+		"batches":          result.Batches,
 	}
-	a.logger.WithField("removed", affected).Info("Successfully expired events")
 }
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 
@@ -38,13 +39,13 @@ func cmdMigrate(subcommand string, flags []string) {
 	}
 
 	db, err := persistence.New(
-		relational.NewRelationalDAL(gormDB),
+		relational.NewRelationalDAL(gormDB, relational.WithEventPartitions(a.config.Database.PartitionEvents)),
 	)
 	if err != nil {
 		a.logger.WithError(err).Fatal("Error creating persistence layer")
 	}
 
-	if err := db.Migrate(); err != nil {
+	if err := db.Migrate(context.Background()); err != nil {
 		a.logger.WithError(err).Fatal("Error applying database migrations")
 	}
 	a.logger.Info("Successfully ran database migrations")
@@ -5,12 +5,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
 	"github.com/offen/offen/server/config"
@@ -60,19 +58,37 @@ func cmdServe(subcommand string, flags []string) {
 		a.logger.WithError(err).Fatal("Unable to establish database connection")
 	}
 
+	persistenceConfigs := []persistence.Config{
+		persistence.WithExpireThreshold(a.config.App.ExpireThreshold),
+		persistence.WithExpireBatchSize(a.config.App.ExpireBatchSize),
+		persistence.WithSecureDelete(a.config.App.SecureDelete),
+		persistence.WithMaxUsersPerAccount(a.config.App.MaxUsersPerAccount),
+		persistence.WithSerializedUserSecrets(a.config.App.SerializeUserSecrets),
+		persistence.WithConsentAudit(a.config.App.ConsentAudit),
+	}
+	if !a.config.SecretEnvelopeKey.IsZero() {
+		envelope, err := persistence.NewMasterKeyEnvelope(a.config.SecretEnvelopeKey.Bytes())
+		if err != nil {
+			a.logger.WithError(err).Fatal("Unable to create secret envelope")
+		}
+		persistenceConfigs = append(persistenceConfigs, persistence.WithSecretEnvelope(envelope))
+	}
+
 	db, err := persistence.New(
-		relational.NewRelationalDAL(gormDB),
+		relational.NewRelationalDAL(gormDB, relational.WithEventPartitions(a.config.Database.PartitionEvents)),
+		persistenceConfigs...,
 	)
 	if err != nil {
 		a.logger.WithError(err).Fatal("Unable to create persistence layer")
 	}
 
-	if a.config.App.SingleNode {
-		if err := db.Migrate(); err != nil {
-			a.logger.WithError(err).Fatal("Error applying database migrations")
-		} else {
-			a.logger.Info("Successfully applied database migrations")
-		}
+	// Migrations are applied after the server has started listening, so
+	// probes can tell the instance is starting up. The readiness gate keeps
+	// the application from handling requests until they have completed.
+	migrateOnStartup := a.config.App.SingleNode || a.config.Database.MigrateOnStartup
+	ready := make(chan struct{})
+	if !migrateOnStartup {
+		close(ready)
 	}
 
 	fs := public.NewLocalizedFS(a.config.App.Locale.String())
@@ -89,75 +105,137 @@ func cmdServe(subcommand string, flags []string) {
 		a.logger.WithError(emailErr).Fatal("Failed parsing template files, cannot continue")
 	}
 
-	srv := &http.Server{
-		Addr: fmt.Sprintf("0.0.0.0:%d", a.config.Server.Port),
-		Handler: router.New(
-			router.WithDatabase(db),
-			router.WithLogger(a.logger),
-			router.WithTemplate(tpl),
-			router.WithEmails(emails),
-			router.WithConfig(a.config),
-			router.WithFS(fs),
-			router.WithMailer(a.config.NewMailer()),
-		),
-	}
-	go func() {
-		if a.config.Server.SSLCertificate != "" && a.config.Server.SSLKey != "" {
-			err := srv.ListenAndServeTLS(a.config.Server.SSLCertificate.String(), a.config.Server.SSLKey.String())
-			if err != nil && err != http.ErrServerClosed {
-				a.logger.WithError(err).Fatal("Error binding server to network")
-			}
-		} else if len(a.config.Server.AutoTLS) != 0 {
-			m := autocert.Manager{
-				Prompt:     autocert.AcceptTOS,
-				HostPolicy: autocert.HostWhitelist(a.config.Server.AutoTLS...),
-				Cache:      autocert.DirCache(a.config.Server.CertificateCache),
-				Email:      a.config.Server.LetsEncryptEmail,
-			}
-			go http.ListenAndServe(":http", m.HTTPHandler(nil))
-			if err := http.Serve(m.Listener(), srv.Handler); err != nil && err != http.ErrServerClosed {
-				a.logger.WithError(err).Fatal("Error binding server to network")
-			}
-		} else {
-			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				a.logger.WithError(err).Fatal("Error binding server to network")
-			}
-		}
-	}()
-	if len(a.config.Server.AutoTLS) != 0 {
-		a.logger.Info("Server now listening on port 80 and 443 using AutoTLS")
-	} else {
-		a.logger.Infof("Server now listening on port %d", a.config.Server.Port)
-	}
+	handler := router.New(
+		router.WithDatabase(db),
+		router.WithLogger(a.logger),
+		router.WithTemplate(tpl),
+		router.WithEmails(emails),
+		router.WithConfig(a.config),
+		router.WithFS(fs),
+		router.WithMailer(a.config.NewMailer()),
+		router.WithFallbackAccount(a.config.App.FallbackAccount),
+		router.WithEventReservoir(a.config.App.EventReservoirSize),
+		router.WithCORSOrigins(a.config.Server.CORSOrigins),
+		router.WithFrameAncestors(a.config.Server.FrameAncestors),
+		router.WithContentSecurityPolicy(a.config.Server.CSP),
+		router.WithHealthCacheTTL(a.config.Server.HealthCacheTTL),
+		router.WithCookieSameSite(a.config.Server.CookieSameSite.SameSite()),
+		router.WithCookieDomain(a.config.Server.CookieDomain),
+		router.WithHostCookiePrefix(a.config.Server.HostCookiePrefix),
+		router.WithAuthCookieMaxAge(a.config.Server.AuthCookieMaxAge),
+		router.WithRateLimit(a.config.Server.AuthRateLimit, a.config.Server.AuthRateBurst),
+		router.WithMetrics(a.config.Server.Metrics),
+		router.WithReadinessGate(ready),
+	)
 
+	// Purging expired events observes this context so that a running purge
+	// stops cleanly between batches when the server is shut down.
+	purgeCtx, cancelPurge := context.WithCancel(context.Background())
+	purgeDone := make(chan struct{})
 	if a.config.App.SingleNode {
-		hourlyJob := time.Tick(time.Hour)
-		runOnInit := make(chan bool)
+		expireJob := time.NewTicker(a.config.App.ExpireInterval)
+		runOnInit := make(chan bool, 1)
 		go func() {
+			defer close(purgeDone)
+			defer expireJob.Stop()
+			// Expiring events requires the schema to be up to date.
+			select {
+			case <-ready:
+			case <-purgeCtx.Done():
+				return
+			}
 			for {
 				select {
-				case <-hourlyJob:
+				case <-expireJob.C:
 				case <-runOnInit:
+				case <-purgeCtx.Done():
+					return
+				}
+				result, err := db.Expire(purgeCtx, config.EventRetention)
+				if result.Interrupted {
+					a.logger.
+						WithFields(purgeResultFields(result)).
+						Info("Stopped pruning expired events because of shutdown")
+					return
+				}
+				if errors.Is(err, persistence.ErrExpireThresholdExceeded) {
+					a.logger.
+						WithError(err).
+						WithFields(purgeResultFields(result)).
+						WithField("threshold", a.config.App.ExpireThreshold).
+						Error("Cron pruned more expired events than expected, check your retention settings")
+					continue
 				}
-				affected, err := db.Expire(config.EventRetention)
 				if err != nil {
-					a.logger.WithError(err).Errorf("Error pruning expired events")
+					a.logger.WithError(err).WithFields(purgeResultFields(result)).Errorf("Error pruning expired events")
 					return
 				}
-				a.logger.WithField("removed", affected).Info("Cron successfully pruned expired events")
+				a.logger.WithFields(purgeResultFields(result)).Info("Cron successfully pruned expired events")
 			}
 		}()
 		runOnInit <- true
+	} else {
+		close(purgeDone)
 	}
 
-	quit := make(chan os.Signal)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	serverConfigs := []router.ServerConfig{
+		router.WithServerDatabase(db),
+		router.WithShutdownGracePeriod(a.config.Server.ShutdownGracePeriod),
+		router.WithShutdownHook(func() {
+			cancelPurge()
+			<-purgeDone
+		}),
+	}
+	if a.config.Server.SSLCertificate != "" && a.config.Server.SSLKey != "" {
+		serverConfigs = append(serverConfigs, router.WithServerTLS(a.config.Server.SSLCertificate.String(), a.config.Server.SSLKey.String()))
+		if a.config.Server.SSLReload {
+			serverConfigs = append(serverConfigs, router.WithServerCertReload(func(err error) {
+				if err != nil {
+					a.logger.WithError(err).Error("Error reloading SSL certificate, continuing to use the previous one")
+					return
+				}
+				a.logger.Info("Successfully reloaded SSL certificate")
+			}))
+		}
+	} else if len(a.config.Server.AutoTLS) != 0 {
+		m := autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(a.config.Server.AutoTLS...),
+			Cache:      autocert.DirCache(a.config.Server.CertificateCache),
+			Email:      a.config.Server.LetsEncryptEmail,
+		}
+		go http.ListenAndServe(":http", m.HTTPHandler(nil))
+		serverConfigs = append(serverConfigs, router.WithServerListener(m.Listener()))
+	}
+	srv := router.NewServer(fmt.Sprintf("0.0.0.0:%d", a.config.Server.Port), handler, serverConfigs...)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
-		a.logger.WithError(err).Fatal("Error shutting down server")
+	if len(a.config.Server.AutoTLS) != 0 {
+		a.logger.Info("Server now listening on port 80 and 443 using AutoTLS")
+	} else {
+		a.logger.Infof("Server now listening on port %d", a.config.Server.Port)
+	}
+	runCtx, cancelRun := context.WithCancel(context.Background())
+	defer cancelRun()
+	migrateErr := make(chan error, 1)
+	if migrateOnStartup {
+		go func() {
+			if err := db.Migrate(runCtx); err != nil {
+				migrateErr <- err
+				cancelRun()
+				return
+			}
+			a.logger.Info("Successfully applied database migrations")
+			close(ready)
+		}()
+	}
+
+	if err := srv.Run(runCtx); err != nil {
+		a.logger.WithError(err).Fatal("Error running server")
+	}
+	select {
+	case err := <-migrateErr:
+		a.logger.WithError(err).Fatal("Error applying database migrations, refusing to serve")
+	default:
 	}
 
 	a.logger.Info("Gracefully shut down server")
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"html"
@@ -139,12 +140,12 @@ func cmdSetup(subcommand string, flags []string) {
 		a.logger.WithError(dbErr).Fatal("Error establishing database connection")
 	}
 
-	db, dbErr := persistence.New(relational.NewRelationalDAL(gormDB))
+	db, dbErr := persistence.New(relational.NewRelationalDAL(gormDB, relational.WithEventPartitions(a.config.Database.PartitionEvents)))
 	if dbErr != nil {
 		a.logger.WithError(dbErr).Fatal("Error creating persistence layer")
 	}
 
-	if err := db.Migrate(); err != nil {
+	if err := db.Migrate(context.Background()); err != nil {
 		a.logger.WithError(err).Fatal("Error applying database migrations")
 	}
 
@@ -7,6 +7,9 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	// account timezones need to be resolvable in images that do not
+	// ship a timezone database
+	_ "time/tzdata"
 )
 
 var mainUsage = `
@@ -6,9 +6,11 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -174,6 +176,28 @@ func New(populateMissing bool, override string) (*Config, error) {
 		return result, err
 	}
 
+	for _, origin := range c.Server.CORSOrigins {
+		if strings.TrimSpace(origin) == "*" {
+			return &c, errors.New("config: wildcard origins cannot be used in OFFEN_SERVER_CORSORIGINS as cross origin requests are credentialed")
+		}
+	}
+
+	if c.Server.CookieSameSite.SameSite() == http.SameSiteNoneMode && c.App.Development {
+		return &c, errors.New("config: OFFEN_SERVER_COOKIESAMESITE cannot be none in development mode as browsers reject insecure cookies using SameSite=None")
+	}
+
+	if c.Server.HostCookiePrefix && (c.App.Development || c.Server.CookieDomain != "") {
+		return &c, errors.New("config: OFFEN_SERVER_HOSTCOOKIEPREFIX requires secure cookies without a domain, so it cannot be used in development mode or together with OFFEN_SERVER_COOKIEDOMAIN")
+	}
+
+	if c.Server.AuthCookieMaxAge <= 0 {
+		return &c, errors.New("config: OFFEN_SERVER_AUTHCOOKIEMAXAGE must be a positive duration")
+	}
+
+	if c.App.ExpireInterval <= 0 {
+		return &c, errors.New("config: OFFEN_APP_EXPIREINTERVAL must be a positive duration")
+	}
+
 	if c.Secret.IsZero() {
 		cookieSecret, cookieSecretErr := keys.GenerateRandomBytes(keys.DefaultSecretLength)
 		if cookieSecretErr != nil {
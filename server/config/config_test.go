@@ -6,6 +6,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestNew(t *testing.T) {
@@ -31,3 +32,89 @@ func TestNew(t *testing.T) {
 		t.Error("Expected app secret to be populated")
 	}
 }
+
+func TestNew_CORSWildcard(t *testing.T) {
+	defer os.Setenv("OFFEN_SERVER_CORSORIGINS", os.Getenv("OFFEN_SERVER_CORSORIGINS"))
+	os.Setenv("OFFEN_SERVER_CORSORIGINS", "https://www.offen.dev,*")
+
+	if _, err := New(false, "./testdata/offen.env"); err == nil {
+		t.Error("Expected error when using wildcard cors origin, got nil")
+	}
+}
+
+func TestNew_CookieSameSite(t *testing.T) {
+	// other tests might leave an empty deploy target behind, which is
+	// invalid, so it's unset for the duration of the test
+	t.Setenv("OFFEN_APP_DEPLOYTARGET", "")
+	os.Unsetenv("OFFEN_APP_DEPLOYTARGET")
+	t.Setenv("OFFEN_SERVER_COOKIESAMESITE", "none")
+
+	t.Setenv("OFFEN_APP_DEVELOPMENT", "false")
+	if _, err := New(false, "./testdata/offen.env"); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	t.Setenv("OFFEN_APP_DEVELOPMENT", "true")
+	if _, err := New(false, "./testdata/offen.env"); err == nil {
+		t.Error("Expected error when using SameSite=None in development mode, got nil")
+	}
+}
+
+func TestNew_HostCookiePrefix(t *testing.T) {
+	t.Setenv("OFFEN_APP_DEPLOYTARGET", "")
+	os.Unsetenv("OFFEN_APP_DEPLOYTARGET")
+	t.Setenv("OFFEN_SERVER_HOSTCOOKIEPREFIX", "true")
+
+	t.Setenv("OFFEN_APP_DEVELOPMENT", "false")
+	if _, err := New(false, "./testdata/offen.env"); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	t.Setenv("OFFEN_SERVER_COOKIEDOMAIN", "example.com")
+	if _, err := New(false, "./testdata/offen.env"); err == nil {
+		t.Error("Expected error when using a host cookie prefix with a cookie domain, got nil")
+	}
+
+	t.Setenv("OFFEN_SERVER_COOKIEDOMAIN", "")
+	t.Setenv("OFFEN_APP_DEVELOPMENT", "true")
+	if _, err := New(false, "./testdata/offen.env"); err == nil {
+		t.Error("Expected error when using a host cookie prefix in development mode, got nil")
+	}
+}
+
+func TestNew_AuthCookieMaxAge(t *testing.T) {
+	t.Setenv("OFFEN_APP_DEPLOYTARGET", "")
+	os.Unsetenv("OFFEN_APP_DEPLOYTARGET")
+
+	c, err := New(false, "./testdata/offen.env")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if c.Server.AuthCookieMaxAge != 24*time.Hour {
+		t.Errorf("Unexpected default max age %v", c.Server.AuthCookieMaxAge)
+	}
+
+	t.Setenv("OFFEN_SERVER_AUTHCOOKIEMAXAGE", "-1h")
+	if _, err := New(false, "./testdata/offen.env"); err == nil {
+		t.Error("Expected error when using negative max age, got nil")
+	}
+}
+
+func TestNew_ExpireInterval(t *testing.T) {
+	t.Setenv("OFFEN_APP_DEPLOYTARGET", "")
+	os.Unsetenv("OFFEN_APP_DEPLOYTARGET")
+
+	t.Setenv("OFFEN_APP_EXPIREINTERVAL", "15m")
+	c, err := New(false, "./testdata/offen.env")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if c.App.ExpireInterval != 15*time.Minute {
+		t.Errorf("Unexpected interval %v", c.App.ExpireInterval)
+	}
+
+	t.Setenv("OFFEN_APP_EXPIREINTERVAL", "0s")
+	if _, err := New(false, "./testdata/offen.env"); err == nil {
+		t.Error("Expected error when using zero expire interval, got nil")
+	}
+}
@@ -6,36 +6,82 @@
 
 package config
 
+import "time"
+
 // Config contains all runtime configuration needed for running offen as
 // and also defines the desired defaults. Package envconfig is used to
 // source values from the application environment at runtime.
 type Config struct {
 	Server struct {
-		Port             int  `default:"3000"`
-		ReverseProxy     bool `default:"false"`
-		SSLCertificate   EnvString
-		SSLKey           EnvString
-		AutoTLS          []string
-		LetsEncryptEmail string
-		CertificateCache EnvString `default:"/var/www/.cache"`
+		Port                int  `default:"3000"`
+		ReverseProxy        bool `default:"false"`
+		SSLCertificate      EnvString
+		SSLKey              EnvString
+		SSLReload           bool `default:"false"`
+		AutoTLS             []string
+		LetsEncryptEmail    string
+		CertificateCache    EnvString `default:"/var/www/.cache"`
+		HealthCheckToken    EnvString
+		IngestTimeout       time.Duration `default:"0"`
+		ReadTimeout         time.Duration `default:"0"`
+		AdminTimeout        time.Duration `default:"0"`
+		ExportTimeout       time.Duration `default:"0"`
+		CORSOrigins         []string
+		FrameAncestors      []string
+		CSP                 string
+		HealthCacheTTL      time.Duration `default:"0"`
+		CookieSameSite      SameSite
+		CookieDomain        string
+		HostCookiePrefix    bool          `default:"false"`
+		AuthCookieMaxAge    time.Duration `default:"24h"`
+		AuthRateLimit       float64       `default:"0"`
+		AuthRateBurst       int           `default:"5"`
+		ShutdownGracePeriod time.Duration `default:"5s"`
+		Metrics             bool          `default:"false"`
 	}
 	Database struct {
 		Dialect           Dialect   `default:"sqlite3"`
 		ConnectionString  EnvString `default:"/var/opt/offen/offen.db"`
 		ConnectionRetries int       `default:"0"`
+		PartitionEvents   bool      `default:"false"`
+		MigrateOnStartup  bool      `default:"false"`
 	}
 	App struct {
-		Development  bool     `default:"false"`
-		LogLevel     LogLevel `default:"info"`
-		SingleNode   bool     `default:"true"`
-		Locale       Locale   `default:"en"`
-		RootAccount  string
-		DemoAccount  string `ignored:"true"`
-		DeployTarget DeployTarget
-		Retention    Retention `default:"6months"`
+		Development            bool      `default:"false"`
+		LogLevel               LogLevel  `default:"info"`
+		LogFormat              LogFormat `default:"text"`
+		SingleNode             bool      `default:"true"`
+		Locale                 Locale    `default:"en"`
+		RootAccount            string
+		DemoAccount            string `ignored:"true"`
+		DeployTarget           DeployTarget
+		Retention              Retention     `default:"6months"`
+		ExpireThreshold        int           `default:"0"`
+		ExpireBatchSize        int           `default:"0"`
+		ExpireInterval         time.Duration `default:"1h"`
+		SecureDelete           bool          `default:"false"`
+		MaxUsersPerAccount     int           `default:"0"`
+		SerializeUserSecrets   bool          `default:"false"`
+		ConsentAudit           bool          `default:"false"`
+		IngestSuspendThreshold int           `default:"0"`
+		IngestSuspendCooldown  time.Duration `default:"1h"`
+		IngestByteRateLimit    int           `default:"0"`
+		SequenceEvents         bool          `default:"false"`
+		EventsResponseMaxBytes int           `default:"0"`
+		NewUserRateLimit       int           `default:"0"`
+		NewUserRateWindow      time.Duration `default:"1h"`
+		AllowBearerAuth        bool          `default:"false"`
+		LoginLockoutThreshold  int           `default:"0"`
+		LoginLockoutWindow     time.Duration `default:"15m"`
+		LoginLockoutCooldown   time.Duration `default:"15m"`
+		InviteExpiry           time.Duration `default:"168h"`
+		ResetTokenClockSkew    time.Duration `default:"0"`
+		EventReservoirSize     int           `default:"0"`
+		FallbackAccount        string
 	}
-	Secret Bytes
-	SMTP   struct {
+	Secret            Bytes
+	SecretEnvelopeKey Bytes
+	SMTP              struct {
 		User     string
 		Password string
 		Host     string
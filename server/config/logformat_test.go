@@ -0,0 +1,37 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLogFormat(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		var l LogFormat
+		if err := l.Decode("json"); err != nil {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if _, ok := l.Formatter().(*logrus.JSONFormatter); !ok {
+			t.Errorf("Unexpected formatter %v", l.Formatter())
+		}
+	})
+	t.Run("text", func(t *testing.T) {
+		var l LogFormat
+		if err := l.Decode("text"); err != nil {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if _, ok := l.Formatter().(*logrus.TextFormatter); !ok {
+			t.Errorf("Unexpected formatter %v", l.Formatter())
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		var l LogFormat
+		if err := l.Decode("xml"); err == nil {
+			t.Error("Unexpected nil error")
+		}
+	})
+}
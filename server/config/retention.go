@@ -8,6 +8,17 @@ import (
 	"time"
 )
 
+// knownRetentions are the retention periods that are supported. Clients
+// rely on receiving one of these values, so arbitrary durations cannot be
+// used.
+var knownRetentions = map[string]time.Duration{
+	"6months": time.Hour * 24 * 6 * 31,
+	"12weeks": time.Hour * 24 * 7 * 12,
+	"6weeks":  time.Hour * 24 * 7 * 6,
+	"30days":  time.Hour * 24 * 30,
+	"7days":   time.Hour * 24 * 7,
+}
+
 // Retention defines a data retention period.
 type Retention struct {
 	configured string
@@ -16,38 +27,32 @@ type Retention struct {
 
 // Decode validates and assigns v.
 func (r *Retention) Decode(v string) error {
-	switch v {
-	case "6months":
-		*r = Retention{
-			configured: v,
-			retention:  time.Hour * 24 * 6 * 31,
-		}
-	case "12weeks":
-		*r = Retention{
-			configured: v,
-			retention:  time.Hour * 24 * 7 * 12,
-		}
-	case "6weeks":
-		*r = Retention{
-			configured: v,
-			retention:  time.Hour * 24 * 7 * 6,
-		}
-	case "30days":
-		*r = Retention{
-			configured: v,
-			retention:  time.Hour * 24 * 30,
-		}
-	case "7days":
-		*r = Retention{
-			configured: v,
-			retention:  time.Hour * 24 * 7,
-		}
-	default:
+	retention, ok := knownRetentions[v]
+	if !ok {
 		return fmt.Errorf("unknown or unsupported retention period %s", v)
 	}
+	*r = Retention{
+		configured: v,
+		retention:  retention,
+	}
 	return nil
 }
 
 func (r *Retention) String() string {
 	return r.configured
 }
+
+// Duration returns the retention period as a duration.
+func (r *Retention) Duration() time.Duration {
+	return r.retention
+}
+
+// RetentionFor returns the supported retention period of the given duration.
+func RetentionFor(d time.Duration) (Retention, bool) {
+	for configured, retention := range knownRetentions {
+		if retention == d {
+			return Retention{configured: configured, retention: retention}, true
+		}
+	}
+	return Retention{}, false
+}
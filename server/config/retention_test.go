@@ -0,0 +1,41 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetention(t *testing.T) {
+	tests := []struct {
+		name             string
+		value            string
+		expectError      bool
+		expectedDuration time.Duration
+	}{
+		{"6 months", "6months", false, time.Hour * 24 * 6 * 31},
+		{"30 days", "30days", false, time.Hour * 24 * 30},
+		{"bad value", "14days", true, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var r Retention
+			err := r.Decode(test.value)
+			if (err != nil) != test.expectError {
+				t.Errorf("Unexpected error value %v", err)
+			}
+			if r.Duration() != test.expectedDuration {
+				t.Errorf("Expected %v, got %v", test.expectedDuration, r.Duration())
+			}
+			if test.expectError {
+				return
+			}
+			found, ok := RetentionFor(test.expectedDuration)
+			if !ok || found.String() != test.value {
+				t.Errorf("Expected to find %s for duration, got %v", test.value, found.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// SameSite is a wrapped SameSite mode for cookies. The zero value means no
+// mode has been configured.
+type SameSite http.SameSite
+
+// Decode validates and assigns v.
+func (s *SameSite) Decode(v string) error {
+	switch strings.ToLower(v) {
+	case "":
+		*s = SameSite(0)
+	case "lax":
+		*s = SameSite(http.SameSiteLaxMode)
+	case "strict":
+		*s = SameSite(http.SameSiteStrictMode)
+	case "none":
+		*s = SameSite(http.SameSiteNoneMode)
+	default:
+		return fmt.Errorf("unknown SameSite mode %s", v)
+	}
+	return nil
+}
+
+// SameSite unwraps s.
+func (s *SameSite) SameSite() http.SameSite {
+	return http.SameSite(*s)
+}
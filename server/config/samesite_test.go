@@ -0,0 +1,36 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSameSite(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		expectError   bool
+		expectedValue http.SameSite
+	}{
+		{"empty", "", false, 0},
+		{"lax", "lax", false, http.SameSiteLaxMode},
+		{"strict", "Strict", false, http.SameSiteStrictMode},
+		{"none", "none", false, http.SameSiteNoneMode},
+		{"bad value", "sometimes", true, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var s SameSite
+			err := s.Decode(test.value)
+			if (err != nil) != test.expectError {
+				t.Errorf("Unexpected error value %v", err)
+			}
+			if s.SameSite() != test.expectedValue {
+				t.Errorf("Expected %v, got %v", test.expectedValue, s.SameSite())
+			}
+		})
+	}
+}
@@ -50,6 +50,19 @@ func DeriveKey(value, versionedSalt string) ([]byte, error) {
 	}
 }
 
+// DeriveSigningKey derives a key of the given size that can be used for
+// signing values from the given passphrase and salt. The result is
+// deterministic, so the same passphrase and salt always yield the same key.
+func DeriveSigningKey(passphrase, salt []byte, size uint32) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("keys: cannot derive signing key from an empty passphrase")
+	}
+	if len(salt) == 0 {
+		return nil, errors.New("keys: cannot derive signing key without salt")
+	}
+	return defaultArgon2Hash(passphrase, salt, size), nil
+}
+
 // NewSalt creates a new salt value of the default length and wraps it in a
 // versioned cipher using the latest available algo version
 func NewSalt(len int) (*VersionedCipher, error) {
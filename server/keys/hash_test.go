@@ -17,3 +17,27 @@ func TestHashString(t *testing.T) {
 		t.Errorf("Comparison unexpectedly passed for wrong password")
 	}
 }
+
+func TestDeriveSigningKey(t *testing.T) {
+	a, err := DeriveSigningKey([]byte("passphrase"), []byte("salt"), 64)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	b, _ := DeriveSigningKey([]byte("passphrase"), []byte("salt"), 64)
+	if string(a) != string(b) {
+		t.Error("Expected derivation to be deterministic")
+	}
+	if len(a) != 64 {
+		t.Errorf("Unexpected key length %d", len(a))
+	}
+	c, _ := DeriveSigningKey([]byte("passphrase"), []byte("other salt"), 64)
+	if string(a) == string(c) {
+		t.Error("Expected different salts to yield different keys")
+	}
+	if _, err := DeriveSigningKey(nil, []byte("salt"), 64); err == nil {
+		t.Error("Expected error for empty passphrase")
+	}
+	if _, err := DeriveSigningKey([]byte("passphrase"), nil, 64); err == nil {
+		t.Error("Expected error for empty salt")
+	}
+}
@@ -7,3 +7,9 @@ package mailer
 type Mailer interface {
 	Send(from, to, subject, body string) error
 }
+
+// HealthChecker is implemented by mailers that can check whether they are
+// able to send email without sending any.
+type HealthChecker interface {
+	CheckHealth() error
+}
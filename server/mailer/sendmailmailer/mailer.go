@@ -35,6 +35,14 @@ func (s *sendmailMailer) Send(from, to, subject, body string) error {
 	return nil
 }
 
+// CheckHealth checks whether a sendmail binary can be found.
+func (s *sendmailMailer) CheckHealth() error {
+	if _, err := lookupSendmail(); err != nil {
+		return fmt.Errorf("sendmailmailer: error looking up sendmail: %w", err)
+	}
+	return nil
+}
+
 func submitMail(m *gomail.Message) error {
 	// see: https://stackoverflow.com/a/35521846/797194
 	bin, err := lookupSendmail()
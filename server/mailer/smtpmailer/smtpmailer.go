@@ -4,6 +4,8 @@
 package smtpmailer
 
 import (
+	"fmt"
+
 	"github.com/go-gomail/gomail"
 	"github.com/offen/offen/server/mailer"
 )
@@ -26,3 +28,13 @@ func (s *smtpMailer) Send(from, to, subject, body string) error {
 	m.SetBody("text/plain", body)
 	return s.DialAndSend(m)
 }
+
+// CheckHealth connects to and authenticates with the SMTP server without
+// sending any email.
+func (s *smtpMailer) CheckHealth() error {
+	conn, err := s.Dial()
+	if err != nil {
+		return fmt.Errorf("smtpmailer: error connecting to server: %w", err)
+	}
+	return conn.Close()
+}
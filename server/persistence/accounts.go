@@ -30,6 +30,7 @@ func (p *persistenceLayer) GetAccount(accountID string, includeStyles, includeEv
 		AccountID: account.AccountID,
 		Name:      account.Name,
 		Created:   account.Created,
+		Retention: account.Retention,
 	}
 
 	if includeStyles {
@@ -54,12 +55,17 @@ func (p *persistenceLayer) GetAccount(accountID string, includeStyles, includeEv
 
 	for _, evt := range account.Events {
 		eventResults[evt.AccountID] = append(eventResults[evt.AccountID], EventResult{
-			SecretID: evt.SecretID,
-			EventID:  evt.EventID,
-			Payload:  evt.Payload,
+			SecretID:    evt.SecretID,
+			EventID:     evt.EventID,
+			Payload:     evt.Payload,
+			ContentHash: evt.ContentHash,
 		})
 		if evt.SecretID != nil {
-			secrets[*evt.SecretID] = evt.Secret.EncryptedSecret
+			secret, err := p.openSecret(evt.Secret.EncryptedSecret)
+			if err != nil {
+				return AccountResult{}, fmt.Errorf("persistence: error reading secret %s: %w", *evt.SecretID, err)
+			}
+			secrets[*evt.SecretID] = secret
 		}
 		seqs = append(seqs, evt.Sequence)
 	}
@@ -94,6 +100,14 @@ func (p *persistenceLayer) GetAccount(accountID string, includeStyles, includeEv
 }
 
 func (p *persistenceLayer) AssociateUserSecret(accountID, userID, encryptedUserSecret string) error {
+	if p.accountLocks != nil {
+		unlock := p.accountLocks.lock(accountID)
+		defer unlock()
+	}
+	return p.associateUserSecret(accountID, userID, encryptedUserSecret, true)
+}
+
+func (p *persistenceLayer) associateUserSecret(accountID, userID, encryptedUserSecret string, retry bool) error {
 	account, err := p.dal.FindAccount(FindAccountQueryActiveByID(accountID))
 	if err != nil {
 		return fmt.Errorf(`persistence: error looking up account with id "%s": %w`, accountID, err)
@@ -104,12 +118,30 @@ func (p *persistenceLayer) AssociateUserSecret(accountID, userID, encryptedUserS
 		return fmt.Errorf("persistence: erro hashing user id: %w", err)
 	}
 
+	// Sealing happens before any existing data is touched so a failing
+	// envelope does not leave the user with parked events only.
+	sealedSecret, err := p.sealSecret(encryptedUserSecret)
+	if err != nil {
+		return err
+	}
+
 	secret, err := p.dal.FindSecret(FindSecretQueryBySecretID(hashedUserID))
 	if err != nil {
 		var notFound ErrUnknownSecret
 		if !errors.As(err, &notFound) {
 			return fmt.Errorf("persistence: error looking up user: %v", err)
 		}
+		// Only users that are not yet known to the account are subject to the
+		// limit, existing users are still able to replace their secret.
+		if p.maxUsersPerAccount > 0 {
+			count, countErr := p.dal.CountSecrets(CountSecretsQueryByAccountID(accountID))
+			if countErr != nil {
+				return fmt.Errorf("persistence: error counting users for account %s: %w", accountID, countErr)
+			}
+			if count >= int64(p.maxUsersPerAccount) {
+				return fmt.Errorf("%w: account %s has %d users", ErrMaxUsersExceeded, accountID, count)
+			}
+		}
 	} else {
 		// In this branch the following case is covered: a user whose hashed
 		// identifier is known, has sent a new user secret to be saved. This means
@@ -135,7 +167,9 @@ func (p *persistenceLayer) AssociateUserSecret(accountID, userID, encryptedUserS
 		}
 		if err := txn.CreateSecret(&Secret{
 			SecretID:        parkedHash,
+			AccountID:       accountID,
 			EncryptedSecret: secret.EncryptedSecret,
+			Parked:          true,
 		}); err != nil {
 			txn.Rollback()
 			return fmt.Errorf("persistence: error creating user for use as migration target: %w", err)
@@ -199,71 +233,112 @@ func (p *persistenceLayer) AssociateUserSecret(accountID, userID, encryptedUserS
 
 	if err := p.dal.CreateSecret(&Secret{
 		SecretID:        hashedUserID,
-		EncryptedSecret: encryptedUserSecret,
+		AccountID:       accountID,
+		EncryptedSecret: sealedSecret,
 	}); err != nil {
+		// A concurrent request for the same user might have created the
+		// secret in the meantime. Association is retried once in this case,
+		// parking the other secret so the latest write wins.
+		if retry {
+			if _, findErr := p.dal.FindSecret(FindSecretQueryBySecretID(hashedUserID)); findErr == nil {
+				return p.associateUserSecret(accountID, userID, encryptedUserSecret, false)
+			}
+		}
 		return fmt.Errorf("persistence: error creating user: %w", err)
 	}
+	if p.consentAudit {
+		if err := recordConsent(p.dal, accountID, hashedUserID, ConsentActionOptIn); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (p *persistenceLayer) CreateAccount(name, emailAddress, password string) error {
+// CreateAccount creates an account of the given name that the account user
+// with the given credentials can access. In case another account of the same
+// name exists, ErrDuplicateAccountName is returned.
+func (p *persistenceLayer) CreateAccount(name, emailAddress, password string) (AccountResult, error) {
+	account, relationship, err := p.prepareAccount(name, emailAddress, password)
+	if err != nil {
+		return AccountResult{}, err
+	}
+
+	txn, err := p.dal.Transaction()
+	if err != nil {
+		return AccountResult{}, fmt.Errorf("persistence: error creating transaction: %w", err)
+	}
+	if err := txn.CreateAccount(account); err != nil {
+		txn.Rollback()
+		return AccountResult{}, fmt.Errorf("persistence: error persisting account: %w", err)
+	}
+	if err := txn.CreateAccountUserRelationship(relationship); err != nil {
+		txn.Rollback()
+		return AccountResult{}, fmt.Errorf("persistence: error persisting relationship: %w", err)
+	}
+	if err := txn.Commit(); err != nil {
+		return AccountResult{}, fmt.Errorf("persistence: error committing transaction: %w", err)
+	}
+
+	key, err := account.WrapPublicKey()
+	if err != nil {
+		return AccountResult{}, fmt.Errorf("persistence: error wrapping account public key: %w", err)
+	}
+	return AccountResult{
+		AccountID: account.AccountID,
+		Name:      account.Name,
+		PublicKey: key,
+		Created:   account.Created,
+	}, nil
+}
+
+// prepareAccount creates a new account with the given name and a relationship
+// that grants access to the account user with the given credentials. Callers
+// are responsible for persisting both values.
+func (p *persistenceLayer) prepareAccount(name, emailAddress, password string) (*Account, *AccountUserRelationship, error) {
 	accountUsers, err := p.dal.FindAccountUsers(FindAccountUsersQueryAllAccountUsers{true, false})
 	if err != nil {
-		return fmt.Errorf("persistence: error looking up account users: %w", err)
+		return nil, nil, fmt.Errorf("persistence: error looking up account users: %w", err)
 	}
 	match, err := selectAccountUser(accountUsers, emailAddress)
 	if err != nil {
-		return fmt.Errorf("persistence: error looking up account user %s: %w", emailAddress, err)
+		return nil, nil, fmt.Errorf("persistence: error looking up account user %s: %w", emailAddress, err)
 	}
 
 	if err := keys.CompareString(password, match.HashedPassword); err != nil {
-		return fmt.Errorf("persistence: passwords did not match: %w", err)
+		return nil, nil, fmt.Errorf("persistence: passwords did not match: %w", err)
 	}
 
 	allAccounts, allAccountsErr := p.dal.FindAccounts(FindAccountsQueryAllAccounts{})
 	if allAccountsErr != nil {
-		return fmt.Errorf("persistence: error looking up all existing accounts: %w", err)
+		return nil, nil, fmt.Errorf("persistence: error looking up all existing accounts: %w", allAccountsErr)
 	}
 	for _, account := range allAccounts {
 		if account.Name == name {
-			return fmt.Errorf("persistence: account named %s already exists", name)
+			return nil, nil, fmt.Errorf("persistence: error creating account %s: %w", name, ErrDuplicateAccountName)
 		}
 	}
 
 	account, key, err := newAccount(name, "")
 	if err != nil {
-		return fmt.Errorf("persistence: error creating account: %w", err)
+		return nil, nil, fmt.Errorf("persistence: error creating account: %w", err)
 	}
 	relationship, err := newAccountUserRelationship(match.AccountUserID, account.AccountID)
 	if err != nil {
-		return fmt.Errorf("persistence: error creating relationship: %w", err)
+		return nil, nil, fmt.Errorf("persistence: error creating relationship: %w", err)
 	}
 	if err := relationship.addEmailEncryptedKey(key, match.Salt, emailAddress); err != nil {
-		return fmt.Errorf("persistence: error adding email encrypted key: %w", err)
+		return nil, nil, fmt.Errorf("persistence: error adding email encrypted key: %w", err)
 	}
 	if err := relationship.addPasswordEncryptedKey(key, match.Salt, password); err != nil {
-		return fmt.Errorf("persistence: error adding password encrypted key: %w", err)
-	}
-
-	txn, err := p.dal.Transaction()
-	if err != nil {
-		return fmt.Errorf("persistence: error creating transaction: %w", err)
-	}
-	if err := txn.CreateAccount(account); err != nil {
-		txn.Rollback()
-		return fmt.Errorf("persistence: error persisting account: %w", err)
-	}
-	if err := txn.CreateAccountUserRelationship(relationship); err != nil {
-		txn.Rollback()
-		return fmt.Errorf("persistence: error persisting relationship: %w", err)
-	}
-	if err := txn.Commit(); err != nil {
-		return fmt.Errorf("persistence: error committing transaction: %w", err)
+		return nil, nil, fmt.Errorf("persistence: error adding password encrypted key: %w", err)
 	}
-
-	return nil
+	return account, relationship, nil
 }
 
+// RetireAccount retires the account of the given id, revoking access for all
+// account users. All events and user secrets of the account are deleted in
+// the same transaction. The account itself is not deleted but kept as
+// retired, which makes it unknown to all lookups of active accounts.
 func (p *persistenceLayer) RetireAccount(accountID string) error {
 	account, lookupErr := p.dal.FindAccount(FindAccountQueryByID(accountID))
 	if lookupErr != nil {
@@ -272,6 +347,9 @@ func (p *persistenceLayer) RetireAccount(accountID string) error {
 	if account.Retired {
 		return ErrUnknownAccount(fmt.Sprintf("persistence: account %s already retired", accountID))
 	}
+	if account.LegalHold.Enabled {
+		return fmt.Errorf("persistence: refusing to retire account %s: %w", accountID, ErrLegalHold)
+	}
 	txn, txnErr := p.dal.Transaction()
 	if txnErr != nil {
 		return fmt.Errorf("persistence: error creating transaction: %w", txnErr)
@@ -285,6 +363,14 @@ func (p *persistenceLayer) RetireAccount(accountID string) error {
 		txn.Rollback()
 		return fmt.Errorf("persistence: error deleting account user relationships for retired account %s: %w", accountID, err)
 	}
+	if _, err := txn.DeleteEvents(DeleteEventsQueryByAccountID(accountID)); err != nil {
+		txn.Rollback()
+		return fmt.Errorf("persistence: error deleting events for retired account %s: %w", accountID, err)
+	}
+	if err := txn.DeleteSecret(DeleteSecretQueryByAccountID(accountID)); err != nil {
+		txn.Rollback()
+		return fmt.Errorf("persistence: error deleting user secrets for retired account %s: %w", accountID, err)
+	}
 	if err := txn.Commit(); err != nil {
 		txn.Rollback()
 		return fmt.Errorf("persistence: error committing account retiring: %w", err)
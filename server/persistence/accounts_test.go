@@ -7,9 +7,12 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/offen/offen/server/keys"
 )
 
 var publicKey = `
@@ -229,10 +232,260 @@ func TestPersistenceLayer_AssociateUserSecret(t *testing.T) {
 	}
 }
 
+type mockMaxUsersDatabase struct {
+	DataAccessLayer
+	account Account
+	secrets map[string]Secret
+}
+
+func (m *mockMaxUsersDatabase) FindAccount(interface{}) (Account, error) {
+	return m.account, nil
+}
+
+func (m *mockMaxUsersDatabase) FindSecret(q interface{}) (Secret, error) {
+	if secret, ok := m.secrets[string(q.(FindSecretQueryBySecretID))]; ok {
+		return secret, nil
+	}
+	return Secret{}, ErrUnknownSecret("not found")
+}
+
+func (m *mockMaxUsersDatabase) CountSecrets(q interface{}) (int64, error) {
+	var count int64
+	for _, secret := range m.secrets {
+		if secret.AccountID == string(q.(CountSecretsQueryByAccountID)) && !secret.Parked {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *mockMaxUsersDatabase) CreateSecret(s *Secret) error {
+	m.secrets[s.SecretID] = *s
+	return nil
+}
+
+func (m *mockMaxUsersDatabase) DeleteSecret(q interface{}) error {
+	delete(m.secrets, string(q.(DeleteSecretQueryBySecretID)))
+	return nil
+}
+
+func (m *mockMaxUsersDatabase) FindEvents(interface{}) ([]Event, error) {
+	return nil, nil
+}
+
+func (m *mockMaxUsersDatabase) DeleteEvents(interface{}) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockMaxUsersDatabase) Transaction() (Transaction, error) {
+	return m, nil
+}
+
+func (m *mockMaxUsersDatabase) Commit() error {
+	return nil
+}
+
+func (m *mockMaxUsersDatabase) Rollback() error {
+	return nil
+}
+
+func TestPersistenceLayer_AssociateUserSecret_MaxUsers(t *testing.T) {
+	salt, err := keys.NewFastSalt(keys.DefaultSecretLength)
+	if err != nil {
+		t.Fatalf("Unexpected error creating salt: %v", err)
+	}
+	dal := &mockMaxUsersDatabase{
+		account: Account{AccountID: "account-id", UserSalt: salt.Marshal()},
+		secrets: map[string]Secret{},
+	}
+	p := &persistenceLayer{dal: dal, maxUsersPerAccount: 2}
+
+	for _, userID := range []string{"user-a", "user-b"} {
+		if err := p.AssociateUserSecret("account-id", userID, "secret"); err != nil {
+			t.Errorf("Unexpected error associating %s: %v", userID, err)
+		}
+	}
+
+	if err := p.AssociateUserSecret("account-id", "user-c", "secret"); !errors.Is(err, ErrMaxUsersExceeded) {
+		t.Errorf("Expected ErrMaxUsersExceeded, got %v", err)
+	}
+
+	if err := p.AssociateUserSecret("account-id", "user-a", "other-secret"); err != nil {
+		t.Errorf("Unexpected error replacing secret of existing user: %v", err)
+	}
+	t.Run("parked secrets", func(t *testing.T) {
+		dal := &mockMaxUsersDatabase{
+			account: Account{AccountID: "account-id", UserSalt: salt.Marshal()},
+			secrets: map[string]Secret{},
+		}
+		p := &persistenceLayer{dal: dal, maxUsersPerAccount: 2}
+		for _, userID := range []string{"user-a", "user-a", "user-a", "user-b"} {
+			if err := p.AssociateUserSecret("account-id", userID, "secret"); err != nil {
+				t.Errorf("Unexpected error associating %s: %v", userID, err)
+			}
+		}
+		if len(dal.secrets) != 4 {
+			t.Errorf("Expected two users and two parked secrets, got %d secrets", len(dal.secrets))
+		}
+	})
+}
+
+type mockConcurrentSecretsDatabase struct {
+	mockMaxUsersDatabase
+	mu sync.Mutex
+}
+
+func (m *mockConcurrentSecretsDatabase) FindSecret(q interface{}) (Secret, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mockMaxUsersDatabase.FindSecret(q)
+}
+
+func (m *mockConcurrentSecretsDatabase) CountSecrets(q interface{}) (int64, error) {
+	m.mu.Lock()
+	count, err := m.mockMaxUsersDatabase.CountSecrets(q)
+	m.mu.Unlock()
+	// give concurrent callers the chance to interleave between counting
+	// and creating secrets
+	time.Sleep(time.Millisecond)
+	return count, err
+}
+
+func (m *mockConcurrentSecretsDatabase) CreateSecret(s *Secret) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mockMaxUsersDatabase.CreateSecret(s)
+}
+
+func TestPersistenceLayer_AssociateUserSecret_Concurrent(t *testing.T) {
+	salt, err := keys.NewFastSalt(keys.DefaultSecretLength)
+	if err != nil {
+		t.Fatalf("Unexpected error creating salt: %v", err)
+	}
+	tests := []struct {
+		name          string
+		maxUsers      int
+		expectedUsers int
+	}{
+		{"no limit", 0, 50},
+		{"limit", 10, 10},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dal := &mockConcurrentSecretsDatabase{
+				mockMaxUsersDatabase: mockMaxUsersDatabase{
+					account: Account{AccountID: "account-id", UserSalt: salt.Marshal()},
+					secrets: map[string]Secret{},
+				},
+			}
+			p := &persistenceLayer{dal: dal, maxUsersPerAccount: test.maxUsers}
+			WithSerializedUserSecrets(true)(p)
+
+			var wg sync.WaitGroup
+			errs := make(chan error, 50)
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					errs <- p.AssociateUserSecret("account-id", fmt.Sprintf("user-%d", i), "secret")
+				}(i)
+			}
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				if err != nil && !errors.Is(err, ErrMaxUsersExceeded) {
+					t.Errorf("Unexpected error %v", err)
+				}
+			}
+			if len(dal.secrets) != test.expectedUsers {
+				t.Errorf("Expected %d users, got %d", test.expectedUsers, len(dal.secrets))
+			}
+		})
+	}
+}
+
+type mockConflictingSecretsDatabase struct {
+	mockMaxUsersDatabase
+	conflicts []Secret
+}
+
+func (m *mockConflictingSecretsDatabase) CreateSecret(s *Secret) error {
+	if _, ok := m.secrets[s.SecretID]; ok {
+		return errors.New("duplicate key")
+	}
+	// simulate a concurrent request creating the same secret right before
+	if len(m.conflicts) != 0 && m.conflicts[0].SecretID == s.SecretID {
+		m.secrets[s.SecretID] = m.conflicts[0]
+		m.conflicts = m.conflicts[1:]
+		return errors.New("duplicate key")
+	}
+	return m.mockMaxUsersDatabase.CreateSecret(s)
+}
+
+func (m *mockConflictingSecretsDatabase) Transaction() (Transaction, error) {
+	return m, nil
+}
+
+func TestPersistenceLayer_AssociateUserSecret_Conflict(t *testing.T) {
+	salt, err := keys.NewFastSalt(keys.DefaultSecretLength)
+	if err != nil {
+		t.Fatalf("Unexpected error creating salt: %v", err)
+	}
+	account := Account{AccountID: "account-id", UserSalt: salt.Marshal()}
+	hashedUserID, err := account.HashUserID("user-id")
+	if err != nil {
+		t.Fatalf("Unexpected error hashing user id: %v", err)
+	}
+	conflict := Secret{SecretID: hashedUserID, AccountID: "account-id", EncryptedSecret: "other-secret"}
+
+	tests := []struct {
+		name           string
+		conflicts      []Secret
+		expectError    bool
+		expectedSecret string
+		expectedParked int
+	}{
+		{"no conflict", nil, false, "secret", 0},
+		{"concurrent write", []Secret{conflict}, false, "secret", 1},
+		{"repeated concurrent writes", []Secret{conflict, conflict}, true, "other-secret", 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dal := &mockConflictingSecretsDatabase{
+				mockMaxUsersDatabase: mockMaxUsersDatabase{
+					account: account,
+					secrets: map[string]Secret{},
+				},
+				conflicts: test.conflicts,
+			}
+			p := &persistenceLayer{dal: dal}
+			err := p.AssociateUserSecret("account-id", "user-id", "secret")
+			if (err != nil) != test.expectError {
+				t.Errorf("Unexpected error value %v", err)
+			}
+			if secret := dal.secrets[hashedUserID]; secret.EncryptedSecret != test.expectedSecret {
+				t.Errorf("Expected secret %s, got %s", test.expectedSecret, secret.EncryptedSecret)
+			}
+			var parked int
+			for _, secret := range dal.secrets {
+				if secret.Parked {
+					parked++
+				}
+			}
+			if parked != test.expectedParked {
+				t.Errorf("Expected %d parked secrets, got %d", test.expectedParked, parked)
+			}
+		})
+	}
+}
+
 type mockRetireAccountDatabase struct {
 	DataAccessLayer
 	updateErr         error
 	deleteErr         error
+	deleteEventsErr   error
+	deleteSecretsErr  error
 	txnErr            error
 	findAccountResult Account
 	findAccountErr    error
@@ -245,6 +498,15 @@ func (m *mockRetireAccountDatabase) UpdateAccount(*Account) error {
 func (m *mockRetireAccountDatabase) DeleteAccountUserRelationships(interface{}) error {
 	return m.deleteErr
 }
+
+func (m *mockRetireAccountDatabase) DeleteEvents(interface{}) (int64, error) {
+	return 0, m.deleteEventsErr
+}
+
+func (m *mockRetireAccountDatabase) DeleteSecret(interface{}) error {
+	return m.deleteSecretsErr
+}
+
 func (m *mockRetireAccountDatabase) FindAccount(interface{}) (Account, error) {
 	return m.findAccountResult, m.findAccountErr
 }
@@ -281,6 +543,20 @@ func TestPersistenceLayer_RetireAccount(t *testing.T) {
 			},
 			true,
 		},
+		{
+			"delete events error",
+			&mockRetireAccountDatabase{
+				deleteEventsErr: errors.New("did not work"),
+			},
+			true,
+		},
+		{
+			"delete secrets error",
+			&mockRetireAccountDatabase{
+				deleteSecretsErr: errors.New("did not work"),
+			},
+			true,
+		},
 		{
 			"transaction error",
 			&mockRetireAccountDatabase{
@@ -296,7 +572,7 @@ func TestPersistenceLayer_RetireAccount(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			p := persistenceLayer{test.db}
+			p := persistenceLayer{dal: test.db}
 			err := p.RetireAccount("account-a")
 			if test.expectError != (err != nil) {
 				t.Errorf("Unexpected error value: %v", err)
@@ -18,7 +18,7 @@ func (m *mockProbeDatabase) ProbeEmpty() bool {
 }
 
 func TestProbeEmpty(t *testing.T) {
-	p := persistenceLayer{&mockProbeDatabase{result: true}}
+	p := persistenceLayer{dal: &mockProbeDatabase{result: true}}
 	result := p.ProbeEmpty()
 	if result != true {
 		t.Errorf("Expected true, got %v", result)
@@ -0,0 +1,67 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WithConsentAudit records an entry in an append-only audit log each time a
+// user opts in or opts out, so that operators can prove when consent has
+// been given or withdrawn.
+func WithConsentAudit(enabled bool) Config {
+	return func(p *persistenceLayer) {
+		p.consentAudit = enabled
+	}
+}
+
+func (p *persistenceLayer) ConsentAudit(accountID string) ([]ConsentAuditEntry, error) {
+	if _, err := p.dal.FindAccount(FindAccountQueryActiveByID(accountID)); err != nil {
+		return nil, fmt.Errorf("persistence: error looking up account %s: %w", accountID, err)
+	}
+	entries, err := p.dal.FindConsentAuditEntries(FindConsentAuditEntriesQueryByAccountID(accountID))
+	if err != nil {
+		return nil, fmt.Errorf("persistence: error looking up consent audit entries: %w", err)
+	}
+	return entries, nil
+}
+
+func recordConsent(dal DataAccessLayer, accountID, hashedUserID, action string) error {
+	entryID, err := NewULID()
+	if err != nil {
+		return fmt.Errorf("persistence: error creating consent audit entry id: %w", err)
+	}
+	if err := dal.CreateConsentAuditEntry(&ConsentAuditEntry{
+		EntryID:      entryID,
+		AccountID:    accountID,
+		HashedUserID: hashedUserID,
+		Action:       action,
+		Timestamp:    time.Now().UTC(),
+	}); err != nil {
+		return fmt.Errorf("persistence: error recording consent audit entry: %w", err)
+	}
+	return nil
+}
+
+// recordOptOuts records an opt out for each of the given hashed user ids
+// that is known to an account. Hashes that do not belong to a user of the
+// respective account are skipped.
+func recordOptOuts(dal DataAccessLayer, hashedUserIDs []string) error {
+	for _, hashedUserID := range hashedUserIDs {
+		secret, err := dal.FindSecret(FindSecretQueryBySecretID(hashedUserID))
+		if err != nil {
+			var unknownSecret ErrUnknownSecret
+			if errors.As(err, &unknownSecret) {
+				continue
+			}
+			return fmt.Errorf("persistence: error looking up user for consent audit: %w", err)
+		}
+		if err := recordConsent(dal, secret.AccountID, hashedUserID, ConsentActionOptOut); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+)
+
+// VerifyContentHash checks whether the given hash is the hex encoded SHA-256
+// digest of the given payload.
+func VerifyContentHash(payload, contentHash string) bool {
+	sum := sha256.Sum256([]byte(payload))
+	expected := hex.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(contentHash))) == 1
+}
+
+// CorruptedEvents returns the ids of all events in the given result whose
+// stored content hash does not match their payload. Events without a content
+// hash are skipped.
+func CorruptedEvents(events EventsByAccountID) []string {
+	var corrupted []string
+	for _, accountEvents := range events {
+		for _, evt := range accountEvents {
+			if evt.ContentHash != "" && !VerifyContentHash(evt.Payload, evt.ContentHash) {
+				corrupted = append(corrupted, evt.EventID)
+			}
+		}
+	}
+	return corrupted
+}
@@ -0,0 +1,47 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"reflect"
+	"testing"
+)
+
+const somePayloadHash = "658781cd4ed9bca60dacd09f7bb914bb51502e8b5d619f57f39a1d652596cc24"
+
+func TestVerifyContentHash(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		hash     string
+		expected bool
+	}{
+		{"match", "some-payload", somePayloadHash, true},
+		{"uppercase", "some-payload", "658781CD4ED9BCA60DACD09F7BB914BB51502E8B5D619F57F39A1D652596CC24", true},
+		{"mismatch", "other-payload", somePayloadHash, false},
+		{"garbage", "some-payload", "zzz", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := VerifyContentHash(test.payload, test.hash); result != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestCorruptedEvents(t *testing.T) {
+	result := CorruptedEvents(EventsByAccountID{
+		"account-a": []EventResult{
+			{EventID: "event-a", Payload: "some-payload", ContentHash: somePayloadHash},
+			{EventID: "event-b", Payload: "some-payloae", ContentHash: somePayloadHash},
+		},
+		"account-b": []EventResult{
+			{EventID: "event-c", Payload: "no-hash"},
+		},
+	})
+	if !reflect.DeepEqual([]string{"event-b"}, result) {
+		t.Errorf("Unexpected result %v", result)
+	}
+}
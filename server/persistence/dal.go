@@ -3,6 +3,8 @@
 
 package persistence
 
+import "time"
+
 // DataAccessLayer provides a database agnostic interface for storing data. All
 // query methods expect certain types to be passed. In case a unknown query is
 // passed, an error can be returned early.
@@ -10,9 +12,12 @@ type DataAccessLayer interface {
 	CreateEvent(*Event) error
 	FindEvents(interface{}) ([]Event, error)
 	DeleteEvents(interface{}) (int64, error)
+	UpdateEventPayload(*Event) error
 	CreateSecret(*Secret) error
+	IncrementEventCounter(secretID string) (int64, error)
 	FindSecret(interface{}) (Secret, error)
 	DeleteSecret(interface{}) error
+	CountSecrets(interface{}) (int64, error)
 	CreateAccount(*Account) error
 	UpdateAccount(*Account) error
 	FindAccount(interface{}) (Account, error)
@@ -29,17 +34,36 @@ type DataAccessLayer interface {
 	FindTombstones(interface{}) ([]Tombstone, error)
 	Transaction() (Transaction, error)
 	ApplyMigrations() error
+	LockMigrations(owner string) (bool, error)
+	UnlockMigrations(owner string) error
 	DropAll() error
 	ProbeEmpty() bool
 	Ping() error
+	Close() error
+	MeasureStorage(interface{}) (int64, error)
+	FindEventIDs(interface{}) ([]string, error)
+	CountEvents(interface{}) (map[string]int64, error)
+	CreateInvite(*Invite) error
+	DeleteInvites(interface{}) (int64, error)
+	CreateSession(*Session) error
+	FindSession(interface{}) (Session, error)
+	DeleteSessions(interface{}) (int64, error)
+	CreateConsentAuditEntry(*ConsentAuditEntry) error
+	FindConsentAuditEntries(interface{}) ([]ConsentAuditEntry, error)
 }
 
 // FindEventsQueryForSecretIDs requests all events that match the list of
 // secret identifiers. In case the Since value is non-zero it will be used to request
-// only events that are newer than the given ULID.
+// only events that are newer than the given ULID. In case Limit is non-zero,
+// at most Limit events are returned, ordered by their sequence. In case
+// MaxBytes is non-zero, events are read in order of their sequence until
+// their payloads exceed MaxBytes, so the last event returned is the first
+// one that does not fit anymore.
 type FindEventsQueryForSecretIDs struct {
 	SecretIDs []string
 	Since     string
+	Limit     int
+	MaxBytes  int
 }
 
 // FindEventsQueryByEventIDs requests all events that match the given list of
@@ -47,8 +71,48 @@ type FindEventsQueryForSecretIDs struct {
 type FindEventsQueryByEventIDs []string
 
 // FindEventsQueryOlderThan looks up all events older than the given event id
+// that are not exempt from expiry.
 type FindEventsQueryOlderThan string
 
+// FindEventsQueryExpiredBatch looks up the oldest events older than the given
+// event id that are not exempt from expiry, returning at most Limit events.
+// A Limit of 0 returns all such events. Events belonging to any of the
+// accounts in ExcludeAccountIDs are skipped. In case AccountID is set, only
+// events of this account are returned.
+type FindEventsQueryExpiredBatch struct {
+	EventID           string
+	Limit             int
+	ExcludeAccountIDs []string
+	AccountID         string
+}
+
+// FindEventsQueryByAccountIDPage looks up the events of the given account
+// with an event id greater than After, ordered by event id and returning at
+// most Limit events.
+type FindEventsQueryByAccountIDPage struct {
+	AccountID string
+	After     string
+	Limit     int
+}
+
+// FindEventIDsQueryByAccountID requests the ids of all events stored for
+// the account with the given id.
+type FindEventIDsQueryByAccountID string
+
+// FindEventIDsQueryByIDPrefixes requests the ids of the events stored for
+// the given account whose ids start with any of the given prefixes.
+type FindEventIDsQueryByIDPrefixes struct {
+	AccountID string
+	Prefixes  []string
+}
+
+// CountEventsQueryByIDPrefix requests the number of events stored for the
+// given account, grouped by the first PrefixLength characters of their ids.
+type CountEventsQueryByIDPrefix struct {
+	AccountID    string
+	PrefixLength int
+}
+
 // DeleteEventsQueryBySecretIDs requests deletion of all events that match
 // the given identifiers.
 type DeleteEventsQueryBySecretIDs []string
@@ -58,16 +122,36 @@ type DeleteEventsQueryBySecretIDs []string
 type DeleteEventsQueryByEventIDs []string
 
 // DeleteEventsQueryOlderThan requests deletion of all events older than the
-// given deadline
+// given deadline that are not exempt from expiry.
 type DeleteEventsQueryOlderThan string
 
+// DeleteEventsQueryExpired requests deletion of all events older than the
+// given event id that are not exempt from expiry and do not belong to any of
+// the accounts in ExcludeAccountIDs.
+type DeleteEventsQueryExpired struct {
+	EventID           string
+	ExcludeAccountIDs []string
+}
+
+// DeleteEventsQueryByAccountID requests deletion of all events stored for
+// the account with the given id.
+type DeleteEventsQueryByAccountID string
+
 // DeleteSecretQueryBySecretID requests deletion of the secret record with the given
 // secret id.
 type DeleteSecretQueryBySecretID string
 
+// DeleteSecretQueryByAccountID requests deletion of all secret records
+// belonging to the account with the given id.
+type DeleteSecretQueryByAccountID string
+
 // FindSecretQueryBySecretID requests the secret of the given ID
 type FindSecretQueryBySecretID string
 
+// CountSecretsQueryByAccountID requests the number of secrets that are
+// associated with the account of the given ID, leaving out parked secrets
+type CountSecretsQueryByAccountID string
+
 // FindAccountQueryActiveByID requests a non-retired account of the given ID
 type FindAccountQueryActiveByID string
 
@@ -114,12 +198,47 @@ type FindTombstonesQueryByAccounts struct {
 }
 
 // FindTombstonesQueryBySecrets requests all tombstones for an account id that are
-// newer than the given sequence
+// newer than the given sequence. In case Until is non-zero, only tombstones
+// that are not newer than Until are returned.
 type FindTombstonesQueryBySecrets struct {
 	Since     string
+	Until     string
 	SecretIDs []string
 }
 
+// MeasureStorageQueryByAccountID requests the number of bytes used for storing
+// the events and user secrets of the account with the given id.
+type MeasureStorageQueryByAccountID string
+
+// DeleteInvitesQueryUnexpiredByID requests deletion of the invite with the
+// given id in case it has not expired at the given time yet.
+type DeleteInvitesQueryUnexpiredByID struct {
+	InviteID string
+	Now      time.Time
+}
+
+// DeleteInvitesQueryExpired requests deletion of all invites that have
+// expired at the given time.
+type DeleteInvitesQueryExpired time.Time
+
+// FindSessionQueryUnexpiredByID requests the session of the given id in case
+// it has not expired at the given time yet.
+type FindSessionQueryUnexpiredByID struct {
+	SessionID string
+	Now       time.Time
+}
+
+// DeleteSessionsQueryByID requests deletion of the session with the given id.
+type DeleteSessionsQueryByID string
+
+// DeleteSessionsQueryExpired requests deletion of all sessions that have
+// expired at the given time.
+type DeleteSessionsQueryExpired time.Time
+
+// FindConsentAuditEntriesQueryByAccountID requests all consent audit entries
+// recorded for the account with the given id, oldest first.
+type FindConsentAuditEntriesQueryByAccountID string
+
 // Transaction is a data access layer that does not persist data until commit
 // is called. In case rollback is called before, the underlying database will
 // remain in the same state as before.
@@ -20,9 +20,14 @@ type Event struct {
 	Sequence  string
 	AccountID string
 	// the secret id is nullable for anonymous events
-	SecretID *string
-	Payload  string
-	Secret   Secret
+	SecretID    *string
+	Payload     string
+	ContentHash string
+	Exempt      bool
+	Secret      Secret
+	// UserSequence is the number assigned to the event by the server when
+	// sequencing is enabled. It is zero otherwise.
+	UserSequence int64
 }
 
 // A Tombstone replaces an event on its deletion
@@ -38,9 +43,47 @@ type Tombstone struct {
 // to decrypt events stored for that user.
 type Secret struct {
 	SecretID        string
+	AccountID       string
 	EncryptedSecret string
+	// EventCounter is the number of sequenced events stored for the user.
+	EventCounter int64
+	// Parked is set on secrets that only keep events of a user that has
+	// replaced their secret. It does not belong to an actual user.
+	Parked bool
 }
 
+// An Invite allows its bearer to create a single account without requiring
+// admin privileges. Invites are deleted when being redeemed.
+type Invite struct {
+	InviteID  string
+	CreatedBy string
+	Expires   time.Time
+}
+
+// A Session is created when an account user logs in. Auth tokens only
+// reference the session, so they stop working once it has been deleted.
+type Session struct {
+	SessionID     string
+	AccountUserID string
+	Expires       time.Time
+}
+
+// A ConsentAuditEntry records a user opting in or out for an account. Users
+// are only identified by their hashed identifier.
+type ConsentAuditEntry struct {
+	EntryID      string
+	AccountID    string
+	HashedUserID string
+	Action       string
+	Timestamp    time.Time
+}
+
+// The actions that are recorded in the consent audit.
+const (
+	ConsentActionOptIn  = "opt-in"
+	ConsentActionOptOut = "opt-out"
+)
+
 // AccountUserAdminLevel is used to describe the privileges granted to an account
 // user. If zero, no admin privileges are given.
 type AccountUserAdminLevel int
@@ -163,6 +206,15 @@ type Account struct {
 	AccountStyles       string
 	Created             time.Time
 	Events              []Event
+	Settings            AccountSettings
+	EventSchema         string
+	EventSchemaVersion  int
+	LegalHold           LegalHold
+	// Retention is the retention period of the account's events. In case
+	// it is zero, the globally configured retention period applies.
+	Retention time.Duration
+	// IngestPause stops accepting events for the account while enabled.
+	IngestPause IngestPause
 }
 
 // HashUserID uses the account's `UserSalt` to create a hashed version of a
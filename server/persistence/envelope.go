@@ -0,0 +1,92 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"crypto/aes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/offen/offen/server/keys"
+)
+
+// SecretEnvelope adds an additional layer of encryption to user secrets
+// before they are persisted. Implementations can use a local master key or
+// defer to an external key management service.
+type SecretEnvelope interface {
+	Seal(secret string) (string, error)
+	Open(sealed string) (string, error)
+}
+
+// sealedSecretPrefix marks secrets that have been wrapped by an envelope, so
+// secrets that have been stored before envelope encryption was enabled can
+// still be read.
+const sealedSecretPrefix = "sealed:"
+
+// ErrNoSecretEnvelope is returned when a sealed secret is read but no
+// envelope has been configured.
+var ErrNoSecretEnvelope = errors.New("persistence: secret is sealed but no envelope is configured")
+
+// WithSecretEnvelope wraps all user secrets in the given envelope before
+// persisting them.
+func WithSecretEnvelope(e SecretEnvelope) Config {
+	return func(p *persistenceLayer) {
+		p.envelope = e
+	}
+}
+
+func (p *persistenceLayer) sealSecret(secret string) (string, error) {
+	if p.envelope == nil {
+		return secret, nil
+	}
+	sealed, err := p.envelope.Seal(secret)
+	if err != nil {
+		return "", fmt.Errorf("persistence: error sealing secret: %w", err)
+	}
+	return sealedSecretPrefix + sealed, nil
+}
+
+func (p *persistenceLayer) openSecret(secret string) (string, error) {
+	if !strings.HasPrefix(secret, sealedSecretPrefix) {
+		return secret, nil
+	}
+	if p.envelope == nil {
+		return "", ErrNoSecretEnvelope
+	}
+	opened, err := p.envelope.Open(strings.TrimPrefix(secret, sealedSecretPrefix))
+	if err != nil {
+		return "", fmt.Errorf("persistence: error opening secret: %w", err)
+	}
+	return opened, nil
+}
+
+type masterKeyEnvelope struct {
+	key []byte
+}
+
+// NewMasterKeyEnvelope creates a SecretEnvelope that encrypts secrets using
+// AES-GCM and the given master key, which needs to be 16, 24 or 32 bytes long.
+func NewMasterKeyEnvelope(key []byte) (SecretEnvelope, error) {
+	if _, err := aes.NewCipher(key); err != nil {
+		return nil, fmt.Errorf("persistence: invalid master key: %w", err)
+	}
+	return &masterKeyEnvelope{key: key}, nil
+}
+
+func (m *masterKeyEnvelope) Seal(secret string) (string, error) {
+	cipher, err := keys.EncryptWith(m.key, []byte(secret))
+	if err != nil {
+		return "", err
+	}
+	return cipher.Marshal(), nil
+}
+
+func (m *masterKeyEnvelope) Open(sealed string) (string, error) {
+	b, err := keys.DecryptWith(m.key, sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
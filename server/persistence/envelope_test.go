@@ -0,0 +1,130 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/offen/offen/server/keys"
+)
+
+func TestPersistenceLayer_AssociateUserSecret_Envelope(t *testing.T) {
+	salt, err := keys.NewFastSalt(keys.DefaultSecretLength)
+	if err != nil {
+		t.Fatalf("Unexpected error creating salt: %v", err)
+	}
+	masterKey, err := keys.GenerateRandomBytes(32)
+	if err != nil {
+		t.Fatalf("Unexpected error creating master key: %v", err)
+	}
+	envelope, err := NewMasterKeyEnvelope(masterKey)
+	if err != nil {
+		t.Fatalf("Unexpected error creating envelope: %v", err)
+	}
+
+	dal := &mockMaxUsersDatabase{
+		account: Account{AccountID: "account-id", UserSalt: salt.Marshal()},
+		secrets: map[string]Secret{},
+	}
+	p := &persistenceLayer{dal: dal, envelope: envelope}
+
+	if err := p.AssociateUserSecret("account-id", "user-id", "encrypted-user-secret"); err != nil {
+		t.Fatalf("Unexpected error associating secret: %v", err)
+	}
+	if len(dal.secrets) != 1 {
+		t.Fatalf("Expected a single secret to be stored, got %d", len(dal.secrets))
+	}
+
+	for _, stored := range dal.secrets {
+		if strings.Contains(stored.EncryptedSecret, "encrypted-user-secret") {
+			t.Errorf("Expected secret to be sealed, got %s", stored.EncryptedSecret)
+		}
+
+		opened, err := p.openSecret(stored.EncryptedSecret)
+		if err != nil {
+			t.Fatalf("Unexpected error opening secret: %v", err)
+		}
+		if opened != "encrypted-user-secret" {
+			t.Errorf("Unexpected opened secret %s", opened)
+		}
+
+		if _, err := (&persistenceLayer{}).openSecret(stored.EncryptedSecret); !errors.Is(err, ErrNoSecretEnvelope) {
+			t.Errorf("Expected ErrNoSecretEnvelope, got %v", err)
+		}
+
+		otherKey, _ := keys.GenerateRandomBytes(32)
+		otherEnvelope, _ := NewMasterKeyEnvelope(otherKey)
+		if _, err := (&persistenceLayer{envelope: otherEnvelope}).openSecret(stored.EncryptedSecret); err == nil {
+			t.Error("Expected error opening secret with wrong master key")
+		}
+	}
+
+	t.Run("unsealed secrets", func(t *testing.T) {
+		opened, err := p.openSecret("legacy-secret")
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if opened != "legacy-secret" {
+			t.Errorf("Unexpected opened secret %s", opened)
+		}
+	})
+}
+
+func TestNewMasterKeyEnvelope(t *testing.T) {
+	if _, err := NewMasterKeyEnvelope([]byte("too-short")); err == nil {
+		t.Error("Expected error for invalid key length")
+	}
+}
+
+type mockExportEnvelopeDatabase struct {
+	mockMaxUsersDatabase
+}
+
+func (m *mockExportEnvelopeDatabase) FindAccounts(interface{}) ([]Account, error) {
+	return []Account{m.account}, nil
+}
+
+func TestPersistenceLayer_Export_Envelope(t *testing.T) {
+	salt, err := keys.NewFastSalt(keys.DefaultSecretLength)
+	if err != nil {
+		t.Fatalf("Unexpected error creating salt: %v", err)
+	}
+	masterKey, err := keys.GenerateRandomBytes(32)
+	if err != nil {
+		t.Fatalf("Unexpected error creating master key: %v", err)
+	}
+	envelope, err := NewMasterKeyEnvelope(masterKey)
+	if err != nil {
+		t.Fatalf("Unexpected error creating envelope: %v", err)
+	}
+
+	dal := &mockExportEnvelopeDatabase{
+		mockMaxUsersDatabase{
+			account: Account{AccountID: "account-id", UserSalt: salt.Marshal()},
+			secrets: map[string]Secret{},
+		},
+	}
+	p := &persistenceLayer{dal: dal, envelope: envelope}
+
+	if err := p.AssociateUserSecret("account-id", "user-id", "encrypted-user-secret"); err != nil {
+		t.Fatalf("Unexpected error associating secret: %v", err)
+	}
+
+	result, err := p.Export("user-id")
+	if err != nil {
+		t.Fatalf("Unexpected error exporting: %v", err)
+	}
+	if len(result.Secrets) != 1 {
+		t.Fatalf("Expected a single secret to be exported, got %v", result.Secrets)
+	}
+	if result.Secrets[0].EncryptedSecret != "encrypted-user-secret" {
+		t.Errorf("Expected opened secret to be exported, got %s", result.Secrets[0].EncryptedSecret)
+	}
+
+	if _, err := (&persistenceLayer{dal: dal}).Export("user-id"); !errors.Is(err, ErrNoSecretEnvelope) {
+		t.Errorf("Expected ErrNoSecretEnvelope, got %v", err)
+	}
+}
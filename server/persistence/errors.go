@@ -23,3 +23,37 @@ func (e ErrUnknownSecret) Error() string {
 
 // ErrBadQuery is returned when a DAL method cannot handle the given query
 var ErrBadQuery = errors.New("persistence: could not match query")
+
+// ErrExpireThresholdExceeded is returned when a call to Expire deleted more
+// events than the configured threshold. The deletion itself has succeeded
+// when this error is returned.
+var ErrExpireThresholdExceeded = errors.New("persistence: number of expired events exceeded threshold")
+
+// ErrContentHashMismatch is returned when the content hash supplied with an
+// event does not match its payload.
+var ErrContentHashMismatch = errors.New("persistence: content hash does not match payload")
+
+// ErrInviteUnavailable is returned when an invite cannot be redeemed because
+// it is unknown, has expired, or has already been used.
+var ErrInviteUnavailable = errors.New("persistence: invite is not available")
+
+// ErrUnknownSession is returned when a session is unknown, has expired, or
+// has been ended by logging out.
+var ErrUnknownSession = errors.New("persistence: session is not available")
+
+// ErrDuplicateAccountName is returned when an account cannot be created
+// because another account already uses the given name.
+var ErrDuplicateAccountName = errors.New("persistence: an account with this name already exists")
+
+// ErrMaxUsersExceeded is returned when a new user secret cannot be associated
+// with an account as the account has already reached the configured maximum
+// number of users.
+var ErrMaxUsersExceeded = errors.New("persistence: account has reached the maximum number of users")
+
+// ErrBadCredentials is returned when logging in fails because the given
+// email address or password does not match any account user.
+var ErrBadCredentials = errors.New("persistence: credentials do not match any account user")
+
+// errUnknownAccountUser is returned when no account user matches the given
+// email address.
+var errUnknownAccountUser = errors.New("persistence: no matching account user")
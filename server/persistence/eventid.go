@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/oklog/ulid"
@@ -20,10 +21,16 @@ func NewULID() (string, error) {
 	return EventIDAt(time.Now())
 }
 
-var entropy io.Reader
+var (
+	entropy io.Reader
+	// monotonic entropy sources are not safe for concurrent use
+	entropyMu sync.Mutex
+)
 
 // EventIDAt creates a new ULID based on the given timestamp
 func EventIDAt(t time.Time) (string, error) {
+	entropyMu.Lock()
+	defer entropyMu.Unlock()
 	if entropy == nil {
 		entropy = ulid.Monotonic(rand.New(rand.NewSource(t.UnixNano())), 0)
 	}
@@ -37,6 +44,15 @@ func EventIDAt(t time.Time) (string, error) {
 	return eventID.String(), nil
 }
 
+// EventTime returns the time the event with the given id has been created.
+func EventTime(eventID string) (time.Time, error) {
+	id, err := ulid.Parse(eventID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("persistence: error parsing given string to ULID: %w", err)
+	}
+	return ulid.Time(id.Time()), nil
+}
+
 func siblingEventID(id string) (string, error) {
 	pid, err := ulid.Parse(id)
 	if err != nil {
@@ -4,17 +4,154 @@
 package persistence
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 )
 
-func (p *persistenceLayer) Insert(userID, accountID, payload string, idOverride *string) error {
+func (p *persistenceLayer) Insert(userID, accountID, payload, contentHash string, exempt bool, idOverride *string) error {
+	evt, err := p.newEvent(userID, accountID, payload, contentHash, exempt, idOverride)
+	if err != nil {
+		return err
+	}
+	if err := p.dal.CreateEvent(evt); err != nil {
+		return fmt.Errorf("persistence: error inserting event: %w", err)
+	}
+	return nil
+}
+
+// InsertSequenced inserts an event just like Insert, but also assigns it the
+// next number of a counter kept for each user. The number is returned to the
+// caller, so clients can detect events that got lost. Anonymous events cannot
+// be sequenced.
+func (p *persistenceLayer) InsertSequenced(userID, accountID, payload, contentHash string, exempt bool, idOverride *string) (int64, error) {
+	if userID == "" {
+		return 0, errors.New("persistence: anonymous events cannot be sequenced")
+	}
+	evt, err := p.newEvent(userID, accountID, payload, contentHash, exempt, idOverride)
+	if err != nil {
+		return 0, err
+	}
+
+	txn, err := p.dal.Transaction()
+	if err != nil {
+		return 0, fmt.Errorf("persistence: error creating transaction: %w", err)
+	}
+	sequence, err := txn.IncrementEventCounter(*evt.SecretID)
+	if err != nil {
+		txn.Rollback()
+		return 0, fmt.Errorf("persistence: error assigning user sequence: %w", err)
+	}
+	evt.UserSequence = sequence
+	if err := txn.CreateEvent(evt); err != nil {
+		txn.Rollback()
+		return 0, fmt.Errorf("persistence: error inserting event: %w", err)
+	}
+	if err := txn.Commit(); err != nil {
+		return 0, fmt.Errorf("persistence: error committing transaction: %w", err)
+	}
+	return sequence, nil
+}
+
+// EventInput is a single event to be stored using InsertEvents. In case
+// EventID is empty, a new identifier is created.
+type EventInput struct {
+	AccountID   string
+	Payload     string
+	ContentHash string
+	Exempt      bool
+	EventID     string
+}
+
+// BatchInsertError is returned by InsertEvents in case the event at Index
+// could not be stored. None of the events in the batch are stored then.
+type BatchInsertError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchInsertError) Error() string {
+	return fmt.Sprintf("persistence: error inserting event at index %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchInsertError) Unwrap() error {
+	return e.Err
+}
+
+// InsertEvents inserts all of the given events for the given user in a single
+// transaction. In case sequenced is set, each event is assigned the next
+// number of the user's counter like InsertSequenced does and the numbers are
+// returned in the order of the given events.
+func (p *persistenceLayer) InsertEvents(userID string, events []EventInput, sequenced bool) ([]int64, error) {
+	if sequenced && userID == "" {
+		return nil, errors.New("persistence: anonymous events cannot be sequenced")
+	}
+	var records []*Event
+	for i, input := range events {
+		var idOverride *string
+		if input.EventID != "" {
+			idOverride = &input.EventID
+		}
+		evt, err := p.newEvent(userID, input.AccountID, input.Payload, input.ContentHash, input.Exempt, idOverride)
+		if err != nil {
+			return nil, &BatchInsertError{Index: i, Err: err}
+		}
+		records = append(records, evt)
+	}
+
+	txn, err := p.dal.Transaction()
+	if err != nil {
+		return nil, fmt.Errorf("persistence: error creating transaction: %w", err)
+	}
+	sequences := make([]int64, len(records))
+	for i, evt := range records {
+		if sequenced {
+			sequence, err := txn.IncrementEventCounter(*evt.SecretID)
+			if err != nil {
+				txn.Rollback()
+				return nil, &BatchInsertError{Index: i, Err: fmt.Errorf("persistence: error assigning user sequence: %w", err)}
+			}
+			evt.UserSequence = sequence
+			sequences[i] = sequence
+		}
+		if err := txn.CreateEvent(evt); err != nil {
+			txn.Rollback()
+			return nil, &BatchInsertError{Index: i, Err: fmt.Errorf("persistence: error inserting event: %w", err)}
+		}
+	}
+	if err := txn.Commit(); err != nil {
+		return nil, fmt.Errorf("persistence: error committing transaction: %w", err)
+	}
+	return sequences, nil
+}
+
+// SecretID returns the identifier the secret and events of the given user
+// are stored with for the given account.
+func (p *persistenceLayer) SecretID(accountID, userID string) (string, error) {
+	account, err := p.dal.FindAccount(FindAccountQueryActiveByID(accountID))
+	if err != nil {
+		return "", fmt.Errorf("persistence: error looking up account %s: %w", accountID, err)
+	}
+	hashedUserID, err := account.HashUserID(userID)
+	if err != nil {
+		return "", fmt.Errorf("persistence: error hashing user id: %w", err)
+	}
+	return hashedUserID, nil
+}
+
+// newEvent validates the given values and creates the event to be stored.
+func (p *persistenceLayer) newEvent(userID, accountID, payload, contentHash string, exempt bool, idOverride *string) (*Event, error) {
+	if contentHash != "" && !VerifyContentHash(payload, contentHash) {
+		return nil, fmt.Errorf("persistence: error inserting event: %w", ErrContentHashMismatch)
+	}
+
 	var eventID string
 	if idOverride == nil {
 		var err error
 		eventID, err = NewULID()
 		if err != nil {
-			return fmt.Errorf("persistence: error creating new event identifier: %w", err)
+			return nil, fmt.Errorf("persistence: error creating new event identifier: %w", err)
 		}
 	} else {
 		eventID = *idOverride
@@ -22,14 +159,14 @@ func (p *persistenceLayer) Insert(userID, accountID, payload string, idOverride
 
 	account, err := p.dal.FindAccount(FindAccountQueryActiveByID(accountID))
 	if err != nil {
-		return fmt.Errorf("persistence: error looking up matching account for given event: %w", err)
+		return nil, fmt.Errorf("persistence: error looking up matching account for given event: %w", err)
 	}
 
 	var hashedUserID *string
 	if userID != "" {
 		hash, err := account.HashUserID(userID)
 		if err != nil {
-			return fmt.Errorf("persistence: error hashing user id: %w", err)
+			return nil, fmt.Errorf("persistence: error hashing user id: %w", err)
 		}
 		hashedUserID = &hash
 	}
@@ -38,33 +175,41 @@ func (p *persistenceLayer) Insert(userID, accountID, payload string, idOverride
 	// already exists for the account so events can be decrypted lateron
 	if hashedUserID != nil {
 		if _, err := p.dal.FindSecret(FindSecretQueryBySecretID(*hashedUserID)); err != nil {
-			return fmt.Errorf("persistence: error finding secret for given event: %w", err)
+			return nil, fmt.Errorf("persistence: error finding secret for given event: %w", err)
 		}
 	}
 
 	sequence, seqErr := NewULID()
 	if seqErr != nil {
-		return fmt.Errorf("persistence: error creating sequence number: %w", seqErr)
+		return nil, fmt.Errorf("persistence: error creating sequence number: %w", seqErr)
 	}
 
-	insertErr := p.dal.CreateEvent(&Event{
-		AccountID: accountID,
-		SecretID:  hashedUserID,
-		Payload:   payload,
-		EventID:   eventID,
-		Sequence:  sequence,
-	})
-	if insertErr != nil {
-		return fmt.Errorf("persistence: error inserting event: %w", insertErr)
-	}
-	return nil
+	return &Event{
+		AccountID:   accountID,
+		SecretID:    hashedUserID,
+		Payload:     payload,
+		ContentHash: strings.ToLower(contentHash),
+		Exempt:      exempt,
+		EventID:     eventID,
+		Sequence:    sequence,
+	}, nil
 }
 
 // Query defines a set of filters to limit the set of results to be returned
 // In case a field has the zero value, its filter will not be applied.
+//
+// In case Limit is set, at most Limit events are returned. In case more
+// events exist, the result's NextCursor can be passed as Since for
+// requesting the next page.
+//
+// In case MaxBytes is set, the result is truncated before the payloads of
+// the returned events exceed MaxBytes and NextCursor is set so the remaining
+// events can be requested. At least one event is always returned.
 type Query struct {
-	UserID string
-	Since  string
+	UserID   string
+	Since    string
+	Limit    int
+	MaxBytes int
 }
 
 func (p *persistenceLayer) Query(query Query) (EventsResult, error) {
@@ -74,30 +219,46 @@ func (p *persistenceLayer) Query(query Query) (EventsResult, error) {
 		return EventsResult{}, fmt.Errorf("persistence: error looking up all accounts: %v", err)
 	}
 
+	out := EventsResult{}
 	results, err := p.dal.FindEvents(FindEventsQueryForSecretIDs{
 		SecretIDs: hashUserIDForAccounts(query.UserID, accounts),
 		Since:     query.Since,
+		Limit:     query.Limit,
+		MaxBytes:  query.MaxBytes,
 	})
 	if err != nil {
 		return EventsResult{}, fmt.Errorf("persistence: error looking up events: %w", err)
 	}
-	out := EventsResult{}
+	if query.Limit > 0 && len(results) == query.Limit {
+		out.NextCursor = results[len(results)-1].Sequence
+	}
+	if query.MaxBytes > 0 {
+		var truncated bool
+		if results, truncated = truncateEvents(results, query.MaxBytes); truncated {
+			out.NextCursor = results[len(results)-1].Sequence
+		}
+	}
 	eventResults := EventsByAccountID{}
 	seqs := []string{}
 	for _, match := range results {
 		eventResults[match.AccountID] = append(eventResults[match.AccountID], EventResult{
-			AccountID: match.AccountID,
-			Payload:   match.Payload,
-			EventID:   match.EventID,
+			AccountID:    match.AccountID,
+			Payload:      match.Payload,
+			EventID:      match.EventID,
+			ContentHash:  match.ContentHash,
+			UserSequence: match.UserSequence,
 		})
 		seqs = append(seqs, match.Sequence)
 	}
 	out.Events = &eventResults
 
 	if query.Since != "" {
+		// Tombstones newer than the last event of an incomplete result are
+		// returned with the following pages.
 		pruned, err := p.dal.FindTombstones(FindTombstonesQueryBySecrets{
 			SecretIDs: hashUserIDForAccounts(query.UserID, accounts),
 			Since:     query.Since,
+			Until:     out.NextCursor,
 		})
 		if err != nil {
 			return EventsResult{}, fmt.Errorf("persistence: error finding deleted events: %w", err)
@@ -115,6 +276,22 @@ func (p *persistenceLayer) Query(query Query) (EventsResult, error) {
 	return out, nil
 }
 
+// truncateEvents returns the longest run of events ordered by sequence whose
+// payloads do not exceed maxBytes in total, but at least one event.
+func truncateEvents(events []Event, maxBytes int) ([]Event, bool) {
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Sequence < events[j].Sequence
+	})
+	var size int
+	for i, evt := range events {
+		size += len(evt.Payload)
+		if size > maxBytes && i > 0 {
+			return events[:i], true
+		}
+	}
+	return events, false
+}
+
 func (p *persistenceLayer) Purge(userID string) error {
 	sequence, err := NewULID()
 	if err != nil {
@@ -156,12 +333,111 @@ func (p *persistenceLayer) Purge(userID string) error {
 		return fmt.Errorf("persistence: error purging events: %w", err)
 	}
 
+	if p.consentAudit {
+		if err := recordOptOuts(txn, hashedUserIDs); err != nil {
+			txn.Rollback()
+			return err
+		}
+	}
+
 	if err := txn.Commit(); err != nil {
 		return fmt.Errorf("persistence: error committing pruning of events: %w", err)
 	}
 	return nil
 }
 
+// Export returns all events and encrypted user secrets that are stored for the
+// given user. Like Purge, it only considers data that is associated with the
+// hashed user id for each account.
+func (p *persistenceLayer) Export(userID string) (UserExportResult, error) {
+	accounts, err := p.dal.FindAccounts(FindAccountsQueryAllAccounts{})
+	if err != nil {
+		return UserExportResult{}, fmt.Errorf("persistence: error retrieving available accounts: %w", err)
+	}
+
+	hashedUserIDs := hashUserIDForAccounts(userID, accounts)
+
+	events, err := p.dal.FindEvents(FindEventsQueryForSecretIDs{SecretIDs: hashedUserIDs})
+	if err != nil {
+		return UserExportResult{}, fmt.Errorf("persistence: error looking up events to export: %w", err)
+	}
+	out := UserExportResult{Events: EventsByAccountID{}, Secrets: []SecretResult{}}
+	for _, evt := range events {
+		out.Events[evt.AccountID] = append(out.Events[evt.AccountID], EventResult{
+			AccountID:    evt.AccountID,
+			SecretID:     evt.SecretID,
+			EventID:      evt.EventID,
+			Payload:      evt.Payload,
+			ContentHash:  evt.ContentHash,
+			UserSequence: evt.UserSequence,
+		})
+	}
+
+	for _, hashedUserID := range hashedUserIDs {
+		secret, err := p.dal.FindSecret(FindSecretQueryBySecretID(hashedUserID))
+		if err != nil {
+			// the user does not need to have a secret for every account
+			var unknownSecret ErrUnknownSecret
+			if errors.As(err, &unknownSecret) {
+				continue
+			}
+			return UserExportResult{}, fmt.Errorf("persistence: error looking up secrets to export: %w", err)
+		}
+		encryptedSecret, err := p.openSecret(secret.EncryptedSecret)
+		if err != nil {
+			return UserExportResult{}, fmt.Errorf("persistence: error reading secret %s: %w", secret.SecretID, err)
+		}
+		out.Secrets = append(out.Secrets, SecretResult{
+			SecretID:        secret.SecretID,
+			EncryptedSecret: encryptedSecret,
+		})
+	}
+	return out, nil
+}
+
+// ExportAccountEvents calls fn with all events stored for the given account,
+// passing pages of at most batchSize events in the order they have been
+// created. This allows exporting large accounts without loading all of their
+// events into memory. In case fn returns an error, exporting stops and the
+// error is returned.
+func (p *persistenceLayer) ExportAccountEvents(accountID string, batchSize int, fn func([]EventResult) error) error {
+	if _, err := p.dal.FindAccount(FindAccountQueryActiveByID(accountID)); err != nil {
+		return fmt.Errorf("persistence: error looking up account %s: %w", accountID, err)
+	}
+	var after string
+	for {
+		events, err := p.dal.FindEvents(FindEventsQueryByAccountIDPage{
+			AccountID: accountID,
+			After:     after,
+			Limit:     batchSize,
+		})
+		if err != nil {
+			return fmt.Errorf("persistence: error looking up events to export: %w", err)
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		page := make([]EventResult, len(events))
+		for i, evt := range events {
+			page[i] = EventResult{
+				AccountID:    evt.AccountID,
+				SecretID:     evt.SecretID,
+				EventID:      evt.EventID,
+				Payload:      evt.Payload,
+				ContentHash:  evt.ContentHash,
+				UserSequence: evt.UserSequence,
+			}
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if len(events) < batchSize {
+			return nil
+		}
+		after = events[len(events)-1].EventID
+	}
+}
+
 func hashUserIDForAccounts(userID string, accounts []Account) []string {
 	if len(accounts) == 0 {
 		return []string{}
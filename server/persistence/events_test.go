@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -63,6 +64,13 @@ func TestPersistenceLayer_Insert(t *testing.T) {
 				},
 			},
 		},
+		{
+			"content hash mismatch",
+			[]string{"user-id", "account-id", "payload", somePayloadHash},
+			&mockInsertEventDatabase{},
+			true,
+			[]assertion{},
+		},
 		{
 			"user lookup error",
 			[]string{"user-id", "account-id", "payload"},
@@ -216,7 +224,11 @@ func TestPersistenceLayer_Insert(t *testing.T) {
 			r := &persistenceLayer{
 				dal: test.db,
 			}
-			err := r.Insert(test.callArgs[0], test.callArgs[1], test.callArgs[2], nil)
+			var contentHash string
+			if len(test.callArgs) > 3 {
+				contentHash = test.callArgs[3]
+			}
+			err := r.Insert(test.callArgs[0], test.callArgs[1], test.callArgs[2], contentHash, false, nil)
 			if (err != nil) != test.expectError {
 				t.Errorf("Unexpected error value %v", err)
 			}
@@ -232,6 +244,98 @@ func TestPersistenceLayer_Insert(t *testing.T) {
 	}
 }
 
+type mockInsertEventsDatabase struct {
+	mockInsertEventDatabase
+	failAt     int
+	created    []*Event
+	committed  bool
+	rolledBack bool
+}
+
+func (m *mockInsertEventsDatabase) CreateEvent(e *Event) error {
+	if len(m.created) == m.failAt {
+		return errors.New("did not work")
+	}
+	m.created = append(m.created, e)
+	return nil
+}
+
+func (m *mockInsertEventsDatabase) Transaction() (Transaction, error) {
+	return m, nil
+}
+
+func (m *mockInsertEventsDatabase) Commit() error {
+	m.committed = true
+	return nil
+}
+
+func (m *mockInsertEventsDatabase) Rollback() error {
+	m.rolledBack = true
+	return nil
+}
+
+func TestPersistenceLayer_InsertEvents(t *testing.T) {
+	events := []EventInput{
+		{AccountID: "account-id", Payload: "payload-a"},
+		{AccountID: "account-id", Payload: "payload-b"},
+		{AccountID: "account-id", Payload: "payload-c"},
+	}
+	tests := []struct {
+		name          string
+		events        []EventInput
+		failAt        int
+		expectedIndex int
+		expectCommit  bool
+	}{
+		{"ok", events, -1, -1, true},
+		{"insert error", events, 1, 1, false},
+		{
+			"content hash mismatch",
+			[]EventInput{events[0], {AccountID: "account-id", Payload: "payload", ContentHash: somePayloadHash}},
+			-1,
+			1,
+			false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := &mockInsertEventsDatabase{
+				mockInsertEventDatabase: mockInsertEventDatabase{
+					findAccountResult: Account{
+						Name:     "test",
+						UserSalt: "{1,} CaHVhk78uhoPmf5wanA0vg==",
+					},
+				},
+				failAt: test.failAt,
+			}
+			p := &persistenceLayer{dal: db}
+			_, err := p.InsertEvents("user-id", test.events, false)
+			if test.expectedIndex < 0 {
+				if err != nil {
+					t.Fatalf("Unexpected error %v", err)
+				}
+			} else {
+				var batchErr *BatchInsertError
+				if !errors.As(err, &batchErr) {
+					t.Fatalf("Expected batch insert error, got %v", err)
+				}
+				if batchErr.Index != test.expectedIndex {
+					t.Errorf("Expected error at index %d, got %d", test.expectedIndex, batchErr.Index)
+				}
+			}
+			if db.committed != test.expectCommit {
+				t.Errorf("Expected commit to be %v", test.expectCommit)
+			}
+			if db.rolledBack != (test.failAt >= 0) {
+				t.Errorf("Unexpected rollback value %v", db.rolledBack)
+			}
+			if test.expectCommit && len(db.created) != len(test.events) {
+				t.Errorf("Expected %d events to be created, got %d", len(test.events), len(db.created))
+			}
+		})
+	}
+}
+
 type mockPurgeEventsDatabase struct {
 	DataAccessLayer
 	findAccountsResult []Account
@@ -381,6 +485,7 @@ type mockQueryEventDatabase struct {
 	findEventsResult   []Event
 	findEventsErr      error
 	methodArgs         []interface{}
+	tombstonesQuery    interface{}
 }
 
 func (m *mockQueryEventDatabase) FindAccounts(q interface{}) ([]Account, error) {
@@ -394,6 +499,7 @@ func (m *mockQueryEventDatabase) FindEvents(q interface{}) ([]Event, error) {
 }
 
 func (m *mockQueryEventDatabase) FindTombstones(q interface{}) ([]Tombstone, error) {
+	m.tombstonesQuery = q
 	return nil, nil
 }
 
@@ -529,9 +635,89 @@ func TestPersistenceLayer_Query(t *testing.T) {
 	}
 }
 
+func TestPersistenceLayer_Query_Limit(t *testing.T) {
+	db := &mockQueryEventDatabase{
+		findAccountsResult: []Account{
+			{AccountID: "account-a", UserSalt: "LEWtq55DKObqPK+XEQbnZA=="},
+		},
+		findEventsResult: []Event{
+			{AccountID: "account-a", EventID: "event-a", Sequence: "sequence-a"},
+			{AccountID: "account-a", EventID: "event-b", Sequence: "sequence-b"},
+		},
+	}
+	p := &persistenceLayer{dal: db}
+
+	result, err := p.Query(Query{UserID: "user-id", Since: "sequence-0", Limit: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if result.NextCursor != "sequence-b" {
+		t.Errorf("Expected next cursor for full page, got %q", result.NextCursor)
+	}
+	if query, ok := db.methodArgs[1].(FindEventsQueryForSecretIDs); !ok || query.Limit != 2 {
+		t.Errorf("Unexpected events query %v", db.methodArgs[1])
+	}
+	if query, ok := db.tombstonesQuery.(FindTombstonesQueryBySecrets); !ok || query.Until != "sequence-b" {
+		t.Errorf("Unexpected tombstones query %v", db.tombstonesQuery)
+	}
+
+	result, err = p.Query(Query{UserID: "user-id", Since: "sequence-0", Limit: 3})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if result.NextCursor != "" {
+		t.Errorf("Expected no next cursor for last page, got %q", result.NextCursor)
+	}
+}
+
 func TestGetLatestSeq(t *testing.T) {
 	result := getLatestSeq([]string{"x", "0", "z", "a", "x", "1", "0"})
 	if result != "z" {
 		t.Errorf("Unexpected result %v", result)
 	}
 }
+
+func TestPersistenceLayer_Query_MaxBytes(t *testing.T) {
+	db := &mockQueryEventDatabase{
+		findAccountsResult: []Account{
+			{AccountID: "account-a", UserSalt: "LEWtq55DKObqPK+XEQbnZA=="},
+		},
+		findEventsResult: []Event{
+			{AccountID: "account-a", EventID: "event-c", Sequence: "sequence-c", Payload: strings.Repeat("c", 400)},
+			{AccountID: "account-a", EventID: "event-a", Sequence: "sequence-a", Payload: strings.Repeat("a", 400)},
+			{AccountID: "account-a", EventID: "event-b", Sequence: "sequence-b", Payload: strings.Repeat("b", 400)},
+		},
+	}
+	p := &persistenceLayer{dal: db}
+
+	result, err := p.Query(Query{UserID: "user-id", Since: "sequence-0", MaxBytes: 1000})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	events := (*result.Events)["account-a"]
+	if len(events) != 2 || events[0].EventID != "event-a" || events[1].EventID != "event-b" {
+		t.Errorf("Expected result to be truncated at byte cap, got %v", events)
+	}
+	if result.NextCursor != "sequence-b" {
+		t.Errorf("Expected continuation cursor, got %q", result.NextCursor)
+	}
+	if query, ok := db.tombstonesQuery.(FindTombstonesQueryBySecrets); !ok || query.Until != "sequence-b" {
+		t.Errorf("Unexpected tombstones query %v", db.tombstonesQuery)
+	}
+
+	result, err = p.Query(Query{UserID: "user-id", Since: "sequence-0", MaxBytes: 100})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if events := (*result.Events)["account-a"]; len(events) != 1 {
+		t.Errorf("Expected single event exceeding the cap to be returned, got %v", events)
+	}
+
+	result, err = p.Query(Query{UserID: "user-id", Since: "sequence-0", MaxBytes: 2000})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if result.NextCursor != "" {
+		t.Errorf("Expected no cursor when result fits cap, got %q", result.NextCursor)
+	}
+}
@@ -4,34 +4,149 @@
 package persistence
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
+	"sort"
 	"time"
 )
 
+// PurgeResult summarizes a single run of Expire.
+type PurgeResult struct {
+	Cutoff           time.Time
+	Duration         time.Duration
+	Removed          int
+	RemovedByAccount map[string]int
+	Batches          int
+	// Interrupted signals that the given context was cancelled before all
+	// expired events have been deleted.
+	Interrupted bool
+}
+
 // Expire deletes all events in the give database that are older than the given
-// retention threshold.
-func (p *persistenceLayer) Expire(retention time.Duration) (int, error) {
-	limit := time.Now().Add(-retention)
+// retention threshold. In case the number of deleted events exceeds the
+// configured threshold, the result is returned alongside
+// ErrExpireThresholdExceeded. In case deleting a batch fails, the result
+// covers the batches that have been deleted before. When deleting in
+// batches, the given context is checked before each batch. In case it is
+// done, Expire stops without an error and marks the result as interrupted.
+func (p *persistenceLayer) Expire(ctx context.Context, retention time.Duration) (PurgeResult, error) {
+	start := time.Now()
+	limit := start.Add(-retention)
+	result := PurgeResult{
+		Cutoff:           limit,
+		RemovedByAccount: map[string]int{},
+	}
 	deadline, deadlineErr := EventIDAt(limit)
 	if deadlineErr != nil {
-		return 0, fmt.Errorf("persistence: error determing deadline for expiring events: %w", deadlineErr)
+		return PurgeResult{}, fmt.Errorf("persistence: error determing deadline for expiring events: %w", deadlineErr)
 	}
 
 	sequence, seqErr := NewULID()
 	if seqErr != nil {
-		return 0, fmt.Errorf("persistence: error creating sequence number: %w", seqErr)
+		return PurgeResult{}, fmt.Errorf("persistence: error creating sequence number: %w", seqErr)
+	}
+
+	// Events of accounts under legal hold are never expired.
+	held, heldErr := p.heldAccountIDs()
+	if heldErr != nil {
+		return PurgeResult{}, fmt.Errorf("persistence: error looking up accounts under legal hold: %w", heldErr)
+	}
+	isHeld := map[string]bool{}
+	for _, accountID := range held {
+		isHeld[accountID] = true
+	}
+
+	// Accounts that define a retention period of their own are expired
+	// separately using their own deadline.
+	retentions, retentionsErr := p.accountRetentions()
+	if retentionsErr != nil {
+		return PurgeResult{}, fmt.Errorf("persistence: error looking up account retention periods: %w", retentionsErr)
+	}
+	var custom []string
+	for accountID := range retentions {
+		custom = append(custom, accountID)
+	}
+	sort.Strings(custom)
+
+	scopes := []expireScope{{deadline: deadline, exclude: append(held, custom...)}}
+	for _, accountID := range custom {
+		if isHeld[accountID] {
+			continue
+		}
+		accountDeadline, err := EventIDAt(start.Add(-retentions[accountID]))
+		if err != nil {
+			return PurgeResult{}, fmt.Errorf("persistence: error determing deadline for expiring events of account %s: %w", accountID, err)
+		}
+		scopes = append(scopes, expireScope{deadline: accountDeadline, accountID: accountID})
+	}
+
+scopes:
+	for _, scope := range scopes {
+		for {
+			if ctx.Err() != nil {
+				result.Interrupted = true
+				break scopes
+			}
+			found, err := p.expireBatch(scope, sequence, &result)
+			if err != nil {
+				// Batches that have been committed before stay deleted, so
+				// callers need to know about them.
+				result.Duration = time.Since(start)
+				return result, err
+			}
+			result.Batches++
+			if p.expireBatchSize <= 0 || found < p.expireBatchSize {
+				break
+			}
+		}
+	}
+
+	result.Duration = time.Since(start)
+	if p.expireThreshold > 0 && result.Removed > p.expireThreshold {
+		return result, fmt.Errorf(
+			"%w: removed %d events, threshold is %d", ErrExpireThresholdExceeded, result.Removed, p.expireThreshold,
+		)
 	}
+	return result, nil
+}
+
+// expireScope selects the events expired by expireBatch. Events older than
+// deadline are expired, either for the given account only or for all accounts
+// except the excluded ones.
+type expireScope struct {
+	deadline  string
+	accountID string
+	exclude   []string
+}
 
+// expireBatch deletes a single batch of expired events in a transaction and
+// adds the deleted events to the given result. It returns the number of
+// expired events that have been found.
+func (p *persistenceLayer) expireBatch(scope expireScope, sequence string, result *PurgeResult) (int, error) {
 	txn, err := p.dal.Transaction()
 	if err != nil {
 		return 0, fmt.Errorf("persistence: error creating transaction: %w", err)
 	}
-	expiredEvents, err := txn.FindEvents(FindEventsQueryOlderThan(deadline))
+
+	var findQuery interface{} = FindEventsQueryOlderThan(scope.deadline)
+	if p.expireBatchSize > 0 || len(scope.exclude) != 0 || scope.accountID != "" {
+		findQuery = FindEventsQueryExpiredBatch{
+			EventID:           scope.deadline,
+			Limit:             p.expireBatchSize,
+			ExcludeAccountIDs: scope.exclude,
+			AccountID:         scope.accountID,
+		}
+	}
+	expiredEvents, err := txn.FindEvents(findQuery)
 	if err != nil {
 		txn.Rollback()
 		return 0, fmt.Errorf("persistence: error looking up expired events: %w", err)
 	}
 
+	removedByAccount := map[string]int{}
+	var eventIDs []string
 	for _, evt := range expiredEvents {
 		if err := txn.CreateTombstone(&Tombstone{
 			AccountID: evt.AccountID,
@@ -42,9 +157,24 @@ func (p *persistenceLayer) Expire(retention time.Duration) (int, error) {
 			txn.Rollback()
 			return 0, fmt.Errorf("persistence: error creating tombstone: %w", err)
 		}
+		removedByAccount[evt.AccountID]++
+		eventIDs = append(eventIDs, evt.EventID)
+	}
+
+	if p.secureDelete {
+		if err := overwritePayloads(txn, expiredEvents); err != nil {
+			txn.Rollback()
+			return 0, err
+		}
 	}
 
-	eventsAffected, err := txn.DeleteEvents(DeleteEventsQueryOlderThan(deadline))
+	var deleteQuery interface{} = DeleteEventsQueryOlderThan(scope.deadline)
+	if p.expireBatchSize > 0 || scope.accountID != "" {
+		deleteQuery = DeleteEventsQueryByEventIDs(eventIDs)
+	} else if len(scope.exclude) != 0 {
+		deleteQuery = DeleteEventsQueryExpired{EventID: scope.deadline, ExcludeAccountIDs: scope.exclude}
+	}
+	eventsAffected, err := txn.DeleteEvents(deleteQuery)
 	if err != nil {
 		txn.Rollback()
 		return 0, fmt.Errorf("persistence: error deleting expired events: %w", err)
@@ -53,5 +183,26 @@ func (p *persistenceLayer) Expire(retention time.Duration) (int, error) {
 	if err := txn.Commit(); err != nil {
 		return 0, fmt.Errorf("persistence: error expiring events: %w", err)
 	}
-	return int(eventsAffected), nil
+
+	result.Removed += int(eventsAffected)
+	for accountID, count := range removedByAccount {
+		result.RemovedByAccount[accountID] += count
+	}
+	return len(expiredEvents), nil
+}
+
+// overwritePayloads replaces the payloads of the given events with random
+// data of the same length.
+func overwritePayloads(dal DataAccessLayer, events []Event) error {
+	for _, evt := range events {
+		noise := make([]byte, base64.StdEncoding.DecodedLen(len(evt.Payload))+3)
+		if _, err := rand.Read(noise); err != nil {
+			return fmt.Errorf("persistence: error reading random bytes: %w", err)
+		}
+		evt.Payload = base64.StdEncoding.EncodeToString(noise)[:len(evt.Payload)]
+		if err := dal.UpdateEventPayload(&evt); err != nil {
+			return fmt.Errorf("persistence: error overwriting expired event: %w", err)
+		}
+	}
+	return nil
 }
@@ -4,7 +4,10 @@
 package persistence
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -13,9 +16,32 @@ type mockExpireDatabase struct {
 	DataAccessLayer
 	err      error
 	affected int64
+	events   []Event
+	commits  int
+	onCommit func(commits int)
+	accounts []Account
+}
+
+func (m *mockExpireDatabase) FindAccounts(q interface{}) ([]Account, error) {
+	return m.accounts, nil
 }
 
 func (m *mockExpireDatabase) DeleteEvents(q interface{}) (int64, error) {
+	if ids, ok := q.(DeleteEventsQueryByEventIDs); ok {
+		deleted := map[string]bool{}
+		for _, id := range ids {
+			deleted[id] = true
+		}
+		var remaining []Event
+		for _, evt := range m.events {
+			if !deleted[evt.EventID] {
+				remaining = append(remaining, evt)
+			}
+		}
+		affected := int64(len(m.events) - len(remaining))
+		m.events = remaining
+		return affected, m.err
+	}
 	return m.affected, m.err
 }
 
@@ -24,10 +50,21 @@ func (m *mockExpireDatabase) FindTombstones(q interface{}) ([]Tombstone, error)
 }
 
 func (m *mockExpireDatabase) FindEvents(q interface{}) ([]Event, error) {
-	return nil, m.err
+	if batch, ok := q.(FindEventsQueryExpiredBatch); ok && len(m.events) > batch.Limit {
+		return m.events[:batch.Limit], m.err
+	}
+	return m.events, m.err
+}
+
+func (m *mockExpireDatabase) CreateTombstone(*Tombstone) error {
+	return nil
 }
 
 func (m *mockExpireDatabase) Commit() error {
+	m.commits++
+	if m.onCommit != nil {
+		m.onCommit(m.commits)
+	}
 	return nil
 }
 
@@ -47,12 +84,65 @@ func TestPersistenceLayer_Expire(t *testing.T) {
 				affected: 9876,
 			},
 		}
-		affected, err := r.Expire(time.Second)
+		result, err := r.Expire(context.Background(), time.Second)
 		if err != nil {
 			t.Errorf("Unexpected error %v", err)
 		}
-		if affected != 9876 {
-			t.Errorf("Expected %d, got %d", 9876, affected)
+		if result.Removed != 9876 {
+			t.Errorf("Expected %d, got %d", 9876, result.Removed)
+		}
+	})
+	t.Run("per account counts", func(t *testing.T) {
+		r := &persistenceLayer{
+			dal: &mockExpireDatabase{
+				affected: 3,
+				events: []Event{
+					{EventID: "event-a", AccountID: "account-a"},
+					{EventID: "event-b", AccountID: "account-b"},
+					{EventID: "event-c", AccountID: "account-a"},
+				},
+			},
+		}
+		result, err := r.Expire(context.Background(), time.Hour)
+		if err != nil {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if result.Removed != 3 {
+			t.Errorf("Expected %d, got %d", 3, result.Removed)
+		}
+		if !reflect.DeepEqual(map[string]int{"account-a": 2, "account-b": 1}, result.RemovedByAccount) {
+			t.Errorf("Unexpected per account counts %v", result.RemovedByAccount)
+		}
+		if time.Since(result.Cutoff) < time.Hour {
+			t.Errorf("Unexpected cutoff %v", result.Cutoff)
+		}
+	})
+	t.Run("threshold exceeded", func(t *testing.T) {
+		r := &persistenceLayer{
+			dal: &mockExpireDatabase{
+				err:      nil,
+				affected: 9876,
+			},
+			expireThreshold: 1000,
+		}
+		result, err := r.Expire(context.Background(), time.Second)
+		if !errors.Is(err, ErrExpireThresholdExceeded) {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if result.Removed != 9876 {
+			t.Errorf("Expected %d, got %d", 9876, result.Removed)
+		}
+	})
+	t.Run("threshold not exceeded", func(t *testing.T) {
+		r := &persistenceLayer{
+			dal: &mockExpireDatabase{
+				err:      nil,
+				affected: 9876,
+			},
+			expireThreshold: 10000,
+		}
+		if _, err := r.Expire(context.Background(), time.Second); err != nil {
+			t.Errorf("Unexpected error %v", err)
 		}
 	})
 	t.Run("error", func(t *testing.T) {
@@ -61,12 +151,130 @@ func TestPersistenceLayer_Expire(t *testing.T) {
 				err: errors.New("did not work"),
 			},
 		}
-		affected, err := r.Expire(time.Second)
+		result, err := r.Expire(context.Background(), time.Second)
 		if err == nil {
 			t.Errorf("Unexpected error value %v", err)
 		}
-		if affected != 0 {
-			t.Errorf("Expected %d, got %d", 0, affected)
+		if result.Removed != 0 {
+			t.Errorf("Expected %d, got %d", 0, result.Removed)
 		}
 	})
+	t.Run("batches", func(t *testing.T) {
+		dal := &mockExpireDatabase{}
+		for i := 0; i < 25; i++ {
+			dal.events = append(dal.events, Event{EventID: fmt.Sprintf("event-%02d", i), AccountID: "account-a"})
+		}
+		r := &persistenceLayer{dal: dal, expireBatchSize: 10}
+		result, err := r.Expire(context.Background(), time.Second)
+		if err != nil {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if result.Removed != 25 || result.Batches != 3 || result.Interrupted {
+			t.Errorf("Unexpected result %v", result)
+		}
+		if len(dal.events) != 0 {
+			t.Errorf("Expected all events to be deleted, got %d remaining", len(dal.events))
+		}
+	})
+	t.Run("error after batches", func(t *testing.T) {
+		dal := &mockExpireDatabase{}
+		dal.onCommit = func(commits int) {
+			if commits == 2 {
+				dal.err = errors.New("did not work")
+			}
+		}
+		for i := 0; i < 25; i++ {
+			dal.events = append(dal.events, Event{EventID: fmt.Sprintf("event-%02d", i), AccountID: "account-a"})
+		}
+		r := &persistenceLayer{dal: dal, expireBatchSize: 10}
+		result, err := r.Expire(context.Background(), time.Second)
+		if err == nil {
+			t.Error("Expected error")
+		}
+		if result.Removed != 20 || result.RemovedByAccount["account-a"] != 20 {
+			t.Errorf("Expected result to cover deleted batches, got %v", result)
+		}
+	})
+	t.Run("cancelled between batches", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		dal := &mockExpireDatabase{
+			onCommit: func(commits int) {
+				if commits == 2 {
+					cancel()
+				}
+			},
+		}
+		for i := 0; i < 25; i++ {
+			dal.events = append(dal.events, Event{EventID: fmt.Sprintf("event-%02d", i), AccountID: "account-a"})
+		}
+		r := &persistenceLayer{dal: dal, expireBatchSize: 10}
+		result, err := r.Expire(ctx, time.Second)
+		if err != nil {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if !result.Interrupted {
+			t.Error("Expected result to be marked as interrupted")
+		}
+		if result.Removed != 20 || result.Batches != 2 || result.RemovedByAccount["account-a"] != 20 {
+			t.Errorf("Unexpected result %v", result)
+		}
+		if len(dal.events) != 5 {
+			t.Errorf("Expected 5 events to remain, got %d", len(dal.events))
+		}
+	})
+}
+
+type mockSecureDeleteDatabase struct {
+	mockExpireDatabase
+	calls []string
+}
+
+func (m *mockSecureDeleteDatabase) UpdateEventPayload(evt *Event) error {
+	for _, stored := range m.events {
+		if stored.EventID == evt.EventID && stored.Payload == evt.Payload {
+			return fmt.Errorf("expected payload of event %s to be changed", evt.EventID)
+		}
+		if stored.EventID == evt.EventID && len(stored.Payload) != len(evt.Payload) {
+			return fmt.Errorf("expected payload of event %s to keep its length", evt.EventID)
+		}
+	}
+	m.calls = append(m.calls, "overwrite "+evt.EventID)
+	return nil
+}
+
+func (m *mockSecureDeleteDatabase) DeleteEvents(q interface{}) (int64, error) {
+	m.calls = append(m.calls, "delete")
+	return m.mockExpireDatabase.DeleteEvents(q)
+}
+
+func (m *mockSecureDeleteDatabase) Transaction() (Transaction, error) {
+	return m, nil
+}
+
+func TestPersistenceLayer_Expire_SecureDelete(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("enabled %v", enabled), func(t *testing.T) {
+			db := &mockSecureDeleteDatabase{
+				mockExpireDatabase: mockExpireDatabase{
+					affected: 2,
+					events: []Event{
+						{EventID: "event-a", AccountID: "account-a", Payload: "some payload"},
+						{EventID: "event-b", AccountID: "account-a", Payload: "other"},
+					},
+				},
+			}
+			p := &persistenceLayer{dal: db, secureDelete: enabled}
+			if _, err := p.Expire(context.Background(), time.Second); err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			expected := []string{"delete"}
+			if enabled {
+				expected = []string{"overwrite event-a", "overwrite event-b", "delete"}
+			}
+			if !reflect.DeepEqual(expected, db.calls) {
+				t.Errorf("Expected calls %v, got %v", expected, db.calls)
+			}
+		})
+	}
 }
@@ -7,3 +7,8 @@ package persistence
 func (p *persistenceLayer) CheckHealth() error {
 	return p.dal.Ping()
 }
+
+// Close releases the connections held by the underlying database.
+func (p *persistenceLayer) Close() error {
+	return p.dal.Close()
+}
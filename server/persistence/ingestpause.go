@@ -0,0 +1,44 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"fmt"
+	"time"
+)
+
+// IngestPause stops the ingestion of events for a single account, e.g. while
+// it is misbehaving. The account user who last changed the pause and the time
+// of the change are kept for auditing purposes.
+type IngestPause struct {
+	Paused    bool       `json:"paused"`
+	UpdatedBy string     `json:"updatedBy,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+func (p *persistenceLayer) GetIngestPause(accountID string) (IngestPause, error) {
+	a, err := p.dal.FindAccount(FindAccountQueryActiveByID(accountID))
+	if err != nil {
+		return IngestPause{}, fmt.Errorf("persistence: error looking up account %s: %w", accountID, err)
+	}
+	return a.IngestPause, nil
+}
+
+func (p *persistenceLayer) UpdateIngestPause(accountID, accountUserID string, paused bool) (IngestPause, error) {
+	a, err := p.dal.FindAccount(FindAccountQueryActiveByID(accountID))
+	if err != nil {
+		return IngestPause{}, fmt.Errorf("persistence: error looking up account %s before updating ingest pause: %w", accountID, err)
+	}
+
+	now := time.Now().UTC()
+	a.IngestPause = IngestPause{
+		Paused:    paused,
+		UpdatedBy: accountUserID,
+		UpdatedAt: &now,
+	}
+	if err := p.dal.UpdateAccount(&a); err != nil {
+		return IngestPause{}, fmt.Errorf("persistence: error updating ingest pause for account %s: %w", accountID, err)
+	}
+	return a.IngestPause, nil
+}
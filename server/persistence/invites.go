@@ -0,0 +1,72 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"fmt"
+	"time"
+)
+
+// CreateInvite persists a new invite on behalf of the given account user
+// that expires after the given duration. Invites that have already expired
+// are removed.
+func (p *persistenceLayer) CreateInvite(createdBy string, ttl time.Duration) (Invite, error) {
+	inviteID, err := NewULID()
+	if err != nil {
+		return Invite{}, fmt.Errorf("persistence: error creating invite id: %w", err)
+	}
+	now := time.Now()
+	if _, err := p.dal.DeleteInvites(DeleteInvitesQueryExpired(now)); err != nil {
+		return Invite{}, fmt.Errorf("persistence: error removing expired invites: %w", err)
+	}
+	invite := Invite{
+		InviteID:  inviteID,
+		CreatedBy: createdBy,
+		Expires:   now.Add(ttl),
+	}
+	if err := p.dal.CreateInvite(&invite); err != nil {
+		return Invite{}, fmt.Errorf("persistence: error persisting invite: %w", err)
+	}
+	return invite, nil
+}
+
+// RedeemInvite consumes the invite of the given id and creates an account of
+// the given name for the account user with the given credentials. In case the
+// invite is unknown, expired or has already been used, ErrInviteUnavailable is
+// returned.
+func (p *persistenceLayer) RedeemInvite(inviteID, accountName, emailAddress, password string) error {
+	account, relationship, err := p.prepareAccount(accountName, emailAddress, password)
+	if err != nil {
+		return err
+	}
+
+	txn, err := p.dal.Transaction()
+	if err != nil {
+		return fmt.Errorf("persistence: error creating transaction: %w", err)
+	}
+	affected, err := txn.DeleteInvites(DeleteInvitesQueryUnexpiredByID{
+		InviteID: inviteID,
+		Now:      time.Now(),
+	})
+	if err != nil {
+		txn.Rollback()
+		return fmt.Errorf("persistence: error consuming invite: %w", err)
+	}
+	if affected == 0 {
+		txn.Rollback()
+		return fmt.Errorf("persistence: error consuming invite %s: %w", inviteID, ErrInviteUnavailable)
+	}
+	if err := txn.CreateAccount(account); err != nil {
+		txn.Rollback()
+		return fmt.Errorf("persistence: error persisting account: %w", err)
+	}
+	if err := txn.CreateAccountUserRelationship(relationship); err != nil {
+		txn.Rollback()
+		return fmt.Errorf("persistence: error persisting relationship: %w", err)
+	}
+	if err := txn.Commit(); err != nil {
+		return fmt.Errorf("persistence: error committing transaction: %w", err)
+	}
+	return nil
+}
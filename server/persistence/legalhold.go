@@ -0,0 +1,65 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrLegalHold is returned when trying to delete data for an account that
+// is under legal hold.
+var ErrLegalHold = errors.New("persistence: account is under legal hold")
+
+// LegalHold freezes deletion of an account's data regardless of the
+// configured retention. The account user who last changed the hold and the
+// time of the change are kept for auditing purposes.
+type LegalHold struct {
+	Enabled   bool       `json:"enabled"`
+	UpdatedBy string     `json:"updatedBy,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+func (p *persistenceLayer) GetLegalHold(accountID string) (LegalHold, error) {
+	a, err := p.dal.FindAccount(FindAccountQueryActiveByID(accountID))
+	if err != nil {
+		return LegalHold{}, fmt.Errorf("persistence: error looking up account %s: %w", accountID, err)
+	}
+	return a.LegalHold, nil
+}
+
+func (p *persistenceLayer) UpdateLegalHold(accountID, accountUserID string, enabled bool) (LegalHold, error) {
+	a, err := p.dal.FindAccount(FindAccountQueryActiveByID(accountID))
+	if err != nil {
+		return LegalHold{}, fmt.Errorf("persistence: error looking up account %s before updating legal hold: %w", accountID, err)
+	}
+
+	now := time.Now().UTC()
+	a.LegalHold = LegalHold{
+		Enabled:   enabled,
+		UpdatedBy: accountUserID,
+		UpdatedAt: &now,
+	}
+	if err := p.dal.UpdateAccount(&a); err != nil {
+		return LegalHold{}, fmt.Errorf("persistence: error updating legal hold for account %s: %w", accountID, err)
+	}
+	return a.LegalHold, nil
+}
+
+// heldAccountIDs returns the ids of all accounts that are currently under
+// legal hold.
+func (p *persistenceLayer) heldAccountIDs() ([]string, error) {
+	accounts, err := p.dal.FindAccounts(FindAccountsQueryAllAccounts{})
+	if err != nil {
+		return nil, fmt.Errorf("persistence: error looking up accounts: %w", err)
+	}
+	var ids []string
+	for _, a := range accounts {
+		if a.LegalHold.Enabled {
+			ids = append(ids, a.AccountID)
+		}
+	}
+	return ids, nil
+}
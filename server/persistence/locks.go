@@ -0,0 +1,39 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// accountLockStripes is the number of mutexes account ids are distributed
+// across. Accounts sharing a stripe are serialized against each other, which
+// is acceptable in exchange for a fixed memory footprint.
+const accountLockStripes = 64
+
+// accountLocks serializes operations on the same account.
+type accountLocks [accountLockStripes]sync.Mutex
+
+func (a *accountLocks) lock(accountID string) func() {
+	h := fnv.New32a()
+	h.Write([]byte(accountID))
+	m := &a[h.Sum32()%accountLockStripes]
+	m.Lock()
+	return m.Unlock
+}
+
+// WithSerializedUserSecrets makes calls to AssociateUserSecret for the same
+// account wait for each other, so bursts of new users cannot exceed the
+// maximum number of users per account or contend on the same rows. This only
+// applies to calls within a single process.
+func WithSerializedUserSecrets(serialize bool) Config {
+	return func(p *persistenceLayer) {
+		if serialize {
+			p.accountLocks = &accountLocks{}
+		} else {
+			p.accountLocks = nil
+		}
+	}
+}
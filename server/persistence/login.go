@@ -17,11 +17,14 @@ import (
 func (p *persistenceLayer) Login(email, password string) (LoginResult, error) {
 	accountUser, err := p.findAccountUser(email, true, true)
 	if err != nil {
+		if errors.Is(err, errUnknownAccountUser) {
+			return LoginResult{}, fmt.Errorf("%w: %v", ErrBadCredentials, err)
+		}
 		return LoginResult{}, fmt.Errorf("persistence: error looking up account user: %w", err)
 	}
 
 	if err := keys.CompareString(password, accountUser.HashedPassword); err != nil {
-		return LoginResult{}, fmt.Errorf("persistence: error comparing passwords: %w", err)
+		return LoginResult{}, fmt.Errorf("%w: error comparing passwords: %v", ErrBadCredentials, err)
 	}
 
 	pwDerivedKey, pwDerivedKeyErr := keys.DeriveKey(password, accountUser.Salt)
@@ -299,5 +302,5 @@ func selectAccountUser(available []AccountUser, email string) (*AccountUser, err
 			return &user, nil
 		}
 	}
-	return nil, fmt.Errorf("persistence: no account user found for %s", email)
+	return nil, fmt.Errorf("persistence: no account user found for %s: %w", email, errUnknownAccountUser)
 }
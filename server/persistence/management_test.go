@@ -203,7 +203,7 @@ func TestPersistenceLayer_ShareAccount(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			p := persistenceLayer{test.dal}
+			p := persistenceLayer{dal: test.dal}
 			result, err := p.ShareAccount(test.invitee, test.email, test.password, test.accountID, true)
 
 			if test.expectErr != (err != nil) {
@@ -416,7 +416,7 @@ func TestPersistenceLayer_Join(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			p := &persistenceLayer{test.dal}
+			p := &persistenceLayer{dal: test.dal}
 			err := p.Join(test.emailArg, test.pwArg)
 			if test.expectError != (err != nil) {
 				t.Errorf("Unexpected error value: %v", err)
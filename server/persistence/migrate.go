@@ -3,8 +3,47 @@
 
 package persistence
 
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// migrationLockPollInterval is the time to wait before trying to acquire
+// the migration lock again in case another process is holding it.
+const migrationLockPollInterval = time.Second
+
 // Migrate runs the defined database migrations in the given db or initializes it
-// from the latest definition if it is still blank.
-func (p *persistenceLayer) Migrate() error {
-	return p.dal.ApplyMigrations()
+// from the latest definition if it is still blank. Migrations are guarded by a
+// lock, so processes calling Migrate concurrently wait for each other. In case
+// the lock cannot be acquired before the given context is done, an error is
+// returned.
+func (p *persistenceLayer) Migrate(ctx context.Context) error {
+	owner, err := NewULID()
+	if err != nil {
+		return fmt.Errorf("persistence: error creating migration lock owner: %w", err)
+	}
+	for {
+		acquired, err := p.dal.LockMigrations(owner)
+		if err != nil {
+			return fmt.Errorf("persistence: error acquiring migration lock: %w", err)
+		}
+		if acquired {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("persistence: gave up waiting for migration lock: %w", ctx.Err())
+		case <-time.After(migrationLockPollInterval):
+		}
+	}
+
+	migrateErr := p.dal.ApplyMigrations()
+	if err := p.dal.UnlockMigrations(owner); err != nil && migrateErr == nil {
+		return fmt.Errorf("persistence: error releasing migration lock: %w", err)
+	}
+	if migrateErr != nil {
+		return fmt.Errorf("persistence: error applying migrations: %w", migrateErr)
+	}
+	return nil
 }
@@ -4,24 +4,73 @@
 package persistence
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 )
 
 type mockMigrateDatabase struct {
 	DataAccessLayer
-	err error
+	err      error
+	lockedBy string
+	applied  bool
 }
 
 func (m *mockMigrateDatabase) ApplyMigrations() error {
+	m.applied = true
 	return m.err
 }
 
+func (m *mockMigrateDatabase) LockMigrations(owner string) (bool, error) {
+	if m.lockedBy != "" {
+		return false, nil
+	}
+	m.lockedBy = owner
+	return true, nil
+}
+
+func (m *mockMigrateDatabase) UnlockMigrations(owner string) error {
+	if m.lockedBy == owner {
+		m.lockedBy = ""
+	}
+	return nil
+}
+
 func TestPersistenceLayer_Migrate(t *testing.T) {
 	t.Run("error", func(t *testing.T) {
-		r := &persistenceLayer{dal: &mockMigrateDatabase{err: errors.New("did not work")}}
-		if err := r.Migrate(); err == nil {
+		db := &mockMigrateDatabase{err: errors.New("did not work")}
+		r := &persistenceLayer{dal: db}
+		if err := r.Migrate(context.Background()); err == nil {
 			t.Error("Expected error, got nil")
 		}
+		if db.lockedBy != "" {
+			t.Error("Expected lock to be released after failed migration")
+		}
+	})
+	t.Run("ok", func(t *testing.T) {
+		db := &mockMigrateDatabase{}
+		r := &persistenceLayer{dal: db}
+		if err := r.Migrate(context.Background()); err != nil {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if !db.applied {
+			t.Error("Expected migrations to be applied")
+		}
+		if db.lockedBy != "" {
+			t.Error("Expected lock to be released after migration")
+		}
+	})
+	t.Run("locked", func(t *testing.T) {
+		db := &mockMigrateDatabase{lockedBy: "other"}
+		r := &persistenceLayer{dal: db}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+		if err := r.Migrate(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected deadline to be exceeded, got %v", err)
+		}
+		if db.applied {
+			t.Error("Expected migrations not to be applied while locked")
+		}
 	})
 }
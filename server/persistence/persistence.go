@@ -4,6 +4,8 @@
 package persistence
 
 import (
+	"context"
+	"encoding/json"
 	"time"
 )
 
@@ -11,31 +13,63 @@ import (
 // layer. It does not make any assumptions about how data is being modelled
 // and stored.
 type Service interface {
-	Insert(userID, accountID, payload string, eventID *string) error
+	Insert(userID, accountID, payload, contentHash string, exempt bool, eventID *string) error
+	InsertSequenced(userID, accountID, payload, contentHash string, exempt bool, eventID *string) (int64, error)
+	InsertEvents(userID string, events []EventInput, sequenced bool) ([]int64, error)
+	SecretID(accountID, userID string) (string, error)
 	Query(Query) (EventsResult, error)
 	GetAccount(accountID string, styles, events bool, eventsSince string) (AccountResult, error)
-	CreateAccount(name, creatorEmailAddress, creatorPassword string) error
+	CreateAccount(name, creatorEmailAddress, creatorPassword string) (AccountResult, error)
 	RetireAccount(accountID string) error
 	AssociateUserSecret(accountID, userID, encryptedUserSecret string) error
 	Purge(userID string) error
+	Export(userID string) (UserExportResult, error)
+	ExportAccountEvents(accountID string, batchSize int, fn func([]EventResult) error) error
 	Login(email, password string) (LoginResult, error)
 	LookupAccountUser(userID string) (LoginResult, error)
+	CreateSession(accountUserID string, ttl time.Duration) (Session, error)
+	LookupSession(sessionID string) (LoginResult, error)
+	DeleteSession(sessionID string) error
 	ChangePassword(userID, currentPassword, changedPassword string) error
 	ChangeEmail(userID, emailAddress, emailCurrent, password string) error
 	GenerateOneTimeKey(emailAddress string) ([]byte, error)
 	ResetPassword(emailAddress, password string, oneTimeKey []byte) error
 	ShareAccount(inviteeEmailAddress, providerEmailAddress, providerPassword, accountID string, grantAdminPrivileges bool) (ShareAccountResult, error)
 	UpdateAccountStyles(accountID, styles string) error
+	GetAccountSettings(accountID string) (AccountSettings, error)
+	UpdateAccountSettings(accountID string, settings AccountSettings) error
+	GetEventSchema(accountID string) (EventSchema, error)
+	UpdateEventSchema(accountID string, schema json.RawMessage) (EventSchema, error)
+	GetLegalHold(accountID string) (LegalHold, error)
+	UpdateLegalHold(accountID, accountUserID string, enabled bool) (LegalHold, error)
+	GetAccountRetention(accountID string) (time.Duration, error)
+	SetAccountRetention(accountID string, d time.Duration) error
+	GetIngestPause(accountID string) (IngestPause, error)
+	UpdateIngestPause(accountID, accountUserID string, paused bool) (IngestPause, error)
+	EmailSender(emailAddress string) (string, error)
+	AccountStorageBytes(accountID string) (int64, error)
+	AccountEventsPerDay(accountID string) (map[string]int, error)
 	Join(emailAddress, password string) error
-	Expire(retention time.Duration) (int, error)
+	CreateInvite(createdBy string, ttl time.Duration) (Invite, error)
+	RedeemInvite(inviteID, accountName, emailAddress, password string) error
+	ConsentAudit(accountID string) ([]ConsentAuditEntry, error)
+	Expire(ctx context.Context, retention time.Duration) (PurgeResult, error)
 	Bootstrap(data BootstrapConfig) error
 	ProbeEmpty() bool
 	CheckHealth() error
-	Migrate() error
+	Close() error
+	Migrate(ctx context.Context) error
 }
 
 type persistenceLayer struct {
-	dal DataAccessLayer
+	dal                DataAccessLayer
+	expireThreshold    int
+	maxUsersPerAccount int
+	expireBatchSize    int
+	envelope           SecretEnvelope
+	accountLocks       *accountLocks
+	consentAudit       bool
+	secureDelete       bool
 }
 
 // New creates a persistence service that connects to any database using
@@ -50,3 +84,42 @@ func New(dal DataAccessLayer, configs ...Config) (Service, error) {
 
 // Config is a function that adds a configuration option to the constructor
 type Config func(*persistenceLayer)
+
+// WithExpireThreshold sets the number of events a single call to Expire is
+// expected to delete at most. In case more events are deleted, Expire will
+// signal this by returning ErrExpireThresholdExceeded. A value of 0 disables
+// the check.
+func WithExpireThreshold(n int) Config {
+	return func(p *persistenceLayer) {
+		p.expireThreshold = n
+	}
+}
+
+// WithExpireBatchSize makes Expire delete expired events in batches of the
+// given size, each using its own transaction. A value of 0 deletes all
+// expired events in a single transaction.
+func WithExpireBatchSize(n int) Config {
+	return func(p *persistenceLayer) {
+		p.expireBatchSize = n
+	}
+}
+
+// WithSecureDelete makes Expire overwrite the payloads of expired events with
+// random data before deleting them, so they cannot be recovered from pages
+// the database has freed but not yet reused. This requires an additional
+// write for each expired event, making expiry considerably slower.
+func WithSecureDelete(enabled bool) Config {
+	return func(p *persistenceLayer) {
+		p.secureDelete = enabled
+	}
+}
+
+// WithMaxUsersPerAccount sets the maximum number of user secrets that can be
+// associated with a single account. Once the limit is reached, associating
+// secrets for new users returns ErrMaxUsersExceeded. A value of 0 disables
+// the limit.
+func WithMaxUsersPerAccount(n int) Config {
+	return func(p *persistenceLayer) {
+		p.maxUsersPerAccount = n
+	}
+}
@@ -16,6 +16,9 @@ func (r *relationalDAL) CreateAccount(a *persistence.Account) error {
 	if err := r.db.Create(&local).Error; err != nil {
 		return fmt.Errorf("relational: error creating account: %w", err)
 	}
+	if r.partitions != nil {
+		r.partitions.invalidate()
+	}
 	return nil
 }
 
@@ -24,6 +27,9 @@ func (r *relationalDAL) UpdateAccount(a *persistence.Account) error {
 	if err := r.db.Save(&local).Error; err != nil {
 		return fmt.Errorf("relational: error saving account: %w", err)
 	}
+	if r.partitions != nil && local.Retired {
+		r.partitions.invalidate()
+	}
 	return nil
 }
 
@@ -38,23 +44,28 @@ func (r *relationalDAL) FindAccount(q interface{}) (persistence.Account, error)
 			return account.export(), fmt.Errorf(`relational: error looking up account with id %s: %w`, query.AccountID, err)
 		}
 		var limit int = 500
-		var offset int
 		var events []Event
-		queryDB := r.db.Preload("Secret").Limit(limit)
-		for {
-			var nextEvents []Event
-			var found int64
-			queryDB = queryDB.Offset(offset)
-			if query.Since == "" {
-				found = queryDB.Find(&nextEvents, "account_id = ?", query.AccountID).RowsAffected
-			} else {
-				found = queryDB.Find(&nextEvents, "account_id = ? AND event_id > ?", query.AccountID, query.Since).RowsAffected
-			}
-			events = append(events, nextEvents...)
-			if int(found) < limit {
-				break
+		if err := r.eachEventTable(func(db *gorm.DB) error {
+			var offset int
+			queryDB := db.Preload("Secret").Limit(limit)
+			for {
+				var nextEvents []Event
+				var found int64
+				queryDB = queryDB.Offset(offset)
+				if query.Since == "" {
+					found = queryDB.Find(&nextEvents, "account_id = ?", query.AccountID).RowsAffected
+				} else {
+					found = queryDB.Find(&nextEvents, "account_id = ? AND event_id > ?", query.AccountID, query.Since).RowsAffected
+				}
+				events = append(events, nextEvents...)
+				if int(found) < limit {
+					break
+				}
+				offset += limit
 			}
-			offset += limit
+			return nil
+		}, query.AccountID); err != nil {
+			return account.export(), fmt.Errorf("relational: error looking up events for account %s: %w", query.AccountID, err)
 		}
 		account.Events = events
 		return account.export(), nil
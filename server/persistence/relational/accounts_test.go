@@ -399,3 +399,47 @@ func TestRelationalDAL_FindAccounts(t *testing.T) {
 		})
 	}
 }
+
+func TestRetireAccount(t *testing.T) {
+	db, closeDB := createTestDatabase()
+	defer closeDB()
+
+	for _, accountID := range []string{"account-a", "account-b"} {
+		if err := db.Create(&Account{AccountID: accountID, Name: accountID}).Error; err != nil {
+			t.Fatalf("Unexpected error creating account: %v", err)
+		}
+		secretID := fmt.Sprintf("%s-secret", accountID)
+		if err := db.Create(&Secret{SecretID: secretID, AccountID: accountID}).Error; err != nil {
+			t.Fatalf("Unexpected error creating secret: %v", err)
+		}
+		if err := db.Create(&Event{EventID: fmt.Sprintf("%s-event", accountID), AccountID: accountID, SecretID: &secretID}).Error; err != nil {
+			t.Fatalf("Unexpected error creating event: %v", err)
+		}
+	}
+
+	p, _ := persistence.New(NewRelationalDAL(db))
+	if err := p.RetireAccount("account-a"); err != nil {
+		t.Fatalf("Unexpected error retiring account: %v", err)
+	}
+
+	var account Account
+	if err := db.Where("account_id = ?", "account-a").First(&account).Error; err != nil {
+		t.Fatalf("Unexpected error looking up account: %v", err)
+	}
+	if !account.Retired {
+		t.Error("Expected account to be retired")
+	}
+	for accountID, expected := range map[string]int64{"account-a": 0, "account-b": 1} {
+		var events, secrets int64
+		db.Model(&Event{}).Where("account_id = ?", accountID).Count(&events)
+		db.Model(&Secret{}).Where("account_id = ?", accountID).Count(&secrets)
+		if events != expected || secrets != expected {
+			t.Errorf("Expected %d events and secrets for %s, got %d and %d", expected, accountID, events, secrets)
+		}
+	}
+
+	var unknown persistence.ErrUnknownAccount
+	if err := p.RetireAccount("account-a"); !errors.As(err, &unknown) {
+		t.Errorf("Expected ErrUnknownAccount when retiring account twice, got %v", err)
+	}
+}
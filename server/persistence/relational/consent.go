@@ -0,0 +1,35 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package relational
+
+import (
+	"fmt"
+
+	"github.com/offen/offen/server/persistence"
+)
+
+func (r *relationalDAL) CreateConsentAuditEntry(e *persistence.ConsentAuditEntry) error {
+	local := importConsentAuditEntry(e)
+	if err := r.db.Create(&local).Error; err != nil {
+		return fmt.Errorf("relational: error creating consent audit entry: %w", err)
+	}
+	return nil
+}
+
+func (r *relationalDAL) FindConsentAuditEntries(q interface{}) ([]persistence.ConsentAuditEntry, error) {
+	switch query := q.(type) {
+	case persistence.FindConsentAuditEntriesQueryByAccountID:
+		var entries []ConsentAuditEntry
+		if err := r.db.Where("account_id = ?", string(query)).Order("entry_id").Find(&entries).Error; err != nil {
+			return nil, fmt.Errorf("relational: error looking up consent audit entries: %w", err)
+		}
+		result := []persistence.ConsentAuditEntry{}
+		for _, e := range entries {
+			result = append(result, e.export())
+		}
+		return result, nil
+	default:
+		return nil, persistence.ErrBadQuery
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package relational
+
+import (
+	"testing"
+
+	"github.com/offen/offen/server/keys"
+	"github.com/offen/offen/server/persistence"
+)
+
+func TestConsentAudit(t *testing.T) {
+	db, dbClose := createTestDatabase()
+	defer dbClose()
+
+	for _, accountID := range []string{"account-a", "account-b"} {
+		salt, err := keys.NewFastSalt(keys.DefaultSecretLength)
+		if err != nil {
+			t.Fatalf("Unexpected error creating salt: %v", err)
+		}
+		if err := db.Create(&Account{AccountID: accountID, UserSalt: salt.Marshal()}).Error; err != nil {
+			t.Fatalf("Unexpected error creating account: %v", err)
+		}
+	}
+
+	p, _ := persistence.New(NewRelationalDAL(db), persistence.WithConsentAudit(true))
+	if err := p.AssociateUserSecret("account-a", "user-a", "secret"); err != nil {
+		t.Fatalf("Unexpected error opting in: %v", err)
+	}
+	if err := p.Purge("user-a"); err != nil {
+		t.Fatalf("Unexpected error opting out: %v", err)
+	}
+
+	entries, err := p.ConsentAudit("account-a")
+	if err != nil {
+		t.Fatalf("Unexpected error exporting consent audit: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %v", entries)
+	}
+	if entries[0].Action != persistence.ConsentActionOptIn || entries[1].Action != persistence.ConsentActionOptOut {
+		t.Errorf("Unexpected actions %s and %s", entries[0].Action, entries[1].Action)
+	}
+	if entries[0].HashedUserID == "user-a" || entries[0].HashedUserID != entries[1].HashedUserID {
+		t.Errorf("Expected entries to use the same hashed user id, got %s and %s", entries[0].HashedUserID, entries[1].HashedUserID)
+	}
+
+	other, err := p.ConsentAudit("account-b")
+	if err != nil {
+		t.Fatalf("Unexpected error exporting consent audit: %v", err)
+	}
+	if len(other) != 0 {
+		t.Errorf("Expected no entries for account without opt in, got %v", other)
+	}
+
+	if _, err := NewRelationalDAL(db).FindConsentAuditEntries("account-a"); err != persistence.ErrBadQuery {
+		t.Errorf("Expected ErrBadQuery, got %v", err)
+	}
+}
@@ -5,13 +5,20 @@ package relational
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/offen/offen/server/persistence"
+	"gorm.io/gorm"
 )
 
 func (r *relationalDAL) CreateEvent(e *persistence.Event) error {
 	local := importEvent(e)
-	if err := r.db.Create(&local).Error; err != nil {
+	table, err := r.eventTableFor(e.AccountID)
+	if err != nil {
+		return fmt.Errorf("relational: error creating event: %w", err)
+	}
+	if err := r.db.Table(table).Create(&local).Error; err != nil {
 		return fmt.Errorf("relational: error creating event: %w", err)
 	}
 	return nil
@@ -29,11 +36,79 @@ func (r *relationalDAL) FindEvents(q interface{}) ([]persistence.Event, error) {
 	var events []Event
 	switch query := q.(type) {
 	case persistence.FindEventsQueryOlderThan:
-		if err := r.db.Find(&events, "event_id < ?", query).Error; err != nil {
+		if err := r.eachEventTable(func(db *gorm.DB) error {
+			var nextEvents []Event
+			if err := db.Find(&nextEvents, "event_id < ? AND exempt = ?", string(query), false).Error; err != nil {
+				return err
+			}
+			events = append(events, nextEvents...)
+			return nil
+		}); err != nil {
 			return nil, fmt.Errorf("relational: error looking up events by age: %w", err)
 		}
 		return exportEvents(events), nil
+	case persistence.FindEventsQueryExpiredBatch:
+		var accountIDs []string
+		if query.AccountID != "" {
+			accountIDs = append(accountIDs, query.AccountID)
+		}
+		if err := r.eachEventTable(func(db *gorm.DB) error {
+			var nextEvents []Event
+			find := db.Where("event_id < ? AND exempt = ?", query.EventID, false)
+			if len(query.ExcludeAccountIDs) != 0 {
+				find = find.Where("account_id NOT IN (?)", query.ExcludeAccountIDs)
+			}
+			if query.AccountID != "" {
+				find = find.Where("account_id = ?", query.AccountID)
+			}
+			if query.Limit > 0 {
+				find = find.Order("event_id").Limit(query.Limit)
+			}
+			if err := find.Find(&nextEvents).Error; err != nil {
+				return err
+			}
+			events = append(events, nextEvents...)
+			return nil
+		}, accountIDs...); err != nil {
+			return nil, fmt.Errorf("relational: error looking up batch of expired events: %w", err)
+		}
+		if query.Limit > 0 && len(events) > query.Limit {
+			sort.Slice(events, func(i, j int) bool {
+				return events[i].EventID < events[j].EventID
+			})
+			events = events[:query.Limit]
+		}
+		return exportEvents(events), nil
+	case persistence.FindEventsQueryByAccountIDPage:
+		if err := r.eachEventTable(func(db *gorm.DB) error {
+			var nextEvents []Event
+			if err := db.
+				Where("account_id = ? AND event_id > ?", query.AccountID, query.After).
+				Order("event_id").
+				Limit(query.Limit).
+				Find(&nextEvents).Error; err != nil {
+				return err
+			}
+			events = append(events, nextEvents...)
+			return nil
+		}, query.AccountID); err != nil {
+			return nil, fmt.Errorf("relational: error looking up page of account events: %w", err)
+		}
+		sort.Slice(events, func(i, j int) bool {
+			return events[i].EventID < events[j].EventID
+		})
+		if len(events) > query.Limit {
+			events = events[:query.Limit]
+		}
+		return exportEvents(events), nil
 	case persistence.FindEventsQueryForSecretIDs:
+		if query.MaxBytes > 0 {
+			events, err := r.findEventsUpToSize(query)
+			if err != nil {
+				return nil, fmt.Errorf("relational: error looking up events: %w", err)
+			}
+			return exportEvents(events), nil
+		}
 		var eventConditions []interface{}
 		if query.Since != "" {
 			eventConditions = []interface{}{
@@ -47,59 +122,266 @@ func (r *relationalDAL) FindEvents(q interface{}) ([]persistence.Event, error) {
 			}
 		}
 
-		if err := r.db.Find(&events, eventConditions...).Error; err != nil {
+		if err := r.eachEventTable(func(db *gorm.DB) error {
+			var nextEvents []Event
+			find := db
+			if query.Limit > 0 {
+				find = find.Order("sequence").Limit(query.Limit)
+			}
+			if err := find.Find(&nextEvents, eventConditions...).Error; err != nil {
+				return err
+			}
+			events = append(events, nextEvents...)
+			return nil
+		}); err != nil {
 			return nil, fmt.Errorf("default: error looking up events: %w", err)
 		}
+		if query.Limit > 0 && len(events) > query.Limit {
+			sort.Slice(events, func(i, j int) bool {
+				return events[i].Sequence < events[j].Sequence
+			})
+			events = events[:query.Limit]
+		}
 		return exportEvents(events), nil
 	case persistence.FindEventsQueryByEventIDs:
 		var limit int64 = 500
-		var offset int64
+		if err := r.eachEventTable(func(db *gorm.DB) error {
+			var offset int64
+			for {
+				var nextEvents []Event
+				var chunk []string
+				if int64(len(query)) > offset+limit {
+					chunk = query[offset : offset+limit]
+				} else {
+					chunk = query[offset:]
+				}
+				if err := db.Where("event_id IN (?)", chunk).Find(&nextEvents).Error; err != nil {
+					return err
+				}
+				events = append(events, nextEvents...)
+				if int64(len(chunk)) < limit {
+					break
+				}
+				offset += limit
+			}
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("relational: error looking up events: %w", err)
+		}
+		return exportEvents(events), nil
+	default:
+		return nil, persistence.ErrBadQuery
+	}
+}
+
+// eventsBatchSize is the number of events that are read at once when
+// reading events until a size limit is reached.
+const eventsBatchSize = 100
+
+// findEventsUpToSize reads the events matching the given query in order of
+// their sequence, stopping at the first event that makes their payloads
+// exceed query.MaxBytes. As events of different tables are only merged
+// afterwards, tables read later are only read up to the sequence at which
+// earlier tables have already reached the limit.
+func (r *relationalDAL) findEventsUpToSize(query persistence.FindEventsQueryForSecretIDs) ([]Event, error) {
+	batchSize := eventsBatchSize
+	if query.Limit > 0 && query.Limit < batchSize {
+		batchSize = query.Limit
+	}
+	var events []Event
+	var until string
+	if err := r.eachEventTable(func(db *gorm.DB) error {
+		after := query.Since
+		var size, count int
 		for {
+			find := db.Where("secret_id in (?)", query.SecretIDs)
+			if after != "" {
+				find = find.Where("sequence > ?", after)
+			}
+			if until != "" {
+				find = find.Where("sequence <= ?", until)
+			}
 			var nextEvents []Event
-			var chunk []string
-			if int64(len(query)) > offset+limit {
-				chunk = query[offset : offset+limit]
-			} else {
-				chunk = query[offset:]
+			if err := find.Order("sequence").Limit(batchSize).Find(&nextEvents).Error; err != nil {
+				return err
 			}
-			if err := r.db.Where("event_id IN (?)", chunk).Find(&nextEvents).Error; err != nil {
-				return nil, fmt.Errorf("relational: error looking up events: %w", err)
+			for _, evt := range nextEvents {
+				events = append(events, evt)
+				size += len(evt.Payload)
+				count++
+				if size > query.MaxBytes || count == query.Limit {
+					until = evt.Sequence
+					return nil
+				}
 			}
-			events = append(events, nextEvents...)
-			if int64(len(chunk)) < limit {
-				break
+			if len(nextEvents) < batchSize {
+				return nil
 			}
-			offset += limit
+			after = nextEvents[len(nextEvents)-1].Sequence
 		}
-		return exportEvents(events), nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Sequence < events[j].Sequence
+	})
+	var size int
+	for i, evt := range events {
+		size += len(evt.Payload)
+		if size > query.MaxBytes {
+			events = events[:i+1]
+			break
+		}
+	}
+	if query.Limit > 0 && len(events) > query.Limit {
+		events = events[:query.Limit]
+	}
+	return events, nil
+}
+
+func (r *relationalDAL) FindEventIDs(q interface{}) ([]string, error) {
+	switch query := q.(type) {
+	case persistence.FindEventIDsQueryByAccountID:
+		var eventIDs []string
+		if err := r.eachEventTable(func(db *gorm.DB) error {
+			var nextIDs []string
+			if err := db.Where("account_id = ?", string(query)).Pluck("event_id", &nextIDs).Error; err != nil {
+				return err
+			}
+			eventIDs = append(eventIDs, nextIDs...)
+			return nil
+		}, string(query)); err != nil {
+			return nil, fmt.Errorf("relational: error looking up event ids: %w", err)
+		}
+		return eventIDs, nil
+	case persistence.FindEventIDsQueryByIDPrefixes:
+		eventIDs := []string{}
+		if len(query.Prefixes) == 0 {
+			return eventIDs, nil
+		}
+		var args []interface{}
+		for _, prefix := range query.Prefixes {
+			args = append(args, prefix+"%")
+		}
+		matchPrefix := strings.TrimSuffix(strings.Repeat("event_id LIKE ? OR ", len(args)), " OR ")
+		if err := r.eachEventTable(func(db *gorm.DB) error {
+			var nextIDs []string
+			if err := db.Where("account_id = ?", query.AccountID).Where(matchPrefix, args...).Pluck("event_id", &nextIDs).Error; err != nil {
+				return err
+			}
+			eventIDs = append(eventIDs, nextIDs...)
+			return nil
+		}, query.AccountID); err != nil {
+			return nil, fmt.Errorf("relational: error looking up event ids by prefix: %w", err)
+		}
+		return eventIDs, nil
 	default:
 		return nil, persistence.ErrBadQuery
 	}
 }
 
+func (r *relationalDAL) CountEvents(q interface{}) (map[string]int64, error) {
+	switch query := q.(type) {
+	case persistence.CountEventsQueryByIDPrefix:
+		result := map[string]int64{}
+		prefix := fmt.Sprintf("SUBSTR(event_id, 1, %d)", query.PrefixLength)
+		if err := r.eachEventTable(func(db *gorm.DB) error {
+			var rows []struct {
+				Prefix string
+				Count  int64
+			}
+			if err := db.
+				Select(prefix+" AS prefix, COUNT(*) AS count").
+				Where("account_id = ?", query.AccountID).
+				Group(prefix).
+				Scan(&rows).Error; err != nil {
+				return err
+			}
+			for _, row := range rows {
+				result[row.Prefix] += row.Count
+			}
+			return nil
+		}, query.AccountID); err != nil {
+			return nil, fmt.Errorf("relational: error counting events: %w", err)
+		}
+		return result, nil
+	default:
+		return nil, persistence.ErrBadQuery
+	}
+}
+
+// UpdateEventPayload replaces the stored payload of the given event with
+// the event's payload.
+func (r *relationalDAL) UpdateEventPayload(evt *persistence.Event) error {
+	if err := r.eachEventTable(func(db *gorm.DB) error {
+		return db.Where("event_id = ?", evt.EventID).UpdateColumn("payload", evt.Payload).Error
+	}, evt.AccountID); err != nil {
+		return fmt.Errorf("relational: error updating payload of event %s: %w", evt.EventID, err)
+	}
+	return nil
+}
+
+// deleteEvents runs the given deletion against all event tables, returning
+// the total number of rows that have been deleted.
+func (r *relationalDAL) deleteEvents(deletion func(db *gorm.DB) *gorm.DB) (int64, error) {
+	var affected int64
+	err := r.eachEventTable(func(db *gorm.DB) error {
+		result := deletion(db).Delete(&Event{})
+		if err := result.Error; err != nil {
+			return err
+		}
+		affected += result.RowsAffected
+		return nil
+	})
+	return affected, err
+}
+
 func (r *relationalDAL) DeleteEvents(q interface{}) (int64, error) {
 	switch query := q.(type) {
 	case persistence.DeleteEventsQueryByEventIDs:
-		deletion := r.db.Where("event_id in (?)", []string(query)).Delete(&Event{})
-		if err := deletion.Error; err != nil {
+		affected, err := r.deleteEvents(func(db *gorm.DB) *gorm.DB {
+			return db.Where("event_id in (?)", []string(query))
+		})
+		if err != nil {
 			return 0, fmt.Errorf("relational: error deleting events by event id: %w", err)
 		}
-		return deletion.RowsAffected, nil
+		return affected, nil
 	case persistence.DeleteEventsQueryBySecretIDs:
-		deletion := r.db.Where(
-			"secret_id IN (?)",
-			[]string(query),
-		).Delete(&Event{})
-		if err := deletion.Error; err != nil {
+		affected, err := r.deleteEvents(func(db *gorm.DB) *gorm.DB {
+			return db.Where("secret_id IN (?)", []string(query))
+		})
+		if err != nil {
 			return 0, fmt.Errorf("relational: error deleting events: %w", err)
 		}
-		return deletion.RowsAffected, nil
+		return affected, nil
+	case persistence.DeleteEventsQueryByAccountID:
+		affected, err := r.deleteEvents(func(db *gorm.DB) *gorm.DB {
+			return db.Where("account_id = ?", string(query))
+		})
+		if err != nil {
+			return 0, fmt.Errorf("relational: error deleting events by account id: %w", err)
+		}
+		return affected, nil
 	case persistence.DeleteEventsQueryOlderThan:
-		deletion := r.db.Where("event_id < ?", query).Delete(&Event{})
-		if err := deletion.Error; err != nil {
+		affected, err := r.deleteEvents(func(db *gorm.DB) *gorm.DB {
+			return db.Where("event_id < ? AND exempt = ?", string(query), false)
+		})
+		if err != nil {
+			return 0, fmt.Errorf("relational: error deleting events: %w", err)
+		}
+		return affected, nil
+	case persistence.DeleteEventsQueryExpired:
+		affected, err := r.deleteEvents(func(db *gorm.DB) *gorm.DB {
+			deletion := db.Where("event_id < ? AND exempt = ?", query.EventID, false)
+			if len(query.ExcludeAccountIDs) != 0 {
+				deletion = deletion.Where("account_id NOT IN (?)", query.ExcludeAccountIDs)
+			}
+			return deletion
+		})
+		if err != nil {
 			return 0, fmt.Errorf("relational: error deleting events: %w", err)
 		}
-		return deletion.RowsAffected, nil
+		return affected, nil
 	default:
 		return 0, persistence.ErrBadQuery
 	}
@@ -255,3 +255,275 @@ func TestRelationalDAL_DeleteEvents(t *testing.T) {
 		})
 	}
 }
+
+func TestRelationalDAL_FindEventIDs(t *testing.T) {
+	db, closeDB := createTestDatabase()
+	defer closeDB()
+
+	dal := NewRelationalDAL(db)
+	for _, e := range []persistence.Event{
+		{EventID: "event-a", AccountID: "account-a"},
+		{EventID: "event-b", AccountID: "account-b"},
+		{EventID: "event-c", AccountID: "account-a"},
+	} {
+		if err := dal.CreateEvent(&e); err != nil {
+			t.Fatalf("Unexpected error creating event: %v", err)
+		}
+	}
+
+	result, err := dal.FindEventIDs(persistence.FindEventIDsQueryByAccountID("account-a"))
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(result, []string{"event-a", "event-c"}) {
+		t.Errorf("Unexpected result %v", result)
+	}
+
+	if _, err := dal.FindEventIDs("account-a"); err != persistence.ErrBadQuery {
+		t.Errorf("Expected ErrBadQuery, got %v", err)
+	}
+
+	result, err = dal.FindEventIDs(persistence.FindEventIDsQueryByIDPrefixes{
+		AccountID: "account-a",
+		Prefixes:  []string{"event-c", "event-b"},
+	})
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(result, []string{"event-c"}) {
+		t.Errorf("Unexpected result %v", result)
+	}
+}
+
+func TestRelationalDAL_CountEvents(t *testing.T) {
+	for _, partitions := range []bool{false, true} {
+		t.Run(fmt.Sprintf("partitions %v", partitions), func(t *testing.T) {
+			db, closeDB := createTestDatabase()
+			defer closeDB()
+
+			dal := NewRelationalDAL(db, WithEventPartitions(partitions))
+			for _, e := range []persistence.Event{
+				{EventID: "aaa-1", AccountID: "account-a"},
+				{EventID: "aaa-2", AccountID: "account-a"},
+				{EventID: "aab-1", AccountID: "account-a"},
+				{EventID: "aaa-3", AccountID: "account-b"},
+			} {
+				if err := dal.CreateEvent(&e); err != nil {
+					t.Fatalf("Unexpected error creating event: %v", err)
+				}
+			}
+
+			result, err := dal.CountEvents(persistence.CountEventsQueryByIDPrefix{
+				AccountID:    "account-a",
+				PrefixLength: 3,
+			})
+			if err != nil {
+				t.Errorf("Unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(result, map[string]int64{"aaa": 2, "aab": 1}) {
+				t.Errorf("Unexpected result %v", result)
+			}
+
+			if _, err := dal.CountEvents("account-a"); err != persistence.ErrBadQuery {
+				t.Errorf("Expected ErrBadQuery, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRelationalDAL_ExpiryExemption(t *testing.T) {
+	db, dbClose := createTestDatabase()
+	defer dbClose()
+	dal := NewRelationalDAL(db)
+
+	for _, evt := range []persistence.Event{
+		{EventID: "event-a", AccountID: "account-a", Payload: "expired"},
+		{EventID: "event-b", AccountID: "account-a", Payload: "exempt", Exempt: true},
+		{EventID: "event-z", AccountID: "account-a", Payload: "recent"},
+	} {
+		if err := dal.CreateEvent(&evt); err != nil {
+			t.Fatalf("Unexpected error creating event: %v", err)
+		}
+	}
+
+	expired, err := dal.FindEvents(persistence.FindEventsQueryOlderThan("event-y"))
+	if err != nil {
+		t.Fatalf("Unexpected error finding events: %v", err)
+	}
+	if len(expired) != 1 || expired[0].EventID != "event-a" {
+		t.Errorf("Unexpected expired events %v", expired)
+	}
+
+	batch, err := dal.FindEvents(persistence.FindEventsQueryExpiredBatch{EventID: "event-zz", Limit: 1})
+	if err != nil {
+		t.Fatalf("Unexpected error finding batch of events: %v", err)
+	}
+	if len(batch) != 1 || batch[0].EventID != "event-a" {
+		t.Errorf("Unexpected batch of expired events %v", batch)
+	}
+
+	affected, err := dal.DeleteEvents(persistence.DeleteEventsQueryOlderThan("event-y"))
+	if err != nil {
+		t.Fatalf("Unexpected error deleting events: %v", err)
+	}
+	if affected != 1 {
+		t.Errorf("Expected 1 deleted event, got %d", affected)
+	}
+
+	var remaining []string
+	if err := db.Model(&Event{}).Order("event_id").Pluck("event_id", &remaining).Error; err != nil {
+		t.Fatalf("Unexpected error looking up remaining events: %v", err)
+	}
+	if !reflect.DeepEqual([]string{"event-b", "event-z"}, remaining) {
+		t.Errorf("Unexpected remaining events %v", remaining)
+	}
+}
+
+func TestRelationalDAL_FindEvents_Paginated(t *testing.T) {
+	db, dbClose := createTestDatabase()
+	defer dbClose()
+
+	secretID := "secret-a"
+	for i, sequence := range []string{"sequence-e", "sequence-b", "sequence-d", "sequence-a", "sequence-c"} {
+		if err := db.Create(&Event{
+			EventID:   fmt.Sprintf("event-%d", i),
+			Sequence:  sequence,
+			AccountID: "account-a",
+			SecretID:  &secretID,
+		}).Error; err != nil {
+			t.Fatalf("Unexpected error creating event: %v", err)
+		}
+	}
+	if err := db.Create(&Tombstone{EventID: "event-x", SecretID: &secretID, Sequence: "sequence-cc"}).Error; err != nil {
+		t.Fatalf("Unexpected error creating tombstone: %v", err)
+	}
+
+	dal := NewRelationalDAL(db)
+	var sequences []string
+	since := "sequence-"
+	for {
+		page, err := dal.FindEvents(persistence.FindEventsQueryForSecretIDs{
+			SecretIDs: []string{secretID},
+			Since:     since,
+			Limit:     2,
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		for _, evt := range page {
+			sequences = append(sequences, evt.Sequence)
+		}
+		if len(page) < 2 {
+			break
+		}
+		since = page[len(page)-1].Sequence
+	}
+	if !reflect.DeepEqual([]string{"sequence-a", "sequence-b", "sequence-c", "sequence-d", "sequence-e"}, sequences) {
+		t.Errorf("Unexpected sequences %v", sequences)
+	}
+
+	tombstones, err := dal.FindTombstones(persistence.FindTombstonesQueryBySecrets{
+		SecretIDs: []string{secretID},
+		Since:     "sequence-a",
+		Until:     "sequence-c",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(tombstones) != 0 {
+		t.Errorf("Expected tombstone newer than Until to be skipped, got %v", tombstones)
+	}
+}
+
+func TestRelationalDAL_FindEvents_MaxBytes(t *testing.T) {
+	db, dbClose := createTestDatabase()
+	defer dbClose()
+
+	for _, accountID := range []string{"account-a", "account-b"} {
+		if err := db.Create(&Account{AccountID: accountID}).Error; err != nil {
+			t.Fatalf("Unexpected error creating account: %v", err)
+		}
+	}
+	dal := NewRelationalDAL(db, WithEventPartitions(true))
+	secretID := "secret-a"
+	for i, evt := range []struct {
+		accountID string
+		sequence  string
+	}{
+		{"account-a", "sequence-a"},
+		{"account-b", "sequence-b"},
+		{"account-a", "sequence-c"},
+		{"account-b", "sequence-d"},
+		{"account-a", "sequence-e"},
+	} {
+		if err := dal.CreateEvent(&persistence.Event{
+			EventID:   fmt.Sprintf("event-%d", i),
+			Sequence:  evt.sequence,
+			AccountID: evt.accountID,
+			SecretID:  &secretID,
+			Payload:   "1234",
+		}); err != nil {
+			t.Fatalf("Unexpected error creating event: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name              string
+		since             string
+		limit             int
+		maxBytes          int
+		expectedSequences []string
+	}{
+		{"cut off", "", 0, 10, []string{"sequence-a", "sequence-b", "sequence-c"}},
+		{"since", "sequence-b", 0, 4, []string{"sequence-c", "sequence-d"}},
+		{"limit", "", 2, 100, []string{"sequence-a", "sequence-b"}},
+		{"all", "", 0, 100, []string{"sequence-a", "sequence-b", "sequence-c", "sequence-d", "sequence-e"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := dal.FindEvents(persistence.FindEventsQueryForSecretIDs{
+				SecretIDs: []string{secretID},
+				Since:     test.since,
+				Limit:     test.limit,
+				MaxBytes:  test.maxBytes,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			var sequences []string
+			for _, evt := range result {
+				sequences = append(sequences, evt.Sequence)
+			}
+			if !reflect.DeepEqual(test.expectedSequences, sequences) {
+				t.Errorf("Unexpected sequences %v", sequences)
+			}
+		})
+	}
+}
+
+func TestRelationalDAL_UpdateEventPayload(t *testing.T) {
+	db, dbClose := createTestDatabase()
+	defer dbClose()
+	dal := NewRelationalDAL(db)
+
+	for _, evt := range []persistence.Event{
+		{EventID: "event-a", AccountID: "account-a", Payload: "payload-a"},
+		{EventID: "event-b", AccountID: "account-a", Payload: "payload-b"},
+	} {
+		if err := dal.CreateEvent(&evt); err != nil {
+			t.Fatalf("Unexpected error creating event: %v", err)
+		}
+	}
+
+	if err := dal.UpdateEventPayload(&persistence.Event{EventID: "event-a", AccountID: "account-a", Payload: "overwrite"}); err != nil {
+		t.Fatalf("Unexpected error updating payload: %v", err)
+	}
+
+	var payloads []string
+	if err := db.Model(&Event{}).Order("event_id").Pluck("payload", &payloads).Error; err != nil {
+		t.Fatalf("Unexpected error looking up payloads: %v", err)
+	}
+	if !reflect.DeepEqual([]string{"overwrite", "payload-b"}, payloads) {
+		t.Errorf("Unexpected payloads %v", payloads)
+	}
+}
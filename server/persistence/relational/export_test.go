@@ -0,0 +1,122 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package relational
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/offen/offen/server/keys"
+	"github.com/offen/offen/server/persistence"
+)
+
+func TestExport(t *testing.T) {
+	db, dbClose := createTestDatabase()
+	defer dbClose()
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+
+	for _, accountID := range []string{"account-a", "account-b"} {
+		salt, err := keys.NewFastSalt(keys.DefaultSecretLength)
+		if err != nil {
+			t.Fatalf("Unexpected error creating salt: %v", err)
+		}
+		if err := db.Create(&Account{AccountID: accountID, UserSalt: salt.Marshal()}).Error; err != nil {
+			t.Fatalf("Unexpected error creating account: %v", err)
+		}
+	}
+
+	p, _ := persistence.New(NewRelationalDAL(db))
+	for _, userID := range []string{"user-a", "user-b"} {
+		if err := p.AssociateUserSecret("account-a", userID, "secret-"+userID); err != nil {
+			t.Fatalf("Unexpected error creating user: %v", err)
+		}
+		if err := p.Insert(userID, "account-a", "payload-"+userID, "", false, nil); err != nil {
+			t.Fatalf("Unexpected error inserting event: %v", err)
+		}
+	}
+
+	result, err := p.Export("user-a")
+	if err != nil {
+		t.Fatalf("Unexpected error exporting user data: %v", err)
+	}
+	if len(result.Events) != 1 || len(result.Events["account-a"]) != 1 {
+		t.Fatalf("Unexpected events %v", result.Events)
+	}
+	if evt := result.Events["account-a"][0]; evt.Payload != "payload-user-a" {
+		t.Errorf("Unexpected event payload %q", evt.Payload)
+	}
+	if len(result.Secrets) != 1 || result.Secrets[0].EncryptedSecret != "secret-user-a" {
+		t.Errorf("Unexpected secrets %v", result.Secrets)
+	}
+
+	result, err = p.Export("user-z")
+	if err != nil {
+		t.Fatalf("Unexpected error exporting unknown user: %v", err)
+	}
+	if len(result.Events) != 0 || len(result.Secrets) != 0 {
+		t.Errorf("Expected empty export for unknown user, got %v", result)
+	}
+}
+
+func TestExportAccountEvents(t *testing.T) {
+	for _, partitioned := range []bool{false, true} {
+		t.Run(fmt.Sprintf("partitioned %v", partitioned), func(t *testing.T) {
+			db, dbClose := createTestDatabase()
+			defer dbClose()
+			sqlDB, _ := db.DB()
+			sqlDB.SetMaxOpenConns(1)
+
+			for _, accountID := range []string{"account-a", "account-b"} {
+				if err := db.Create(&Account{AccountID: accountID}).Error; err != nil {
+					t.Fatalf("Unexpected error creating account: %v", err)
+				}
+			}
+			dal := NewRelationalDAL(db, WithEventPartitions(partitioned))
+			for i := 0; i < 5; i++ {
+				for _, accountID := range []string{"account-a", "account-b"} {
+					eventID, _ := persistence.NewULID()
+					if err := dal.CreateEvent(&persistence.Event{
+						EventID:   eventID,
+						Sequence:  eventID,
+						AccountID: accountID,
+						Payload:   "payload",
+					}); err != nil {
+						t.Fatalf("Unexpected error creating event: %v", err)
+					}
+				}
+			}
+
+			p, _ := persistence.New(dal)
+			var pageSizes []int
+			var eventIDs []string
+			if err := p.ExportAccountEvents("account-a", 2, func(events []persistence.EventResult) error {
+				pageSizes = append(pageSizes, len(events))
+				for _, evt := range events {
+					if evt.AccountID != "account-a" {
+						t.Errorf("Unexpected event of account %s", evt.AccountID)
+					}
+					eventIDs = append(eventIDs, evt.EventID)
+				}
+				return nil
+			}); err != nil {
+				t.Fatalf("Unexpected error exporting events: %v", err)
+			}
+			if !reflect.DeepEqual(pageSizes, []int{2, 2, 1}) {
+				t.Errorf("Unexpected page sizes %v", pageSizes)
+			}
+			if !sort.StringsAreSorted(eventIDs) {
+				t.Errorf("Expected events to be exported in order, got %v", eventIDs)
+			}
+
+			if err := p.ExportAccountEvents("account-z", 2, func([]persistence.EventResult) error {
+				return nil
+			}); err == nil {
+				t.Error("Expected error exporting unknown account")
+			}
+		})
+	}
+}
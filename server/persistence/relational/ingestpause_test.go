@@ -0,0 +1,41 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package relational
+
+import (
+	"testing"
+
+	"github.com/offen/offen/server/persistence"
+)
+
+func TestIngestPause(t *testing.T) {
+	db, dbClose := createTestDatabase()
+	defer dbClose()
+
+	for _, accountID := range []string{"account-a", "account-b"} {
+		if err := db.Create(&Account{AccountID: accountID}).Error; err != nil {
+			t.Fatalf("Unexpected error creating account: %v", err)
+		}
+	}
+	p, _ := persistence.New(NewRelationalDAL(db))
+	if _, err := p.UpdateIngestPause("account-a", "account-user-a", true); err != nil {
+		t.Fatalf("Unexpected error pausing ingestion: %v", err)
+	}
+
+	pause, err := p.GetIngestPause("account-a")
+	if err != nil {
+		t.Fatalf("Unexpected error looking up ingest pause: %v", err)
+	}
+	if !pause.Paused || pause.UpdatedBy != "account-user-a" || pause.UpdatedAt == nil {
+		t.Errorf("Unexpected ingest pause %v", pause)
+	}
+
+	other, err := p.GetIngestPause("account-b")
+	if err != nil {
+		t.Fatalf("Unexpected error looking up ingest pause: %v", err)
+	}
+	if other.Paused {
+		t.Errorf("Expected other account not to be paused, got %v", other)
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package relational
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/offen/offen/server/persistence"
+)
+
+func (r *relationalDAL) CreateInvite(i *persistence.Invite) error {
+	local := importInvite(i)
+	if err := r.db.Create(&local).Error; err != nil {
+		return fmt.Errorf("relational: error creating invite: %w", err)
+	}
+	return nil
+}
+
+func (r *relationalDAL) DeleteInvites(q interface{}) (int64, error) {
+	switch query := q.(type) {
+	case persistence.DeleteInvitesQueryUnexpiredByID:
+		result := r.db.Where("invite_id = ? AND expires > ?", query.InviteID, query.Now).Delete(&Invite{})
+		if result.Error != nil {
+			return 0, fmt.Errorf("relational: error deleting invite: %w", result.Error)
+		}
+		return result.RowsAffected, nil
+	case persistence.DeleteInvitesQueryExpired:
+		result := r.db.Where("expires <= ?", time.Time(query)).Delete(&Invite{})
+		if result.Error != nil {
+			return 0, fmt.Errorf("relational: error deleting expired invites: %w", result.Error)
+		}
+		return result.RowsAffected, nil
+	default:
+		return 0, persistence.ErrBadQuery
+	}
+}
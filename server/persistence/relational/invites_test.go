@@ -0,0 +1,54 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package relational
+
+import (
+	"testing"
+	"time"
+
+	"github.com/offen/offen/server/persistence"
+)
+
+func TestRelationalDAL_DeleteInvites(t *testing.T) {
+	db, dbClose := createTestDatabase()
+	defer dbClose()
+	dal := NewRelationalDAL(db)
+
+	now := time.Now()
+	for _, invite := range []persistence.Invite{
+		{InviteID: "invite-a", Expires: now.Add(time.Hour)},
+		{InviteID: "invite-b", Expires: now.Add(-time.Hour)},
+	} {
+		if err := dal.CreateInvite(&invite); err != nil {
+			t.Fatalf("Unexpected error creating invite: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		query    interface{}
+		expected int64
+	}{
+		{"valid invite", persistence.DeleteInvitesQueryUnexpiredByID{InviteID: "invite-a", Now: now}, 1},
+		{"reused invite", persistence.DeleteInvitesQueryUnexpiredByID{InviteID: "invite-a", Now: now}, 0},
+		{"expired invite", persistence.DeleteInvitesQueryUnexpiredByID{InviteID: "invite-b", Now: now}, 0},
+		{"unknown invite", persistence.DeleteInvitesQueryUnexpiredByID{InviteID: "invite-z", Now: now}, 0},
+		{"cleanup", persistence.DeleteInvitesQueryExpired(now), 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			affected, err := dal.DeleteInvites(test.query)
+			if err != nil {
+				t.Errorf("Unexpected error %v", err)
+			}
+			if affected != test.expected {
+				t.Errorf("Expected %d affected rows, got %d", test.expected, affected)
+			}
+		})
+	}
+
+	if _, err := dal.DeleteInvites("invite-a"); err != persistence.ErrBadQuery {
+		t.Errorf("Unexpected error for bad query %v", err)
+	}
+}
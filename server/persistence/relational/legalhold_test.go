@@ -0,0 +1,80 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package relational
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/offen/offen/server/persistence"
+)
+
+func TestLegalHold(t *testing.T) {
+	for _, batchSize := range []int{0, 1} {
+		t.Run(fmt.Sprintf("batch size %d", batchSize), func(t *testing.T) {
+			db, dbClose := createTestDatabase()
+			defer dbClose()
+
+			for _, accountID := range []string{"account-a", "account-b"} {
+				if err := db.Create(&Account{AccountID: accountID}).Error; err != nil {
+					t.Fatalf("Unexpected error creating account: %v", err)
+				}
+				for i := 0; i < 2; i++ {
+					eventID, err := persistence.EventIDAt(time.Now().Add(-48 * time.Hour))
+					if err != nil {
+						t.Fatalf("Unexpected error creating event id: %v", err)
+					}
+					if err := db.Create(&Event{EventID: eventID, AccountID: accountID}).Error; err != nil {
+						t.Fatalf("Unexpected error creating event: %v", err)
+					}
+				}
+			}
+
+			p, _ := persistence.New(NewRelationalDAL(db), persistence.WithExpireBatchSize(batchSize))
+			hold, err := p.UpdateLegalHold("account-a", "account-user-a", true)
+			if err != nil {
+				t.Fatalf("Unexpected error setting legal hold: %v", err)
+			}
+			if !hold.Enabled || hold.UpdatedBy != "account-user-a" || hold.UpdatedAt == nil {
+				t.Errorf("Unexpected legal hold %v", hold)
+			}
+
+			result, err := p.Expire(context.Background(), time.Hour)
+			if err != nil {
+				t.Fatalf("Unexpected error expiring events: %v", err)
+			}
+			if result.Removed != 2 || result.RemovedByAccount["account-a"] != 0 {
+				t.Errorf("Unexpected purge result %v", result)
+			}
+
+			var held, other int64
+			db.Model(&Event{}).Where("account_id = ?", "account-a").Count(&held)
+			db.Model(&Event{}).Where("account_id = ?", "account-b").Count(&other)
+			if held != 2 || other != 0 {
+				t.Errorf("Expected held events to survive expiry, got %d held and %d other events", held, other)
+			}
+
+			if err := p.RetireAccount("account-a"); !errors.Is(err, persistence.ErrLegalHold) {
+				t.Errorf("Expected ErrLegalHold when retiring held account, got %v", err)
+			}
+
+			if _, err := p.UpdateLegalHold("account-a", "account-user-b", false); err != nil {
+				t.Fatalf("Unexpected error clearing legal hold: %v", err)
+			}
+			hold, err = p.GetLegalHold("account-a")
+			if err != nil {
+				t.Fatalf("Unexpected error looking up legal hold: %v", err)
+			}
+			if hold.Enabled || hold.UpdatedBy != "account-user-b" {
+				t.Errorf("Unexpected legal hold %v", hold)
+			}
+			if err := p.RetireAccount("account-a"); err != nil {
+				t.Errorf("Unexpected error retiring account after clearing legal hold: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package relational
+
+import (
+	"fmt"
+	"time"
+)
+
+// migrationLockTimeout is the time after which a migration lock is considered
+// stale, e.g. because the process holding it has crashed, and may be taken
+// over by another process.
+const migrationLockTimeout = time.Minute * 10
+
+const migrationLockID = "migrations"
+
+// MigrationLock is a single row that exists while a process is applying
+// migrations. It is not part of the migrated schema, so it can be used
+// before any migration has been applied.
+type MigrationLock struct {
+	LockID     string `gorm:"primary_key;size:32"`
+	Owner      string `gorm:"size:64"`
+	AcquiredAt time.Time
+}
+
+func (r *relationalDAL) LockMigrations(owner string) (bool, error) {
+	if err := r.db.AutoMigrate(&MigrationLock{}); err != nil {
+		return false, fmt.Errorf("relational: error creating migration lock table: %w", err)
+	}
+	if err := r.db.
+		Where("lock_id = ? AND acquired_at < ?", migrationLockID, time.Now().Add(-migrationLockTimeout)).
+		Delete(&MigrationLock{}).Error; err != nil {
+		return false, fmt.Errorf("relational: error releasing stale migration lock: %w", err)
+	}
+
+	createErr := r.db.Create(&MigrationLock{
+		LockID:     migrationLockID,
+		Owner:      owner,
+		AcquiredAt: time.Now(),
+	}).Error
+	if createErr == nil {
+		return true, nil
+	}
+	// Inserting fails when the lock is held by another process. As the error
+	// returned in this case differs between dialects, the lock is looked up
+	// to tell apart this case from other errors.
+	var count int64
+	if err := r.db.Model(&MigrationLock{}).Where("lock_id = ?", migrationLockID).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("relational: error looking up migration lock: %w", err)
+	}
+	if count != 0 {
+		return false, nil
+	}
+	return false, fmt.Errorf("relational: error acquiring migration lock: %w", createErr)
+}
+
+func (r *relationalDAL) UnlockMigrations(owner string) error {
+	if err := r.db.
+		Where("lock_id = ? AND owner = ?", migrationLockID, owner).
+		Delete(&MigrationLock{}).Error; err != nil {
+		return fmt.Errorf("relational: error releasing migration lock: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package relational
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRelationalDAL_MigrationLock(t *testing.T) {
+	db, dbClose := createTestDatabase()
+	defer dbClose()
+	// in memory databases are not shared between connections
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	dal := &relationalDAL{db: db}
+
+	if acquired, err := dal.LockMigrations("owner-a"); err != nil || !acquired {
+		t.Fatalf("Expected lock to be acquired, got %v and %v", acquired, err)
+	}
+	if acquired, err := dal.LockMigrations("owner-b"); err != nil || acquired {
+		t.Fatalf("Expected lock to be held, got %v and %v", acquired, err)
+	}
+	if err := dal.UnlockMigrations("owner-b"); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if acquired, _ := dal.LockMigrations("owner-b"); acquired {
+		t.Fatal("Expected lock not to be released by other owner")
+	}
+	if err := dal.UnlockMigrations("owner-a"); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if acquired, err := dal.LockMigrations("owner-b"); err != nil || !acquired {
+		t.Fatalf("Expected lock to be acquired after release, got %v and %v", acquired, err)
+	}
+
+	if err := db.Model(&MigrationLock{}).
+		Where("lock_id = ?", migrationLockID).
+		Update("acquired_at", time.Now().Add(-2*migrationLockTimeout)).Error; err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if acquired, err := dal.LockMigrations("owner-c"); err != nil || !acquired {
+		t.Errorf("Expected stale lock to be taken over, got %v and %v", acquired, err)
+	}
+}
@@ -350,6 +350,474 @@ func (r *relationalDAL) ApplyMigrations() error {
 				return db.Migrator().DropColumn("accounts", "account_styles")
 			},
 		},
+		{
+			ID: "008_account_strict_event_decoding",
+			Migrate: func(db *gorm.DB) error {
+				type Account struct {
+					AccountID           string `gorm:"primary_key;size:36;unique"`
+					Name                string
+					PublicKey           string `gorm:"type:text"`
+					EncryptedPrivateKey string `gorm:"type:text"`
+					UserSalt            string
+					Retired             bool
+					AccountStyles       string `gorm:"type:text"`
+					Created             time.Time
+					Events              []Event `gorm:"foreignkey:AccountID;association_foreignkey:AccountID"`
+					StrictEventDecoding bool
+				}
+				return db.AutoMigrate(&Account{})
+			},
+			Rollback: func(db *gorm.DB) error {
+				return db.Migrator().DropColumn("accounts", "strict_event_decoding")
+			},
+		},
+		{
+			ID: "009_secret_account_id",
+			Migrate: func(db *gorm.DB) error {
+				type Secret struct {
+					SecretID        string `gorm:"primary_key;size:64;unique"`
+					AccountID       string `gorm:"size:36;index"`
+					EncryptedSecret string `gorm:"type:text"`
+				}
+				if err := db.AutoMigrate(&Secret{}); err != nil {
+					return err
+				}
+				// existing secrets are assigned to the account of any event
+				// that references them, secrets without events remain unassigned
+				return db.Exec(
+					"UPDATE secrets SET account_id = COALESCE((SELECT events.account_id FROM events WHERE events.secret_id = secrets.secret_id LIMIT 1), '') WHERE account_id IS NULL OR account_id = ''",
+				).Error
+			},
+			Rollback: func(db *gorm.DB) error {
+				return db.Migrator().DropColumn("secrets", "account_id")
+			},
+		},
+		{
+			ID: "010_account_email_sender",
+			Migrate: func(db *gorm.DB) error {
+				type Account struct {
+					AccountID           string `gorm:"primary_key;size:36;unique"`
+					Name                string
+					PublicKey           string `gorm:"type:text"`
+					EncryptedPrivateKey string `gorm:"type:text"`
+					UserSalt            string
+					Retired             bool
+					AccountStyles       string `gorm:"type:text"`
+					Created             time.Time
+					Events              []Event `gorm:"foreignkey:AccountID;association_foreignkey:AccountID"`
+					StrictEventDecoding bool
+					EmailSender         string
+				}
+				return db.AutoMigrate(&Account{})
+			},
+			Rollback: func(db *gorm.DB) error {
+				return db.Migrator().DropColumn("accounts", "email_sender")
+			},
+		},
+		{
+			ID: "011_account_timezone",
+			Migrate: func(db *gorm.DB) error {
+				type Account struct {
+					AccountID           string `gorm:"primary_key;size:36;unique"`
+					Name                string
+					PublicKey           string `gorm:"type:text"`
+					EncryptedPrivateKey string `gorm:"type:text"`
+					UserSalt            string
+					Retired             bool
+					AccountStyles       string `gorm:"type:text"`
+					Created             time.Time
+					Events              []Event `gorm:"foreignkey:AccountID;association_foreignkey:AccountID"`
+					StrictEventDecoding bool
+					EmailSender         string
+					Timezone            string
+				}
+				return db.AutoMigrate(&Account{})
+			},
+			Rollback: func(db *gorm.DB) error {
+				return db.Migrator().DropColumn("accounts", "timezone")
+			},
+		},
+		{
+			ID: "012_account_allowed_origins",
+			Migrate: func(db *gorm.DB) error {
+				type Account struct {
+					AccountID           string `gorm:"primary_key;size:36;unique"`
+					Name                string
+					PublicKey           string `gorm:"type:text"`
+					EncryptedPrivateKey string `gorm:"type:text"`
+					UserSalt            string
+					Retired             bool
+					AccountStyles       string `gorm:"type:text"`
+					Created             time.Time
+					Events              []Event `gorm:"foreignkey:AccountID;association_foreignkey:AccountID"`
+					StrictEventDecoding bool
+					EmailSender         string
+					Timezone            string
+					AllowedOrigins      string `gorm:"type:text"`
+				}
+				return db.AutoMigrate(&Account{})
+			},
+			Rollback: func(db *gorm.DB) error {
+				return db.Migrator().DropColumn("accounts", "allowed_origins")
+			},
+		},
+		{
+			ID: "013_event_content_hash",
+			Migrate: func(db *gorm.DB) error {
+				type Secret struct {
+					SecretID        string `gorm:"primary_key;size:64;unique"`
+					AccountID       string `gorm:"size:36;index"`
+					EncryptedSecret string `gorm:"type:text"`
+				}
+				type Event struct {
+					EventID     string  `gorm:"primary_key;size:26;unique"`
+					Sequence    string  `gorm:"size:26"`
+					AccountID   string  `gorm:"size:36"`
+					SecretID    *string `gorm:"size:64"`
+					Payload     string  `gorm:"type:text"`
+					ContentHash string  `gorm:"size:64"`
+					Secret      Secret  `gorm:"foreignkey:SecretID;association_foreignkey:SecretID"`
+				}
+				return db.AutoMigrate(&Event{})
+			},
+			Rollback: func(db *gorm.DB) error {
+				return db.Migrator().DropColumn("events", "content_hash")
+			},
+		},
+		{
+			ID: "014_invites",
+			Migrate: func(db *gorm.DB) error {
+				type Invite struct {
+					InviteID  string `gorm:"primary_key;size:26;unique"`
+					CreatedBy string `gorm:"size:36"`
+					Expires   time.Time
+				}
+				return db.AutoMigrate(&Invite{})
+			},
+			Rollback: func(db *gorm.DB) error {
+				return db.Migrator().DropTable("invites")
+			},
+		},
+		{
+			ID: "015_expiry_exemption",
+			Migrate: func(db *gorm.DB) error {
+				type Secret struct {
+					SecretID        string `gorm:"primary_key;size:64;unique"`
+					AccountID       string `gorm:"size:36;index"`
+					EncryptedSecret string `gorm:"type:text"`
+				}
+				type Event struct {
+					EventID     string  `gorm:"primary_key;size:26;unique"`
+					Sequence    string  `gorm:"size:26"`
+					AccountID   string  `gorm:"size:36"`
+					SecretID    *string `gorm:"size:64"`
+					Payload     string  `gorm:"type:text"`
+					ContentHash string  `gorm:"size:64"`
+					Exempt      bool    `gorm:"default:false"`
+					Secret      Secret  `gorm:"foreignkey:SecretID;association_foreignkey:SecretID"`
+				}
+				type Account struct {
+					AccountID            string `gorm:"primary_key;size:36;unique"`
+					Name                 string
+					PublicKey            string `gorm:"type:text"`
+					EncryptedPrivateKey  string `gorm:"type:text"`
+					UserSalt             string
+					Retired              bool
+					AccountStyles        string `gorm:"type:text"`
+					Created              time.Time
+					Events               []Event `gorm:"foreignkey:AccountID;association_foreignkey:AccountID"`
+					StrictEventDecoding  bool
+					EmailSender          string
+					Timezone             string
+					AllowedOrigins       string `gorm:"type:text"`
+					AllowExpiryExemption bool
+				}
+				return db.AutoMigrate(&Event{}, &Account{})
+			},
+			Rollback: func(db *gorm.DB) error {
+				if err := db.Migrator().DropColumn("events", "exempt"); err != nil {
+					return err
+				}
+				return db.Migrator().DropColumn("accounts", "allow_expiry_exemption")
+			},
+		},
+		{
+			ID: "016_account_countries",
+			Migrate: func(db *gorm.DB) error {
+				type Account struct {
+					AccountID            string `gorm:"primary_key;size:36;unique"`
+					Name                 string
+					PublicKey            string `gorm:"type:text"`
+					EncryptedPrivateKey  string `gorm:"type:text"`
+					UserSalt             string
+					Retired              bool
+					AccountStyles        string `gorm:"type:text"`
+					Created              time.Time
+					StrictEventDecoding  bool
+					EmailSender          string
+					Timezone             string
+					AllowedOrigins       string `gorm:"type:text"`
+					AllowExpiryExemption bool
+					AllowedCountries     string `gorm:"type:text"`
+					DeniedCountries      string `gorm:"type:text"`
+				}
+				return db.AutoMigrate(&Account{})
+			},
+			Rollback: func(db *gorm.DB) error {
+				if err := db.Migrator().DropColumn("accounts", "allowed_countries"); err != nil {
+					return err
+				}
+				return db.Migrator().DropColumn("accounts", "denied_countries")
+			},
+		},
+		{
+			ID: "017_account_event_schema",
+			Migrate: func(db *gorm.DB) error {
+				type Account struct {
+					AccountID            string `gorm:"primary_key;size:36;unique"`
+					Name                 string
+					PublicKey            string `gorm:"type:text"`
+					EncryptedPrivateKey  string `gorm:"type:text"`
+					UserSalt             string
+					Retired              bool
+					AccountStyles        string `gorm:"type:text"`
+					Created              time.Time
+					StrictEventDecoding  bool
+					EmailSender          string
+					Timezone             string
+					AllowedOrigins       string `gorm:"type:text"`
+					AllowExpiryExemption bool
+					AllowedCountries     string `gorm:"type:text"`
+					DeniedCountries      string `gorm:"type:text"`
+					EventSchema          string `gorm:"type:text"`
+					EventSchemaVersion   int
+				}
+				return db.AutoMigrate(&Account{})
+			},
+			Rollback: func(db *gorm.DB) error {
+				if err := db.Migrator().DropColumn("accounts", "event_schema"); err != nil {
+					return err
+				}
+				return db.Migrator().DropColumn("accounts", "event_schema_version")
+			},
+		},
+		{
+			ID: "018_consent_audit",
+			Migrate: func(db *gorm.DB) error {
+				type ConsentAuditEntry struct {
+					EntryID      string `gorm:"primary_key;size:26;unique"`
+					AccountID    string `gorm:"size:36;index"`
+					HashedUserID string `gorm:"size:64"`
+					Action       string `gorm:"size:16"`
+					Timestamp    time.Time
+				}
+				return db.AutoMigrate(&ConsentAuditEntry{})
+			},
+			Rollback: func(db *gorm.DB) error {
+				return db.Migrator().DropTable("consent_audit_entries")
+			},
+		},
+		{
+			ID: "019_account_legal_hold",
+			Migrate: func(db *gorm.DB) error {
+				type Account struct {
+					AccountID            string `gorm:"primary_key;size:36;unique"`
+					Name                 string
+					PublicKey            string `gorm:"type:text"`
+					EncryptedPrivateKey  string `gorm:"type:text"`
+					UserSalt             string
+					Retired              bool
+					AccountStyles        string `gorm:"type:text"`
+					Created              time.Time
+					StrictEventDecoding  bool
+					EmailSender          string
+					Timezone             string
+					AllowedOrigins       string `gorm:"type:text"`
+					AllowExpiryExemption bool
+					AllowedCountries     string `gorm:"type:text"`
+					DeniedCountries      string `gorm:"type:text"`
+					EventSchema          string `gorm:"type:text"`
+					EventSchemaVersion   int
+					LegalHold            bool
+					LegalHoldUpdatedBy   string
+					LegalHoldUpdatedAt   *time.Time
+				}
+				return db.AutoMigrate(&Account{})
+			},
+			Rollback: func(db *gorm.DB) error {
+				for _, column := range []string{"legal_hold", "legal_hold_updated_by", "legal_hold_updated_at"} {
+					if err := db.Migrator().DropColumn("accounts", column); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "020_user_sequences",
+			Migrate: func(db *gorm.DB) error {
+				type Secret struct {
+					SecretID        string `gorm:"primary_key;size:64;unique"`
+					AccountID       string `gorm:"size:36;index"`
+					EncryptedSecret string `gorm:"type:text"`
+					EventCounter    int64  `gorm:"default:0"`
+				}
+				type Event struct {
+					EventID      string  `gorm:"primary_key;size:26;unique"`
+					Sequence     string  `gorm:"size:26"`
+					AccountID    string  `gorm:"size:36"`
+					SecretID     *string `gorm:"size:64"`
+					Payload      string  `gorm:"type:text"`
+					ContentHash  string  `gorm:"size:64"`
+					Exempt       bool    `gorm:"default:false"`
+					Secret       Secret  `gorm:"foreignkey:SecretID;association_foreignkey:SecretID"`
+					UserSequence int64   `gorm:"default:0"`
+				}
+				return db.AutoMigrate(&Secret{}, &Event{})
+			},
+			Rollback: func(db *gorm.DB) error {
+				if err := db.Migrator().DropColumn("secrets", "event_counter"); err != nil {
+					return err
+				}
+				return db.Migrator().DropColumn("events", "user_sequence")
+			},
+		},
+		{
+			ID: "021_account_consent_exempt",
+			Migrate: func(db *gorm.DB) error {
+				type Account struct {
+					AccountID            string `gorm:"primary_key;size:36;unique"`
+					Name                 string
+					PublicKey            string `gorm:"type:text"`
+					EncryptedPrivateKey  string `gorm:"type:text"`
+					UserSalt             string
+					Retired              bool
+					AccountStyles        string `gorm:"type:text"`
+					Created              time.Time
+					StrictEventDecoding  bool
+					EmailSender          string
+					Timezone             string
+					AllowedOrigins       string `gorm:"type:text"`
+					AllowExpiryExemption bool
+					AllowedCountries     string `gorm:"type:text"`
+					DeniedCountries      string `gorm:"type:text"`
+					EventSchema          string `gorm:"type:text"`
+					EventSchemaVersion   int
+					LegalHold            bool
+					LegalHoldUpdatedBy   string
+					LegalHoldUpdatedAt   *time.Time
+					ConsentExempt        bool
+				}
+				return db.AutoMigrate(&Account{})
+			},
+			Rollback: func(db *gorm.DB) error {
+				return db.Migrator().DropColumn("accounts", "consent_exempt")
+			},
+		},
+		{
+			ID: "022_account_retention",
+			Migrate: func(db *gorm.DB) error {
+				type Account struct {
+					AccountID            string `gorm:"primary_key;size:36;unique"`
+					Name                 string
+					PublicKey            string `gorm:"type:text"`
+					EncryptedPrivateKey  string `gorm:"type:text"`
+					UserSalt             string
+					Retired              bool
+					AccountStyles        string `gorm:"type:text"`
+					Created              time.Time
+					StrictEventDecoding  bool
+					EmailSender          string
+					Timezone             string
+					AllowedOrigins       string `gorm:"type:text"`
+					AllowExpiryExemption bool
+					AllowedCountries     string `gorm:"type:text"`
+					DeniedCountries      string `gorm:"type:text"`
+					EventSchema          string `gorm:"type:text"`
+					EventSchemaVersion   int
+					LegalHold            bool
+					LegalHoldUpdatedBy   string
+					LegalHoldUpdatedAt   *time.Time
+					ConsentExempt        bool
+					Retention            time.Duration
+				}
+				return db.AutoMigrate(&Account{})
+			},
+			Rollback: func(db *gorm.DB) error {
+				return db.Migrator().DropColumn("accounts", "retention")
+			},
+		},
+		{
+			ID: "023_account_ingest_pause",
+			Migrate: func(db *gorm.DB) error {
+				type Account struct {
+					AccountID            string `gorm:"primary_key;size:36;unique"`
+					Name                 string
+					PublicKey            string `gorm:"type:text"`
+					EncryptedPrivateKey  string `gorm:"type:text"`
+					UserSalt             string
+					Retired              bool
+					AccountStyles        string `gorm:"type:text"`
+					Created              time.Time
+					StrictEventDecoding  bool
+					EmailSender          string
+					Timezone             string
+					AllowedOrigins       string `gorm:"type:text"`
+					AllowExpiryExemption bool
+					AllowedCountries     string `gorm:"type:text"`
+					DeniedCountries      string `gorm:"type:text"`
+					EventSchema          string `gorm:"type:text"`
+					EventSchemaVersion   int
+					LegalHold            bool
+					LegalHoldUpdatedBy   string
+					LegalHoldUpdatedAt   *time.Time
+					ConsentExempt        bool
+					Retention            time.Duration
+					IngestPaused         bool
+					IngestPauseUpdatedBy string
+					IngestPauseUpdatedAt *time.Time
+				}
+				return db.AutoMigrate(&Account{})
+			},
+			Rollback: func(db *gorm.DB) error {
+				for _, column := range []string{"ingest_paused", "ingest_pause_updated_by", "ingest_pause_updated_at"} {
+					if err := db.Migrator().DropColumn("accounts", column); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "024_sessions",
+			Migrate: func(db *gorm.DB) error {
+				type Session struct {
+					SessionID     string `gorm:"primary_key;size:64;unique"`
+					AccountUserID string `gorm:"size:36;index"`
+					Expires       time.Time
+				}
+				return db.AutoMigrate(&Session{})
+			},
+			Rollback: func(db *gorm.DB) error {
+				return db.Migrator().DropTable("sessions")
+			},
+		},
+		{
+			ID: "025_secrets_parked",
+			Migrate: func(db *gorm.DB) error {
+				type Secret struct {
+					SecretID        string `gorm:"primary_key;size:64;unique"`
+					AccountID       string `gorm:"size:36;index"`
+					EncryptedSecret string `gorm:"type:text"`
+					EventCounter    int64  `gorm:"default:0"`
+					Parked          bool   `gorm:"default:false"`
+				}
+				return db.AutoMigrate(&Secret{})
+			},
+			Rollback: func(db *gorm.DB) error {
+				return db.Migrator().DropColumn("secrets", "parked")
+			},
+		},
 	})
 
 	m.InitSchema(func(db *gorm.DB) error {
@@ -4,6 +4,7 @@
 package relational
 
 import (
+	"strings"
 	"time"
 
 	"github.com/offen/offen/server/persistence"
@@ -16,9 +17,12 @@ type Event struct {
 	Sequence  string `gorm:"size:26"`
 	AccountID string `gorm:"size:36"`
 	// the secret id is nullable for anonymous events
-	SecretID *string `gorm:"size:64"`
-	Payload  string  `gorm:"type:text"`
-	Secret   Secret  `gorm:"foreignkey:SecretID;association_foreignkey:SecretID"`
+	SecretID     *string `gorm:"size:64"`
+	Payload      string  `gorm:"type:text"`
+	ContentHash  string  `gorm:"size:64"`
+	Exempt       bool    `gorm:"default:false"`
+	Secret       Secret  `gorm:"foreignkey:SecretID;association_foreignkey:SecretID"`
+	UserSequence int64   `gorm:"default:0"`
 }
 
 // A Tombstone replaces an event on its deletion
@@ -34,20 +38,63 @@ type Tombstone struct {
 // to decrypt events stored for that user.
 type Secret struct {
 	SecretID        string `gorm:"primary_key;size:64;unique"`
+	AccountID       string `gorm:"size:36;index"`
 	EncryptedSecret string `gorm:"type:text"`
+	EventCounter    int64  `gorm:"default:0"`
+	Parked          bool   `gorm:"default:false"`
+}
+
+// Invite is a single use permission for creating an account.
+type Invite struct {
+	InviteID  string `gorm:"primary_key;size:26;unique"`
+	CreatedBy string `gorm:"size:36"`
+	Expires   time.Time
+}
+
+// Session is a login of an account user.
+type Session struct {
+	SessionID     string `gorm:"primary_key;size:64;unique"`
+	AccountUserID string `gorm:"size:36;index"`
+	Expires       time.Time
+}
+
+// ConsentAuditEntry records a single opt in or opt out.
+type ConsentAuditEntry struct {
+	EntryID      string `gorm:"primary_key;size:26;unique"`
+	AccountID    string `gorm:"size:36;index"`
+	HashedUserID string `gorm:"size:64"`
+	Action       string `gorm:"size:16"`
+	Timestamp    time.Time
 }
 
 // Account stores information about an account.
 type Account struct {
-	AccountID           string `gorm:"primary_key;size:36;unique"`
-	Name                string
-	PublicKey           string `gorm:"type:text"`
-	EncryptedPrivateKey string `gorm:"type:text"`
-	UserSalt            string
-	Retired             bool
-	AccountStyles       string `gorm:"type:text"`
-	Created             time.Time
-	Events              []Event `gorm:"foreignkey:AccountID;association_foreignkey:AccountID"`
+	AccountID            string `gorm:"primary_key;size:36;unique"`
+	Name                 string
+	PublicKey            string `gorm:"type:text"`
+	EncryptedPrivateKey  string `gorm:"type:text"`
+	UserSalt             string
+	Retired              bool
+	AccountStyles        string `gorm:"type:text"`
+	Created              time.Time
+	Events               []Event `gorm:"foreignkey:AccountID;association_foreignkey:AccountID"`
+	StrictEventDecoding  bool
+	EmailSender          string
+	Timezone             string
+	AllowedOrigins       string `gorm:"type:text"`
+	AllowExpiryExemption bool
+	AllowedCountries     string `gorm:"type:text"`
+	DeniedCountries      string `gorm:"type:text"`
+	EventSchema          string `gorm:"type:text"`
+	EventSchemaVersion   int
+	LegalHold            bool
+	LegalHoldUpdatedBy   string
+	LegalHoldUpdatedAt   *time.Time
+	ConsentExempt        bool
+	Retention            time.Duration
+	IngestPaused         bool
+	IngestPauseUpdatedBy string
+	IngestPauseUpdatedAt *time.Time
 }
 
 // AccountUser is a person that can log in and access data related to all
@@ -74,23 +121,29 @@ type AccountUserRelationship struct {
 
 func (e *Event) export() persistence.Event {
 	return persistence.Event{
-		EventID:   e.EventID,
-		AccountID: e.AccountID,
-		SecretID:  e.SecretID,
-		Payload:   e.Payload,
-		Secret:    e.Secret.export(),
-		Sequence:  e.Sequence,
+		EventID:      e.EventID,
+		AccountID:    e.AccountID,
+		SecretID:     e.SecretID,
+		Payload:      e.Payload,
+		ContentHash:  e.ContentHash,
+		Exempt:       e.Exempt,
+		Secret:       e.Secret.export(),
+		Sequence:     e.Sequence,
+		UserSequence: e.UserSequence,
 	}
 }
 
 func importEvent(e *persistence.Event) Event {
 	return Event{
-		EventID:   e.EventID,
-		AccountID: e.AccountID,
-		SecretID:  e.SecretID,
-		Payload:   e.Payload,
-		Secret:    importSecret(&e.Secret),
-		Sequence:  e.Sequence,
+		EventID:      e.EventID,
+		AccountID:    e.AccountID,
+		SecretID:     e.SecretID,
+		Payload:      e.Payload,
+		ContentHash:  e.ContentHash,
+		Exempt:       e.Exempt,
+		Secret:       importSecret(&e.Secret),
+		Sequence:     e.Sequence,
+		UserSequence: e.UserSequence,
 	}
 }
 
@@ -115,14 +168,72 @@ func importTombstone(t *persistence.Tombstone) *Tombstone {
 func (s *Secret) export() persistence.Secret {
 	return persistence.Secret{
 		SecretID:        s.SecretID,
+		AccountID:       s.AccountID,
 		EncryptedSecret: s.EncryptedSecret,
+		EventCounter:    s.EventCounter,
+		Parked:          s.Parked,
 	}
 }
 
 func importSecret(s *persistence.Secret) Secret {
 	return Secret{
 		SecretID:        s.SecretID,
+		AccountID:       s.AccountID,
 		EncryptedSecret: s.EncryptedSecret,
+		EventCounter:    s.EventCounter,
+		Parked:          s.Parked,
+	}
+}
+
+func (i *Invite) export() persistence.Invite {
+	return persistence.Invite{
+		InviteID:  i.InviteID,
+		CreatedBy: i.CreatedBy,
+		Expires:   i.Expires,
+	}
+}
+
+func importInvite(i *persistence.Invite) Invite {
+	return Invite{
+		InviteID:  i.InviteID,
+		CreatedBy: i.CreatedBy,
+		Expires:   i.Expires,
+	}
+}
+
+func (s *Session) export() persistence.Session {
+	return persistence.Session{
+		SessionID:     s.SessionID,
+		AccountUserID: s.AccountUserID,
+		Expires:       s.Expires,
+	}
+}
+
+func importSession(s *persistence.Session) Session {
+	return Session{
+		SessionID:     s.SessionID,
+		AccountUserID: s.AccountUserID,
+		Expires:       s.Expires,
+	}
+}
+
+func (e *ConsentAuditEntry) export() persistence.ConsentAuditEntry {
+	return persistence.ConsentAuditEntry{
+		EntryID:      e.EntryID,
+		AccountID:    e.AccountID,
+		HashedUserID: e.HashedUserID,
+		Action:       e.Action,
+		Timestamp:    e.Timestamp,
+	}
+}
+
+func importConsentAuditEntry(e *persistence.ConsentAuditEntry) ConsentAuditEntry {
+	return ConsentAuditEntry{
+		EntryID:      e.EntryID,
+		AccountID:    e.AccountID,
+		HashedUserID: e.HashedUserID,
+		Action:       e.Action,
+		Timestamp:    e.Timestamp,
 	}
 }
 
@@ -193,6 +304,29 @@ func (a *Account) export() persistence.Account {
 		Created:             a.Created,
 		Events:              events,
 		AccountStyles:       a.AccountStyles,
+		Settings: persistence.AccountSettings{
+			StrictEventDecoding:  a.StrictEventDecoding,
+			EmailSender:          a.EmailSender,
+			Timezone:             a.Timezone,
+			AllowedOrigins:       splitList(a.AllowedOrigins),
+			AllowExpiryExemption: a.AllowExpiryExemption,
+			AllowedCountries:     splitList(a.AllowedCountries),
+			DeniedCountries:      splitList(a.DeniedCountries),
+			ConsentExempt:        a.ConsentExempt,
+		},
+		EventSchema:        a.EventSchema,
+		EventSchemaVersion: a.EventSchemaVersion,
+		LegalHold: persistence.LegalHold{
+			Enabled:   a.LegalHold,
+			UpdatedBy: a.LegalHoldUpdatedBy,
+			UpdatedAt: a.LegalHoldUpdatedAt,
+		},
+		Retention: a.Retention,
+		IngestPause: persistence.IngestPause{
+			Paused:    a.IngestPaused,
+			UpdatedBy: a.IngestPauseUpdatedBy,
+			UpdatedAt: a.IngestPauseUpdatedAt,
+		},
 	}
 }
 
@@ -202,14 +336,38 @@ func importAccount(a *persistence.Account) Account {
 		events = append(events, importEvent(&e))
 	}
 	return Account{
-		AccountID:           a.AccountID,
-		Name:                a.Name,
-		PublicKey:           a.PublicKey,
-		EncryptedPrivateKey: a.EncryptedPrivateKey,
-		UserSalt:            a.UserSalt,
-		Retired:             a.Retired,
-		Created:             a.Created,
-		Events:              events,
-		AccountStyles:       a.AccountStyles,
+		AccountID:            a.AccountID,
+		Name:                 a.Name,
+		PublicKey:            a.PublicKey,
+		EncryptedPrivateKey:  a.EncryptedPrivateKey,
+		UserSalt:             a.UserSalt,
+		Retired:              a.Retired,
+		Created:              a.Created,
+		Events:               events,
+		AccountStyles:        a.AccountStyles,
+		StrictEventDecoding:  a.Settings.StrictEventDecoding,
+		EmailSender:          a.Settings.EmailSender,
+		Timezone:             a.Settings.Timezone,
+		AllowedOrigins:       strings.Join(a.Settings.AllowedOrigins, ","),
+		AllowExpiryExemption: a.Settings.AllowExpiryExemption,
+		AllowedCountries:     strings.Join(a.Settings.AllowedCountries, ","),
+		DeniedCountries:      strings.Join(a.Settings.DeniedCountries, ","),
+		EventSchema:          a.EventSchema,
+		EventSchemaVersion:   a.EventSchemaVersion,
+		LegalHold:            a.LegalHold.Enabled,
+		LegalHoldUpdatedBy:   a.LegalHold.UpdatedBy,
+		LegalHoldUpdatedAt:   a.LegalHold.UpdatedAt,
+		ConsentExempt:        a.Settings.ConsentExempt,
+		Retention:            a.Retention,
+		IngestPaused:         a.IngestPause.Paused,
+		IngestPauseUpdatedBy: a.IngestPause.UpdatedBy,
+		IngestPauseUpdatedAt: a.IngestPause.UpdatedAt,
+	}
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
 	}
+	return strings.Split(s, ",")
 }
@@ -0,0 +1,177 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package relational
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Config is a function that adds a configuration option to the data
+// access layer.
+type Config func(*relationalDAL)
+
+// WithEventPartitions stores the events of each account in a table of its
+// own instead of a single table shared by all accounts. Events that have been
+// stored before enabling partitions stay in the shared table and are still
+// returned by all queries, so no data has to be migrated. Once enabled,
+// partitions must never be disabled as events stored in partitions would not
+// be found anymore.
+func WithEventPartitions(enabled bool) Config {
+	return func(r *relationalDAL) {
+		if enabled {
+			r.partitions = &eventPartitions{known: map[string]bool{}}
+		}
+	}
+}
+
+const sharedEventTable = "events"
+
+// eventPartitions keeps track of the partition tables that are known to
+// exist, so existence does not need to be checked on every query. It also
+// caches the list of partitions of all accounts, which is dropped whenever
+// an account is created or retired.
+type eventPartitions struct {
+	sync.Mutex
+	known map[string]bool
+	all   []string
+}
+
+func (p *eventPartitions) isKnown(table string) bool {
+	p.Lock()
+	defer p.Unlock()
+	return p.known[table]
+}
+
+func (p *eventPartitions) add(table string) {
+	p.Lock()
+	defer p.Unlock()
+	if p.all != nil && !p.known[table] {
+		p.all = append(p.all, table)
+	}
+	p.known[table] = true
+}
+
+// allTables returns the cached list of partitions of all accounts. The
+// second return value is false in case the list has not been loaded yet.
+func (p *eventPartitions) allTables() ([]string, bool) {
+	p.Lock()
+	defer p.Unlock()
+	if p.all == nil {
+		return nil, false
+	}
+	return append([]string{}, p.all...), true
+}
+
+func (p *eventPartitions) setAllTables(tables []string) {
+	p.Lock()
+	defer p.Unlock()
+	p.all = append([]string{}, tables...)
+}
+
+func (p *eventPartitions) invalidate() {
+	p.Lock()
+	defer p.Unlock()
+	p.all = nil
+}
+
+func (p *eventPartitions) reset() {
+	p.Lock()
+	defer p.Unlock()
+	p.known = map[string]bool{}
+	p.all = nil
+}
+
+// partitionTable returns the name of the table storing the events of the
+// given account. Account ids are hashed so that the name is guaranteed to be
+// a valid identifier of fixed length.
+func partitionTable(accountID string) string {
+	sum := sha256.Sum256([]byte(accountID))
+	return fmt.Sprintf("events_%x", sum[:12])
+}
+
+// partitionedEvent is the schema used for creating partition tables. It
+// equals Event but does not define any associations, so no constraints that
+// would clash with the ones of the shared table are created.
+type partitionedEvent struct {
+	EventID      string  `gorm:"primary_key;size:26;unique"`
+	Sequence     string  `gorm:"size:26"`
+	AccountID    string  `gorm:"size:36"`
+	SecretID     *string `gorm:"size:64"`
+	Payload      string  `gorm:"type:text"`
+	ContentHash  string  `gorm:"size:64"`
+	Exempt       bool    `gorm:"default:false"`
+	UserSequence int64   `gorm:"default:0"`
+}
+
+// eventTableFor returns the table new events for the given account are
+// written to, creating it if needed.
+func (r *relationalDAL) eventTableFor(accountID string) (string, error) {
+	if r.partitions == nil {
+		return sharedEventTable, nil
+	}
+	table := partitionTable(accountID)
+	if r.partitions.isKnown(table) {
+		return table, nil
+	}
+	if err := r.db.Table(table).AutoMigrate(&partitionedEvent{}); err != nil {
+		return "", fmt.Errorf("relational: error creating event partition for account %s: %w", accountID, err)
+	}
+	r.partitions.add(table)
+	return table, nil
+}
+
+// eventTables returns all tables that might contain events of the given
+// accounts. In case no account ids are given, the tables for all accounts
+// are returned.
+func (r *relationalDAL) eventTables(accountIDs ...string) ([]string, error) {
+	tables := []string{sharedEventTable}
+	if r.partitions == nil {
+		return tables, nil
+	}
+	all := len(accountIDs) == 0
+	if all {
+		if cached, ok := r.partitions.allTables(); ok {
+			return append(tables, cached...), nil
+		}
+		if err := r.db.Model(&Account{}).Pluck("account_id", &accountIDs).Error; err != nil {
+			return nil, fmt.Errorf("relational: error looking up accounts for event partitions: %w", err)
+		}
+	}
+	for _, accountID := range accountIDs {
+		table := partitionTable(accountID)
+		if !r.partitions.isKnown(table) {
+			// Partitions are only created when the first event for an account
+			// is stored, so there might not be one yet.
+			if !r.db.Migrator().HasTable(table) {
+				continue
+			}
+			r.partitions.add(table)
+		}
+		tables = append(tables, table)
+	}
+	if all {
+		r.partitions.setAllTables(tables[1:])
+	}
+	return tables, nil
+}
+
+// eachEventTable calls fn with a handle scoped to each of the tables that
+// might contain events of the given accounts, or of all accounts in case
+// none are given.
+func (r *relationalDAL) eachEventTable(fn func(db *gorm.DB) error, accountIDs ...string) error {
+	tables, err := r.eventTables(accountIDs...)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if err := fn(r.db.Table(table)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
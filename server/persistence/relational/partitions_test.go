@@ -0,0 +1,161 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package relational
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/offen/offen/server/persistence"
+)
+
+func TestRelationalDAL_EventPartitions(t *testing.T) {
+	db, dbClose := createTestDatabase()
+	defer dbClose()
+
+	accountIDs := []string{"account-a", "account-b"}
+	for _, accountID := range accountIDs {
+		if err := db.Create(&Account{AccountID: accountID}).Error; err != nil {
+			t.Fatalf("Unexpected error creating account: %v", err)
+		}
+	}
+
+	dal := NewRelationalDAL(db, WithEventPartitions(true))
+	for _, accountID := range accountIDs {
+		for i := 0; i < 2; i++ {
+			if err := dal.CreateEvent(&persistence.Event{
+				EventID:   fmt.Sprintf("%s-event-%d", accountID, i),
+				Sequence:  fmt.Sprintf("%s-event-%d", accountID, i),
+				AccountID: accountID,
+				SecretID:  strptr(fmt.Sprintf("%s-secret", accountID)),
+				Payload:   "payload",
+			}); err != nil {
+				t.Fatalf("Unexpected error creating event: %v", err)
+			}
+		}
+	}
+
+	var shared int64
+	if err := db.Model(&Event{}).Count(&shared).Error; err != nil {
+		t.Fatalf("Unexpected error counting events in shared table: %v", err)
+	}
+	if shared != 0 {
+		t.Errorf("Expected shared table to be empty, got %d events", shared)
+	}
+
+	for _, accountID := range accountIDs {
+		t.Run(accountID, func(t *testing.T) {
+			var stored []Event
+			if err := db.Table(partitionTable(accountID)).Find(&stored).Error; err != nil {
+				t.Fatalf("Unexpected error reading partition: %v", err)
+			}
+			if len(stored) != 2 {
+				t.Fatalf("Expected 2 events in partition, got %d", len(stored))
+			}
+			for _, e := range stored {
+				if e.AccountID != accountID {
+					t.Errorf("Found event of account %s in partition of account %s", e.AccountID, accountID)
+				}
+			}
+
+			eventIDs, err := dal.FindEventIDs(persistence.FindEventIDsQueryByAccountID(accountID))
+			if err != nil {
+				t.Fatalf("Unexpected error looking up event ids: %v", err)
+			}
+			if len(eventIDs) != 2 {
+				t.Errorf("Expected 2 event ids, got %v", eventIDs)
+			}
+
+			events, err := dal.FindEvents(persistence.FindEventsQueryForSecretIDs{
+				SecretIDs: []string{fmt.Sprintf("%s-secret", accountID)},
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error looking up events: %v", err)
+			}
+			if len(events) != 2 {
+				t.Fatalf("Expected 2 events, got %d", len(events))
+			}
+			for _, e := range events {
+				if e.AccountID != accountID {
+					t.Errorf("Unexpected event of account %s when reading account %s", e.AccountID, accountID)
+				}
+			}
+
+			account, err := dal.FindAccount(persistence.FindAccountQueryIncludeEvents{AccountID: accountID})
+			if err != nil {
+				t.Fatalf("Unexpected error looking up account: %v", err)
+			}
+			if len(account.Events) != 2 {
+				t.Errorf("Expected account to include 2 events, got %d", len(account.Events))
+			}
+		})
+	}
+
+	deleted, err := dal.DeleteEvents(persistence.DeleteEventsQueryBySecretIDs([]string{"account-a-secret"}))
+	if err != nil {
+		t.Fatalf("Unexpected error deleting events: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 events to be deleted, got %d", deleted)
+	}
+	var remaining int64
+	db.Table(partitionTable("account-b")).Count(&remaining)
+	if remaining != 2 {
+		t.Errorf("Expected events of other account to be left untouched, got %d", remaining)
+	}
+
+	size, err := dal.MeasureStorage(persistence.MeasureStorageQueryByAccountID("account-b"))
+	if err != nil {
+		t.Fatalf("Unexpected error measuring storage: %v", err)
+	}
+	if size != int64(2*len("payload")) {
+		t.Errorf("Unexpected storage size %d", size)
+	}
+}
+
+func TestRelationalDAL_EventTables_Cached(t *testing.T) {
+	db, dbClose := createTestDatabase()
+	defer dbClose()
+
+	if err := db.Create(&Account{AccountID: "account-a"}).Error; err != nil {
+		t.Fatalf("Unexpected error creating account: %v", err)
+	}
+	dal := NewRelationalDAL(db, WithEventPartitions(true)).(*relationalDAL)
+	if _, err := dal.eventTableFor("account-a"); err != nil {
+		t.Fatalf("Unexpected error creating partition: %v", err)
+	}
+
+	assertTables := func(expected ...string) {
+		t.Helper()
+		tables, err := dal.eventTables()
+		if err != nil {
+			t.Fatalf("Unexpected error looking up tables: %v", err)
+		}
+		expected = append([]string{sharedEventTable}, expected...)
+		if !reflect.DeepEqual(expected, tables) {
+			t.Errorf("Expected tables %v, got %v", expected, tables)
+		}
+	}
+	assertTables(partitionTable("account-a"))
+
+	// partitions created by others are only picked up after refreshing
+	if err := db.Create(&Account{AccountID: "account-b"}).Error; err != nil {
+		t.Fatalf("Unexpected error creating account: %v", err)
+	}
+	if err := db.Table(partitionTable("account-b")).AutoMigrate(&partitionedEvent{}); err != nil {
+		t.Fatalf("Unexpected error creating partition: %v", err)
+	}
+	assertTables(partitionTable("account-a"))
+
+	if err := dal.CreateAccount(&persistence.Account{AccountID: "account-c"}); err != nil {
+		t.Fatalf("Unexpected error creating account: %v", err)
+	}
+	assertTables(partitionTable("account-a"), partitionTable("account-b"))
+
+	if _, err := dal.eventTableFor("account-c"); err != nil {
+		t.Fatalf("Unexpected error creating partition: %v", err)
+	}
+	assertTables(partitionTable("account-a"), partitionTable("account-b"), partitionTable("account-c"))
+}
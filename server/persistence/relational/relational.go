@@ -16,15 +16,20 @@ import (
 )
 
 type relationalDAL struct {
-	db *gorm.DB
+	db         *gorm.DB
+	partitions *eventPartitions
 }
 
 // NewRelationalDAL wraps the given *gorm.DB, exposing the default
 // interface for data access layers.
-func NewRelationalDAL(db *gorm.DB) persistence.DataAccessLayer {
-	return &relationalDAL{
+func NewRelationalDAL(db *gorm.DB, configs ...Config) persistence.DataAccessLayer {
+	r := &relationalDAL{
 		db: db.Session(&gorm.Session{FullSaveAssociations: true}),
 	}
+	for _, cfg := range configs {
+		cfg(r)
+	}
+	return r
 }
 
 func (r *relationalDAL) Transaction() (persistence.Transaction, error) {
@@ -32,7 +37,7 @@ func (r *relationalDAL) Transaction() (persistence.Transaction, error) {
 	if err := txn.Error; err != nil {
 		return nil, fmt.Errorf("relational: begun transaction in error state: %w", err)
 	}
-	dal := relationalDAL{txn}
+	dal := relationalDAL{db: txn, partitions: r.partitions}
 	return &transaction{&dal}, nil
 }
 
@@ -43,6 +48,9 @@ var knownTables = []interface{}{
 	&Event{},
 	&Secret{},
 	&Tombstone{},
+	&Invite{},
+	&ConsentAuditEntry{},
+	&Session{},
 }
 
 func (r *relationalDAL) ProbeEmpty() bool {
@@ -69,13 +77,41 @@ func (r *relationalDAL) Ping() error {
 	return err
 }
 
+func (r *relationalDAL) Close() error {
+	db, err := r.db.DB()
+	if err != nil {
+		return fmt.Errorf("relational: error accessing underlying database connection: %w", err)
+	}
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("relational: error closing database: %w", err)
+	}
+	return nil
+}
+
 func (r *relationalDAL) DropAll() error {
+	if r.partitions != nil {
+		tables, err := r.eventTables()
+		if err != nil {
+			return fmt.Errorf("relational: error looking up event partitions: %w", err)
+		}
+		for _, table := range tables[1:] {
+			if err := r.db.Migrator().DropTable(table); err != nil {
+				return fmt.Errorf("relational: error dropping event partition: %w", err)
+			}
+		}
+		r.partitions.reset()
+	}
 	if err := r.db.Migrator().DropTable(
 		&Event{},
 		&Account{},
 		&Secret{},
 		&AccountUser{},
 		&AccountUserRelationship{},
+		&Tombstone{},
+		&Invite{},
+		&Session{},
+		&ConsentAuditEntry{},
+		&MigrationLock{},
 		"migrations",
 	); err != nil {
 		return fmt.Errorf("relational: error dropping tables: %w,", err)
@@ -19,7 +19,7 @@ func createTestDatabase() (*gorm.DB, func() error) {
 	if err != nil {
 		panic(err)
 	}
-	if err := db.AutoMigrate(&Event{}, &Account{}, &Secret{}, &AccountUser{}, &AccountUserRelationship{}, &Tombstone{}); err != nil {
+	if err := db.AutoMigrate(&Event{}, &Account{}, &Secret{}, &AccountUser{}, &AccountUserRelationship{}, &Tombstone{}, &Invite{}, &ConsentAuditEntry{}, &Session{}); err != nil {
 		panic(err)
 	}
 	d, _ := db.DB()
@@ -58,6 +58,16 @@ func TestRelationalDAL_DropAll(t *testing.T) {
 		t.Errorf("Unexpected error: %v", err)
 	}
 
+	for _, model := range []interface{}{
+		&Event{}, &Account{}, &Secret{}, &AccountUser{}, &AccountUserRelationship{},
+		&Tombstone{}, &Invite{}, &Session{}, &ConsentAuditEntry{}, &MigrationLock{},
+		"migrations",
+	} {
+		if db.Migrator().HasTable(model) {
+			t.Errorf("Expected table for %T to be dropped", model)
+		}
+	}
+
 	if err := dal.ApplyMigrations(); err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
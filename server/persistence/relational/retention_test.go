@@ -0,0 +1,87 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package relational
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/offen/offen/server/persistence"
+)
+
+func TestAccountRetention(t *testing.T) {
+	for _, batchSize := range []int{0, 1} {
+		t.Run(fmt.Sprintf("batch size %d", batchSize), func(t *testing.T) {
+			db, dbClose := createTestDatabase()
+			defer dbClose()
+
+			// account-a keeps events for a week, account-b uses the global
+			// retention of 30 days and account-c keeps events for 6 months
+			ages := []time.Duration{time.Hour * 24 * 10, time.Hour * 24 * 40}
+			for _, accountID := range []string{"account-a", "account-b", "account-c"} {
+				if err := db.Create(&Account{AccountID: accountID}).Error; err != nil {
+					t.Fatalf("Unexpected error creating account: %v", err)
+				}
+				for _, age := range ages {
+					eventID, err := persistence.EventIDAt(time.Now().Add(-age))
+					if err != nil {
+						t.Fatalf("Unexpected error creating event id: %v", err)
+					}
+					if err := db.Create(&Event{EventID: eventID, AccountID: accountID}).Error; err != nil {
+						t.Fatalf("Unexpected error creating event: %v", err)
+					}
+				}
+			}
+
+			p, _ := persistence.New(NewRelationalDAL(db), persistence.WithExpireBatchSize(batchSize))
+			if err := p.SetAccountRetention("account-a", time.Hour*24*7); err != nil {
+				t.Fatalf("Unexpected error setting retention: %v", err)
+			}
+			if err := p.SetAccountRetention("account-c", time.Hour*24*6*31); err != nil {
+				t.Fatalf("Unexpected error setting retention: %v", err)
+			}
+			if err := p.SetAccountRetention("account-c", -time.Hour); err == nil {
+				t.Error("Expected error when setting negative retention")
+			}
+
+			retention, err := p.GetAccountRetention("account-a")
+			if err != nil {
+				t.Fatalf("Unexpected error looking up retention: %v", err)
+			}
+			if retention != time.Hour*24*7 {
+				t.Errorf("Unexpected retention %v", retention)
+			}
+
+			result, err := p.Expire(context.Background(), time.Hour*24*30)
+			if err != nil {
+				t.Fatalf("Unexpected error expiring events: %v", err)
+			}
+			if result.Removed != 3 {
+				t.Errorf("Unexpected purge result %v", result)
+			}
+
+			for accountID, expected := range map[string]int64{"account-a": 0, "account-b": 1, "account-c": 2} {
+				var remaining int64
+				db.Model(&Event{}).Where("account_id = ?", accountID).Count(&remaining)
+				if remaining != expected {
+					t.Errorf("Expected %d remaining events for %s, got %d", expected, accountID, remaining)
+				}
+			}
+
+			if err := p.SetAccountRetention("account-c", 0); err != nil {
+				t.Fatalf("Unexpected error clearing retention: %v", err)
+			}
+			if _, err := p.Expire(context.Background(), time.Hour*24*30); err != nil {
+				t.Fatalf("Unexpected error expiring events: %v", err)
+			}
+			var remaining int64
+			db.Model(&Event{}).Where("account_id = ?", "account-c").Count(&remaining)
+			if remaining != 1 {
+				t.Errorf("Expected global retention to apply after clearing, got %d remaining events", remaining)
+			}
+		})
+	}
+}
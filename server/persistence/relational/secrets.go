@@ -19,6 +19,26 @@ func (r *relationalDAL) CreateSecret(s *persistence.Secret) error {
 	return nil
 }
 
+// IncrementEventCounter increments the event counter of the given secret and
+// returns the new value. Callers need to use a transaction that also stores
+// the event, so that concurrent callers cannot observe the same value.
+func (r *relationalDAL) IncrementEventCounter(secretID string) (int64, error) {
+	update := r.db.Model(&Secret{}).
+		Where("secret_id = ?", secretID).
+		UpdateColumn("event_counter", gorm.Expr("event_counter + ?", 1))
+	if err := update.Error; err != nil {
+		return 0, fmt.Errorf("relational: error incrementing event counter: %w", err)
+	}
+	if update.RowsAffected == 0 {
+		return 0, persistence.ErrUnknownSecret("relational: no matching secret found")
+	}
+	var secret Secret
+	if err := r.db.Select("event_counter").Where("secret_id = ?", secretID).First(&secret).Error; err != nil {
+		return 0, fmt.Errorf("relational: error reading event counter: %w", err)
+	}
+	return secret.EventCounter, nil
+}
+
 func (r *relationalDAL) DeleteSecret(q interface{}) error {
 	switch query := q.(type) {
 	case persistence.DeleteSecretQueryBySecretID:
@@ -26,6 +46,11 @@ func (r *relationalDAL) DeleteSecret(q interface{}) error {
 			return fmt.Errorf("relational: error deleting secret: %w", err)
 		}
 		return nil
+	case persistence.DeleteSecretQueryByAccountID:
+		if err := r.db.Where("account_id = ?", string(query)).Delete(&Secret{}).Error; err != nil {
+			return fmt.Errorf("relational: error deleting secrets by account id: %w", err)
+		}
+		return nil
 	default:
 		return persistence.ErrBadQuery
 	}
@@ -49,3 +74,16 @@ func (r *relationalDAL) FindSecret(q interface{}) (persistence.Secret, error) {
 		return secret.export(), persistence.ErrBadQuery
 	}
 }
+
+func (r *relationalDAL) CountSecrets(q interface{}) (int64, error) {
+	switch query := q.(type) {
+	case persistence.CountSecretsQueryByAccountID:
+		var count int64
+		if err := r.db.Model(&Secret{}).Where("account_id = ? AND parked = ?", string(query), false).Count(&count).Error; err != nil {
+			return 0, fmt.Errorf("relational: error counting secrets: %w", err)
+		}
+		return count, nil
+	default:
+		return 0, persistence.ErrBadQuery
+	}
+}
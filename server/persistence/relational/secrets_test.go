@@ -15,10 +15,11 @@ import (
 
 func TestRelationalDAL_CreateSecret(t *testing.T) {
 	tests := []struct {
-		name      string
-		setup     dbAccess
-		secret    *persistence.Secret
-		assertion dbAccess
+		name        string
+		setup       dbAccess
+		secret      *persistence.Secret
+		expectError bool
+		assertion   dbAccess
 	}{
 		{
 			"ok",
@@ -27,6 +28,7 @@ func TestRelationalDAL_CreateSecret(t *testing.T) {
 				SecretID:        "hashed-id-1",
 				EncryptedSecret: "encrypted-secret",
 			},
+			false,
 			func(db *gorm.DB) error {
 				var secret Secret
 				if err := db.Where("secret_id = ?", "hashed-id-1").First(&secret).Error; err != nil {
@@ -38,6 +40,27 @@ func TestRelationalDAL_CreateSecret(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			"existing secret",
+			func(db *gorm.DB) error {
+				return db.Create(&Secret{SecretID: "hashed-id-1", EncryptedSecret: "other-secret"}).Error
+			},
+			&persistence.Secret{
+				SecretID:        "hashed-id-1",
+				EncryptedSecret: "encrypted-secret",
+			},
+			true,
+			func(db *gorm.DB) error {
+				var secrets []Secret
+				if err := db.Where("secret_id = ?", "hashed-id-1").Find(&secrets).Error; err != nil {
+					return fmt.Errorf("error looking up records: %w", err)
+				}
+				if len(secrets) != 1 || secrets[0].EncryptedSecret != "other-secret" {
+					return fmt.Errorf("unexpected user secrets %v", secrets)
+				}
+				return nil
+			},
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -48,8 +71,8 @@ func TestRelationalDAL_CreateSecret(t *testing.T) {
 			if err := test.setup(db); err != nil {
 				t.Fatalf("Unexpected error running setup: %v", err)
 			}
-			if err := dal.CreateSecret(test.secret); err != nil {
-				t.Errorf("Unexpected error creating secret: %v", err)
+			if err := dal.CreateSecret(test.secret); (err != nil) != test.expectError {
+				t.Errorf("Unexpected error value %v", err)
 			}
 			if err := test.assertion(db); err != nil {
 				t.Errorf("Encountered assertion error: %v", err)
@@ -197,3 +220,58 @@ func TestRelationalDAL_FindSecret(t *testing.T) {
 		})
 	}
 }
+
+func TestRelationalDAL_CountSecrets(t *testing.T) {
+	tests := []struct {
+		name           string
+		setup          dbAccess
+		query          interface{}
+		expectedResult int64
+		expectError    bool
+	}{
+		{
+			"bad query",
+			noop,
+			"account-a",
+			0,
+			true,
+		},
+		{
+			"ok",
+			func(db *gorm.DB) error {
+				for _, s := range []Secret{
+					{SecretID: "secret-a", AccountID: "account-a"},
+					{SecretID: "secret-b", AccountID: "account-a"},
+					{SecretID: "secret-c", AccountID: "account-b"},
+					{SecretID: "secret-d", AccountID: "account-a", Parked: true},
+				} {
+					if err := db.Create(&s).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			persistence.CountSecretsQueryByAccountID("account-a"),
+			2,
+			false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, dbClose := createTestDatabase()
+			defer dbClose()
+			dal := NewRelationalDAL(db)
+
+			if err := test.setup(db); err != nil {
+				t.Fatalf("Unexpected error running setup: %v", err)
+			}
+			result, err := dal.CountSecrets(test.query)
+			if (err != nil) != test.expectError {
+				t.Errorf("Unexpected error value %v", err)
+			}
+			if result != test.expectedResult {
+				t.Errorf("Expected %d, got %d", test.expectedResult, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package relational
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/offen/offen/server/keys"
+	"github.com/offen/offen/server/persistence"
+)
+
+func TestInsertSequenced_Concurrent(t *testing.T) {
+	db, dbClose := createTestDatabase()
+	defer dbClose()
+	// in memory databases are not shared between connections
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+
+	salt, err := keys.NewFastSalt(keys.DefaultSecretLength)
+	if err != nil {
+		t.Fatalf("Unexpected error creating salt: %v", err)
+	}
+	if err := db.Create(&Account{AccountID: "account-a", UserSalt: salt.Marshal()}).Error; err != nil {
+		t.Fatalf("Unexpected error creating account: %v", err)
+	}
+
+	p, _ := persistence.New(NewRelationalDAL(db))
+	userIDs := []string{"user-a", "user-b"}
+	for _, userID := range userIDs {
+		if err := p.AssociateUserSecret("account-a", userID, "secret"); err != nil {
+			t.Fatalf("Unexpected error creating user: %v", err)
+		}
+	}
+
+	const eventsPerUser = 25
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sequences := map[string][]int64{}
+	for _, userID := range userIDs {
+		for i := 0; i < eventsPerUser; i++ {
+			wg.Add(1)
+			go func(userID string) {
+				defer wg.Done()
+				sequence, err := p.InsertSequenced(userID, "account-a", "payload", "", false, nil)
+				if err != nil {
+					t.Errorf("Unexpected error inserting event: %v", err)
+					return
+				}
+				mu.Lock()
+				sequences[userID] = append(sequences[userID], sequence)
+				mu.Unlock()
+			}(userID)
+		}
+	}
+	wg.Wait()
+
+	for _, userID := range userIDs {
+		values := sequences[userID]
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+		if len(values) != eventsPerUser {
+			t.Fatalf("Expected %d sequence numbers for %s, got %d", eventsPerUser, userID, len(values))
+		}
+		for i, value := range values {
+			if value != int64(i+1) {
+				t.Fatalf("Expected gapless unique sequence numbers for %s, got %v", userID, values)
+			}
+		}
+
+		result, err := p.Query(persistence.Query{UserID: userID})
+		if err != nil {
+			t.Fatalf("Unexpected error querying events: %v", err)
+		}
+		var stored []int64
+		for _, evt := range (*result.Events)["account-a"] {
+			stored = append(stored, evt.UserSequence)
+		}
+		// events are returned in the order they have been created, which
+		// needs to match the order of the assigned numbers
+		if !sort.SliceIsSorted(stored, func(i, j int) bool { return stored[i] < stored[j] }) || len(stored) != eventsPerUser {
+			t.Errorf("Expected stored events of %s to be numbered monotonically, got %v", userID, stored)
+		}
+	}
+
+	if _, err := p.InsertSequenced("", "account-a", "payload", "", false, nil); err == nil {
+		t.Error("Expected error when sequencing anonymous event")
+	}
+}
+
+func TestInsertEvents_Sequenced(t *testing.T) {
+	db, dbClose := createTestDatabase()
+	defer dbClose()
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+
+	salt, err := keys.NewFastSalt(keys.DefaultSecretLength)
+	if err != nil {
+		t.Fatalf("Unexpected error creating salt: %v", err)
+	}
+	if err := db.Create(&Account{AccountID: "account-a", UserSalt: salt.Marshal()}).Error; err != nil {
+		t.Fatalf("Unexpected error creating account: %v", err)
+	}
+
+	p, _ := persistence.New(NewRelationalDAL(db))
+	if err := p.AssociateUserSecret("account-a", "user-a", "secret"); err != nil {
+		t.Fatalf("Unexpected error creating user: %v", err)
+	}
+
+	batch := []persistence.EventInput{
+		{AccountID: "account-a", Payload: "payload-a"},
+		{AccountID: "account-a", Payload: "payload-b"},
+	}
+	sequences, err := p.InsertEvents("user-a", batch, true)
+	if err != nil {
+		t.Fatalf("Unexpected error inserting events: %v", err)
+	}
+	if len(sequences) != 2 || sequences[0] != 1 || sequences[1] != 2 {
+		t.Errorf("Unexpected sequence numbers %v", sequences)
+	}
+
+	_, err = p.InsertEvents("user-a", append(batch, persistence.EventInput{AccountID: "account-z", Payload: "payload"}), true)
+	var batchErr *persistence.BatchInsertError
+	if !errors.As(err, &batchErr) || batchErr.Index != 2 {
+		t.Fatalf("Expected error for event at index 2, got %v", err)
+	}
+
+	result, err := p.Query(persistence.Query{UserID: "user-a"})
+	if err != nil {
+		t.Fatalf("Unexpected error querying events: %v", err)
+	}
+	if stored := (*result.Events)["account-a"]; len(stored) != 2 {
+		t.Errorf("Expected failed batch not to be stored, got %d events", len(stored))
+	}
+}
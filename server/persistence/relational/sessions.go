@@ -0,0 +1,56 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package relational
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/offen/offen/server/persistence"
+	"gorm.io/gorm"
+)
+
+func (r *relationalDAL) CreateSession(s *persistence.Session) error {
+	local := importSession(s)
+	if err := r.db.Create(&local).Error; err != nil {
+		return fmt.Errorf("relational: error creating session: %w", err)
+	}
+	return nil
+}
+
+func (r *relationalDAL) FindSession(q interface{}) (persistence.Session, error) {
+	var session Session
+	switch query := q.(type) {
+	case persistence.FindSessionQueryUnexpiredByID:
+		if err := r.db.Where("session_id = ? AND expires > ?", query.SessionID, query.Now).First(&session).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return session.export(), fmt.Errorf("relational: no matching session found: %w", persistence.ErrUnknownSession)
+			}
+			return session.export(), fmt.Errorf("relational: error looking up session: %w", err)
+		}
+		return session.export(), nil
+	default:
+		return session.export(), persistence.ErrBadQuery
+	}
+}
+
+func (r *relationalDAL) DeleteSessions(q interface{}) (int64, error) {
+	switch query := q.(type) {
+	case persistence.DeleteSessionsQueryByID:
+		result := r.db.Where("session_id = ?", string(query)).Delete(&Session{})
+		if result.Error != nil {
+			return 0, fmt.Errorf("relational: error deleting session: %w", result.Error)
+		}
+		return result.RowsAffected, nil
+	case persistence.DeleteSessionsQueryExpired:
+		result := r.db.Where("expires <= ?", time.Time(query)).Delete(&Session{})
+		if result.Error != nil {
+			return 0, fmt.Errorf("relational: error deleting expired sessions: %w", result.Error)
+		}
+		return result.RowsAffected, nil
+	default:
+		return 0, persistence.ErrBadQuery
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package relational
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/offen/offen/server/persistence"
+)
+
+func TestSessions(t *testing.T) {
+	db, dbClose := createTestDatabase()
+	defer dbClose()
+
+	if err := db.Create(&AccountUser{AccountUserID: "account-user-a"}).Error; err != nil {
+		t.Fatalf("Unexpected error creating account user: %v", err)
+	}
+	if err := db.Create(&Session{SessionID: "session-expired", AccountUserID: "account-user-a", Expires: time.Now().Add(-time.Hour)}).Error; err != nil {
+		t.Fatalf("Unexpected error creating session: %v", err)
+	}
+
+	p, _ := persistence.New(NewRelationalDAL(db))
+	session, err := p.CreateSession("account-user-a", time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error creating session: %v", err)
+	}
+	if session.SessionID == "" || session.AccountUserID != "account-user-a" {
+		t.Errorf("Unexpected session %v", session)
+	}
+
+	var count int64
+	db.Model(&Session{}).Where("session_id = ?", "session-expired").Count(&count)
+	if count != 0 {
+		t.Error("Expected expired session to be removed")
+	}
+
+	result, err := p.LookupSession(session.SessionID)
+	if err != nil {
+		t.Fatalf("Unexpected error looking up session: %v", err)
+	}
+	if result.AccountUserID != "account-user-a" {
+		t.Errorf("Unexpected result %v", result)
+	}
+
+	if err := p.DeleteSession(session.SessionID); err != nil {
+		t.Fatalf("Unexpected error deleting session: %v", err)
+	}
+	if _, err := p.LookupSession(session.SessionID); !errors.Is(err, persistence.ErrUnknownSession) {
+		t.Errorf("Expected deleted session to be unknown, got %v", err)
+	}
+	if err := p.DeleteSession(session.SessionID); err != nil {
+		t.Errorf("Expected deleting an unknown session to succeed, got %v", err)
+	}
+}
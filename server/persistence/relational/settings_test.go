@@ -0,0 +1,40 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package relational
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/offen/offen/server/persistence"
+)
+
+func TestAccountSettings(t *testing.T) {
+	db, dbClose := createTestDatabase()
+	defer dbClose()
+	if err := db.Create(&Account{AccountID: "account-a"}).Error; err != nil {
+		t.Fatalf("Unexpected error creating account: %v", err)
+	}
+
+	p, _ := persistence.New(NewRelationalDAL(db))
+	settings := persistence.AccountSettings{
+		StrictEventDecoding:  true,
+		EmailSender:          "Offen <hioffen@example.com>",
+		Timezone:             "Europe/Berlin",
+		AllowedOrigins:       []string{"https://www.example.com"},
+		AllowExpiryExemption: true,
+		AllowedCountries:     []string{"DE", "FR"},
+		ConsentExempt:        true,
+	}
+	if err := p.UpdateAccountSettings("account-a", settings); err != nil {
+		t.Fatalf("Unexpected error updating settings: %v", err)
+	}
+	stored, err := p.GetAccountSettings("account-a")
+	if err != nil {
+		t.Fatalf("Unexpected error looking up settings: %v", err)
+	}
+	if !reflect.DeepEqual(settings, stored) {
+		t.Errorf("Expected settings %v to be stored, got %v", settings, stored)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package relational
+
+import (
+	"fmt"
+
+	"github.com/offen/offen/server/persistence"
+	"gorm.io/gorm"
+)
+
+// byteLength returns an SQL expression that computes the size in bytes of the
+// given column using the function available in the current dialect.
+func (r *relationalDAL) byteLength(column string) string {
+	switch r.db.Dialector.Name() {
+	case "postgres":
+		return fmt.Sprintf("OCTET_LENGTH(%s)", column)
+	case "sqlite":
+		return fmt.Sprintf("LENGTH(CAST(%s AS BLOB))", column)
+	default:
+		return fmt.Sprintf("LENGTH(%s)", column)
+	}
+}
+
+func (r *relationalDAL) MeasureStorage(q interface{}) (int64, error) {
+	switch query := q.(type) {
+	case persistence.MeasureStorageQueryByAccountID:
+		var eventBytes, secretBytes int64
+		secrets := r.db.Model(&Secret{}).Select(fmt.Sprintf("COALESCE(SUM(%s), 0)", r.byteLength("encrypted_secret")))
+		if err := r.eachEventTable(func(db *gorm.DB) error {
+			var tableBytes int64
+			if err := db.
+				Select(fmt.Sprintf("COALESCE(SUM(%s), 0)", r.byteLength("payload"))).
+				Where("account_id = ?", string(query)).
+				Scan(&tableBytes).Error; err != nil {
+				return err
+			}
+			eventBytes += tableBytes
+			// Secrets might be referenced by events in more than one table,
+			// so conditions are combined instead of summing up each table.
+			secretIDs := db.Session(&gorm.Session{NewDB: true}).
+				Table(db.Statement.Table).
+				Distinct("secret_id").
+				Where("account_id = ?", string(query))
+			secrets = secrets.Or("secret_id IN (?)", secretIDs)
+			return nil
+		}, string(query)); err != nil {
+			return 0, fmt.Errorf("relational: error measuring size of events: %w", err)
+		}
+
+		if err := secrets.Scan(&secretBytes).Error; err != nil {
+			return 0, fmt.Errorf("relational: error measuring size of secrets: %w", err)
+		}
+		return eventBytes + secretBytes, nil
+	default:
+		return 0, persistence.ErrBadQuery
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package relational
+
+import (
+	"testing"
+
+	"github.com/offen/offen/server/persistence"
+)
+
+func TestRelationalDAL_MeasureStorage(t *testing.T) {
+	t.Run("bad query", func(t *testing.T) {
+		db, closeDB := createTestDatabase()
+		defer closeDB()
+
+		dal := NewRelationalDAL(db)
+		if _, err := dal.MeasureStorage("account-a"); err != persistence.ErrBadQuery {
+			t.Errorf("Expected ErrBadQuery, got %v", err)
+		}
+	})
+	t.Run("size increases with inserted data", func(t *testing.T) {
+		db, closeDB := createTestDatabase()
+		defer closeDB()
+
+		dal := NewRelationalDAL(db)
+		query := persistence.MeasureStorageQueryByAccountID("account-a")
+
+		initial, err := dal.MeasureStorage(query)
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if initial != 0 {
+			t.Errorf("Expected empty database to have size 0, got %d", initial)
+		}
+
+		secretID := "secret-a"
+		if err := db.Create(&Secret{SecretID: secretID, EncryptedSecret: "encrypted"}).Error; err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if err := dal.CreateEvent(&persistence.Event{
+			EventID:   "event-a",
+			AccountID: "account-a",
+			SecretID:  &secretID,
+			Payload:   "payload",
+		}); err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		afterFirst, err := dal.MeasureStorage(query)
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if afterFirst != int64(len("payload")+len("encrypted")) {
+			t.Errorf("Unexpected size %d", afterFirst)
+		}
+
+		if err := dal.CreateEvent(&persistence.Event{
+			EventID:   "event-b",
+			AccountID: "account-a",
+			Payload:   "another payload",
+		}); err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if err := dal.CreateEvent(&persistence.Event{
+			EventID:   "event-c",
+			AccountID: "account-b",
+			Payload:   "other account",
+		}); err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		afterSecond, err := dal.MeasureStorage(query)
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if afterSecond != afterFirst+int64(len("another payload")) {
+			t.Errorf("Unexpected size %d", afterSecond)
+		}
+	})
+}
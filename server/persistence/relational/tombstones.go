@@ -31,7 +31,11 @@ func (r *relationalDAL) FindTombstones(q interface{}) ([]persistence.Tombstone,
 		return export, nil
 	case persistence.FindTombstonesQueryBySecrets:
 		var result []Tombstone
-		if err := r.db.Find(&result, "secret_id IN (?) AND sequence > ?", query.SecretIDs, query.Since).Error; err != nil {
+		find := r.db.Where("secret_id IN (?) AND sequence > ?", query.SecretIDs, query.Since)
+		if query.Until != "" {
+			find = find.Where("sequence <= ?", query.Until)
+		}
+		if err := find.Find(&result).Error; err != nil {
 			return nil, fmt.Errorf("relational: error looking up tombstones by secret ids: %w", err)
 		}
 		var export []persistence.Tombstone
@@ -18,15 +18,26 @@ type EventsResult struct {
 	DeletedEvents   []string           `json:"deletedEvents,omitempty"`
 	Sequence        string             `json:"sequence,omitempty"`
 	RetentionPeriod string             `json:"retentionPeriod,omitempty"`
+	NextCursor      string             `json:"nextCursor,omitempty"`
+}
+
+// UserExportResult contains all data that is stored about a single user.
+type UserExportResult struct {
+	Events  EventsByAccountID `json:"events"`
+	Secrets []SecretResult    `json:"secrets"`
 }
 
 // EventResult is an element returned from a query. It contains all data that
 // is stored about an atomic event.
 type EventResult struct {
-	AccountID string  `json:"accountId,omitempty"`
-	SecretID  *string `json:"secretId,omitempty"`
-	EventID   string  `json:"eventId"`
-	Payload   string  `json:"payload"`
+	AccountID   string  `json:"accountId,omitempty"`
+	SecretID    *string `json:"secretId,omitempty"`
+	EventID     string  `json:"eventId"`
+	Payload     string  `json:"payload"`
+	ContentHash string  `json:"contentHash,omitempty"`
+	// UserSequence is only set for events that have been sequenced by the
+	// server.
+	UserSequence int64 `json:"userSequence,omitempty"`
 }
 
 // EventsByAccountID groups a list of events by AccountID in a response
@@ -49,6 +60,9 @@ type AccountResult struct {
 	AccountStyles       string                `json:"accountStyles,omitempty"`
 	Created             time.Time             `json:"created,omitempty"`
 	RetentionPeriod     string                `json:"retentionPeriod,omitempty"`
+	// Retention is the retention period configured for the account. A zero
+	// value means the global retention period applies.
+	Retention time.Duration `json:"-"`
 }
 
 // ShareAccountResult is a successful invitation of a user
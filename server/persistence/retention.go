@@ -0,0 +1,54 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GetAccountRetention returns the retention period configured for the given
+// account. A zero value means the global retention period applies.
+func (p *persistenceLayer) GetAccountRetention(accountID string) (time.Duration, error) {
+	a, err := p.dal.FindAccount(FindAccountQueryActiveByID(accountID))
+	if err != nil {
+		return 0, fmt.Errorf("persistence: error looking up account %s: %w", accountID, err)
+	}
+	return a.Retention, nil
+}
+
+// SetAccountRetention sets the retention period for events of the given
+// account. Passing zero makes the account use the global retention period
+// again.
+func (p *persistenceLayer) SetAccountRetention(accountID string, d time.Duration) error {
+	if d < 0 {
+		return errors.New("persistence: retention period must not be negative")
+	}
+	a, err := p.dal.FindAccount(FindAccountQueryActiveByID(accountID))
+	if err != nil {
+		return fmt.Errorf("persistence: error looking up account %s before updating retention: %w", accountID, err)
+	}
+	a.Retention = d
+	if err := p.dal.UpdateAccount(&a); err != nil {
+		return fmt.Errorf("persistence: error updating retention for account %s: %w", accountID, err)
+	}
+	return nil
+}
+
+// accountRetentions returns the retention periods of all accounts that do
+// not use the global retention period.
+func (p *persistenceLayer) accountRetentions() (map[string]time.Duration, error) {
+	accounts, err := p.dal.FindAccounts(FindAccountsQueryAllAccounts{})
+	if err != nil {
+		return nil, fmt.Errorf("persistence: error looking up accounts: %w", err)
+	}
+	retentions := map[string]time.Duration{}
+	for _, a := range accounts {
+		if a.Retention > 0 {
+			retentions[a.AccountID] = a.Retention
+		}
+	}
+	return retentions, nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// EventSchema is a JSON schema describing the events collected for an
+// account. Its version is incremented on each update, so clients can signal
+// which version of the schema they are using.
+type EventSchema struct {
+	Version int             `json:"version"`
+	Schema  json.RawMessage `json:"schema"`
+}
+
+func (p *persistenceLayer) GetEventSchema(accountID string) (EventSchema, error) {
+	a, err := p.dal.FindAccount(FindAccountQueryActiveByID(accountID))
+	if err != nil {
+		return EventSchema{}, fmt.Errorf("persistence: error looking up account %s: %w", accountID, err)
+	}
+	return a.eventSchema(), nil
+}
+
+func (p *persistenceLayer) UpdateEventSchema(accountID string, schema json.RawMessage) (EventSchema, error) {
+	a, err := p.dal.FindAccount(FindAccountQueryActiveByID(accountID))
+	if err != nil {
+		return EventSchema{}, fmt.Errorf("persistence: error looking up account %s before updating event schema: %w", accountID, err)
+	}
+
+	a.EventSchema = string(schema)
+	a.EventSchemaVersion++
+	if err := p.dal.UpdateAccount(&a); err != nil {
+		return EventSchema{}, fmt.Errorf("persistence: error updating event schema for account %s: %w", accountID, err)
+	}
+	return a.eventSchema(), nil
+}
+
+func (a *Account) eventSchema() EventSchema {
+	result := EventSchema{Version: a.EventSchemaVersion}
+	if a.EventSchema != "" {
+		result.Schema = json.RawMessage(a.EventSchema)
+	}
+	return result
+}
@@ -0,0 +1,41 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPersistenceLayer_UpdateEventSchema(t *testing.T) {
+	t.Run("lookup error", func(t *testing.T) {
+		p := &persistenceLayer{dal: &mockAccountSettingsDatabase{
+			findAccountErr: errors.New("did not work"),
+		}}
+		if _, err := p.UpdateEventSchema("account-a", json.RawMessage(`{}`)); err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+	t.Run("ok", func(t *testing.T) {
+		db := &mockAccountSettingsDatabase{
+			findAccountResult: Account{
+				AccountID:          "account-a",
+				EventSchema:        `{"type":"string"}`,
+				EventSchemaVersion: 2,
+			},
+		}
+		p := &persistenceLayer{dal: db}
+		result, err := p.UpdateEventSchema("account-a", json.RawMessage(`{"type":"object"}`))
+		if err != nil {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if result.Version != 3 || string(result.Schema) != `{"type":"object"}` {
+			t.Errorf("Unexpected result %v", result)
+		}
+		if db.updated == nil || db.updated.EventSchemaVersion != 3 || db.updated.EventSchema != `{"type":"object"}` {
+			t.Errorf("Unexpected update %v", db.updated)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/offen/offen/server/keys"
+)
+
+// sessionIDLength is the number of random bytes used for a session id.
+const sessionIDLength = 32
+
+// CreateSession persists a new session for the given account user that
+// expires after the given duration. Sessions that have already expired are
+// removed.
+func (p *persistenceLayer) CreateSession(accountUserID string, ttl time.Duration) (Session, error) {
+	sessionID, err := keys.GenerateRandomValueWith(sessionIDLength, base64.RawURLEncoding)
+	if err != nil {
+		return Session{}, fmt.Errorf("persistence: error creating session id: %w", err)
+	}
+	now := time.Now()
+	if _, err := p.dal.DeleteSessions(DeleteSessionsQueryExpired(now)); err != nil {
+		return Session{}, fmt.Errorf("persistence: error removing expired sessions: %w", err)
+	}
+	session := Session{
+		SessionID:     sessionID,
+		AccountUserID: accountUserID,
+		Expires:       now.Add(ttl),
+	}
+	if err := p.dal.CreateSession(&session); err != nil {
+		return Session{}, fmt.Errorf("persistence: error persisting session: %w", err)
+	}
+	return session, nil
+}
+
+// LookupSession returns the account user the session of the given id belongs
+// to. In case the session is unknown or has expired, ErrUnknownSession is
+// returned.
+func (p *persistenceLayer) LookupSession(sessionID string) (LoginResult, error) {
+	session, err := p.dal.FindSession(FindSessionQueryUnexpiredByID{
+		SessionID: sessionID,
+		Now:       time.Now(),
+	})
+	if err != nil {
+		return LoginResult{}, fmt.Errorf("persistence: error looking up session: %w", err)
+	}
+	return p.LookupAccountUser(session.AccountUserID)
+}
+
+// DeleteSession ends the session of the given id. Deleting a session that
+// does not exist is not considered an error.
+func (p *persistenceLayer) DeleteSession(sessionID string) error {
+	if _, err := p.dal.DeleteSessions(DeleteSessionsQueryByID(sessionID)); err != nil {
+		return fmt.Errorf("persistence: error deleting session: %w", err)
+	}
+	return nil
+}
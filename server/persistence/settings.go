@@ -0,0 +1,79 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AccountSettings contains configuration values that can be set for each
+// account individually.
+type AccountSettings struct {
+	StrictEventDecoding  bool     `json:"strictEventDecoding"`
+	EmailSender          string   `json:"emailSender"`
+	Timezone             string   `json:"timezone"`
+	AllowedOrigins       []string `json:"allowedOrigins"`
+	AllowExpiryExemption bool     `json:"allowExpiryExemption"`
+	AllowedCountries     []string `json:"allowedCountries"`
+	DeniedCountries      []string `json:"deniedCountries"`
+	// ConsentExempt makes the account accept events from users that have not
+	// opted in. Such events are always stored anonymously. This must only be
+	// enabled for accounts that have a legal basis for collecting data without
+	// consent.
+	ConsentExempt bool `json:"consentExempt"`
+}
+
+func (p *persistenceLayer) GetAccountSettings(accountID string) (AccountSettings, error) {
+	a, err := p.dal.FindAccount(FindAccountQueryActiveByID(accountID))
+	if err != nil {
+		return AccountSettings{}, fmt.Errorf("persistence: error looking up account %s: %w", accountID, err)
+	}
+	return a.Settings, nil
+}
+
+func (p *persistenceLayer) UpdateAccountSettings(accountID string, settings AccountSettings) error {
+	a, err := p.dal.FindAccount(FindAccountQueryActiveByID(accountID))
+	if err != nil {
+		return fmt.Errorf("persistence: error looking up account %s before updating settings: %w", accountID, err)
+	}
+
+	a.Settings = settings
+	if err := p.dal.UpdateAccount(&a); err != nil {
+		return fmt.Errorf("persistence: error updating settings for account %s: %w", accountID, err)
+	}
+	return nil
+}
+
+// EmailSender returns the sender identity that should be used for emails sent
+// to the account user with the given email address. An account specific sender
+// is only returned if all of the user's accounts agree on it. In all other
+// cases an empty string is returned and callers are expected to use the
+// global default.
+func (p *persistenceLayer) EmailSender(emailAddress string) (string, error) {
+	accountUser, err := p.findAccountUser(emailAddress, true, false)
+	if err != nil {
+		return "", fmt.Errorf("persistence: error looking up account user: %w", err)
+	}
+
+	var sender string
+	for _, relationship := range accountUser.Relationships {
+		account, err := p.dal.FindAccount(FindAccountQueryActiveByID(relationship.AccountID))
+		if err != nil {
+			var unknownAccount ErrUnknownAccount
+			if errors.As(err, &unknownAccount) {
+				continue
+			}
+			return "", fmt.Errorf("persistence: error looking up account %s: %w", relationship.AccountID, err)
+		}
+		if account.Settings.EmailSender == "" {
+			return "", nil
+		}
+		if sender != "" && sender != account.Settings.EmailSender {
+			return "", nil
+		}
+		sender = account.Settings.EmailSender
+	}
+	return sender, nil
+}
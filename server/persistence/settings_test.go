@@ -0,0 +1,173 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/offen/offen/server/keys"
+)
+
+type mockAccountSettingsDatabase struct {
+	DataAccessLayer
+	findAccountResult Account
+	findAccountErr    error
+	updateAccountErr  error
+	updated           *Account
+}
+
+func (m *mockAccountSettingsDatabase) FindAccount(interface{}) (Account, error) {
+	return m.findAccountResult, m.findAccountErr
+}
+
+func (m *mockAccountSettingsDatabase) UpdateAccount(a *Account) error {
+	m.updated = a
+	return m.updateAccountErr
+}
+
+func TestPersistenceLayer_GetAccountSettings(t *testing.T) {
+	t.Run("lookup error", func(t *testing.T) {
+		p := &persistenceLayer{dal: &mockAccountSettingsDatabase{
+			findAccountErr: errors.New("did not work"),
+		}}
+		if _, err := p.GetAccountSettings("account-a"); err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+	t.Run("ok", func(t *testing.T) {
+		p := &persistenceLayer{dal: &mockAccountSettingsDatabase{
+			findAccountResult: Account{
+				AccountID: "account-a",
+				Settings:  AccountSettings{StrictEventDecoding: true},
+			},
+		}}
+		settings, err := p.GetAccountSettings("account-a")
+		if err != nil {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if !settings.StrictEventDecoding {
+			t.Errorf("Unexpected settings %v", settings)
+		}
+	})
+}
+
+func TestPersistenceLayer_UpdateAccountSettings(t *testing.T) {
+	t.Run("lookup error", func(t *testing.T) {
+		p := &persistenceLayer{dal: &mockAccountSettingsDatabase{
+			findAccountErr: errors.New("did not work"),
+		}}
+		if err := p.UpdateAccountSettings("account-a", AccountSettings{}); err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+	t.Run("update error", func(t *testing.T) {
+		p := &persistenceLayer{dal: &mockAccountSettingsDatabase{
+			updateAccountErr: errors.New("did not work"),
+		}}
+		if err := p.UpdateAccountSettings("account-a", AccountSettings{}); err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+	t.Run("ok", func(t *testing.T) {
+		dal := &mockAccountSettingsDatabase{
+			findAccountResult: Account{AccountID: "account-a", Name: "name"},
+		}
+		p := &persistenceLayer{dal: dal}
+		if err := p.UpdateAccountSettings("account-a", AccountSettings{StrictEventDecoding: true}); err != nil {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if dal.updated.Name != "name" || !dal.updated.Settings.StrictEventDecoding {
+			t.Errorf("Unexpected update %v", dal.updated)
+		}
+	})
+}
+
+type mockEmailSenderDatabase struct {
+	DataAccessLayer
+	accountUsers []AccountUser
+	accounts     map[string]Account
+}
+
+func (m *mockEmailSenderDatabase) FindAccountUsers(interface{}) ([]AccountUser, error) {
+	return m.accountUsers, nil
+}
+
+func (m *mockEmailSenderDatabase) FindAccount(q interface{}) (Account, error) {
+	if account, ok := m.accounts[string(q.(FindAccountQueryActiveByID))]; ok {
+		return account, nil
+	}
+	return Account{}, ErrUnknownAccount("not found")
+}
+
+func TestPersistenceLayer_EmailSender(t *testing.T) {
+	hashedEmail, err := keys.HashString("develop@offen.dev")
+	if err != nil {
+		t.Fatalf("Unexpected error hashing email: %v", err)
+	}
+	accountUsers := []AccountUser{
+		{
+			AccountUserID: "user-a",
+			HashedEmail:   hashedEmail.Marshal(),
+			Relationships: []AccountUserRelationship{
+				{AccountID: "account-a"},
+				{AccountID: "account-b"},
+				{AccountID: "account-retired"},
+			},
+		},
+	}
+	tests := []struct {
+		name           string
+		accounts       map[string]Account
+		expectedResult string
+	}{
+		{
+			"no sender configured",
+			map[string]Account{
+				"account-a": {},
+				"account-b": {},
+			},
+			"",
+		},
+		{
+			"partially configured",
+			map[string]Account{
+				"account-a": {Settings: AccountSettings{EmailSender: "hello@brand.example"}},
+				"account-b": {},
+			},
+			"",
+		},
+		{
+			"conflicting senders",
+			map[string]Account{
+				"account-a": {Settings: AccountSettings{EmailSender: "hello@brand.example"}},
+				"account-b": {Settings: AccountSettings{EmailSender: "hello@other.example"}},
+			},
+			"",
+		},
+		{
+			"ok",
+			map[string]Account{
+				"account-a": {Settings: AccountSettings{EmailSender: "hello@brand.example"}},
+				"account-b": {Settings: AccountSettings{EmailSender: "hello@brand.example"}},
+			},
+			"hello@brand.example",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &persistenceLayer{dal: &mockEmailSenderDatabase{
+				accountUsers: accountUsers,
+				accounts:     test.accounts,
+			}}
+			result, err := p.EmailSender("develop@offen.dev")
+			if err != nil {
+				t.Errorf("Unexpected error %v", err)
+			}
+			if result != test.expectedResult {
+				t.Errorf("Expected %s, got %s", test.expectedResult, result)
+			}
+		})
+	}
+}
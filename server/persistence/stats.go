@@ -0,0 +1,107 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid"
+)
+
+// AccountStorageBytes returns the number of bytes used for storing event
+// payloads and encrypted user secrets for the account of the given id.
+func (p *persistenceLayer) AccountStorageBytes(accountID string) (int64, error) {
+	if _, err := p.dal.FindAccount(FindAccountQueryActiveByID(accountID)); err != nil {
+		return 0, fmt.Errorf("persistence: error looking up account %s: %w", accountID, err)
+	}
+	size, err := p.dal.MeasureStorage(MeasureStorageQueryByAccountID(accountID))
+	if err != nil {
+		return 0, fmt.Errorf("persistence: error measuring storage for account %s: %w", accountID, err)
+	}
+	return size, nil
+}
+
+// eventDayPrefixLength is the number of leading characters of event ids
+// that events are grouped by in the database when counting events per day.
+// As event ids are ULIDs, each group covers about 17 minutes.
+const eventDayPrefixLength = 6
+
+// AccountEventsPerDay returns the number of events stored for the account
+// of the given id, grouped by the day they were created on. Days are
+// formatted as YYYY-MM-DD and are aligned to the timezone configured for
+// the account, defaulting to UTC.
+func (p *persistenceLayer) AccountEventsPerDay(accountID string) (map[string]int, error) {
+	account, err := p.dal.FindAccount(FindAccountQueryActiveByID(accountID))
+	if err != nil {
+		return nil, fmt.Errorf("persistence: error looking up account %s: %w", accountID, err)
+	}
+	location, err := time.LoadLocation(account.Settings.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("persistence: error loading timezone for account %s: %w", accountID, err)
+	}
+
+	// event ids are ULIDs so the time of creation can be derived without
+	// having to access the encrypted payload, and the database can group
+	// them by the time encoded in their leading characters
+	counts, err := p.dal.CountEvents(CountEventsQueryByIDPrefix{
+		AccountID:    accountID,
+		PrefixLength: eventDayPrefixLength,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("persistence: error counting events for account %s: %w", accountID, err)
+	}
+
+	result := map[string]int{}
+	var straddling []string
+	for prefix, count := range counts {
+		from, err := eventIDPrefixTime(prefix, "0")
+		if err != nil {
+			return nil, err
+		}
+		to, err := eventIDPrefixTime(prefix, "Z")
+		if err != nil {
+			return nil, err
+		}
+		day := from.In(location).Format("2006-01-02")
+		if day != to.In(location).Format("2006-01-02") {
+			// groups spanning midnight are resolved by looking at the
+			// individual events they contain
+			straddling = append(straddling, prefix)
+			continue
+		}
+		result[day] += int(count)
+	}
+	if len(straddling) == 0 {
+		return result, nil
+	}
+
+	eventIDs, err := p.dal.FindEventIDs(FindEventIDsQueryByIDPrefixes{
+		AccountID: accountID,
+		Prefixes:  straddling,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("persistence: error looking up events for account %s: %w", accountID, err)
+	}
+	for _, eventID := range eventIDs {
+		id, err := ulid.Parse(eventID)
+		if err != nil {
+			return nil, fmt.Errorf("persistence: error parsing event id %s: %w", eventID, err)
+		}
+		day := ulid.Time(id.Time()).In(location).Format("2006-01-02")
+		result[day]++
+	}
+	return result, nil
+}
+
+// eventIDPrefixTime returns the time encoded in an event id that starts
+// with the given prefix, filling its remaining characters with fill.
+func eventIDPrefixTime(prefix, fill string) (time.Time, error) {
+	id, err := ulid.Parse(prefix + strings.Repeat(fill, ulid.EncodedSize-len(prefix)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("persistence: error parsing event id prefix %s: %w", prefix, err)
+	}
+	return ulid.Time(id.Time()), nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockAccountStorageDatabase struct {
+	DataAccessLayer
+	findAccountResult Account
+	findAccountErr    error
+	size              int64
+	measureErr        error
+	eventIDs          []string
+}
+
+func (m *mockAccountStorageDatabase) FindAccount(interface{}) (Account, error) {
+	return m.findAccountResult, m.findAccountErr
+}
+
+func (m *mockAccountStorageDatabase) FindEventIDs(q interface{}) ([]string, error) {
+	var result []string
+	for _, eventID := range m.eventIDs {
+		for _, prefix := range q.(FindEventIDsQueryByIDPrefixes).Prefixes {
+			if strings.HasPrefix(eventID, prefix) {
+				result = append(result, eventID)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (m *mockAccountStorageDatabase) CountEvents(q interface{}) (map[string]int64, error) {
+	length := q.(CountEventsQueryByIDPrefix).PrefixLength
+	result := map[string]int64{}
+	for _, eventID := range m.eventIDs {
+		result[eventID[:length]]++
+	}
+	return result, nil
+}
+
+func (m *mockAccountStorageDatabase) MeasureStorage(interface{}) (int64, error) {
+	return m.size, m.measureErr
+}
+
+func TestPersistenceLayer_AccountStorageBytes(t *testing.T) {
+	tests := []struct {
+		name           string
+		dal            *mockAccountStorageDatabase
+		expectedResult int64
+		expectError    bool
+	}{
+		{
+			"unknown account",
+			&mockAccountStorageDatabase{findAccountErr: ErrUnknownAccount("did not work")},
+			0,
+			true,
+		},
+		{
+			"measure error",
+			&mockAccountStorageDatabase{measureErr: errors.New("did not work")},
+			0,
+			true,
+		},
+		{
+			"ok",
+			&mockAccountStorageDatabase{size: 4096},
+			4096,
+			false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &persistenceLayer{dal: test.dal}
+			result, err := p.AccountStorageBytes("account-a")
+			if (err != nil) != test.expectError {
+				t.Errorf("Unexpected error value %v", err)
+			}
+			if result != test.expectedResult {
+				t.Errorf("Expected %d, got %d", test.expectedResult, result)
+			}
+		})
+	}
+}
+
+func TestPersistenceLayer_AccountEventsPerDay(t *testing.T) {
+	mustEventID := func(s string) string {
+		ts, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing time: %v", err)
+		}
+		id, err := EventIDAt(ts)
+		if err != nil {
+			t.Fatalf("Unexpected error creating event id: %v", err)
+		}
+		return id
+	}
+	eventIDs := []string{
+		mustEventID("2022-03-01T12:00:00Z"),
+		mustEventID("2022-03-01T23:30:00Z"),
+		mustEventID("2022-03-02T00:30:00Z"),
+		mustEventID("2022-03-02T23:59:59Z"),
+		mustEventID("2022-03-03T00:00:01Z"),
+	}
+	tests := []struct {
+		name           string
+		timezone       string
+		expectedResult map[string]int
+		expectError    bool
+	}{
+		{
+			"default utc",
+			"",
+			map[string]int{"2022-03-01": 2, "2022-03-02": 2, "2022-03-03": 1},
+			false,
+		},
+		{
+			"ahead of utc",
+			"Europe/Berlin",
+			map[string]int{"2022-03-01": 1, "2022-03-02": 2, "2022-03-03": 2},
+			false,
+		},
+		{
+			"behind utc",
+			"America/New_York",
+			map[string]int{"2022-03-01": 3, "2022-03-02": 2},
+			false,
+		},
+		{
+			"bad timezone",
+			"Mars/Olympus_Mons",
+			nil,
+			true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &persistenceLayer{dal: &mockAccountStorageDatabase{
+				findAccountResult: Account{Settings: AccountSettings{Timezone: test.timezone}},
+				eventIDs:          eventIDs,
+			}}
+			result, err := p.AccountEventsPerDay("account-a")
+			if (err != nil) != test.expectError {
+				t.Errorf("Unexpected error value %v", err)
+			}
+			if !reflect.DeepEqual(result, test.expectedResult) {
+				t.Errorf("Expected %v, got %v", test.expectedResult, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ratelimiter
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// BoundedCache is a concurrency safe GetSetter that holds at most a fixed
+// number of entries. When full, the least recently used entry is evicted.
+// Entries also expire after the duration given when setting them.
+type BoundedCache struct {
+	maxEntries int
+	lock       sync.Mutex
+	items      map[string]*list.Element
+	order      *list.List
+}
+
+type boundedCacheEntry struct {
+	key    string
+	value  interface{}
+	expiry time.Time
+}
+
+// NewBoundedCache creates a BoundedCache holding at most maxEntries
+// entries. A value smaller than 1 is treated as 1.
+func NewBoundedCache(maxEntries int) *BoundedCache {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+	return &BoundedCache{
+		maxEntries: maxEntries,
+		items:      map[string]*list.Element{},
+		order:      list.New(),
+	}
+}
+
+// Get returns the value stored for the given key in case it exists and has
+// not expired yet. Retrieving a value marks it as recently used.
+func (b *BoundedCache) Get(key string) (interface{}, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	elem, ok := b.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*boundedCacheEntry)
+	if time.Now().After(entry.expiry) {
+		b.remove(elem)
+		return nil, false
+	}
+	b.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Set stores the value for the given key, expiring it after the given
+// duration. In case the cache is full, the least recently used entry is
+// evicted.
+func (b *BoundedCache) Set(key string, value interface{}, expiry time.Duration) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	now := time.Now()
+	b.removeExpired(now)
+	if elem, ok := b.items[key]; ok {
+		entry := elem.Value.(*boundedCacheEntry)
+		entry.value = value
+		entry.expiry = now.Add(expiry)
+		b.order.MoveToFront(elem)
+		return
+	}
+	b.items[key] = b.order.PushFront(&boundedCacheEntry{
+		key:    key,
+		value:  value,
+		expiry: now.Add(expiry),
+	})
+	for b.order.Len() > b.maxEntries {
+		b.remove(b.order.Back())
+	}
+}
+
+// Delete removes the value stored for the given key.
+func (b *BoundedCache) Delete(key string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if elem, ok := b.items[key]; ok {
+		b.remove(elem)
+	}
+}
+
+// Len returns the number of entries currently held, including those that
+// have expired but have not been removed yet.
+func (b *BoundedCache) Len() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.order.Len()
+}
+
+// removeExpired drops expired entries starting with the least recently used
+// one until it finds an entry that is still valid. As idle entries collect
+// at the back of the list, this removes idle entries without having to scan
+// the entire cache.
+func (b *BoundedCache) removeExpired(now time.Time) {
+	for elem := b.order.Back(); elem != nil; elem = b.order.Back() {
+		if !now.After(elem.Value.(*boundedCacheEntry).expiry) {
+			return
+		}
+		b.remove(elem)
+	}
+}
+
+func (b *BoundedCache) remove(elem *list.Element) {
+	b.order.Remove(elem)
+	delete(b.items, elem.Value.(*boundedCacheEntry).key)
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package ratelimiter
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBoundedCache_Eviction(t *testing.T) {
+	c := NewBoundedCache(3)
+	c.Set("a", 1, time.Hour)
+	c.Set("b", 2, time.Hour)
+	c.Set("c", 3, time.Hour)
+	// reading a marks it as recently used so b is the next to be evicted
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Expected a to be present")
+	}
+	c.Set("d", 4, time.Hour)
+
+	if c.Len() != 3 {
+		t.Errorf("Expected 3 entries, got %d", c.Len())
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("Expected %s to be present", key)
+		}
+	}
+}
+
+func TestBoundedCache_Expiry(t *testing.T) {
+	c := NewBoundedCache(10)
+	c.Set("idle", 1, time.Millisecond*10)
+	c.Set("active", 2, time.Hour)
+	time.Sleep(time.Millisecond * 20)
+
+	if _, ok := c.Get("idle"); ok {
+		t.Error("Expected idle entry to be expired")
+	}
+
+	c.Set("another-idle", 3, time.Millisecond*10)
+	// active entries are being used while idle ones are not
+	c.Get("active")
+	time.Sleep(time.Millisecond * 20)
+	c.Set("new", 4, time.Hour)
+	if c.Len() != 2 {
+		t.Errorf("Expected expired entries to be removed, got %d entries", c.Len())
+	}
+	if value, ok := c.Get("active"); !ok || value != 2 {
+		t.Errorf("Unexpected value for active entry %v", value)
+	}
+}
+
+func TestBoundedCache_Delete(t *testing.T) {
+	c := NewBoundedCache(10)
+	c.Set("a", 1, time.Hour)
+	c.Set("b", 2, time.Hour)
+	c.Delete("a")
+	c.Delete("unknown")
+
+	if c.Len() != 1 {
+		t.Errorf("Expected 1 entry, got %d", c.Len())
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected a to be deleted")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Error("Expected b to be present")
+	}
+}
+
+func TestBoundedCache_Concurrency(t *testing.T) {
+	c := NewBoundedCache(50)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := fmt.Sprintf("%d-%d", i, j)
+				c.Set(key, j, time.Minute)
+				c.Get(key)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if c.Len() != 50 {
+		t.Errorf("Expected cache to be capped at 50 entries, got %d", c.Len())
+	}
+}
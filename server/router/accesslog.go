@@ -0,0 +1,45 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// accessLogMiddleware emits a structured log entry for each request after
+// it has been handled. It expects requestIDMiddleware to run before.
+// Requests for any of the given quiet paths are logged at debug level only,
+// so frequent health checks do not flood logs.
+func accessLogMiddleware(logger *logrus.Logger, quietPaths ...string) gin.HandlerFunc {
+	quiet := map[string]bool{}
+	for _, path := range quietPaths {
+		quiet[path] = true
+	}
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		entry := logger.WithFields(logrus.Fields{
+			"method":    c.Request.Method,
+			"path":      c.Request.URL.Path,
+			"status":    anonymizeStatusCode(c.Writer.Status()),
+			"latency":   time.Since(start).Seconds(),
+			"bytes":     size,
+			"requestID": c.GetString(contextKeyRequestID),
+			"class":     classifyRequest(c.Request.URL.Path).String(),
+		})
+		if quiet[c.Request.URL.Path] {
+			entry.Debug("Handled request")
+			return
+		}
+		entry.Info("Handled request")
+	}
+}
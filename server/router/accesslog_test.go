@@ -0,0 +1,79 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestAccessLogMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		level          logrus.Level
+		requestID      string
+		status         int
+		expectEntry    bool
+		expectedLevel  logrus.Level
+		expectedStatus int
+	}{
+		{"regular request", "/hello", logrus.InfoLevel, "request-a", http.StatusTeapot, true, logrus.InfoLevel, http.StatusTeapot},
+		{"generated request id", "/hello", logrus.InfoLevel, "", http.StatusTeapot, true, logrus.InfoLevel, http.StatusTeapot},
+		{"anonymized status", "/hello", logrus.InfoLevel, "", http.StatusNoContent, true, logrus.InfoLevel, http.StatusOK},
+		{"quiet path", "/healthz", logrus.InfoLevel, "", http.StatusTeapot, false, 0, 0},
+		{"quiet path at debug level", "/healthz", logrus.DebugLevel, "", http.StatusTeapot, true, logrus.DebugLevel, http.StatusTeapot},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logger, hook := logtest.NewNullLogger()
+			logger.SetLevel(test.level)
+
+			m := gin.New()
+			m.Use(requestIDMiddleware(), accessLogMiddleware(logger, "/healthz"))
+			m.GET("/*path", func(c *gin.Context) {
+				c.String(test.status, "OK!")
+			})
+			r := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if test.requestID != "" {
+				r.Header.Set("X-Request-Id", test.requestID)
+			}
+			w := httptest.NewRecorder()
+			m.ServeHTTP(w, r)
+
+			entries := hook.AllEntries()
+			if !test.expectEntry {
+				if len(entries) != 0 {
+					t.Errorf("Expected no log entries, got %v", entries)
+				}
+				return
+			}
+			if len(entries) != 1 {
+				t.Fatalf("Expected a single log entry, got %d", len(entries))
+			}
+			entry := entries[0]
+			if entry.Level != test.expectedLevel {
+				t.Errorf("Unexpected level %v", entry.Level)
+			}
+			if entry.Data["method"] != http.MethodGet || entry.Data["path"] != test.path {
+				t.Errorf("Unexpected fields %v", entry.Data)
+			}
+			if entry.Data["status"] != test.expectedStatus {
+				t.Errorf("Unexpected fields %v", entry.Data)
+			}
+			if _, ok := entry.Data["latency"].(float64); !ok {
+				t.Errorf("Unexpected latency %v", entry.Data["latency"])
+			}
+			requestID, _ := entry.Data["requestID"].(string)
+			if requestID == "" || (test.requestID != "" && requestID != test.requestID) {
+				t.Errorf("Unexpected request id %v", entry.Data["requestID"])
+			}
+		})
+	}
+}
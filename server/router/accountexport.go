@@ -0,0 +1,110 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+)
+
+// accountExportBatchSize is the number of events that are read from the
+// database and written to the response at once when exporting events.
+const accountExportBatchSize = 1000
+
+var accountExportColumns = []string{"eventId", "secretId", "timestamp"}
+
+// getAccountExport returns all events of an account as a downloadable
+// document. CSV output is streamed so that accounts with a large number of
+// events do not need to be held in memory. JSON output equals the response
+// of getAccount.
+func (rt *router) getAccountExport(c *gin.Context) {
+	accountID := c.Param("accountID")
+	switch format := c.DefaultQuery("format", "csv"); format {
+	case "json":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="offen-%s.json"`, accountID))
+		rt.getAccount(c)
+		return
+	case "csv":
+	default:
+		newJSONError(
+			fmt.Errorf("router: unsupported export format %q", format),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+
+	if l := <-rt.getLimiter().LinearThrottle(time.Second, fmt.Sprintf("getAccountExport-%s", accountID)); l.Error != nil {
+		newJSONError(
+			fmt.Errorf("router: error rate limiting request: %w", l.Error),
+			http.StatusTooManyRequests,
+		).Pipe(c)
+		return
+	}
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+	if ok := accountUser.CanAccessAccount(accountID); !ok {
+		newJSONError(
+			fmt.Errorf("router: account user does not have permissions to access account %s", accountID),
+			http.StatusForbidden,
+		).Pipe(c)
+		return
+	}
+
+	w := csv.NewWriter(c.Writer)
+	// Headers can only be written as long as no events have been written,
+	// so errors occurring later are only logged.
+	var started bool
+	start := func() error {
+		started = true
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="offen-%s.csv"`, accountID))
+		return w.Write(accountExportColumns)
+	}
+	err := rt.db.ExportAccountEvents(accountID, accountExportBatchSize, func(events []persistence.EventResult) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		for _, evt := range events {
+			var secretID, timestamp string
+			if evt.SecretID != nil {
+				secretID = *evt.SecretID
+			}
+			if created, err := persistence.EventTime(evt.EventID); err == nil {
+				timestamp = created.UTC().Format(time.RFC3339)
+			}
+			if err := w.Write([]string{evt.EventID, secretID, timestamp}); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		c.Writer.Flush()
+		return w.Error()
+	})
+	if err != nil {
+		if started {
+			rt.logError(c.Request.Context(), err, "error streaming account export")
+			return
+		}
+		accountError(c, accountID, fmt.Errorf("router: error exporting account events: %w", err))
+		return
+	}
+	if !started {
+		start()
+	}
+	w.Flush()
+}
@@ -0,0 +1,204 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/config"
+	"github.com/offen/offen/server/persistence"
+)
+
+type mockAccountExportDatabase struct {
+	persistence.Service
+	pages [][]persistence.EventResult
+	err   error
+}
+
+func (m *mockAccountExportDatabase) ExportAccountEvents(accountID string, batchSize int, fn func([]persistence.EventResult) error) error {
+	if m.err != nil {
+		return m.err
+	}
+	for _, page := range m.pages {
+		if err := fn(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mockAccountExportDatabase) GetAccount(string, bool, bool, string) (persistence.AccountResult, error) {
+	return persistence.AccountResult{AccountID: "account-a"}, nil
+}
+
+func TestRouter_getAccountExport(t *testing.T) {
+	tests := []struct {
+		name                string
+		accountID           string
+		query               string
+		db                  *mockAccountExportDatabase
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			"csv",
+			"account-a",
+			"?format=csv",
+			&mockAccountExportDatabase{
+				pages: [][]persistence.EventResult{
+					{
+						{EventID: "01BX5ZZKBKACTAV9WEVGEMMVRZ", SecretID: strptr("secret-a")},
+						{EventID: "01BX5ZZKBKACTAV9WEVGEMMVS0"},
+					},
+					{
+						{EventID: "01BX5ZZKBKACTAV9WEVGEMMVS1", SecretID: strptr("secret-b")},
+					},
+				},
+			},
+			http.StatusOK,
+			"text/csv; charset=utf-8",
+			"eventId,secretId,timestamp\n" +
+				"01BX5ZZKBKACTAV9WEVGEMMVRZ,secret-a,2017-10-24T01:29:36Z\n" +
+				"01BX5ZZKBKACTAV9WEVGEMMVS0,,2017-10-24T01:29:36Z\n" +
+				"01BX5ZZKBKACTAV9WEVGEMMVS1,secret-b,2017-10-24T01:29:36Z\n",
+		},
+		{
+			"csv without events",
+			"account-a",
+			"",
+			&mockAccountExportDatabase{},
+			http.StatusOK,
+			"text/csv; charset=utf-8",
+			"eventId,secretId,timestamp\n",
+		},
+		{
+			"json",
+			"account-a",
+			"?format=json",
+			&mockAccountExportDatabase{},
+			http.StatusOK,
+			"application/json; charset=utf-8",
+			`{"accountId":"account-a","name":"","created":"0001-01-01T00:00:00Z"}`,
+		},
+		{
+			"unknown format",
+			"account-a",
+			"?format=xml",
+			&mockAccountExportDatabase{},
+			http.StatusBadRequest,
+			"",
+			"",
+		},
+		{
+			"no access",
+			"account-b",
+			"",
+			&mockAccountExportDatabase{},
+			http.StatusForbidden,
+			"",
+			"",
+		},
+		{
+			"unknown account",
+			"account-a",
+			"",
+			&mockAccountExportDatabase{err: persistence.ErrUnknownAccount("did not work")},
+			http.StatusNotFound,
+			"",
+			"",
+		},
+		{
+			"database error",
+			"account-a",
+			"",
+			&mockAccountExportDatabase{err: errors.New("did not work")},
+			http.StatusInternalServerError,
+			"",
+			"",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := router{db: test.db, config: &config.Config{}}
+			m := gin.New()
+			m.GET("/:accountID", func(c *gin.Context) {
+				c.Set(
+					contextKeyAuth,
+					persistence.LoginResult{
+						Accounts: []persistence.LoginAccountResult{
+							{AccountID: "account-a"},
+						},
+					},
+				)
+				c.Next()
+			}, rt.getAccountExport)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/"+test.accountID+test.query, nil)
+			m.ServeHTTP(w, r)
+			if w.Code != test.expectedStatus {
+				t.Errorf("Unexpected status code %v", w.Code)
+			}
+			if test.expectedContentType != "" {
+				if ct := w.Header().Get("Content-Type"); ct != test.expectedContentType {
+					t.Errorf("Unexpected content type %q", ct)
+				}
+				if cd := w.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment") {
+					t.Errorf("Unexpected Content-Disposition header %q", cd)
+				}
+			}
+			if test.expectedBody != "" && strings.TrimSpace(w.Body.String()) != strings.TrimSpace(test.expectedBody) {
+				t.Errorf("Unexpected response body %q", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRouter_getAccountExport_RouteTimeout(t *testing.T) {
+	rt := router{
+		db: &mockAccountExportDatabase{
+			pages: [][]persistence.EventResult{
+				{{EventID: "01BX5ZZKBKACTAV9WEVGEMMVRZ", SecretID: strptr("secret-a")}},
+				{{EventID: "01BX5ZZKBKACTAV9WEVGEMMVS1", SecretID: strptr("secret-b")}},
+			},
+		},
+		config: &config.Config{},
+	}
+	m := gin.New()
+	m.GET("/api/accounts/:accountID/export", func(c *gin.Context) {
+		c.Set(
+			contextKeyAuth,
+			persistence.LoginResult{
+				Accounts: []persistence.LoginAccountResult{
+					{AccountID: "account-a"},
+				},
+			},
+		)
+		c.Next()
+	}, rt.getAccountExport)
+	handler := routeTimeoutHandler(m, map[routeClass]time.Duration{
+		routeClassIngest: time.Second,
+		routeClassRead:   time.Second,
+		routeClassAdmin:  time.Second,
+	})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/accounts/account-a/export", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Unexpected status code %v", w.Code)
+	}
+	if !w.Flushed {
+		t.Error("Expected response to be flushed while streaming")
+	}
+	if lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n"); len(lines) != 3 {
+		t.Errorf("Unexpected response body %q", w.Body.String())
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/config"
 	"github.com/offen/offen/server/persistence"
 )
 
@@ -56,10 +57,24 @@ func (rt *router) getAccount(c *gin.Context) {
 		).Pipe(c)
 		return
 	}
+	rt.logCorruptedEvents(c.Request.Context(), result.Events)
 	result.RetentionPeriod = rt.config.App.Retention.String()
+	if retention, ok := config.RetentionFor(result.Retention); ok && result.Retention > 0 {
+		result.RetentionPeriod = retention.String()
+	}
 	c.JSON(http.StatusOK, result)
 }
 
+// deleteAccountRequest confirms the deletion of an account by repeating its
+// name, so a stray request cannot delete an account.
+type deleteAccountRequest struct {
+	AccountName string `json:"accountName"`
+}
+
+// deleteAccount soft deletes the account of the given id. The account itself
+// is kept but marked as retired, so its id cannot be used for ingesting
+// events again. All of its events, user secrets and account user
+// relationships are removed.
 func (rt *router) deleteAccount(c *gin.Context) {
 	accountID := c.Param("accountID")
 
@@ -88,8 +103,28 @@ func (rt *router) deleteAccount(c *gin.Context) {
 		return
 	}
 
-	err := rt.db.RetireAccount(accountID)
+	var req deleteAccountRequest
+	if err := c.BindJSON(&req); err != nil {
+		newJSONError(
+			fmt.Errorf("router: error decoding request payload: %w", err),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+	account, err := rt.db.GetAccount(accountID, false, false, "")
 	if err != nil {
+		accountError(c, accountID, fmt.Errorf("router: error looking up account: %w", err))
+		return
+	}
+	if req.AccountName != account.Name {
+		newJSONError(
+			fmt.Errorf("router: given name does not match the name of account %s", accountID),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+
+	if err := rt.db.RetireAccount(accountID); err != nil {
 		var errUnknown persistence.ErrUnknownAccount
 		if errors.As(err, &errUnknown) {
 			newJSONError(
@@ -98,12 +133,20 @@ func (rt *router) deleteAccount(c *gin.Context) {
 			).Pipe(c)
 			return
 		}
+		if errors.Is(err, persistence.ErrLegalHold) {
+			newJSONError(
+				fmt.Errorf("router: account %s is under legal hold and cannot be deleted", accountID),
+				http.StatusConflict,
+			).Pipe(c)
+			return
+		}
 		newJSONError(
 			fmt.Errorf("router: error deleting account: %w", err),
 			http.StatusInternalServerError,
 		).Pipe(c)
 		return
 	}
+	rt.dropEventSample(accountID)
 	c.Status(http.StatusNoContent)
 }
 
@@ -166,12 +209,20 @@ func (rt *router) postAccount(c *gin.Context) {
 		return
 	}
 
-	if err := rt.db.CreateAccount(html.UnescapeString(rt.sanitizer.Sanitize(req.AccountName)), req.EmailAddress, req.Password); err != nil {
+	result, err := rt.db.CreateAccount(html.UnescapeString(rt.sanitizer.Sanitize(req.AccountName)), req.EmailAddress, req.Password)
+	if err != nil {
+		if errors.Is(err, persistence.ErrDuplicateAccountName) {
+			newJSONError(
+				fmt.Errorf("router: account %s already exists", req.AccountName),
+				http.StatusConflict,
+			).Pipe(c)
+			return
+		}
 		newJSONError(
 			fmt.Errorf("router: error creating account %s: %w", req.AccountName, err),
 			http.StatusInternalServerError,
 		).Pipe(c)
 		return
 	}
-	c.JSON(http.StatusCreated, nil)
+	c.JSON(http.StatusCreated, result)
 }
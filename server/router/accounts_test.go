@@ -16,7 +16,12 @@ import (
 	"github.com/gorilla/securecookie"
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/offen/offen/server/config"
+	"github.com/offen/offen/server/keys"
 	"github.com/offen/offen/server/persistence"
+	"github.com/offen/offen/server/persistence/relational"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 type mockGetAccountDatabase struct {
@@ -51,7 +56,7 @@ func TestRouter_GetAccount(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			cookieSigner := securecookie.New([]byte("abc123"), nil)
 			auth, _ := cookieSigner.Encode("auth", test.accountID)
-			rt := router{db: test.database, cookieSigner: cookieSigner, config: &config.Config{}}
+			rt := router{db: test.database, authSigner: cookieSigner, config: &config.Config{}}
 			w := httptest.NewRecorder()
 			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/%s", test.accountID), nil)
 			m := gin.New()
@@ -84,19 +89,32 @@ func TestRouter_GetAccount(t *testing.T) {
 
 type mockDeleteAccountDatabase struct {
 	persistence.Service
-	result error
+	result  error
+	retired bool
+}
+
+func (m *mockDeleteAccountDatabase) GetAccount(accountID string, events, styles bool, since string) (persistence.AccountResult, error) {
+	return persistence.AccountResult{AccountID: accountID, Name: "Account A"}, nil
 }
 
 func (m *mockDeleteAccountDatabase) RetireAccount(string) error {
+	m.retired = m.result == nil
 	return m.result
 }
 
 func TestRouter_DeleteAccount(t *testing.T) {
+	superAdmin := persistence.LoginResult{
+		AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+		Accounts: []persistence.LoginAccountResult{
+			{AccountID: "account-a"},
+		},
+	}
 	tests := []struct {
 		name               string
 		accountID          string
-		database           persistence.Service
+		database           *mockDeleteAccountDatabase
 		user               persistence.LoginResult
+		body               string
 		expectedStatusCode int
 	}{
 		{
@@ -108,40 +126,65 @@ func TestRouter_DeleteAccount(t *testing.T) {
 					{AccountID: "account-a"},
 				},
 			},
+			`{"accountName":"Account A"}`,
 			http.StatusForbidden,
 		},
 		{
 			"account out of scope",
 			"account-b",
 			&mockDeleteAccountDatabase{},
-			persistence.LoginResult{
-				AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
-				Accounts: []persistence.LoginAccountResult{
-					{AccountID: "account-a"},
-				},
-			},
+			superAdmin,
+			`{"accountName":"Account A"}`,
 			http.StatusForbidden,
 		},
+		{
+			"missing confirmation",
+			"account-a",
+			&mockDeleteAccountDatabase{},
+			superAdmin,
+			"",
+			http.StatusBadRequest,
+		},
+		{
+			"name mismatch",
+			"account-a",
+			&mockDeleteAccountDatabase{},
+			superAdmin,
+			`{"accountName":"Account B"}`,
+			http.StatusBadRequest,
+		},
 		{
 			"ok",
 			"account-a",
 			&mockDeleteAccountDatabase{},
-			persistence.LoginResult{
-				AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
-				Accounts: []persistence.LoginAccountResult{
-					{AccountID: "account-a"},
-				},
-			},
+			superAdmin,
+			`{"accountName":"Account A"}`,
 			http.StatusNoContent,
 		},
+		{
+			"unknown account",
+			"account-a",
+			&mockDeleteAccountDatabase{result: persistence.ErrUnknownAccount("did not work")},
+			superAdmin,
+			`{"accountName":"Account A"}`,
+			http.StatusNotFound,
+		},
+		{
+			"legal hold",
+			"account-a",
+			&mockDeleteAccountDatabase{result: fmt.Errorf("did not work: %w", persistence.ErrLegalHold)},
+			superAdmin,
+			`{"accountName":"Account A"}`,
+			http.StatusConflict,
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			cookieSigner := securecookie.New([]byte("abc123"), nil)
 			auth, _ := cookieSigner.Encode("auth", test.accountID)
-			rt := router{db: test.database, cookieSigner: cookieSigner}
+			rt := router{db: test.database, authSigner: cookieSigner}
 			w := httptest.NewRecorder()
-			r := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/%s", test.accountID), nil)
+			r := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/%s", test.accountID), strings.NewReader(test.body))
 			m := gin.New()
 			m.DELETE("/:accountID", func(c *gin.Context) {
 				c.Set(
@@ -159,10 +202,80 @@ func TestRouter_DeleteAccount(t *testing.T) {
 			if w.Code != test.expectedStatusCode {
 				t.Errorf("Unexpected status code %v", w.Code)
 			}
+			if test.database.retired != (test.expectedStatusCode == http.StatusNoContent) {
+				t.Errorf("Unexpected retirement of account %v", test.database.retired)
+			}
 		})
 	}
 }
 
+func TestRouter_DeleteAccount_RemovesData(t *testing.T) {
+	gormDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error opening database: %v", err)
+	}
+	dal := relational.NewRelationalDAL(gormDB)
+	defer dal.Close()
+	if err := dal.ApplyMigrations(); err != nil {
+		t.Fatalf("Unexpected error applying migrations: %v", err)
+	}
+
+	publicKey, _, err := keys.GenerateRSAKeypair(2048)
+	if err != nil {
+		t.Fatalf("Unexpected error creating key: %v", err)
+	}
+	for _, record := range []interface{}{
+		&relational.Account{AccountID: "account-a", Name: "Account A", PublicKey: string(publicKey)},
+		&relational.Account{AccountID: "account-b", Name: "Account B", PublicKey: string(publicKey)},
+		&relational.Secret{SecretID: "secret-a", AccountID: "account-a"},
+		&relational.Secret{SecretID: "secret-b", AccountID: "account-b"},
+		&relational.Event{EventID: "event-a", AccountID: "account-a", SecretID: strptr("secret-a")},
+		&relational.Event{EventID: "event-b", AccountID: "account-b", SecretID: strptr("secret-b")},
+	} {
+		if err := gormDB.Create(record).Error; err != nil {
+			t.Fatalf("Unexpected error setting up test: %v", err)
+		}
+	}
+
+	db, _ := persistence.New(dal)
+	rt := router{db: db}
+	m := gin.New()
+	m.DELETE("/:accountID", func(c *gin.Context) {
+		c.Set(contextKeyAuth, persistence.LoginResult{
+			AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+			Accounts: []persistence.LoginAccountResult{
+				{AccountID: "account-a"},
+			},
+		})
+		c.Next()
+	}, rt.deleteAccount)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodDelete, "/account-a", strings.NewReader(`{"accountName":"Account A"}`))
+	m.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Unexpected status code %v", w.Code)
+	}
+
+	for _, model := range []interface{}{&relational.Event{}, &relational.Secret{}} {
+		var remaining []string
+		if err := gormDB.Model(model).Where("account_id = ?", "account-a").Pluck("account_id", &remaining).Error; err != nil {
+			t.Fatalf("Unexpected error looking up %T: %v", model, err)
+		}
+		if len(remaining) != 0 {
+			t.Errorf("Expected no %T to be left for deleted account, got %d", model, len(remaining))
+		}
+		var kept []string
+		if err := gormDB.Model(model).Where("account_id = ?", "account-b").Pluck("account_id", &kept).Error; err != nil {
+			t.Fatalf("Unexpected error looking up %T: %v", model, err)
+		}
+		if len(kept) != 1 {
+			t.Errorf("Expected %T of other account to be kept, got %d", model, len(kept))
+		}
+	}
+}
+
 type mockPostAccountDatabase struct {
 	persistence.Service
 	loginResult      persistence.LoginResult
@@ -174,8 +287,11 @@ func (m *mockPostAccountDatabase) Login(string, string) (persistence.LoginResult
 	return m.loginResult, m.loginErr
 }
 
-func (m *mockPostAccountDatabase) CreateAccount(string, string, string) error {
-	return m.createAccountErr
+func (m *mockPostAccountDatabase) CreateAccount(name, emailAddress, password string) (persistence.AccountResult, error) {
+	if m.createAccountErr != nil {
+		return persistence.AccountResult{}, m.createAccountErr
+	}
+	return persistence.AccountResult{AccountID: "account-z", Name: name}, nil
 }
 
 func TestRouter_postAccount(t *testing.T) {
@@ -270,6 +386,22 @@ func TestRouter_postAccount(t *testing.T) {
 			strings.NewReader(`{"accountName":"new","emailAddress":"hioffen@posteo.de","password":"pass"}`),
 			http.StatusInternalServerError,
 		},
+		{
+			"duplicate name",
+			mockPostAccountDatabase{
+				loginResult: persistence.LoginResult{
+					AccountUserID: "account-a",
+					AdminLevel:    persistence.AccountUserAdminLevelSuperAdmin,
+				},
+				createAccountErr: fmt.Errorf("did not work: %w", persistence.ErrDuplicateAccountName),
+			},
+			persistence.LoginResult{
+				AccountUserID: "account-a",
+				AdminLevel:    persistence.AccountUserAdminLevelSuperAdmin,
+			},
+			strings.NewReader(`{"accountName":"new","emailAddress":"hioffen@posteo.de","password":"pass"}`),
+			http.StatusConflict,
+		},
 		{
 			"ok",
 			mockPostAccountDatabase{
@@ -306,6 +438,9 @@ func TestRouter_postAccount(t *testing.T) {
 			if w.Code != test.expectedStatusCode {
 				t.Errorf("Unexpected status code %v", w.Code)
 			}
+			if w.Code == http.StatusCreated && !strings.Contains(w.Body.String(), `"accountId":"account-z"`) {
+				t.Errorf("Expected response to contain created account, got %s", w.Body.String())
+			}
 		})
 	}
 }
@@ -0,0 +1,181 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultMaxBodyBytes is the size limit for request bodies sent to the event
+// and secret endpoints in case no limit has been configured.
+const defaultMaxBodyBytes = 256 << 10
+
+// defaultMaxCompressionRatio is the ratio of decompressed to compressed
+// size that is accepted for gzip encoded request bodies in case no ratio has
+// been configured.
+const defaultMaxCompressionRatio = 100
+
+// compressionRatioSlack is the number of decompressed bytes that are
+// accepted regardless of the ratio, so tiny payloads whose size is dominated
+// by the gzip header are not rejected.
+const compressionRatioSlack = 4 << 10
+
+// errBodyTooLarge is returned when a request body exceeds the configured
+// size limit.
+var errBodyTooLarge = errors.New("router: request body too large")
+
+// errCompressionRatio is returned when a compressed request body expands
+// beyond the configured compression ratio.
+var errCompressionRatio = errors.New("router: request body exceeds compression ratio")
+
+// WithMaxBodyBytes limits the size of request bodies sent to the event and
+// secret endpoints. Requests exceeding the limit are rejected with 413.
+// Passing zero uses the default limit.
+func WithMaxBodyBytes(n int64) Config {
+	return func(r *router) {
+		r.maxBodyBytes = n
+	}
+}
+
+// WithMaxCompressionRatio limits the ratio of decompressed to compressed size
+// for gzip encoded request bodies. Decompression is aborted as soon as the
+// ratio is exceeded, so payloads designed to expand to huge sizes are
+// rejected before the size limit is reached. Passing zero uses the default
+// ratio of 100:1.
+func WithMaxCompressionRatio(n int64) Config {
+	return func(r *router) {
+		r.maxGzipRatio = n
+	}
+}
+
+func (rt *router) compressionRatio() int64 {
+	if rt.maxGzipRatio <= 0 {
+		return defaultMaxCompressionRatio
+	}
+	return rt.maxGzipRatio
+}
+
+func (rt *router) bodyLimit() int64 {
+	if rt.maxBodyBytes <= 0 {
+		return defaultMaxBodyBytes
+	}
+	return rt.maxBodyBytes
+}
+
+// readLimitedBody reads the request body, returning errBodyTooLarge in case
+// it exceeds the configured limit. The limit is enforced while reading, so
+// oversized bodies are never buffered completely.
+func readLimitedBody(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		// http.MaxBytesReader reads exactly up to the limit before failing,
+		// which is how exceeding the limit can be told apart from other
+		// errors without being able to match the error itself.
+		if int64(len(body)) >= limit {
+			return nil, errBodyTooLarge
+		}
+		return nil, err
+	}
+	return body, nil
+}
+
+// countingReader counts the bytes read from the wrapped reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ratioGuardReader fails with errCompressionRatio as soon as more than ratio
+// bytes have been read for each byte read from the compressed source.
+type ratioGuardReader struct {
+	r          io.Reader
+	compressed *countingReader
+	ratio      int64
+	n          int64
+}
+
+func (g *ratioGuardReader) Read(p []byte) (int, error) {
+	n, err := g.r.Read(p)
+	g.n += int64(n)
+	if g.n > compressionRatioSlack && g.n > g.compressed.n*g.ratio {
+		return n, errCompressionRatio
+	}
+	return n, err
+}
+
+// readCompressedBody decompresses a gzip encoded request body. The limit
+// applies to both the compressed and the decompressed size, and reading is
+// aborted once the decompressed body grows beyond the given ratio.
+func readCompressedBody(w http.ResponseWriter, r *http.Request, limit, ratio int64) ([]byte, error) {
+	compressed := &countingReader{r: http.MaxBytesReader(w, r.Body, limit)}
+	zr, err := gzip.NewReader(compressed)
+	if err != nil {
+		if compressed.n >= limit {
+			return nil, errBodyTooLarge
+		}
+		return nil, fmt.Errorf("router: error decompressing request body: %w", err)
+	}
+	defer zr.Close()
+
+	guard := &ratioGuardReader{r: zr, compressed: compressed, ratio: ratio}
+	body, err := io.ReadAll(io.LimitReader(guard, limit+1))
+	if err != nil {
+		if errors.Is(err, errCompressionRatio) {
+			return nil, err
+		}
+		if compressed.n >= limit {
+			return nil, errBodyTooLarge
+		}
+		return nil, fmt.Errorf("router: error decompressing request body: %w", err)
+	}
+	if int64(len(body)) > limit {
+		return nil, errBodyTooLarge
+	}
+	return body, nil
+}
+
+// readBody reads the body of the given request, responding with an
+// appropriate error in case this is not possible. Bodies using gzip content
+// encoding are decompressed.
+func (rt *router) readBody(c *gin.Context) ([]byte, *errorResponse) {
+	var body []byte
+	var err error
+	if strings.EqualFold(c.GetHeader("Content-Encoding"), "gzip") {
+		body, err = readCompressedBody(c.Writer, c.Request, rt.bodyLimit(), rt.compressionRatio())
+	} else {
+		body, err = readLimitedBody(c.Writer, c.Request, rt.bodyLimit())
+	}
+	if err != nil {
+		if errors.Is(err, errCompressionRatio) {
+			return nil, newJSONError(
+				fmt.Errorf("router: compressed request body expands beyond the maximum ratio of %d:1", rt.compressionRatio()),
+				http.StatusRequestEntityTooLarge,
+			)
+		}
+		if errors.Is(err, errBodyTooLarge) {
+			return nil, newJSONError(
+				fmt.Errorf("router: request body exceeds the maximum of %d bytes", rt.bodyLimit()),
+				http.StatusRequestEntityTooLarge,
+			)
+		}
+		return nil, newJSONError(
+			fmt.Errorf("router: error reading request payload: %v", err),
+			http.StatusBadRequest,
+		)
+	}
+	return body, nil
+}
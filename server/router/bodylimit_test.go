@@ -0,0 +1,212 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/config"
+	"github.com/offen/offen/server/ratelimiter"
+)
+
+func TestReadLimitedBody(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		limit         int64
+		expectedError error
+	}{
+		{"below limit", "abc", 4, nil},
+		{"at limit", "abcd", 4, nil},
+		{"above limit", "abcde", 4, errBodyTooLarge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+			body, err := readLimitedBody(httptest.NewRecorder(), r, test.limit)
+			if !errors.Is(err, test.expectedError) {
+				t.Errorf("Expected error %v, got %v", test.expectedError, err)
+			}
+			if err == nil && string(body) != test.body {
+				t.Errorf("Unexpected body %q", body)
+			}
+		})
+	}
+}
+
+func TestRouter_MaxBodyBytes(t *testing.T) {
+	rt := router{
+		db:           &mockPostEventsService{},
+		config:       &config.Config{},
+		limiter:      ratelimiter.NewNoopRateLimiter(),
+		maxBodyBytes: 512,
+	}
+	m := gin.New()
+	m.POST("/events", func(c *gin.Context) {
+		c.Set(contextKeyCookie, "user-id")
+		c.Next()
+	}, rt.postEvents)
+	m.POST("/exchange", rt.postUserSecret)
+
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{
+			"event within limit",
+			"/events",
+			`{"accountId":"account-a","payload":"payload"}`,
+			http.StatusCreated,
+		},
+		{
+			"oversized event",
+			"/events",
+			`{"accountId":"account-a","payload":"` + strings.Repeat("x", 1024) + `"}`,
+			http.StatusRequestEntityTooLarge,
+		},
+		{
+			"malformed event",
+			"/events",
+			`{"accountId":`,
+			http.StatusBadRequest,
+		},
+		{
+			"oversized secret",
+			"/exchange",
+			`{"accountId":"account-a","encryptedSecret":"` + strings.Repeat("x", 1024) + `"}`,
+			http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(test.body))
+			m.ServeHTTP(w, r)
+			if w.Code != test.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", test.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestWithMaxBodyBytes(t *testing.T) {
+	rt := router{}
+	if limit := rt.bodyLimit(); limit != defaultMaxBodyBytes {
+		t.Errorf("Expected default limit, got %d", limit)
+	}
+	WithMaxBodyBytes(1024)(&rt)
+	if limit := rt.bodyLimit(); limit != 1024 {
+		t.Errorf("Unexpected limit %d", limit)
+	}
+}
+
+func gzipBody(t *testing.T, body string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(body)); err != nil {
+		t.Fatalf("Unexpected error compressing body: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Unexpected error compressing body: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadCompressedBody(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          []byte
+		limit         int64
+		ratio         int64
+		expectedBody  string
+		expectedError error
+	}{
+		{"valid payload", gzipBody(t, "abc"), 1024, 100, "abc", nil},
+		{"decompressed above limit", gzipBody(t, strings.Repeat("abc", 100)), 64, 100, "", errBodyTooLarge},
+		{"high ratio", gzipBody(t, strings.Repeat("0", 1<<20)), 8 << 20, 100, "", errCompressionRatio},
+		{"high ratio within threshold", gzipBody(t, strings.Repeat("0", 1<<20)), 8 << 20, 10000, strings.Repeat("0", 1<<20), nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(test.body))
+			body, err := readCompressedBody(httptest.NewRecorder(), r, test.limit, test.ratio)
+			if !errors.Is(err, test.expectedError) {
+				t.Errorf("Expected error %v, got %v", test.expectedError, err)
+			}
+			if err == nil && string(body) != test.expectedBody {
+				t.Errorf("Unexpected body of length %d", len(body))
+			}
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("not gzip"))
+	if _, err := readCompressedBody(httptest.NewRecorder(), r, 1024, 100); err == nil {
+		t.Error("Expected error for body that is not gzip encoded")
+	}
+}
+
+func TestRouter_CompressionRatio(t *testing.T) {
+	rt := router{
+		db:           &mockPostEventsService{},
+		config:       &config.Config{},
+		limiter:      ratelimiter.NewNoopRateLimiter(),
+		maxBodyBytes: 8 << 20,
+	}
+	m := gin.New()
+	m.POST("/events", func(c *gin.Context) {
+		c.Set(contextKeyCookie, "user-id")
+		c.Next()
+	}, rt.postEvents)
+
+	tests := []struct {
+		name           string
+		body           []byte
+		expectedStatus int
+	}{
+		{
+			"compressed event",
+			gzipBody(t, `{"accountId":"account-a","payload":"payload"}`),
+			http.StatusCreated,
+		},
+		{
+			"zip bomb",
+			gzipBody(t, `{"accountId":"account-a","payload":"`+strings.Repeat("x", 4<<20)+`"}`),
+			http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/events", bytes.NewReader(test.body))
+			r.Header.Set("Content-Encoding", "gzip")
+			m.ServeHTTP(w, r)
+			if w.Code != test.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", test.expectedStatus, w.Code)
+			}
+			if test.expectedStatus == http.StatusRequestEntityTooLarge && !strings.Contains(w.Body.String(), "ratio") {
+				t.Errorf("Expected request to be rejected by ratio guard, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestWithMaxCompressionRatio(t *testing.T) {
+	rt := router{}
+	if ratio := rt.compressionRatio(); ratio != defaultMaxCompressionRatio {
+		t.Errorf("Expected default ratio, got %d", ratio)
+	}
+	WithMaxCompressionRatio(10)(&rt)
+	if ratio := rt.compressionRatio(); ratio != 10 {
+		t.Errorf("Unexpected ratio %d", ratio)
+	}
+}
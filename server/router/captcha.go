@@ -0,0 +1,27 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+// CaptchaVerifier checks the response token a client obtained from solving a
+// CAPTCHA challenge. Implementations are expected to return a non-nil error
+// in case the token is invalid or cannot be verified.
+type CaptchaVerifier interface {
+	Verify(token, remoteIP string) error
+}
+
+// CaptchaVerifierFunc allows using a plain function as a CaptchaVerifier.
+type CaptchaVerifierFunc func(token, remoteIP string) error
+
+// Verify calls the underlying function.
+func (f CaptchaVerifierFunc) Verify(token, remoteIP string) error {
+	return f(token, remoteIP)
+}
+
+// WithCaptchaVerifier requires requests for password reset emails to pass
+// the given CAPTCHA verification before any email is sent.
+func WithCaptchaVerifier(v CaptchaVerifier) Config {
+	return func(r *router) {
+		r.captcha = v
+	}
+}
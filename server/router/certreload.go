@@ -0,0 +1,47 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync"
+)
+
+// certReloader keeps the certificate used for serving TLS in memory and
+// re-reads it from disk on request, so renewed certificates can be used
+// without restarting the process.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+}
+
+func newCertReloader(certFile, keyFile string) *certReloader {
+	return &certReloader{certFile: certFile, keyFile: keyFile}
+}
+
+// reload reads the certificate and key files. In case they cannot be read
+// or do not form a valid key pair, the previous certificate is kept.
+func (c *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("router: error loading certificate: %w", err)
+	}
+	c.mu.Lock()
+	c.cert = &cert
+	c.mu.Unlock()
+	return nil
+}
+
+// getCertificate can be used as the GetCertificate callback of a tls.Config.
+func (c *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.cert == nil {
+		return nil, fmt.Errorf("router: no certificate loaded from %s", c.certFile)
+	}
+	return c.cert, nil
+}
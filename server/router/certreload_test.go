@@ -0,0 +1,139 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestCertificate(t *testing.T, dir string, serial int64) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unexpected error generating key: %v", err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Unexpected error creating certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Unexpected error marshaling key: %v", err)
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("Unexpected error writing certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("Unexpected error writing key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func servedSerial(t *testing.T, c *certReloader) int64 {
+	cert, err := c.getCertificate(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error getting certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("Unexpected error parsing certificate: %v", err)
+	}
+	return leaf.SerialNumber.Int64()
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, 1)
+	c := newCertReloader(certFile, keyFile)
+	if _, err := c.getCertificate(nil); err == nil {
+		t.Error("Expected error before loading certificate")
+	}
+	if err := c.reload(); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if serial := servedSerial(t, c); serial != 1 {
+		t.Errorf("Unexpected serial %d", serial)
+	}
+
+	writeTestCertificate(t, dir, 2)
+	if err := c.reload(); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if serial := servedSerial(t, c); serial != 2 {
+		t.Errorf("Expected renewed certificate to be served, got serial %d", serial)
+	}
+
+	if err := os.WriteFile(certFile, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if err := c.reload(); err == nil {
+		t.Error("Expected error when reloading invalid certificate")
+	}
+	if serial := servedSerial(t, c); serial != 2 {
+		t.Errorf("Expected previous certificate to be kept, got serial %d", serial)
+	}
+}
+
+func TestServer_CertReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, 1)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error creating listener: %v", err)
+	}
+	srv := NewServer("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}),
+		WithServerListener(l),
+		WithServerTLS(certFile, keyFile),
+		WithServerCertReload(func(error) {}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- srv.Run(ctx)
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+	res, err := client.Get("https://" + l.Addr().String())
+	if err != nil {
+		t.Fatalf("Unexpected error performing request: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		t.Errorf("Unexpected status code %d", res.StatusCode)
+	}
+	if serial := res.TLS.PeerCertificates[0].SerialNumber.Int64(); serial != 1 {
+		t.Errorf("Unexpected serial %d", serial)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
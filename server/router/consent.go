@@ -0,0 +1,75 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+)
+
+type consentAuditEntry struct {
+	HashedUserID string    `json:"hashedUserId"`
+	Action       string    `json:"action"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+type consentAuditResponse struct {
+	AccountID string              `json:"accountId"`
+	Entries   []consentAuditEntry `json:"entries"`
+}
+
+func (rt *router) getConsentAudit(c *gin.Context) {
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+
+	accountID := c.Param("accountID")
+	if ok := accountUser.CanAccessAccount(accountID) && accountUser.IsSuperAdmin(); !ok {
+		newJSONError(
+			fmt.Errorf("router: account user does not have permissions to export consent audit of account %s", accountID),
+			http.StatusForbidden,
+		).Pipe(c)
+		return
+	}
+
+	entries, err := rt.db.ConsentAudit(accountID)
+	if err != nil {
+		var errUnknown persistence.ErrUnknownAccount
+		if errors.As(err, &errUnknown) {
+			newJSONError(
+				fmt.Errorf("router: account %s not found", accountID),
+				http.StatusNotFound,
+			).Pipe(c)
+			return
+		}
+		newJSONError(
+			fmt.Errorf("router: error looking up consent audit: %w", err),
+			http.StatusInternalServerError,
+		).Pipe(c)
+		return
+	}
+
+	response := consentAuditResponse{
+		AccountID: accountID,
+		Entries:   []consentAuditEntry{},
+	}
+	for _, entry := range entries {
+		response.Entries = append(response.Entries, consentAuditEntry{
+			HashedUserID: entry.HashedUserID,
+			Action:       entry.Action,
+			Timestamp:    entry.Timestamp,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
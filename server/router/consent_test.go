@@ -0,0 +1,99 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+)
+
+type mockConsentAuditDatabase struct {
+	persistence.Service
+	entries []persistence.ConsentAuditEntry
+	err     error
+}
+
+func (m *mockConsentAuditDatabase) ConsentAudit(string) ([]persistence.ConsentAuditEntry, error) {
+	return m.entries, m.err
+}
+
+func TestRouter_getConsentAudit(t *testing.T) {
+	tests := []struct {
+		name               string
+		db                 *mockConsentAuditDatabase
+		user               interface{}
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			"no user",
+			&mockConsentAuditDatabase{},
+			nil,
+			http.StatusNotFound,
+			"",
+		},
+		{
+			"no admin",
+			&mockConsentAuditDatabase{},
+			persistence.LoginResult{
+				Accounts: []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			http.StatusForbidden,
+			"",
+		},
+		{
+			"unknown account",
+			&mockConsentAuditDatabase{
+				err: persistence.ErrUnknownAccount("did not work"),
+			},
+			persistence.LoginResult{
+				AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+				Accounts:   []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			http.StatusNotFound,
+			"",
+		},
+		{
+			"ok",
+			&mockConsentAuditDatabase{
+				entries: []persistence.ConsentAuditEntry{
+					{HashedUserID: "hashed-a", Action: persistence.ConsentActionOptIn, Timestamp: time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)},
+					{HashedUserID: "hashed-a", Action: persistence.ConsentActionOptOut, Timestamp: time.Date(2022, 3, 2, 12, 0, 0, 0, time.UTC)},
+				},
+			},
+			persistence.LoginResult{
+				AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+				Accounts:   []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			http.StatusOK,
+			`{"accountId":"account-a","entries":[{"hashedUserId":"hashed-a","action":"opt-in","timestamp":"2022-03-01T12:00:00Z"},{"hashedUserId":"hashed-a","action":"opt-out","timestamp":"2022-03-02T12:00:00Z"}]}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := router{db: test.db}
+			m := gin.New()
+			m.GET("/:accountID", func(c *gin.Context) {
+				c.Set(contextKeyAuth, test.user)
+			}, rt.getConsentAudit)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/account-a", nil)
+			m.ServeHTTP(w, r)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("Unexpected status code %v", w.Code)
+			}
+			if !strings.Contains(w.Body.String(), test.expectedBody) {
+				t.Errorf("Expected response body %s to contain %s", w.Body.String(), test.expectedBody)
+			}
+		})
+	}
+}
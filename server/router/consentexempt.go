@@ -0,0 +1,82 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const contextKeyConsentExempt = "contextKeyConsentExempt"
+
+// optinOrExemptMiddleware drops all requests that are missing the given
+// consent cookie, except for events sent to accounts that are exempt from
+// requiring consent. As the account is only known from the payload, the
+// body is read and restored for the wrapped handler in this case. Requests
+// passing because of an exemption are marked using contextKeyConsentExempt.
+// WebSocket handshakes do not carry any events yet, so they are passed on
+// marked as exempt, leaving it to the handler to check each message.
+func (rt *router) optinOrExemptMiddleware(cookieName, passWhen string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ck, err := c.Request.Cookie(cookieName); err == nil && ck.Value == passWhen {
+			c.Next()
+			return
+		}
+
+		if isWebSocketUpgrade(c.Request) {
+			c.Set(contextKeyConsentExempt, true)
+			c.Next()
+			return
+		}
+
+		body, errResp := rt.readBody(c)
+		if errResp != nil || !rt.consentExempt(body) {
+			c.Status(http.StatusNoContent)
+			c.Abort()
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Set(contextKeyConsentExempt, true)
+		c.Next()
+	}
+}
+
+// consentExempt checks whether all events in the given payload are sent to
+// accounts that are exempt from requiring consent. Batches are only exempt
+// if all of the accounts they contain events for are exempt.
+func (rt *router) consentExempt(body []byte) bool {
+	type accountPayload struct {
+		AccountID string `json:"accountId"`
+	}
+	var payloads []accountPayload
+	var err error
+	if isEventBatch(body) {
+		err = json.Unmarshal(body, &payloads)
+	} else {
+		payloads = make([]accountPayload, 1)
+		err = json.Unmarshal(body, &payloads[0])
+	}
+	if err != nil || len(payloads) == 0 {
+		return false
+	}
+	for _, payload := range payloads {
+		settings, err := rt.lookupAccountSettings(rt.resolveAccountID(payload.AccountID))
+		if err != nil || !settings.ConsentExempt {
+			return false
+		}
+	}
+	return true
+}
+
+// isWebSocketUpgrade checks whether the given request asks for upgrading
+// the connection to a WebSocket.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+type mockConsentExemptDatabase struct {
+	persistence.Service
+	settings map[string]persistence.AccountSettings
+}
+
+func (m *mockConsentExemptDatabase) GetAccountSettings(accountID string) (persistence.AccountSettings, error) {
+	settings, ok := m.settings[accountID]
+	if !ok {
+		return persistence.AccountSettings{}, persistence.ErrUnknownAccount("not found")
+	}
+	return settings, nil
+}
+
+func (m *mockConsentExemptDatabase) GetIngestPause(string) (persistence.IngestPause, error) {
+	return persistence.IngestPause{}, nil
+}
+
+func (m *mockConsentExemptDatabase) UpdateAccountSettings(accountID string, s persistence.AccountSettings) error {
+	m.settings[accountID] = s
+	return nil
+}
+
+func TestRouter_optinOrExemptMiddleware(t *testing.T) {
+	rt := router{
+		db: &mockConsentExemptDatabase{
+			settings: map[string]persistence.AccountSettings{
+				"account-a": {},
+				"account-b": {ConsentExempt: true},
+			},
+		},
+	}
+	tests := []struct {
+		name           string
+		accountID      string
+		consent        bool
+		expectedStatus int
+		expectedUserID string
+	}{
+		{"consent", "account-a", true, http.StatusOK, "user-a"},
+		{"no consent", "account-a", false, http.StatusNoContent, ""},
+		{"unknown account", "account-z", false, http.StatusNoContent, ""},
+		{"no consent exempt account", "account-b", false, http.StatusOK, ""},
+		{"consent exempt account", "account-b", true, http.StatusOK, "user-a"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payload := `{"accountId":"` + test.accountID + `","payload":"payload"}`
+			m := gin.New()
+			m.POST(
+				"/",
+				rt.optinOrExemptMiddleware(optinKey, optinValue),
+				userCookieMiddleware(cookieKey, contextKeyCookie),
+				func(c *gin.Context) {
+					if userID := c.GetString(contextKeyCookie); userID != test.expectedUserID {
+						t.Errorf("Expected user id %q, got %q", test.expectedUserID, userID)
+					}
+					if body, _ := io.ReadAll(c.Request.Body); string(body) != payload {
+						t.Errorf("Expected body to be passed on, got %s", body)
+					}
+					c.Status(http.StatusOK)
+				},
+			)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload))
+			r.AddCookie(&http.Cookie{Name: cookieKey, Value: "user-a"})
+			if test.consent {
+				r.AddCookie(&http.Cookie{Name: optinKey, Value: optinValue})
+			}
+			m.ServeHTTP(w, r)
+			if w.Code != test.expectedStatus {
+				t.Errorf("Expected status %d, got %d", test.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestRouter_putAccountSettings_ConsentExemptAudit(t *testing.T) {
+	logger, hook := logtest.NewNullLogger()
+	rt := router{
+		db: &mockConsentExemptDatabase{
+			settings: map[string]persistence.AccountSettings{"account-a": {}},
+		},
+		logger: logger,
+	}
+	m := gin.New()
+	m.PUT("/:accountID", func(c *gin.Context) {
+		c.Set(contextKeyAuth, persistence.LoginResult{
+			AccountUserID: "account-user-a",
+			AdminLevel:    persistence.AccountUserAdminLevelSuperAdmin,
+			Accounts:      []persistence.LoginAccountResult{{AccountID: "account-a"}},
+		})
+		c.Next()
+	}, rt.putAccountSettings)
+
+	for _, body := range []string{`{"consentExempt":true}`, `{"consentExempt":true}`, `{"consentExempt":false}`} {
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/account-a", strings.NewReader(body)))
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Unexpected status code %d", w.Code)
+		}
+	}
+
+	entries := hook.AllEntries()
+	if len(entries) != 2 {
+		t.Fatalf("Expected each change to be logged once, got %d entries", len(entries))
+	}
+	for i, expected := range []bool{true, false} {
+		if entries[i].Level != logrus.WarnLevel || entries[i].Data["enabled"] != expected || entries[i].Data["accountUserID"] != "account-user-a" {
+			t.Errorf("Unexpected log entry %v", entries[i].Data)
+		}
+	}
+}
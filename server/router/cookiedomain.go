@@ -0,0 +1,38 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// validateCookieDomain normalizes the given cookie domain and checks it can
+// be used by browsers for the given hosts. Browsers silently drop cookies
+// whose domain does not match the host that is setting them, so an invalid
+// domain is rejected instead and host only cookies have to be used. In case
+// no hosts are known, only the domain itself is checked.
+func validateCookieDomain(domain string, hosts []string) (string, error) {
+	normalized := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "."))
+	if normalized == "" {
+		return "", nil
+	}
+	if strings.ContainsAny(normalized, ":/ ") {
+		return "", fmt.Errorf("router: cookie domain %s must not contain a scheme, port or path", domain)
+	}
+	if net.ParseIP(normalized) != nil {
+		return "", fmt.Errorf("router: cookie domain %s must not be an ip address", domain)
+	}
+	if !strings.Contains(normalized, ".") {
+		return "", fmt.Errorf("router: cookie domain %s must consist of at least two labels", domain)
+	}
+	for _, host := range hosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host != normalized && !strings.HasSuffix(host, "."+normalized) {
+			return "", fmt.Errorf("router: cookie domain %s is not a parent of host %s", domain, host)
+		}
+	}
+	return normalized, nil
+}
@@ -0,0 +1,40 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"testing"
+)
+
+func TestValidateCookieDomain(t *testing.T) {
+	tests := []struct {
+		name           string
+		domain         string
+		hosts          []string
+		expectedDomain string
+		expectError    bool
+	}{
+		{"empty", "", nil, "", false},
+		{"leading dot", ".Example.com", nil, "example.com", false},
+		{"parent of hosts", "example.com", []string{"vault.example.com", "stats.example.com"}, "example.com", false},
+		{"equals host", "example.com", []string{"example.com"}, "example.com", false},
+		{"not a parent", "example.com", []string{"vault.example.com", "offen.dev"}, "", true},
+		{"suffix only", "example.com", []string{"notexample.com"}, "", true},
+		{"port", "example.com:8080", nil, "", true},
+		{"scheme", "https://example.com", nil, "", true},
+		{"ip address", "127.0.0.1", nil, "", true},
+		{"single label", "localhost", nil, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			domain, err := validateCookieDomain(test.domain, test.hosts)
+			if (err != nil) != test.expectError {
+				t.Errorf("Unexpected error value %v", err)
+			}
+			if domain != test.expectedDomain {
+				t.Errorf("Expected %q, got %q", test.expectedDomain, domain)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errCORSWildcard is returned when a wildcard is used as a CORS origin. As
+// API requests are credentialed, browsers would refuse a wildcard anyways,
+// and echoing back any origin instead would defeat the purpose of CORS.
+var errCORSWildcard = errors.New("router: wildcard origins cannot be used for credentialed cross origin requests")
+
+// WithCORSOrigins allows embedding pages served from the given origins to
+// make credentialed cross origin requests against the API. Each origin is
+// expected to be an absolute http(s) URL. The wildcard origin `*` is rejected.
+func WithCORSOrigins(origins []string) Config {
+	return func(r *router) {
+		r.corsOrigins = origins
+	}
+}
+
+// corsAllowlist normalizes the given origins so they can be compared against
+// the Origin header of incoming requests. Origins that cannot be used are
+// skipped and reported in the returned error.
+func corsAllowlist(origins []string) ([]string, error) {
+	var allowlist []string
+	var errs []string
+	for _, origin := range origins {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+		if origin == "*" {
+			errs = append(errs, errCORSWildcard.Error())
+			continue
+		}
+		normalized, err := normalizeOrigin(origin)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		allowlist = append(allowlist, normalized)
+	}
+	if len(errs) != 0 {
+		return allowlist, fmt.Errorf("router: skipped invalid cors origins: %s", strings.Join(errs, "; "))
+	}
+	return allowlist, nil
+}
+
+func corsOriginAllowed(origin string, allowlist []string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, allowed := range allowlist {
+		if allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// corsMiddleware adds the headers allowing credentialed cross origin requests
+// to responses for requests sent from one of the allowed origins. Requests
+// from other origins are passed on unchanged, leaving it to the browser to
+// block access to the response.
+func corsMiddleware(allowlist []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Origin")
+		origin := c.GetHeader("Origin")
+		if corsOriginAllowed(origin, allowlist) {
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+		c.Next()
+	}
+}
+
+// corsPreflightHandler responds to preflight requests, listing the methods
+// registered for the requested path. It is expected to be used after
+// corsMiddleware.
+func corsPreflightHandler(routes gin.RoutesInfo, allowlist []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !corsOriginAllowed(c.GetHeader("Origin"), allowlist) {
+			newJSONError(
+				fmt.Errorf("router: origin %s is not allowed to make cross origin requests", c.GetHeader("Origin")),
+				http.StatusForbidden,
+			).Pipe(c)
+			return
+		}
+		methods := allowedMethods(routes, c.Request.URL.Path)
+		if len(methods) == 0 {
+			newJSONError(
+				fmt.Errorf("router: no route registered for %s", c.Request.URL.Path),
+				http.StatusNotFound,
+			).Pipe(c)
+			return
+		}
+		c.Header("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		if requested := c.GetHeader("Access-Control-Request-Headers"); requested != "" {
+			c.Header("Access-Control-Allow-Headers", requested)
+		} else {
+			c.Header("Access-Control-Allow-Headers", "Content-Type")
+		}
+		c.Header("Access-Control-Max-Age", "600")
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/offen/offen/server/config"
+)
+
+func TestCORSAllowlist(t *testing.T) {
+	tests := []struct {
+		name           string
+		origins        []string
+		expectedResult []string
+		expectError    bool
+	}{
+		{"empty", nil, nil, false},
+		{"normalized", []string{"https://www.offen.dev/", " http://localhost:8080 "}, []string{"https://www.offen.dev", "http://localhost:8080"}, false},
+		{"wildcard", []string{"*", "https://www.offen.dev"}, []string{"https://www.offen.dev"}, true},
+		{"bad origin", []string{"www.offen.dev"}, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := corsAllowlist(test.origins)
+			if (err != nil) != test.expectError {
+				t.Errorf("Unexpected error value %v", err)
+			}
+			if !reflect.DeepEqual(test.expectedResult, result) {
+				t.Errorf("Expected %v, got %v", test.expectedResult, result)
+			}
+		})
+	}
+}
+
+func TestNew_CORS(t *testing.T) {
+	handler := New(
+		WithDatabase(&mockCacheControlDatabase{}),
+		WithConfig(&config.Config{}),
+		WithTemplate(template.New("a test")),
+		WithCORSOrigins([]string{"https://www.offen.dev", "*"}),
+	)
+	tests := []struct {
+		name                string
+		method              string
+		path                string
+		origin              string
+		expectedStatus      int
+		expectedAllowOrigin string
+		expectedMethods     string
+	}{
+		{
+			"preflight",
+			http.MethodOptions,
+			"/api/exchange",
+			"https://www.offen.dev",
+			http.StatusNoContent,
+			"https://www.offen.dev",
+			"GET, POST",
+		},
+		{
+			"versioned preflight",
+			http.MethodOptions,
+			"/api/v1/purge",
+			"https://www.offen.dev",
+			http.StatusNoContent,
+			"https://www.offen.dev",
+			"POST",
+		},
+		{
+			"preflight from unknown origin",
+			http.MethodOptions,
+			"/api/exchange",
+			"https://www.example.net",
+			http.StatusForbidden,
+			"",
+			"",
+		},
+		{
+			"preflight for unknown path",
+			http.MethodOptions,
+			"/api/unknown",
+			"https://www.offen.dev",
+			http.StatusNotFound,
+			"https://www.offen.dev",
+			"",
+		},
+		{
+			"request",
+			http.MethodGet,
+			"/api/exchange?accountId=account-a",
+			"https://www.offen.dev",
+			http.StatusOK,
+			"https://www.offen.dev",
+			"",
+		},
+		{
+			"request from unknown origin",
+			http.MethodGet,
+			"/api/exchange?accountId=account-a",
+			"https://www.example.net",
+			http.StatusOK,
+			"",
+			"",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, test.path, nil)
+			r.Header.Set("Origin", test.origin)
+			handler.ServeHTTP(w, r)
+			if w.Code != test.expectedStatus {
+				t.Errorf("Expected status %d, got %d", test.expectedStatus, w.Code)
+			}
+			if found := w.Header().Get("Access-Control-Allow-Origin"); found != test.expectedAllowOrigin {
+				t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", test.expectedAllowOrigin, found)
+			}
+			if test.expectedAllowOrigin != "" {
+				if found := w.Header().Get("Access-Control-Allow-Credentials"); found != "true" {
+					t.Errorf("Expected credentials to be allowed, got %q", found)
+				}
+			}
+			if found := w.Header().Get("Access-Control-Allow-Methods"); found != test.expectedMethods {
+				t.Errorf("Expected Access-Control-Allow-Methods %q, got %q", test.expectedMethods, found)
+			}
+		})
+	}
+}
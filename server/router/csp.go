@@ -0,0 +1,63 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import "strings"
+
+// cspSelf is the source expression for the origin Offen is served from,
+// which is also the origin of the API all clients talk to.
+const cspSelf = "'self'"
+
+// WithContentSecurityPolicy replaces the Content-Security-Policy that is sent
+// with the HTML documents Offen serves, i.e. the Auditorium, the vault and
+// all other pages. In case the policy does not allow connecting to the API,
+// 'self' is added to its connect-src directive. Responses of the JSON API
+// never carry a policy.
+func WithContentSecurityPolicy(policy string) Config {
+	return func(r *router) {
+		r.contentPolicy = policy
+	}
+}
+
+// withConnectSelf ensures the given policy allows connecting to the origin
+// Offen is served from.
+func withConnectSelf(policy string) string {
+	var directives []string
+	var hasConnectSrc bool
+	for _, directive := range strings.Split(policy, ";") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+		fields := strings.Fields(directive)
+		if strings.EqualFold(fields[0], "connect-src") {
+			hasConnectSrc = true
+			if !containsSource(fields[1:], cspSelf) {
+				fields = append(fields, cspSelf)
+			}
+			// 'none' cannot be combined with other sources
+			sources := fields[:1]
+			for _, source := range fields[1:] {
+				if source != "'none'" {
+					sources = append(sources, source)
+				}
+			}
+			directive = strings.Join(sources, " ")
+		}
+		directives = append(directives, directive)
+	}
+	if !hasConnectSrc {
+		directives = append(directives, "connect-src "+cspSelf)
+	}
+	return strings.Join(directives, "; ")
+}
+
+func containsSource(sources []string, source string) bool {
+	for _, s := range sources {
+		if strings.EqualFold(s, source) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/offen/offen/server/config"
+)
+
+func TestWithConnectSelf(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		expected string
+	}{
+		{"missing directive", "default-src 'none'; script-src 'self'", "default-src 'none'; script-src 'self'; connect-src 'self'"},
+		{"directive without self", "connect-src https://api.example.com;", "connect-src https://api.example.com 'self'"},
+		{"directive with self", "default-src 'self'; connect-src 'self' https://api.example.com", "default-src 'self'; connect-src 'self' https://api.example.com"},
+		{"none", "connect-src 'none'", "connect-src 'self'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if policy := withConnectSelf(test.policy); policy != test.expected {
+				t.Errorf("Expected %s, got %s", test.expected, policy)
+			}
+		})
+	}
+}
+
+func TestNew_ContentSecurityPolicy(t *testing.T) {
+	fs := http.FS(fstest.MapFS{
+		"auditorium/index.html": &fstest.MapFile{Data: []byte("<html><body>auditorium</body></html>")},
+		"script.js":             &fstest.MapFile{Data: []byte("console.log('offen')")},
+	})
+	policy := "default-src 'none'; script-src 'self'"
+	expected := "default-src 'none'; script-src 'self'; connect-src 'self'"
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedPolicy string
+	}{
+		{"auditorium", http.MethodGet, "/auditorium/", expected},
+		{"vault", http.MethodGet, "/vault", expected},
+		{"api", http.MethodPost, "/api/logout", ""},
+		{"script", http.MethodGet, "/script.js", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := New(
+				WithDatabase(&mockDatabase{}),
+				WithConfig(&config.Config{}),
+				WithTemplate(template.Must(template.New("vault").Parse("vault"))),
+				WithFS(fs),
+				WithContentSecurityPolicy(policy),
+			)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, test.path, nil)
+			handler.ServeHTTP(w, r)
+			if w.Code >= http.StatusBadRequest {
+				t.Errorf("Unexpected status code %v", w.Code)
+			}
+			if header := w.Header().Get("Content-Security-Policy"); header != test.expectedPolicy {
+				t.Errorf("Expected policy %q, got %q", test.expectedPolicy, header)
+			}
+		})
+	}
+}
@@ -6,14 +6,30 @@ package router
 import "github.com/gin-gonic/gin"
 
 type errorResponse struct {
-	Error  string `json:"error"`
-	Status int    `json:"status"`
+	Error     string `json:"error"`
+	Status    int    `json:"status"`
+	RequestID string `json:"requestId,omitempty"`
+	// Fields lists all invalid fields in case the request payload did
+	// not pass validation.
+	Fields []fieldError `json:"fields,omitempty"`
+	// Index is the position of the offending event in case a batch of
+	// events has been rejected.
+	Index *int `json:"index,omitempty"`
 }
 
 func (e *errorResponse) Pipe(c *gin.Context) {
+	e.withRequestID(c)
 	c.AbortWithStatusJSON(e.Status, e)
 }
 
+// withRequestID adds the id of the current request to the response, so
+// clients can refer to it when reporting errors.
+func (e *errorResponse) withRequestID(c *gin.Context) {
+	if e.RequestID == "" {
+		e.RequestID = c.GetString(contextKeyRequestID)
+	}
+}
+
 func newJSONError(err error, status int) *errorResponse {
 	return &errorResponse{
 		Error:  err.Error(),
@@ -0,0 +1,181 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+)
+
+// maxEventBatchSize is the maximum number of events that are accepted in a
+// single request.
+const maxEventBatchSize = 100
+
+type batchAckResponse struct {
+	Ack bool `json:"ack"`
+	// Accepted is the number of events that have been persisted. Events
+	// that have been dropped are not counted.
+	Accepted int `json:"accepted"`
+	// Sequences are the numbers the server has assigned to the persisted
+	// events in case sequencing is enabled.
+	Sequences []int64       `json:"sequences,omitempty"`
+	Echo      []echoedEvent `json:"echo,omitempty"`
+	DryRun    bool          `json:"dryRun,omitempty"`
+}
+
+// isEventBatch checks whether the given request body contains an array of
+// events instead of a single event.
+func isEventBatch(body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	return len(trimmed) != 0 && trimmed[0] == '['
+}
+
+func withIndex(errResp *errorResponse, index int) *errorResponse {
+	errResp.Index = &index
+	return errResp
+}
+
+// postEventBatch persists all events in the given body in a single
+// transaction. In case any of the events is rejected, none of them are stored
+// and the index of the offending event is returned to the client.
+func (rt *router) postEventBatch(c *gin.Context, userID string, body []byte, echo, dryRun bool) {
+	// The batch as a whole is checked against limits that allow for the
+	// maximum number of events. Each event is checked against the regular
+	// limits when being decoded in prepareEvent.
+	maxDepth, maxTokens := rt.jsonLimits()
+	var items []json.RawMessage
+	err := checkJSONLimits(body, maxDepth+1, maxEventBatchSize*maxTokens+2)
+	if err == nil {
+		err = json.Unmarshal(body, &items)
+	}
+	if err != nil {
+		newJSONError(
+			fmt.Errorf("router: error decoding request payload: %v", err),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+	if len(items) == 0 {
+		newJSONError(
+			errors.New("router: expected batch to contain at least one event"),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+	if len(items) > maxEventBatchSize {
+		newJSONError(
+			fmt.Errorf("router: batch contains %d events, at most %d are accepted", len(items), maxEventBatchSize),
+			http.StatusRequestEntityTooLarge,
+		).Pipe(c)
+		return
+	}
+
+	if errResp := rt.throttleIngest(userID); errResp != nil {
+		errResp.Pipe(c)
+		return
+	}
+
+	var events []InboundEvent
+	// positions maps the index of each prepared event to its index in the
+	// request, which differ when events are dropped.
+	var positions []int
+	for i, item := range items {
+		result, errResp := rt.prepareEvent(c.Request, item)
+		if errResp != nil {
+			if result.retryAfter > 0 {
+				c.Header("Retry-After", fmt.Sprintf("%d", int(result.retryAfter.Seconds())+1))
+			}
+			withIndex(errResp, i).Pipe(c)
+			return
+		}
+		if result.dropped {
+			continue
+		}
+		events = append(events, result.event)
+		positions = append(positions, i)
+	}
+
+	response := batchAckResponse{Ack: true, Accepted: len(events), DryRun: dryRun}
+	if echo {
+		for _, evt := range events {
+			response.Echo = append(response.Echo, echoedEvent{
+				AccountID:   evt.AccountID,
+				Payload:     evt.Payload,
+				ContentHash: evt.ContentHash,
+				Exempt:      evt.Exempt,
+			})
+		}
+	}
+	if dryRun {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	if len(events) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	inputs := make([]persistence.EventInput, len(events))
+	eventIDs := make([]*string, len(events))
+	for i, evt := range events {
+		eventID, err := rt.newSampledEventID()
+		if err != nil {
+			newJSONError(err, http.StatusInternalServerError).Pipe(c)
+			return
+		}
+		eventIDs[i] = eventID
+		inputs[i] = persistence.EventInput{
+			AccountID:   evt.AccountID,
+			Payload:     evt.Payload,
+			ContentHash: evt.ContentHash,
+			Exempt:      evt.Exempt,
+		}
+		if eventID != nil {
+			inputs[i].EventID = *eventID
+		}
+	}
+
+	start := time.Now()
+	sequenced := rt.featureEnabled(c.Request, featureSequenceEvents, rt.config.App.SequenceEvents) && userID != ""
+	sequences, err := rt.db.InsertEvents(userID, inputs, sequenced)
+	if err != nil {
+		errResp := insertErrorResponse(err)
+		var batchErr *persistence.BatchInsertError
+		if errors.As(err, &batchErr) && batchErr.Index >= 0 && batchErr.Index < len(positions) {
+			withIndex(errResp, positions[batchErr.Index])
+		}
+		errResp.Pipe(c)
+		return
+	}
+	dbDuration := time.Since(start)
+	for i, evt := range events {
+		rt.countIngestedEvent(c.Request, userID, evt, eventIDs[i])
+	}
+	if sequenced {
+		response.Sequences = sequences
+	}
+
+	// The cookie is needed for as long as the events of any of the
+	// accounts in the batch are retained.
+	var retention time.Duration
+	for _, evt := range events {
+		if r := rt.lookupAccountRetention(evt.AccountID); r > retention {
+			retention = r
+		}
+	}
+
+	c.Header("Server-Timing", fmt.Sprintf("db;desc=\"event write\";dur=%.3f", float64(dbDuration)/float64(time.Millisecond)))
+	http.SetCookie(
+		c.Writer,
+		rt.userCookie(userID, retention, c.GetBool(contextKeySecureContext)),
+	)
+	c.JSON(http.StatusCreated, response)
+}
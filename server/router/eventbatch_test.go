@@ -0,0 +1,187 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/config"
+	"github.com/offen/offen/server/persistence"
+)
+
+type mockEventBatchService struct {
+	persistence.Service
+	err      error
+	inserted []persistence.EventInput
+	single   bool
+}
+
+func (m *mockEventBatchService) Insert(string, string, string, string, bool, *string) error {
+	m.single = true
+	return m.err
+}
+
+func (m *mockEventBatchService) GetAccountRetention(string) (time.Duration, error) {
+	return 0, nil
+}
+
+func (m *mockEventBatchService) InsertEvents(userID string, events []persistence.EventInput, sequenced bool) ([]int64, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.inserted = events
+	return nil, nil
+}
+
+func (m *mockEventBatchService) GetAccountSettings(accountID string) (persistence.AccountSettings, error) {
+	if accountID == "account-z" {
+		return persistence.AccountSettings{}, persistence.ErrUnknownAccount("unknown account")
+	}
+	return persistence.AccountSettings{}, nil
+}
+
+func (m *mockEventBatchService) GetIngestPause(string) (persistence.IngestPause, error) {
+	return persistence.IngestPause{}, nil
+}
+
+func TestRouter_postEvents_Batch(t *testing.T) {
+	tests := []struct {
+		name             string
+		db               *mockEventBatchService
+		body             string
+		expectedStatus   int
+		expectedBody     string
+		expectedInserted int
+		expectSingle     bool
+		expectIndex      bool
+	}{
+		{
+			"single event",
+			&mockEventBatchService{},
+			`{"accountId":"account-a","payload":"payload-a"}`,
+			http.StatusCreated,
+			`{"ack":true}`,
+			0,
+			true,
+			false,
+		},
+		{
+			"ok",
+			&mockEventBatchService{},
+			` [{"accountId":"account-a","payload":"payload-a"},{"accountId":"account-a","payload":"payload-b"}]`,
+			http.StatusCreated,
+			`{"ack":true,"accepted":2}`,
+			2,
+			false,
+			false,
+		},
+		{
+			"empty batch",
+			&mockEventBatchService{},
+			`[]`,
+			http.StatusBadRequest,
+			"",
+			0,
+			false,
+			false,
+		},
+		{
+			"invalid event",
+			&mockEventBatchService{},
+			`[{"accountId":"account-a","payload":"payload-a"},{"accountId":"account-a"}]`,
+			http.StatusBadRequest,
+			"",
+			0,
+			false,
+			true,
+		},
+		{
+			"unknown account",
+			&mockEventBatchService{},
+			`[{"accountId":"account-a","payload":"payload-a"},{"accountId":"account-z","payload":"payload-b"}]`,
+			http.StatusNotFound,
+			"",
+			0,
+			false,
+			true,
+		},
+		{
+			"insert error",
+			&mockEventBatchService{
+				err: &persistence.BatchInsertError{Index: 1, Err: errors.New("did not work")},
+			},
+			`[{"accountId":"account-a","payload":"payload-a"},{"accountId":"account-a","payload":"payload-b"}]`,
+			http.StatusInternalServerError,
+			"",
+			0,
+			false,
+			true,
+		},
+		{
+			"full batch",
+			&mockEventBatchService{},
+			"[" + strings.TrimSuffix(strings.Repeat(`{"accountId":"account-a","payload":"payload","contentHash":"","exempt":false,"schemaVersion":0},`, maxEventBatchSize), ",") + "]",
+			http.StatusCreated,
+			fmt.Sprintf(`{"ack":true,"accepted":%d}`, maxEventBatchSize),
+			maxEventBatchSize,
+			false,
+			false,
+		},
+		{
+			"event exceeding json limits",
+			&mockEventBatchService{},
+			`[{"accountId":"account-a","payload":"payload-a"},{"accountId":"account-a","payload":"payload-b","extra":[` + strings.TrimSuffix(strings.Repeat("1,", defaultMaxJSONTokens), ",") + `]}]`,
+			http.StatusBadRequest,
+			"",
+			0,
+			false,
+			true,
+		},
+		{
+			"too many events",
+			&mockEventBatchService{},
+			"[" + strings.TrimSuffix(strings.Repeat(`{"accountId":"account-a","payload":"payload"},`, maxEventBatchSize+1), ",") + "]",
+			http.StatusRequestEntityTooLarge,
+			"",
+			0,
+			false,
+			false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := router{db: test.db, config: &config.Config{}}
+			m := gin.New()
+			m.POST("/", func(c *gin.Context) {
+				c.Set(contextKeyCookie, "user-id")
+				c.Next()
+			}, rt.postEvents)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+			m.ServeHTTP(w, r)
+			if w.Code != test.expectedStatus {
+				t.Errorf("Unexpected status code %d", w.Code)
+			}
+			if test.expectedBody != "" && strings.TrimSpace(w.Body.String()) != test.expectedBody {
+				t.Errorf("Unexpected response body %s", w.Body.String())
+			}
+			if test.expectIndex && !strings.Contains(w.Body.String(), `"index":1`) {
+				t.Errorf("Expected response to contain index of failing event, got %s", w.Body.String())
+			}
+			if len(test.db.inserted) != test.expectedInserted {
+				t.Errorf("Expected %d events to be inserted, got %d", test.expectedInserted, len(test.db.inserted))
+			}
+			if test.db.single != test.expectSingle {
+				t.Errorf("Unexpected use of single insert %v", test.db.single)
+			}
+		})
+	}
+}
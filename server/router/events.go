@@ -4,9 +4,14 @@
 package router
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,66 +19,318 @@ import (
 )
 
 type inboundEventPayload struct {
-	AccountID string `json:"accountId"`
-	Payload   string `json:"payload"`
+	AccountID   string `json:"accountId"`
+	Payload     string `json:"payload"`
+	ContentHash string `json:"contentHash"`
+	Exempt      bool   `json:"exempt"`
+	// SchemaVersion is the version of the account's event schema the client
+	// used when creating the payload. Events that do not declare a version
+	// are not checked against the schema.
+	SchemaVersion int `json:"schemaVersion"`
+	// Origin is the origin of the page the vault is embedded in. As the vault
+	// sends events from the Offen origin, this cannot be derived from the
+	// request's headers.
+	Origin string `json:"origin"`
 }
 
 type ackResponse struct {
 	Ack bool `json:"ack"`
+	// Sequence is the number the server has assigned to the event in case
+	// sequencing is enabled.
+	Sequence int64 `json:"sequence,omitempty"`
+	// Echo is the event as it would be stored after applying the ingest
+	// pipeline. It is only returned when requested in development mode.
+	Echo   *echoedEvent `json:"echo,omitempty"`
+	DryRun bool         `json:"dryRun,omitempty"`
+}
+
+type echoedEvent struct {
+	AccountID   string `json:"accountId"`
+	Payload     string `json:"payload"`
+	ContentHash string `json:"contentHash,omitempty"`
+	Exempt      bool   `json:"exempt"`
 }
 
 var errBadRequestContext = errors.New("could not use user id in request context")
 
 func (rt *router) postEvents(c *gin.Context) {
 	userID := c.GetString(contextKeyCookie)
-	if l := <-rt.getLimiter().LinearThrottle(time.Second/2, fmt.Sprintf("postEvents-%s", userID)); l.Error != nil {
+	// Clients can ask for the event to be echoed back as it would be stored
+	// and skip persisting it for debugging their integration.
+	echo, dryRun := c.Query("echo") == "1", c.Query("dryRun") == "1"
+	if (echo || dryRun) && !rt.config.App.Development {
 		newJSONError(
-			fmt.Errorf("router: error rate limiting request: %w", l.Error),
-			http.StatusTooManyRequests,
+			errors.New("router: echo and dryRun are only available in development mode"),
+			http.StatusForbidden,
 		).Pipe(c)
 		return
 	}
 
+	body, errResp := rt.readBody(c)
+	if errResp != nil {
+		errResp.Pipe(c)
+		return
+	}
+
+	if isEventBatch(body) {
+		rt.postEventBatch(c, userID, body, echo, dryRun)
+		return
+	}
+
+	result, errResp := rt.ingestEvent(c.Request, userID, body, dryRun)
+	if errResp != nil {
+		if result.retryAfter > 0 {
+			c.Header("Retry-After", fmt.Sprintf("%d", int(result.retryAfter.Seconds())+1))
+		}
+		errResp.Pipe(c)
+		return
+	}
+
+	if result.dropped {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	response := ackResponse{Ack: true, Sequence: result.userSequence, DryRun: dryRun}
+	if echo {
+		response.Echo = &echoedEvent{
+			AccountID:   result.event.AccountID,
+			Payload:     result.event.Payload,
+			ContentHash: result.event.ContentHash,
+			Exempt:      result.event.Exempt,
+		}
+	}
+	if dryRun {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	// Clients can use this to observe the cost of persisting their events.
+	c.Header("Server-Timing", fmt.Sprintf("db;desc=\"event write\";dur=%.3f", float64(result.dbDuration)/float64(time.Millisecond)))
+
+	http.SetCookie(
+		c.Writer,
+		rt.userCookie(userID, rt.lookupAccountRetention(result.event.AccountID), c.GetBool(contextKeySecureContext)),
+	)
+	c.JSON(http.StatusCreated, response)
+}
+
+type ingestResult struct {
+	// retryAfter signals when the client is allowed to retry in case
+	// ingestion for the account is suspended
+	retryAfter time.Duration
+	// dbDuration is the time it took to persist the event
+	dbDuration time.Duration
+	// dropped signals the event has been accepted but was not persisted
+	dropped bool
+	// userSequence is the number assigned to the event in case sequencing
+	// is enabled
+	userSequence int64
+	// event is the event after applying the ingest pipeline
+	event InboundEvent
+}
+
+// ingestEvent decodes, validates and persists a single event payload sent by
+// the given user. It is shared by all transports that accept events. In case
+// dryRun is set, the event is processed but not persisted.
+func (rt *router) ingestEvent(r *http.Request, userID string, body []byte, dryRun bool) (ingestResult, *errorResponse) {
+	if errResp := rt.throttleIngest(userID); errResp != nil {
+		return ingestResult{}, errResp
+	}
+
+	result, errResp := rt.prepareEvent(r, body)
+	if errResp != nil || result.dropped || dryRun {
+		return result, errResp
+	}
+	inbound := result.event
+
+	eventID, err := rt.newSampledEventID()
+	if err != nil {
+		return ingestResult{}, newJSONError(err, http.StatusInternalServerError)
+	}
+
+	start := time.Now()
+	var userSequence int64
+	if rt.featureEnabled(r, featureSequenceEvents, rt.config.App.SequenceEvents) && userID != "" {
+		userSequence, err = rt.db.InsertSequenced(userID, inbound.AccountID, inbound.Payload, inbound.ContentHash, inbound.Exempt, eventID)
+	} else {
+		err = rt.db.Insert(userID, inbound.AccountID, inbound.Payload, inbound.ContentHash, inbound.Exempt, eventID)
+	}
+	if err != nil {
+		return ingestResult{}, insertErrorResponse(err)
+	}
+	dbDuration := time.Since(start)
+	rt.countIngestedEvent(r, userID, inbound, eventID)
+	return ingestResult{dbDuration: dbDuration, userSequence: userSequence, event: inbound}, nil
+}
+
+func (rt *router) throttleIngest(userID string) *errorResponse {
+	if l := <-rt.getLimiter().LinearThrottle(time.Second/2, fmt.Sprintf("postEvents-%s", userID)); l.Error != nil {
+		return newJSONError(
+			fmt.Errorf("router: error rate limiting request: %w", l.Error),
+			http.StatusTooManyRequests,
+		)
+	}
+	return nil
+}
+
+func (rt *router) countIngestedEvent(r *http.Request, userID string, inbound InboundEvent, eventID *string) {
+	rt.sampleEvent(r, userID, inbound, eventID)
+	if rt.prometheus != nil {
+		rt.prometheus.countEventIngested()
+	}
+}
+
+// prepareEvent decodes and validates a single event payload and applies the
+// ingest pipeline. The returned event is ready to be persisted unless it has
+// been dropped.
+func (rt *router) prepareEvent(r *http.Request, body []byte) (ingestResult, *errorResponse) {
 	evt := inboundEventPayload{}
-	if err := c.BindJSON(&evt); err != nil {
-		newJSONError(
+	if err := rt.decodeJSON(body, &evt); err != nil {
+		return ingestResult{}, newJSONError(
 			fmt.Errorf("router: error decoding request payload: %v", err),
 			http.StatusBadRequest,
-		).Pipe(c)
-		return
+		)
 	}
 
-	if err := rt.db.Insert(userID, evt.AccountID, evt.Payload, nil); err != nil {
+	if errs := validateEventPayload(evt); len(errs) != 0 {
+		return ingestResult{}, newValidationError(errs)
+	}
+
+	evt.AccountID = rt.resolveAccountID(evt.AccountID)
+	settings, err := rt.lookupAccountSettings(evt.AccountID)
+	if err != nil {
 		var unknownAccountErr persistence.ErrUnknownAccount
 		if errors.As(err, &unknownAccountErr) {
-			newJSONError(
-				fmt.Errorf("router: error inserting event: %w", unknownAccountErr),
+			return ingestResult{}, newJSONError(
+				fmt.Errorf("router: error looking up account: %w", unknownAccountErr),
 				http.StatusNotFound,
-			).Pipe(c)
-			return
+			)
 		}
+		return ingestResult{}, newJSONError(
+			fmt.Errorf("router: error looking up account settings: %v", err),
+			http.StatusInternalServerError,
+		)
+	}
 
-		var unknownSecretErr persistence.ErrUnknownSecret
-		if errors.As(err, &unknownSecretErr) {
-			newJSONError(
-				fmt.Errorf("router: error inserting event: %w", unknownSecretErr),
+	paused, err := rt.lookupIngestPause(evt.AccountID)
+	if err != nil {
+		return ingestResult{}, newJSONError(
+			fmt.Errorf("router: error looking up ingest pause: %v", err),
+			http.StatusInternalServerError,
+		)
+	}
+	if paused {
+		return ingestResult{retryAfter: ingestPauseRetryAfter}, newJSONError(
+			fmt.Errorf("router: event ingestion for account %s has been paused", evt.AccountID),
+			http.StatusServiceUnavailable,
+		)
+	}
+
+	if origin := eventOrigin(r, evt.Origin); !originAllowed(origin, settings.AllowedOrigins) {
+		return ingestResult{}, newJSONError(
+			fmt.Errorf("router: events for account %s are not accepted from origin %q", evt.AccountID, origin),
+			http.StatusForbidden,
+		)
+	}
+
+	// Events from countries the account does not accept are dropped without
+	// signaling an error to the client.
+	if !rt.countryAllowed(r, settings) {
+		return ingestResult{dropped: true}, nil
+	}
+
+	if retryAfter, suspended := rt.checkIngestSuspension(evt.AccountID); suspended {
+		return ingestResult{retryAfter: retryAfter}, newJSONError(
+			fmt.Errorf("router: event ingestion for account %s is temporarily suspended", evt.AccountID),
+			http.StatusTooManyRequests,
+		)
+	}
+
+	if retryAfter, exceeded := rt.checkIngestByteRate(evt.AccountID, len(body)); exceeded {
+		return ingestResult{retryAfter: retryAfter}, newJSONError(
+			fmt.Errorf("router: account %s has exceeded the number of event bytes accepted per minute", evt.AccountID),
+			http.StatusTooManyRequests,
+		)
+	}
+
+	// Accounts can opt into rejecting payloads that contain unknown fields.
+	// As the account is only known after decoding, the payload is decoded a
+	// second time in this case.
+	if settings.StrictEventDecoding {
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&inboundEventPayload{}); err != nil {
+			return ingestResult{}, newJSONError(
+				fmt.Errorf("router: error decoding request payload: %v", err),
 				http.StatusBadRequest,
-			).Pipe(c)
-			return
+			)
 		}
+	}
 
-		newJSONError(
-			fmt.Errorf("router: error persisting event: %v", err),
-			http.StatusInternalServerError,
-		).Pipe(c)
-		return
+	if evt.SchemaVersion != 0 {
+		current, err := rt.lookupEventSchemaVersion(evt.AccountID)
+		if err != nil {
+			return ingestResult{}, newJSONError(
+				fmt.Errorf("router: error looking up event schema: %v", err),
+				http.StatusInternalServerError,
+			)
+		}
+		if evt.SchemaVersion != current {
+			return ingestResult{}, newJSONError(
+				fmt.Errorf("router: event uses schema version %d, current version is %d", evt.SchemaVersion, current),
+				http.StatusConflict,
+			)
+		}
 	}
 
-	http.SetCookie(
-		c.Writer,
-		rt.userCookie(userID, c.GetBool(contextKeySecureContext)),
+	inbound := InboundEvent{
+		AccountID:   evt.AccountID,
+		Payload:     evt.Payload,
+		ContentHash: evt.ContentHash,
+		// Events can only be exempt from expiry if the account allows it.
+		Exempt: evt.Exempt && settings.AllowExpiryExemption,
+	}
+	if err := rt.applyIngestPipeline(r, &inbound); err != nil {
+		return ingestResult{}, newJSONError(
+			err,
+			http.StatusBadRequest,
+		)
+	}
+
+	return ingestResult{event: inbound}, nil
+}
+
+// insertErrorResponse translates an error returned when persisting events
+// into the response sent to the client.
+func insertErrorResponse(err error) *errorResponse {
+	if errors.Is(err, persistence.ErrContentHashMismatch) {
+		return newJSONError(
+			fmt.Errorf("router: error inserting event: %w", err),
+			http.StatusBadRequest,
+		)
+	}
+
+	var unknownAccountErr persistence.ErrUnknownAccount
+	if errors.As(err, &unknownAccountErr) {
+		return newJSONError(
+			fmt.Errorf("router: error inserting event: %w", unknownAccountErr),
+			http.StatusNotFound,
+		)
+	}
+
+	var unknownSecretErr persistence.ErrUnknownSecret
+	if errors.As(err, &unknownSecretErr) {
+		return newJSONError(
+			fmt.Errorf("router: error inserting event: %w", unknownSecretErr),
+			http.StatusBadRequest,
+		)
+	}
+
+	return newJSONError(
+		fmt.Errorf("router: error persisting event: %v", err),
+		http.StatusInternalServerError,
 	)
-	c.JSON(http.StatusCreated, ackResponse{true})
 }
 
 func (rt *router) getEvents(c *gin.Context) {
@@ -85,9 +342,23 @@ func (rt *router) getEvents(c *gin.Context) {
 		).Pipe(c)
 		return
 	}
+	var limit int
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			newJSONError(
+				fmt.Errorf("router: expected limit to be a non-negative integer, got %q", value),
+				http.StatusBadRequest,
+			).Pipe(c)
+			return
+		}
+		limit = parsed
+	}
 	result, err := rt.db.Query(persistence.Query{
-		UserID: userID,
-		Since:  c.Query("since"),
+		UserID:   userID,
+		Since:    c.Query("since"),
+		Limit:    limit,
+		MaxBytes: rt.config.App.EventsResponseMaxBytes,
 	})
 	if err != nil {
 		newJSONError(
@@ -96,10 +367,27 @@ func (rt *router) getEvents(c *gin.Context) {
 		).Pipe(c)
 		return
 	}
+	rt.logCorruptedEvents(c.Request.Context(), result.Events)
 	result.RetentionPeriod = rt.config.App.Retention.String()
 	c.JSON(http.StatusOK, result)
 }
 
+// logCorruptedEvents reports events whose content hash does not match their
+// stored payload anymore. The events are still returned to the client, which
+// can decide on how to handle them.
+func (rt *router) logCorruptedEvents(ctx context.Context, events *persistence.EventsByAccountID) {
+	if events == nil {
+		return
+	}
+	if corrupted := persistence.CorruptedEvents(*events); len(corrupted) != 0 {
+		rt.logError(
+			ctx,
+			fmt.Errorf("router: content hash mismatch for events %s", strings.Join(corrupted, ", ")),
+			"detected corrupted events",
+		)
+	}
+}
+
 func (rt *router) purgeEvents(c *gin.Context) {
 	userID := c.GetString(contextKeyCookie)
 	if l := <-rt.getLimiter().LinearThrottle(time.Second, fmt.Sprintf("purgeEvents-%s", userID)); l.Error != nil {
@@ -116,11 +404,38 @@ func (rt *router) purgeEvents(c *gin.Context) {
 		).Pipe(c)
 		return
 	}
+	rt.dropSampledEvents(c.Request, userID)
 	if c.Query("user") != "" {
 		http.SetCookie(
 			c.Writer,
-			rt.userCookie("", c.GetBool(contextKeySecureContext)),
+			rt.userCookie("", 0, c.GetBool(contextKeySecureContext)),
 		)
+		if rt.prometheus != nil {
+			rt.prometheus.countOptout()
+		}
 	}
 	c.Status(http.StatusNoContent)
 }
+
+// getUserExport returns all data stored about the user identified by the
+// user cookie as a downloadable document.
+func (rt *router) getUserExport(c *gin.Context) {
+	userID := c.GetString(contextKeyCookie)
+	if l := <-rt.getLimiter().LinearThrottle(time.Second, fmt.Sprintf("getUserExport-%s", userID)); l.Error != nil {
+		newJSONError(
+			fmt.Errorf("router: error rate limiting request: %w", l.Error),
+			http.StatusTooManyRequests,
+		).Pipe(c)
+		return
+	}
+	result, err := rt.db.Export(userID)
+	if err != nil {
+		newJSONError(
+			fmt.Errorf("router: error exporting user data: %v", err),
+			http.StatusInternalServerError,
+		).Pipe(c)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="offen-export.json"`)
+	c.JSON(http.StatusOK, result)
+}
@@ -5,14 +5,20 @@ package router
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/offen/offen/server/config"
 	"github.com/offen/offen/server/persistence"
+	"github.com/offen/offen/server/ratelimiter"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 )
 
 func strptr(s string) *string {
@@ -71,6 +77,81 @@ func TestRouter_purgeEvents(t *testing.T) {
 	}
 }
 
+type mockUserExportService struct {
+	persistence.Service
+	userID string
+	result persistence.UserExportResult
+	err    error
+}
+
+func (m *mockUserExportService) Export(userID string) (persistence.UserExportResult, error) {
+	m.userID = userID
+	return m.result, m.err
+}
+
+func TestRouter_getUserExport(t *testing.T) {
+	tests := []struct {
+		name           string
+		db             *mockUserExportService
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			"database error",
+			&mockUserExportService{
+				err: errors.New("did not work"),
+			},
+			http.StatusInternalServerError,
+			"",
+		},
+		{
+			"ok",
+			&mockUserExportService{
+				result: persistence.UserExportResult{
+					Events: persistence.EventsByAccountID{
+						"account-a": {{AccountID: "account-a", EventID: "event-a", Payload: "payload"}},
+					},
+					Secrets: []persistence.SecretResult{{SecretID: "secret-a", EncryptedSecret: "encrypted"}},
+				},
+			},
+			http.StatusOK,
+			`{"events":{"account-a":[{"accountId":"account-a","eventId":"event-a","payload":"payload"}]},"secrets":[{"secretId":"secret-a","encryptedSecret":"encrypted"}]}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := gin.New()
+			rt := router{
+				db: test.db,
+			}
+			m.GET("/", func(c *gin.Context) {
+				c.Set(contextKeyCookie, "user-id")
+				c.Next()
+			}, rt.getUserExport)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			m.ServeHTTP(w, r)
+
+			if w.Code != test.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", test.expectedStatus, w.Code)
+			}
+			if test.db.userID != "user-id" {
+				t.Errorf("Expected export for user in cookie, got %q", test.db.userID)
+			}
+			if test.expectedBody == "" {
+				return
+			}
+			if strings.TrimSpace(w.Body.String()) != test.expectedBody {
+				t.Errorf("Unexpected response body %s", w.Body.String())
+			}
+			if h := w.Header().Get("Content-Disposition"); !strings.HasPrefix(h, "attachment") {
+				t.Errorf("Unexpected Content-Disposition header %q", h)
+			}
+		})
+	}
+}
+
 type mockGetEventsService struct {
 	persistence.Service
 	result persistence.EventsResult
@@ -84,12 +165,14 @@ func (m *mockGetEventsService) Query(persistence.Query) (persistence.EventsResul
 func TestRouter_getEvents(t *testing.T) {
 	tests := []struct {
 		name           string
+		query          string
 		db             persistence.Service
 		expectedStatus int
 		expectedBody   string
 	}{
 		{
 			"database error",
+			"",
 			&mockGetEventsService{
 				err: errors.New("did not work"),
 			},
@@ -98,6 +181,7 @@ func TestRouter_getEvents(t *testing.T) {
 		},
 		{
 			"StatusOK",
+			"",
 			&mockGetEventsService{
 				result: persistence.EventsResult{
 					Events: &persistence.EventsByAccountID{
@@ -110,14 +194,38 @@ func TestRouter_getEvents(t *testing.T) {
 			http.StatusOK,
 			`{"events":{"account-a":[{"accountId":"account-a","secretId":"hashed-user-a","eventId":"event-a","payload":"payload"}]}}`,
 		},
+		{
+			"paginated",
+			"?since=sequence-a&limit=1",
+			&mockGetEventsService{
+				result: persistence.EventsResult{
+					Events: &persistence.EventsByAccountID{
+						"account-a": []persistence.EventResult{
+							{AccountID: "account-a", EventID: "event-b", Payload: "payload"},
+						},
+					},
+					NextCursor: "sequence-b",
+				},
+			},
+			http.StatusOK,
+			`"nextCursor":"sequence-b"`,
+		},
+		{
+			"bad limit",
+			"?limit=-1",
+			&mockGetEventsService{},
+			http.StatusBadRequest,
+			"",
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			m := gin.New()
 			rt := router{
-				db:     test.db,
-				config: &config.Config{},
+				db:      test.db,
+				config:  &config.Config{},
+				limiter: ratelimiter.NewNoopRateLimiter(),
 			}
 			m.GET("/", func(c *gin.Context) {
 				c.Set(contextKeyCookie, "user-id")
@@ -125,7 +233,7 @@ func TestRouter_getEvents(t *testing.T) {
 			}, rt.getEvents)
 
 			w := httptest.NewRecorder()
-			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r := httptest.NewRequest(http.MethodGet, "/"+test.query, nil)
 
 			m.ServeHTTP(w, r)
 
@@ -142,20 +250,74 @@ func TestRouter_getEvents(t *testing.T) {
 	}
 }
 
+func TestRouter_getEvents_CorruptedEvents(t *testing.T) {
+	logger, hook := logtest.NewNullLogger()
+	rt := router{
+		db: &mockGetEventsService{
+			result: persistence.EventsResult{
+				Events: &persistence.EventsByAccountID{
+					"account-a": []persistence.EventResult{
+						{EventID: "event-a", Payload: "some-payload", ContentHash: "658781cd4ed9bca60dacd09f7bb914bb51502e8b5d619f57f39a1d652596cc24"},
+						{EventID: "event-b", Payload: "some-tampered-payload", ContentHash: "658781cd4ed9bca60dacd09f7bb914bb51502e8b5d619f57f39a1d652596cc24"},
+						{EventID: "event-c", Payload: "no-hash"},
+					},
+				},
+			},
+		},
+		config: &config.Config{},
+		logger: logger,
+	}
+	m := gin.New()
+	m.GET("/", func(c *gin.Context) {
+		c.Set(contextKeyCookie, "user-id")
+		c.Next()
+	}, rt.getEvents)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	m.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Unexpected status code %v", w.Code)
+	}
+	if len(hook.Entries) != 1 {
+		t.Fatalf("Expected one log entry, got %d", len(hook.Entries))
+	}
+	if err := hook.LastEntry().Data[logrus.ErrorKey].(error); !strings.Contains(err.Error(), "event-b") || strings.Contains(err.Error(), "event-a") {
+		t.Errorf("Unexpected error logged %v", err)
+	}
+}
+
 type mockPostEventsService struct {
 	persistence.Service
-	err error
+	err      error
+	settings persistence.AccountSettings
 }
 
-func (m *mockPostEventsService) Insert(string, string, string, *string) error {
+func (m *mockPostEventsService) Insert(string, string, string, string, bool, *string) error {
 	return m.err
 }
 
+func (m *mockPostEventsService) GetAccountRetention(string) (time.Duration, error) {
+	return 0, nil
+}
+
+func (m *mockPostEventsService) GetAccountSettings(string) (persistence.AccountSettings, error) {
+	return m.settings, nil
+}
+
+func (m *mockPostEventsService) GetIngestPause(string) (persistence.IngestPause, error) {
+	return persistence.IngestPause{}, nil
+}
+
+var serverTimingRegexp = regexp.MustCompile(`^db;desc="event write";dur=\d+\.\d{3}$`)
+
 func TestRouter_postEvents(t *testing.T) {
 	tests := []struct {
 		name           string
 		db             persistence.Service
 		body           string
+		origin         string
 		expectedStatus int
 		expectedBody   string
 	}{
@@ -163,6 +325,7 @@ func TestRouter_postEvents(t *testing.T) {
 			"bad payload",
 			&mockPostEventsService{},
 			"o hai!",
+			"",
 			http.StatusBadRequest,
 			"",
 		},
@@ -172,6 +335,7 @@ func TestRouter_postEvents(t *testing.T) {
 				err: errors.New("did not work"),
 			},
 			`{"accountId":"account-a","payload":"some-payload"}`,
+			"",
 			http.StatusInternalServerError,
 			"",
 		},
@@ -181,6 +345,7 @@ func TestRouter_postEvents(t *testing.T) {
 				err: persistence.ErrUnknownAccount("unknown account"),
 			},
 			`{"accountId":"account-a","payload":"some-payload"}`,
+			"",
 			http.StatusNotFound,
 			"",
 		},
@@ -190,6 +355,7 @@ func TestRouter_postEvents(t *testing.T) {
 				err: persistence.ErrUnknownSecret("unknown secret"),
 			},
 			`{"accountId":"account-a","payload":"some-payload"}`,
+			"",
 			http.StatusBadRequest,
 			"",
 		},
@@ -197,9 +363,108 @@ func TestRouter_postEvents(t *testing.T) {
 			"ok",
 			&mockPostEventsService{},
 			`{"accountId":"account-a","payload":"some-payload"}`,
+			"",
+			http.StatusCreated,
+			`{"ack":true}`,
+		},
+		{
+			"unknown field tolerated",
+			&mockPostEventsService{},
+			`{"accountId":"account-a","payload":"some-payload","extra":true}`,
+			"",
+			http.StatusCreated,
+			`{"ack":true}`,
+		},
+		{
+			"unknown field in strict mode",
+			&mockPostEventsService{
+				settings: persistence.AccountSettings{StrictEventDecoding: true},
+			},
+			`{"accountId":"account-a","payload":"some-payload","extra":true}`,
+			"",
+			http.StatusBadRequest,
+			`unknown field \"extra\"`,
+		},
+		{
+			"strict mode ok",
+			&mockPostEventsService{
+				settings: persistence.AccountSettings{StrictEventDecoding: true},
+			},
+			`{"accountId":"account-a","payload":"some-payload"}`,
+			"",
 			http.StatusCreated,
 			`{"ack":true}`,
 		},
+		{
+			"allowed origin",
+			&mockPostEventsService{
+				settings: persistence.AccountSettings{AllowedOrigins: []string{"https://www.example.net", "https://example.net"}},
+			},
+			`{"accountId":"account-a","payload":"some-payload"}`,
+			"https://example.net",
+			http.StatusCreated,
+			`{"ack":true}`,
+		},
+		{
+			"disallowed origin",
+			&mockPostEventsService{
+				settings: persistence.AccountSettings{AllowedOrigins: []string{"https://www.example.net", "https://example.net"}},
+			},
+			`{"accountId":"account-a","payload":"some-payload"}`,
+			"https://attacker.example.com",
+			http.StatusForbidden,
+			"",
+		},
+		{
+			"allowed origin reported by vault",
+			&mockPostEventsService{
+				settings: persistence.AccountSettings{AllowedOrigins: []string{"https://www.example.net", "https://example.net"}},
+			},
+			`{"accountId":"account-a","payload":"some-payload","origin":"https://www.example.net"}`,
+			"http://example.com",
+			http.StatusCreated,
+			`{"ack":true}`,
+		},
+		{
+			"disallowed origin reported by vault",
+			&mockPostEventsService{
+				settings: persistence.AccountSettings{AllowedOrigins: []string{"https://www.example.net", "https://example.net"}},
+			},
+			`{"accountId":"account-a","payload":"some-payload","origin":"https://attacker.example.com"}`,
+			"http://example.com",
+			http.StatusForbidden,
+			"",
+		},
+		{
+			"allowed origin reported from other origin",
+			&mockPostEventsService{
+				settings: persistence.AccountSettings{AllowedOrigins: []string{"https://www.example.net", "https://example.net"}},
+			},
+			`{"accountId":"account-a","payload":"some-payload","origin":"https://www.example.net"}`,
+			"https://attacker.example.com",
+			http.StatusForbidden,
+			"",
+		},
+		{
+			"content hash mismatch",
+			&mockPostEventsService{
+				err: fmt.Errorf("persistence: error inserting event: %w", persistence.ErrContentHashMismatch),
+			},
+			`{"accountId":"account-a","payload":"some-payload","contentHash":"abc"}`,
+			"",
+			http.StatusBadRequest,
+			"",
+		},
+		{
+			"missing origin with allowlist",
+			&mockPostEventsService{
+				settings: persistence.AccountSettings{AllowedOrigins: []string{"https://example.net"}},
+			},
+			`{"accountId":"account-a","payload":"some-payload"}`,
+			"",
+			http.StatusForbidden,
+			"",
+		},
 	}
 
 	for _, test := range tests {
@@ -217,6 +482,9 @@ func TestRouter_postEvents(t *testing.T) {
 
 			w := httptest.NewRecorder()
 			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+			if test.origin != "" {
+				r.Header.Set("Origin", test.origin)
+			}
 
 			m.ServeHTTP(w, r)
 
@@ -224,6 +492,12 @@ func TestRouter_postEvents(t *testing.T) {
 				t.Errorf("Expected status code %d, got %d", test.expectedStatus, w.Code)
 			}
 
+			if w.Code == http.StatusCreated {
+				if timing := w.Header().Get("Server-Timing"); !serverTimingRegexp.MatchString(timing) {
+					t.Errorf("Unexpected Server-Timing header %q", timing)
+				}
+			}
+
 			if test.expectedBody != "" {
 				if !strings.Contains(w.Body.String(), test.expectedBody) {
 					t.Errorf("Expected response body %s to contain %s", w.Body.String(), test.expectedBody)
@@ -232,3 +506,269 @@ func TestRouter_postEvents(t *testing.T) {
 		})
 	}
 }
+
+type mockCountriesService struct {
+	persistence.Service
+	settings persistence.AccountSettings
+	inserted int
+}
+
+func (m *mockCountriesService) Insert(string, string, string, string, bool, *string) error {
+	m.inserted++
+	return nil
+}
+
+func (m *mockCountriesService) GetAccountRetention(string) (time.Duration, error) {
+	return 0, nil
+}
+
+func (m *mockCountriesService) GetAccountSettings(string) (persistence.AccountSettings, error) {
+	return m.settings, nil
+}
+
+func (m *mockCountriesService) GetIngestPause(string) (persistence.IngestPause, error) {
+	return persistence.IngestPause{}, nil
+}
+
+type mockSequencedEventsService struct {
+	mockPostEventsService
+	sequence int64
+}
+
+func (m *mockSequencedEventsService) InsertSequenced(string, string, string, string, bool, *string) (int64, error) {
+	m.sequence++
+	return m.sequence, nil
+}
+
+func TestRouter_postEvents_SequenceEvents(t *testing.T) {
+	tests := []struct {
+		name         string
+		enabled      bool
+		userID       string
+		expectedBody string
+	}{
+		{"enabled", true, "user-a", `{"ack":true,"sequence":1}`},
+		{"disabled", false, "user-a", `{"ack":true}`},
+		{"anonymous", true, "", `{"ack":true}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.App.SequenceEvents = test.enabled
+			rt := router{
+				db:      &mockSequencedEventsService{},
+				config:  cfg,
+				limiter: ratelimiter.NewNoopRateLimiter(),
+			}
+			m := gin.New()
+			m.POST("/", func(c *gin.Context) {
+				c.Set(contextKeyCookie, test.userID)
+				c.Next()
+			}, rt.postEvents)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"accountId":"account-a","payload":"some-payload"}`))
+			m.ServeHTTP(w, r)
+			if w.Code != http.StatusCreated {
+				t.Errorf("Unexpected status code %v", w.Code)
+			}
+			if body := strings.TrimSpace(w.Body.String()); body != test.expectedBody {
+				t.Errorf("Unexpected response body %s", body)
+			}
+		})
+	}
+}
+
+func TestRouter_postEvents_Countries(t *testing.T) {
+	stubLookup := GeoLookupFunc(func(r *http.Request) (string, error) {
+		return r.Header.Get("X-Country"), nil
+	})
+	tests := []struct {
+		name           string
+		settings       persistence.AccountSettings
+		geo            GeoLookup
+		country        string
+		expectedStatus int
+		expectInsert   bool
+	}{
+		{
+			"no lists",
+			persistence.AccountSettings{},
+			stubLookup,
+			"US",
+			http.StatusCreated,
+			true,
+		},
+		{
+			"allowed country",
+			persistence.AccountSettings{AllowedCountries: []string{"DE", "AT"}},
+			stubLookup,
+			"DE",
+			http.StatusCreated,
+			true,
+		},
+		{
+			"country not in allowlist",
+			persistence.AccountSettings{AllowedCountries: []string{"DE", "AT"}},
+			stubLookup,
+			"US",
+			http.StatusNoContent,
+			false,
+		},
+		{
+			"unknown country with allowlist",
+			persistence.AccountSettings{AllowedCountries: []string{"DE"}},
+			stubLookup,
+			"",
+			http.StatusNoContent,
+			false,
+		},
+		{
+			"denied country",
+			persistence.AccountSettings{DeniedCountries: []string{"US"}},
+			stubLookup,
+			"us",
+			http.StatusNoContent,
+			false,
+		},
+		{
+			"country not in denylist",
+			persistence.AccountSettings{DeniedCountries: []string{"US"}},
+			stubLookup,
+			"DE",
+			http.StatusCreated,
+			true,
+		},
+		{
+			"no lookup configured",
+			persistence.AccountSettings{AllowedCountries: []string{"DE"}},
+			nil,
+			"US",
+			http.StatusCreated,
+			true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := &mockCountriesService{settings: test.settings}
+			rt := router{
+				db:     db,
+				config: &config.Config{},
+				geo:    test.geo,
+			}
+			m := gin.New()
+			m.POST("/", func(c *gin.Context) {
+				c.Set(contextKeyCookie, "user-id")
+				c.Set(contextKeySecureContext, false)
+				c.Next()
+			}, rt.postEvents)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"accountId":"account-a","payload":"some-payload"}`))
+			r.Header.Set("X-Country", test.country)
+			m.ServeHTTP(w, r)
+
+			if w.Code != test.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", test.expectedStatus, w.Code)
+			}
+			if inserted := db.inserted == 1; inserted != test.expectInsert {
+				t.Errorf("Expected insert to be %v, got %v", test.expectInsert, inserted)
+			}
+		})
+	}
+}
+
+type mockFallbackAccountService struct {
+	persistence.Service
+	insertedFor string
+}
+
+func (m *mockFallbackAccountService) GetAccountSettings(accountID string) (persistence.AccountSettings, error) {
+	if accountID != "fallback" {
+		return persistence.AccountSettings{}, persistence.ErrUnknownAccount("unknown account")
+	}
+	return persistence.AccountSettings{}, nil
+}
+
+func (m *mockFallbackAccountService) GetIngestPause(string) (persistence.IngestPause, error) {
+	return persistence.IngestPause{}, nil
+}
+
+func (m *mockFallbackAccountService) GetAccount(accountID string, includeEvents, includeInvitations bool, since string) (persistence.AccountResult, error) {
+	if accountID != "fallback" {
+		return persistence.AccountResult{}, persistence.ErrUnknownAccount("unknown account")
+	}
+	return persistence.AccountResult{AccountID: accountID}, nil
+}
+
+func (m *mockFallbackAccountService) Insert(userID, accountID, payload, contentHash string, exempt bool, eventID *string) error {
+	if accountID != "fallback" {
+		return persistence.ErrUnknownAccount("unknown account")
+	}
+	m.insertedFor = accountID
+	return nil
+}
+
+func (m *mockFallbackAccountService) GetAccountRetention(string) (time.Duration, error) {
+	return 0, nil
+}
+
+func TestRouter_fallbackAccount(t *testing.T) {
+	tests := []struct {
+		name              string
+		fallback          string
+		expectedEvents    int
+		expectedKey       int
+		expectedInsertFor string
+	}{
+		{
+			"no fallback",
+			"",
+			http.StatusNotFound,
+			http.StatusBadRequest,
+			"",
+		},
+		{
+			"fallback",
+			"fallback",
+			http.StatusCreated,
+			http.StatusOK,
+			"fallback",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := &mockFallbackAccountService{}
+			rt := router{
+				db:              db,
+				config:          &config.Config{},
+				fallbackAccount: test.fallback,
+			}
+			m := gin.New()
+			m.POST("/events", func(c *gin.Context) {
+				c.Set(contextKeyCookie, "user-id")
+				c.Next()
+			}, rt.postEvents)
+			m.GET("/exchange", rt.getPublicKey)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"accountId":"unknown","payload":"some-payload"}`))
+			m.ServeHTTP(w, r)
+			if w.Code != test.expectedEvents {
+				t.Errorf("Unexpected status code for events %v", w.Code)
+			}
+			if db.insertedFor != test.expectedInsertFor {
+				t.Errorf("Unexpected insert for %v", db.insertedFor)
+			}
+
+			w = httptest.NewRecorder()
+			r = httptest.NewRequest(http.MethodGet, "/exchange?accountId=unknown", nil)
+			m.ServeHTTP(w, r)
+			if w.Code != test.expectedKey {
+				t.Errorf("Unexpected status code for public key %v", w.Code)
+			}
+			if test.fallback != "" && !strings.Contains(w.Body.String(), `"accountId":"fallback"`) {
+				t.Errorf("Unexpected response body %v", w.Body.String())
+			}
+		})
+	}
+}
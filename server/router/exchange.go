@@ -14,8 +14,10 @@ import (
 	"github.com/offen/offen/server/persistence"
 )
 
+const publicKeyMaxAge = time.Hour
+
 func (rt *router) getPublicKey(c *gin.Context) {
-	account, err := rt.db.GetAccount(c.Query("accountId"), false, false, "")
+	account, err := rt.db.GetAccount(rt.resolveAccountID(c.Query("accountId")), false, false, "")
 	if err != nil {
 		var unknownAccountErr persistence.ErrUnknownAccount
 		if errors.As(err, &unknownAccountErr) {
@@ -31,6 +33,9 @@ func (rt *router) getPublicKey(c *gin.Context) {
 		).Pipe(c)
 		return
 	}
+	// An account's public key does not change over its lifetime, so clients
+	// and intermediaries are allowed to cache successful responses.
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(publicKeyMaxAge.Seconds())))
 	c.JSON(http.StatusOK, account)
 }
 
@@ -41,11 +46,13 @@ type userSecretPayload struct {
 
 func (rt *router) postUserSecret(c *gin.Context) {
 	var userID string
+	var isNewUser bool
 
 	ck, err := c.Request.Cookie(cookieKey)
 	if err == nil {
 		userID = ck.Value
 	} else {
+		isNewUser = true
 		newID, newIDErr := uuid.NewV4()
 		if newIDErr != nil {
 			newJSONError(
@@ -57,8 +64,14 @@ func (rt *router) postUserSecret(c *gin.Context) {
 		userID = newID.String()
 	}
 
+	body, errResp := rt.readBody(c)
+	if errResp != nil {
+		errResp.Pipe(c)
+		return
+	}
+
 	payload := userSecretPayload{}
-	if err := c.BindJSON(&payload); err != nil {
+	if err := rt.decodeJSON(body, &payload); err != nil {
 		newJSONError(
 			fmt.Errorf("router: error decoding response body: %v", err),
 			http.StatusBadRequest,
@@ -74,7 +87,26 @@ func (rt *router) postUserSecret(c *gin.Context) {
 		return
 	}
 
+	payload.AccountID = rt.resolveAccountID(payload.AccountID)
+	if isNewUser {
+		if retryAfter, exceeded := rt.checkNewUserRate(payload.AccountID, c.ClientIP()); exceeded {
+			c.Header("Retry-After", fmt.Sprintf("%d", int(retryAfter.Seconds())+1))
+			newJSONError(
+				fmt.Errorf("router: too many new users created for account %s", payload.AccountID),
+				http.StatusTooManyRequests,
+			).Pipe(c)
+			return
+		}
+	}
+
 	if err := rt.db.AssociateUserSecret(payload.AccountID, userID, payload.EncryptedUserSecret); err != nil {
+		if errors.Is(err, persistence.ErrMaxUsersExceeded) {
+			newJSONError(
+				fmt.Errorf("router: account %s does not accept any more users", payload.AccountID),
+				http.StatusForbidden,
+			).Pipe(c)
+			return
+		}
 		newJSONError(
 			fmt.Errorf("router: error associating user secret: %v", err),
 			http.StatusBadRequest,
@@ -84,7 +116,7 @@ func (rt *router) postUserSecret(c *gin.Context) {
 
 	http.SetCookie(
 		c.Writer,
-		rt.userCookie(userID, c.GetBool(contextKeySecureContext)),
+		rt.userCookie(userID, rt.lookupAccountRetention(payload.AccountID), c.GetBool(contextKeySecureContext)),
 	)
 	c.Status(http.StatusNoContent)
 }
@@ -11,10 +11,12 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/offen/offen/server/config"
 	"github.com/offen/offen/server/persistence"
+	"github.com/offen/offen/server/ratelimiter"
 )
 
 type mockAccountsDatabase struct {
@@ -86,6 +88,10 @@ func (m *mockUserSecretDatabase) AssociateUserSecret(string, string, string) err
 	return m.err
 }
 
+func (m *mockUserSecretDatabase) GetAccountRetention(string) (time.Duration, error) {
+	return 0, nil
+}
+
 func TestRouter_PostUserSecret(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -103,6 +109,14 @@ func TestRouter_PostUserSecret(t *testing.T) {
 			http.StatusBadRequest,
 			func(string) bool { return true },
 		},
+		{
+			"deeply nested payload",
+			&mockUserSecretDatabase{},
+			strings.NewReader(`{"accountId": ` + strings.Repeat("[", 1000) + strings.Repeat("]", 1000) + `}`),
+			&http.Cookie{},
+			http.StatusBadRequest,
+			func(string) bool { return true },
+		},
 		{
 			"db error",
 			&mockUserSecretDatabase{
@@ -118,6 +132,21 @@ func TestRouter_PostUserSecret(t *testing.T) {
 			http.StatusBadRequest,
 			func(input string) bool { return input != "" },
 		},
+		{
+			"max users exceeded",
+			&mockUserSecretDatabase{
+				err: fmt.Errorf("%w: did not work", persistence.ErrMaxUsersExceeded),
+			},
+			strings.NewReader(`
+			{
+				"encrypted_user_secret": "a value",
+				"accountId": "another value"
+			}
+			`),
+			&http.Cookie{},
+			http.StatusForbidden,
+			func(input string) bool { return input != "" },
+		},
 		{
 			"new user id",
 			&mockUserSecretDatabase{},
@@ -171,3 +200,48 @@ func TestRouter_PostUserSecret(t *testing.T) {
 		})
 	}
 }
+
+func TestRouter_PostUserSecret_NewUserRate(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.App.NewUserRateLimit = 2
+	cfg.App.NewUserRateWindow = time.Hour
+	rt := router{db: &mockUserSecretDatabase{}, config: cfg, limiter: ratelimiter.NewNoopRateLimiter()}
+	m := gin.New()
+	m.POST("/", rt.postUserSecret)
+
+	do := func(accountID, remoteAddr string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(
+			fmt.Sprintf(`{"encryptedSecret": "a value", "accountId": "%s"}`, accountID),
+		))
+		r.RemoteAddr = remoteAddr
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		m.ServeHTTP(w, r)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := do("account-a", "192.0.2.1:1234", nil); w.Code != http.StatusNoContent {
+			t.Errorf("Expected new user %d to be created, got status %d", i, w.Code)
+		}
+	}
+	w := do("account-a", "192.0.2.1:1234", nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected new user exceeding rate to be rejected, got status %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header to be set")
+	}
+
+	if w := do("account-a", "192.0.2.1:1234", &http.Cookie{Name: cookieKey, Value: "existing-user-id"}); w.Code != http.StatusNoContent {
+		t.Errorf("Expected returning user to be unaffected, got status %d", w.Code)
+	}
+	if w := do("account-a", "192.0.2.2:1234", nil); w.Code != http.StatusNoContent {
+		t.Errorf("Expected new user from other source to be created, got status %d", w.Code)
+	}
+	if w := do("account-b", "192.0.2.1:1234", nil); w.Code != http.StatusNoContent {
+		t.Errorf("Expected new user for other account to be created, got status %d", w.Code)
+	}
+}
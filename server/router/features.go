@@ -0,0 +1,175 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+)
+
+const (
+	featuresHeader    = "X-Offen-Features"
+	featuresTokenName = "features"
+	// featuresTokenMaxAge limits the time a feature token can be used after
+	// it has been issued.
+	featuresTokenMaxAge = time.Hour
+)
+
+// featureSequenceEvents overrides the App.SequenceEvents setting.
+const featureSequenceEvents = "sequenceEvents"
+
+// knownFeatures are the features that can be overridden for a single
+// request by passing a feature token.
+var knownFeatures = map[string]bool{
+	featureSequenceEvents: true,
+}
+
+// featureToken is the signed value passed in the X-Offen-Features header.
+type featureToken struct {
+	IssuedBy string
+	Features map[string]bool
+}
+
+// featuresContextKey is used for storing feature overrides in the context of
+// the underlying *http.Request, as handlers ingesting events do not have
+// access to the gin context.
+type featuresContextKey struct{}
+
+// featureOverridesMiddleware applies the feature overrides passed in a
+// signed X-Offen-Features header to the current request. Overrides are only
+// honored in development mode or when the request is authenticated as the
+// account user the token has been issued to. Headers that cannot be verified
+// are ignored.
+func (rt *router) featureOverridesMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value := c.GetHeader(featuresHeader)
+		if value == "" {
+			c.Next()
+			return
+		}
+		var token featureToken
+		if err := rt.featuresSigner.Decode(featuresTokenName, value, &token); err != nil {
+			c.Next()
+			return
+		}
+		if !rt.config.App.Development && !rt.isAuthenticatedAs(c, token.IssuedBy) {
+			c.Next()
+			return
+		}
+		c.Request = c.Request.WithContext(
+			context.WithValue(c.Request.Context(), featuresContextKey{}, token.Features),
+		)
+		c.Next()
+	}
+}
+
+// isAuthenticatedAs checks whether the request carries a valid auth token of
+// the given account user.
+func (rt *router) isAuthenticatedAs(c *gin.Context, accountUserID string) bool {
+	if accountUserID == "" {
+		return false
+	}
+	token, _ := rt.authToken(c)
+	sessionID, err := rt.decodeAuthToken(token)
+	if err != nil {
+		return false
+	}
+	user, err := rt.db.LookupSession(sessionID)
+	if err != nil {
+		return false
+	}
+	return user.AccountUserID == accountUserID
+}
+
+// featureEnabled returns whether the given feature is enabled for the given
+// request, falling back to the configured value in case the request does not
+// override it.
+func (rt *router) featureEnabled(r *http.Request, feature string, configured bool) bool {
+	overrides, _ := r.Context().Value(featuresContextKey{}).(map[string]bool)
+	if enabled, ok := overrides[feature]; ok {
+		return enabled
+	}
+	return configured
+}
+
+type featureTokenRequest struct {
+	Features map[string]bool `json:"features"`
+}
+
+type featureTokenResponse struct {
+	Token  string `json:"token"`
+	Header string `json:"header"`
+}
+
+func (rt *router) postFeatureToken(c *gin.Context) {
+	var req featureTokenRequest
+	if err := c.BindJSON(&req); err != nil {
+		newJSONError(
+			fmt.Errorf("router: error decoding request payload: %w", err),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+	if len(req.Features) == 0 {
+		newJSONError(
+			errors.New("router: expected request payload to contain at least one feature"),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+	var names []string
+	for name := range req.Features {
+		if !knownFeatures[name] {
+			newJSONError(
+				fmt.Errorf("router: unknown feature %s", name),
+				http.StatusBadRequest,
+			).Pipe(c)
+			return
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+	if !accountUser.IsSuperAdmin() {
+		newJSONError(
+			errors.New("router: account user does not have permissions to issue feature tokens"),
+			http.StatusForbidden,
+		).Pipe(c)
+		return
+	}
+
+	token, err := rt.featuresSigner.Encode(featuresTokenName, featureToken{
+		IssuedBy: accountUser.AccountUserID,
+		Features: req.Features,
+	})
+	if err != nil {
+		newJSONError(
+			fmt.Errorf("router: error signing feature token: %w", err),
+			http.StatusInternalServerError,
+		).Pipe(c)
+		return
+	}
+	if rt.logger != nil {
+		rt.logger.
+			WithField("accountUserID", accountUser.AccountUserID).
+			WithField("features", names).
+			Warn("Issued feature token")
+	}
+	c.JSON(http.StatusOK, featureTokenResponse{Token: token, Header: featuresHeader})
+}
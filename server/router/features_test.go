@@ -0,0 +1,127 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/securecookie"
+	"github.com/offen/offen/server/config"
+	"github.com/offen/offen/server/persistence"
+	"github.com/offen/offen/server/ratelimiter"
+)
+
+type mockFeatureOverridesService struct {
+	mockSequencedEventsService
+}
+
+func (m *mockFeatureOverridesService) LookupSession(sessionID string) (persistence.LoginResult, error) {
+	if sessionID == "session-a" {
+		return persistence.LoginResult{AccountUserID: "account-user-a"}, nil
+	}
+	return persistence.LoginResult{}, persistence.ErrUnknownSession
+}
+
+func TestRouter_featureOverrides(t *testing.T) {
+	signer := newTokenSigner([]byte("abc"), featuresTokenMaxAge)
+	sign := func(issuedBy string) string {
+		token, err := signer.Encode(featuresTokenName, featureToken{
+			IssuedBy: issuedBy,
+			Features: map[string]bool{featureSequenceEvents: true},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		return token
+	}
+	otherSigner := securecookie.New([]byte("xyz"), nil)
+	forged, _ := otherSigner.Encode(featuresTokenName, featureToken{
+		Features: map[string]bool{featureSequenceEvents: true},
+	})
+	authToken, _ := signer.Encode(authKey, "session-a")
+	endedToken, _ := signer.Encode(authKey, "session-b")
+
+	tests := []struct {
+		name         string
+		development  bool
+		header       string
+		authToken    string
+		expectedBody string
+	}{
+		{"no header", true, "", "", `{"ack":true}`},
+		{"signed header in development", true, sign(""), "", `{"ack":true,"sequence":1}`},
+		{"unsigned header", true, "sequenceEvents=true", "", `{"ack":true}`},
+		{"header signed with other key", true, forged, "", `{"ack":true}`},
+		{"signed header in production", false, sign("account-user-a"), "", `{"ack":true}`},
+		{"signed header with admin auth", false, sign("account-user-a"), authToken, `{"ack":true,"sequence":1}`},
+		{"signed header with other auth", false, sign("account-user-b"), authToken, `{"ack":true}`},
+		{"signed header with ended session", false, sign("account-user-a"), endedToken, `{"ack":true}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.App.Development = test.development
+			rt := router{
+				db:             &mockFeatureOverridesService{},
+				config:         cfg,
+				limiter:        ratelimiter.NewNoopRateLimiter(),
+				authSigner:     signer,
+				featuresSigner: signer,
+			}
+			m := gin.New()
+			m.POST("/", rt.featureOverridesMiddleware(), func(c *gin.Context) {
+				c.Set(contextKeyCookie, "user-a")
+				c.Next()
+			}, rt.postEvents)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"accountId":"account-a","payload":"some-payload"}`))
+			if test.header != "" {
+				r.Header.Set(featuresHeader, test.header)
+			}
+			if test.authToken != "" {
+				r.AddCookie(&http.Cookie{Name: authKey, Value: test.authToken})
+			}
+			m.ServeHTTP(w, r)
+			if w.Code != http.StatusCreated {
+				t.Errorf("Unexpected status code %v", w.Code)
+			}
+			if body := strings.TrimSpace(w.Body.String()); body != test.expectedBody {
+				t.Errorf("Unexpected response body %s", body)
+			}
+		})
+	}
+}
+
+func TestRouter_postFeatureToken(t *testing.T) {
+	rt := router{featuresSigner: newTokenSigner([]byte("abc"), featuresTokenMaxAge)}
+	tests := []struct {
+		name           string
+		user           persistence.LoginResult
+		body           string
+		expectedStatus int
+	}{
+		{"ok", persistence.LoginResult{AccountUserID: "account-user-a", AdminLevel: persistence.AccountUserAdminLevelSuperAdmin}, `{"features":{"sequenceEvents":true}}`, http.StatusOK},
+		{"non admin", persistence.LoginResult{AccountUserID: "account-user-a"}, `{"features":{"sequenceEvents":true}}`, http.StatusForbidden},
+		{"unknown feature", persistence.LoginResult{AdminLevel: persistence.AccountUserAdminLevelSuperAdmin}, `{"features":{"zipBombs":true}}`, http.StatusBadRequest},
+		{"empty", persistence.LoginResult{AdminLevel: persistence.AccountUserAdminLevelSuperAdmin}, `{}`, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := gin.New()
+			m.POST("/", func(c *gin.Context) {
+				c.Set(contextKeyAuth, test.user)
+			}, rt.postFeatureToken)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+			m.ServeHTTP(w, r)
+			if w.Code != test.expectedStatus {
+				t.Errorf("Expected status %d, got %d", test.expectedStatus, w.Code)
+			}
+		})
+	}
+}
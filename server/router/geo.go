@@ -0,0 +1,74 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/offen/offen/server/persistence"
+)
+
+// GeoLookup resolves the country a request originates from. Implementations
+// are expected to return an ISO 3166-1 alpha-2 country code, or an empty
+// string in case the country cannot be determined.
+type GeoLookup interface {
+	Country(r *http.Request) (string, error)
+}
+
+// GeoLookupFunc allows using a plain function as a GeoLookup.
+type GeoLookupFunc func(r *http.Request) (string, error)
+
+// Country calls the underlying function.
+func (f GeoLookupFunc) Country(r *http.Request) (string, error) {
+	return f(r)
+}
+
+// WithGeoLookup enables accounts to restrict event ingestion to certain
+// countries, using the given lookup for determining the country of each
+// request. Without a lookup, country restrictions are not applied.
+func WithGeoLookup(g GeoLookup) Config {
+	return func(r *router) {
+		r.geo = g
+	}
+}
+
+func normalizeCountryCode(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+		return "", fmt.Errorf("router: %q is not a two letter country code", code)
+	}
+	return code, nil
+}
+
+// countryAllowed checks whether events for an account with the given settings
+// are accepted from the country the request originates from. Requests whose
+// country cannot be determined are only rejected when the account allows
+// a fixed set of countries.
+func (rt *router) countryAllowed(r *http.Request, settings persistence.AccountSettings) bool {
+	if rt.geo == nil || (len(settings.AllowedCountries) == 0 && len(settings.DeniedCountries) == 0) {
+		return true
+	}
+	country, err := rt.geo.Country(r)
+	if err != nil {
+		rt.logError(r.Context(), err, "error looking up country of request")
+		country = ""
+	}
+	country = strings.ToUpper(country)
+	for _, denied := range settings.DeniedCountries {
+		if denied == country {
+			return false
+		}
+	}
+	if len(settings.AllowedCountries) == 0 {
+		return true
+	}
+	for _, allowed := range settings.AllowedCountries {
+		if allowed == country {
+			return true
+		}
+	}
+	return false
+}
@@ -4,19 +4,131 @@
 package router
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/mailer"
 )
 
+// dependencyError is returned when a dependency of the application is
+// not available.
+type dependencyError struct {
+	errorResponse
+	Dependency string `json:"dependency"`
+}
+
+func newDependencyError(dependency string, err error) *dependencyError {
+	return &dependencyError{
+		errorResponse: errorResponse{
+			Error:  err.Error(),
+			Status: http.StatusServiceUnavailable,
+		},
+		Dependency: dependency,
+	}
+}
+
+func (e *dependencyError) Pipe(c *gin.Context) {
+	// dependency errors might be cached and shared between requests, so the
+	// request id is only added to a copy
+	resp := *e
+	resp.withRequestID(c)
+	c.AbortWithStatusJSON(resp.Status, &resp)
+}
+
+// getHealth is a liveness check that responds as long as the application is
+// able to handle requests. It does not check any dependencies, so that
+// dependencies being unavailable do not cause the application to be restarted.
 func (rt *router) getHealth(c *gin.Context) {
-	if err := rt.db.CheckHealth(); err != nil {
-		newJSONError(
-			fmt.Errorf("router: failed checking health of connected persistence layer: %v", err),
-			http.StatusBadGateway,
-		).Pipe(c)
+	c.JSON(http.StatusOK, map[string]bool{"ok": true})
+}
+
+// getReady is a readiness check that verifies the application's dependencies
+// are available.
+func (rt *router) getReady(c *gin.Context) {
+	// In case a token is configured, checking dependencies is reserved for
+	// callers that know the token.
+	if token := rt.config.Server.HealthCheckToken.String(); token != "" {
+		given := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			newJSONError(
+				errors.New("router: missing or invalid health check token"),
+				http.StatusUnauthorized,
+			).Pipe(c)
+			return
+		}
+	}
+
+	if rt.startupPending() {
+		newDependencyError("startup", errStartupPending).Pipe(c)
+		return
+	}
+
+	deep, _ := strconv.ParseBool(c.Query("deep"))
+	if depErr := rt.checkDependencies(deep); depErr != nil {
+		depErr.Pipe(c)
 		return
 	}
 	c.JSON(http.StatusOK, map[string]bool{"ok": true})
 }
+
+func readinessCacheKey(deep bool) string {
+	return fmt.Sprintf("readiness-%v", deep)
+}
+
+// checkDependencies returns an error describing the first dependency that
+// is not available. In case a TTL for health check results is configured,
+// results are reused for the given duration so that frequent probes do not
+// add load to the database.
+func (rt *router) checkDependencies(deep bool) *dependencyError {
+	cache, cacheKey := rt.getCache(), readinessCacheKey(deep)
+	if rt.healthCacheTTL > 0 {
+		if cachedItem, ok := cache.Get(cacheKey); ok {
+			if result, castOk := cachedItem.(*dependencyError); castOk {
+				return result
+			}
+		}
+	}
+
+	result := rt.probeDependencies(deep)
+	if rt.healthCacheTTL > 0 {
+		cache.Set(cacheKey, result, rt.healthCacheTTL)
+	}
+	return result
+}
+
+func (rt *router) probeDependencies(deep bool) *dependencyError {
+	if err := rt.db.CheckHealth(); err != nil {
+		return newDependencyError(
+			"database",
+			fmt.Errorf("router: failed checking health of connected persistence layer: %v", err),
+		)
+	}
+
+	// Checking the mailer might require connecting to a remote server, so
+	// it is only done when explicitly requested.
+	if deep {
+		if checker, ok := rt.mailer.(mailer.HealthChecker); ok {
+			if err := checker.CheckHealth(); err != nil {
+				return newDependencyError(
+					"mailer",
+					fmt.Errorf("router: failed checking health of configured mailer: %v", err),
+				)
+			}
+		}
+	}
+	return nil
+}
+
+// WithHealthCacheTTL reuses the result of checking the application's
+// dependencies for the given duration. Liveness checks are not affected.
+func WithHealthCacheTTL(d time.Duration) Config {
+	return func(r *router) {
+		r.healthCacheTTL = d
+	}
+}
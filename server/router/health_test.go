@@ -4,51 +4,251 @@
 package router
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/config"
+	"github.com/offen/offen/server/mailer"
 	"github.com/offen/offen/server/persistence"
 )
 
 type mockHealthChecker struct {
 	persistence.Service
-	err error
+	err     error
+	checked bool
+	calls   int
 }
 
 func (m *mockHealthChecker) CheckHealth() error {
+	m.checked = true
+	m.calls++
+	return m.err
+}
+
+type mockHealthMailer struct {
+	mailer.Mailer
+	err     error
+	checked bool
+}
+
+func (m *mockHealthMailer) CheckHealth() error {
+	m.checked = true
 	return m.err
 }
 
 func TestRouter_getHealth(t *testing.T) {
-	t.Run("ok", func(t *testing.T) {
-		rt := router{
-			db: &mockHealthChecker{},
-		}
-		m := gin.New()
-		m.GET("/", rt.getHealth)
-		w := httptest.NewRecorder()
-		r := httptest.NewRequest(http.MethodGet, "/", nil)
-		m.ServeHTTP(w, r)
-		if w.Code != http.StatusOK {
-			t.Errorf("Unexpected status code %v", w.Code)
-		}
-	})
-	t.Run("ping error", func(t *testing.T) {
-		rt := router{
-			db: &mockHealthChecker{
-				err: errors.New("did not work"),
-			},
-		}
-		m := gin.New()
-		m.GET("/", rt.getHealth)
-		w := httptest.NewRecorder()
-		r := httptest.NewRequest(http.MethodGet, "/", nil)
-		m.ServeHTTP(w, r)
-		if w.Code != http.StatusBadGateway {
-			t.Errorf("Unexpected status code %v", w.Code)
-		}
-	})
+	db := &mockHealthChecker{err: errors.New("did not work")}
+	rt := router{db: db, config: &config.Config{}}
+	m := gin.New()
+	m.GET("/", rt.getHealth)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	m.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Unexpected status code %v", w.Code)
+	}
+	if db.checked {
+		t.Error("Expected liveness check not to check the database")
+	}
+}
+
+func TestRouter_getReady(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          string
+		header         string
+		db             *mockHealthChecker
+		expectedStatus int
+		expectCheck    bool
+	}{
+		{
+			"ok",
+			"",
+			"",
+			&mockHealthChecker{},
+			http.StatusOK,
+			true,
+		},
+		{
+			"ping error",
+			"",
+			"",
+			&mockHealthChecker{err: errors.New("did not work")},
+			http.StatusServiceUnavailable,
+			true,
+		},
+		{
+			"token configured, missing token",
+			"s3cr3t",
+			"",
+			&mockHealthChecker{err: errors.New("did not work")},
+			http.StatusUnauthorized,
+			false,
+		},
+		{
+			"token configured, bad token",
+			"s3cr3t",
+			"Bearer other",
+			&mockHealthChecker{},
+			http.StatusUnauthorized,
+			false,
+		},
+		{
+			"token configured, deep check",
+			"s3cr3t",
+			"Bearer s3cr3t",
+			&mockHealthChecker{err: errors.New("did not work")},
+			http.StatusServiceUnavailable,
+			true,
+		},
+		{
+			"token configured, deep check ok",
+			"s3cr3t",
+			"Bearer s3cr3t",
+			&mockHealthChecker{},
+			http.StatusOK,
+			true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Server.HealthCheckToken = config.EnvString(test.token)
+			rt := router{
+				db:     test.db,
+				config: cfg,
+			}
+			m := gin.New()
+			m.GET("/", rt.getReady)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.header != "" {
+				r.Header.Set("Authorization", test.header)
+			}
+			m.ServeHTTP(w, r)
+			if w.Code != test.expectedStatus {
+				t.Errorf("Unexpected status code %v", w.Code)
+			}
+			if test.db.checked != test.expectCheck {
+				t.Errorf("Unexpected health check state %v", test.db.checked)
+			}
+		})
+	}
+}
+
+func TestRouter_getReady_Dependencies(t *testing.T) {
+	tests := []struct {
+		name               string
+		query              string
+		db                 *mockHealthChecker
+		mailer             *mockHealthMailer
+		expectedStatus     int
+		expectedDependency string
+		expectMailerCheck  bool
+	}{
+		{
+			"database error",
+			"?deep=1",
+			&mockHealthChecker{err: errors.New("did not work")},
+			&mockHealthMailer{},
+			http.StatusServiceUnavailable,
+			"database",
+			false,
+		},
+		{
+			"mailer not checked by default",
+			"",
+			&mockHealthChecker{},
+			&mockHealthMailer{err: errors.New("did not work")},
+			http.StatusOK,
+			"",
+			false,
+		},
+		{
+			"mailer error",
+			"?deep=1",
+			&mockHealthChecker{},
+			&mockHealthMailer{err: errors.New("did not work")},
+			http.StatusServiceUnavailable,
+			"mailer",
+			true,
+		},
+		{
+			"deep check ok",
+			"?deep=true",
+			&mockHealthChecker{},
+			&mockHealthMailer{},
+			http.StatusOK,
+			"",
+			true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := router{
+				db:     test.db,
+				mailer: test.mailer,
+				config: &config.Config{},
+			}
+			m := gin.New()
+			m.GET("/", rt.getReady)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/"+test.query, nil)
+			m.ServeHTTP(w, r)
+			if w.Code != test.expectedStatus {
+				t.Errorf("Unexpected status code %v", w.Code)
+			}
+			var body struct {
+				Dependency string `json:"dependency"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Unexpected error decoding response body: %v", err)
+			}
+			if body.Dependency != test.expectedDependency {
+				t.Errorf("Expected dependency %q, got %q", test.expectedDependency, body.Dependency)
+			}
+			if test.mailer.checked != test.expectMailerCheck {
+				t.Errorf("Unexpected mailer check state %v", test.mailer.checked)
+			}
+		})
+	}
+}
+
+func TestRouter_getReady_Cache(t *testing.T) {
+	tests := []struct {
+		name          string
+		ttl           time.Duration
+		paths         []string
+		expectedCalls int
+	}{
+		{"no ttl", 0, []string{"/readyz", "/readyz"}, 2},
+		{"within ttl", time.Minute, []string{"/readyz", "/readyz"}, 1},
+		{"deep and shallow checks", time.Minute, []string{"/readyz", "/readyz?deep=1", "/readyz?deep=1"}, 2},
+		{"liveness", time.Minute, []string{"/healthz", "/healthz"}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := &mockHealthChecker{err: errors.New("did not work")}
+			rt := router{db: db, config: &config.Config{}, healthCacheTTL: test.ttl}
+			m := gin.New()
+			m.GET("/healthz", rt.getHealth)
+			m.GET("/readyz", rt.getReady)
+			for _, path := range test.paths {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(http.MethodGet, path, nil)
+				m.ServeHTTP(w, r)
+				if path != "/healthz" && w.Code != http.StatusServiceUnavailable {
+					t.Errorf("Expected cached failure to be reported, got status %d", w.Code)
+				}
+			}
+			if db.calls != test.expectedCalls {
+				t.Errorf("Expected %d database pings, got %d", test.expectedCalls, db.calls)
+			}
+		})
+	}
 }
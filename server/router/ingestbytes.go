@@ -0,0 +1,48 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"fmt"
+	"time"
+)
+
+func ingestBytesCacheKey(accountID string) string {
+	return fmt.Sprintf("ingest-bytes-%s", accountID)
+}
+
+// checkIngestByteRate counts the given number of inbound bytes for the given
+// account and reports how long the client has to wait in case the account
+// would exceed the configured number of bytes per minute. Rejected payloads
+// are not counted, so small events can still be ingested while large ones
+// are rejected.
+func (rt *router) checkIngestByteRate(accountID string, size int) (time.Duration, bool) {
+	limit := rt.config.App.IngestByteRateLimit
+	if limit <= 0 {
+		return 0, false
+	}
+	cache, countKey := rt.getCache(), ingestBytesCacheKey(accountID)
+	if size > limit {
+		_, until, ok := cache.GetWithExpiration(countKey)
+		if !ok {
+			return ingestSpikeWindow, true
+		}
+		return time.Until(until), true
+	}
+	if err := cache.Add(countKey, size, ingestSpikeWindow); err == nil {
+		return 0, false
+	}
+	count, err := cache.IncrementInt(countKey, size)
+	if err != nil {
+		// the window has expired in between calls
+		cache.Set(countKey, size, ingestSpikeWindow)
+		return 0, false
+	}
+	if count <= limit {
+		return 0, false
+	}
+	cache.DecrementInt(countKey, size)
+	_, until, _ := cache.GetWithExpiration(countKey)
+	return time.Until(until), true
+}
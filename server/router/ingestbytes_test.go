@@ -0,0 +1,63 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/config"
+	"github.com/offen/offen/server/ratelimiter"
+)
+
+func TestRouter_postEvents_IngestByteRate(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.App.IngestByteRateLimit = 2048
+	rt := router{
+		db:      &mockPostEventsService{},
+		config:  cfg,
+		limiter: ratelimiter.NewNoopRateLimiter(),
+	}
+	m := gin.New()
+	m.POST("/", func(c *gin.Context) {
+		c.Set(contextKeyCookie, "user-id")
+		c.Next()
+	}, rt.postEvents)
+
+	post := func(accountID string, payloadSize int) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(
+			http.MethodPost, "/",
+			strings.NewReader(`{"accountId":"`+accountID+`","payload":"`+strings.Repeat("x", payloadSize)+`"}`),
+		)
+		m.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := post("account-a", 1500); w.Code != http.StatusCreated {
+		t.Fatalf("Unexpected status code %d for first large event", w.Code)
+	}
+	w := post("account-a", 1500)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected large event to exceed byte rate, got status code %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header to be set")
+	}
+	if w := post("account-a", 4096); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected event larger than the limit to be rejected, got status code %d", w.Code)
+	}
+
+	for i := 0; i < 3; i++ {
+		if w := post("account-a", 10); w.Code != http.StatusCreated {
+			t.Errorf("Expected small event %d to pass, got status code %d", i, w.Code)
+		}
+	}
+	if w := post("account-b", 1500); w.Code != http.StatusCreated {
+		t.Errorf("Expected other accounts not to be affected, got status code %d", w.Code)
+	}
+}
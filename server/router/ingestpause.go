@@ -0,0 +1,124 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+)
+
+// ingestPauseRetryAfter is the time clients are asked to wait before sending
+// events again while ingestion for an account is paused.
+const ingestPauseRetryAfter = time.Minute * 5
+
+func ingestPauseCacheKey(accountID string) string {
+	return fmt.Sprintf("ingest-paused-%s", accountID)
+}
+
+// lookupIngestPause reports whether ingestion for the given account has been
+// paused by an operator. Results are cached for a short amount of time as
+// this is called for every inbound event, so other nodes might keep on
+// accepting events for up to a minute after pausing.
+func (rt *router) lookupIngestPause(accountID string) (bool, error) {
+	cache, cacheKey := rt.getCache(), ingestPauseCacheKey(accountID)
+	if cachedItem, ok := cache.Get(cacheKey); ok {
+		if paused, castOk := cachedItem.(bool); castOk {
+			return paused, nil
+		}
+	}
+
+	pause, err := rt.db.GetIngestPause(accountID)
+	if err != nil {
+		return false, err
+	}
+	cache.Set(cacheKey, pause.Paused, time.Minute)
+	return pause.Paused, nil
+}
+
+func (rt *router) getIngestPause(c *gin.Context) {
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+
+	accountID := c.Param("accountID")
+	if ok := accountUser.CanAccessAccount(accountID) && accountUser.IsSuperAdmin(); !ok {
+		newJSONError(
+			fmt.Errorf("router: account user does not have permissions to access ingest pause of account %s", accountID),
+			http.StatusForbidden,
+		).Pipe(c)
+		return
+	}
+
+	pause, err := rt.db.GetIngestPause(accountID)
+	if err != nil {
+		accountError(c, accountID, fmt.Errorf("router: error looking up ingest pause: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, pause)
+}
+
+type ingestPauseRequest struct {
+	Paused *bool `json:"paused"`
+}
+
+func (rt *router) putIngestPause(c *gin.Context) {
+	var req ingestPauseRequest
+	if err := c.BindJSON(&req); err != nil {
+		newJSONError(
+			fmt.Errorf("router: error decoding request payload: %w", err),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+	if req.Paused == nil {
+		newJSONError(
+			errors.New("router: expected request payload to contain a value for paused"),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+
+	accountID := c.Param("accountID")
+	if ok := accountUser.CanAccessAccount(accountID) && accountUser.IsSuperAdmin(); !ok {
+		newJSONError(
+			fmt.Errorf("router: account user does not have permissions to update ingest pause of account %s", accountID),
+			http.StatusForbidden,
+		).Pipe(c)
+		return
+	}
+
+	result, err := rt.db.UpdateIngestPause(accountID, accountUser.AccountUserID, *req.Paused)
+	if err != nil {
+		accountError(c, accountID, fmt.Errorf("router: error updating ingest pause: %w", err))
+		return
+	}
+	rt.getCache().Delete(ingestPauseCacheKey(accountID))
+	if rt.logger != nil {
+		rt.logger.
+			WithField("accountID", accountID).
+			WithField("accountUserID", accountUser.AccountUserID).
+			WithField("paused", result.Paused).
+			Warn("Updated ingest pause for account")
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/config"
+	"github.com/offen/offen/server/persistence"
+	"github.com/offen/offen/server/ratelimiter"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+type mockIngestPauseService struct {
+	persistence.Service
+	paused   map[string]bool
+	inserted []string
+}
+
+func (m *mockIngestPauseService) GetAccountSettings(string) (persistence.AccountSettings, error) {
+	return persistence.AccountSettings{}, nil
+}
+
+func (m *mockIngestPauseService) GetAccountRetention(string) (time.Duration, error) {
+	return 0, nil
+}
+
+func (m *mockIngestPauseService) GetIngestPause(accountID string) (persistence.IngestPause, error) {
+	return persistence.IngestPause{Paused: m.paused[accountID]}, nil
+}
+
+func (m *mockIngestPauseService) UpdateIngestPause(accountID, accountUserID string, paused bool) (persistence.IngestPause, error) {
+	m.paused[accountID] = paused
+	return persistence.IngestPause{Paused: paused, UpdatedBy: accountUserID}, nil
+}
+
+func (m *mockIngestPauseService) Insert(userID, accountID, payload, contentHash string, exempt bool, eventID *string) error {
+	m.inserted = append(m.inserted, accountID)
+	return nil
+}
+
+func TestRouter_ingestPause(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	db := &mockIngestPauseService{paused: map[string]bool{}}
+	rt := router{
+		db:      db,
+		config:  &config.Config{},
+		limiter: ratelimiter.NewNoopRateLimiter(),
+		logger:  logger,
+	}
+
+	m := gin.New()
+	admin := func(c *gin.Context) {
+		c.Set(contextKeyAuth, persistence.LoginResult{
+			AccountUserID: "account-user-a",
+			AdminLevel:    persistence.AccountUserAdminLevelSuperAdmin,
+			Accounts: []persistence.LoginAccountResult{
+				{AccountID: "account-a"},
+				{AccountID: "account-b"},
+			},
+		})
+	}
+	m.PUT("/accounts/:accountID/ingest-pause", admin, rt.putIngestPause)
+	m.PUT("/readonly/:accountID/ingest-pause", func(c *gin.Context) {
+		c.Set(contextKeyAuth, persistence.LoginResult{
+			Accounts: []persistence.LoginAccountResult{{AccountID: "account-a"}},
+		})
+	}, rt.putIngestPause)
+	m.POST("/events", func(c *gin.Context) {
+		c.Set(contextKeyCookie, "user-a")
+	}, rt.postEvents)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		m.ServeHTTP(w, r)
+		return w
+	}
+	postEvent := func(accountID string) *httptest.ResponseRecorder {
+		return do(http.MethodPost, "/events", fmt.Sprintf(`{"accountId":"%s","payload":"payload"}`, accountID))
+	}
+
+	// warm the cache so pausing is required to invalidate it
+	if w := postEvent("account-a"); w.Code != http.StatusCreated {
+		t.Fatalf("Expected event to be accepted before pausing, got %d", w.Code)
+	}
+
+	if w := do(http.MethodPut, "/accounts/account-a/ingest-pause", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for missing value, got %d", w.Code)
+	}
+	if w := do(http.MethodPut, "/readonly/account-a/ingest-pause", `{"paused":true}`); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for non admin, got %d", w.Code)
+	}
+	if w := do(http.MethodPut, "/accounts/account-a/ingest-pause", `{"paused":true}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 when pausing, got %d", w.Code)
+	}
+	entry := hook.LastEntry()
+	if entry == nil || entry.Level != logrus.WarnLevel || entry.Data["accountID"] != "account-a" || entry.Data["paused"] != true {
+		t.Errorf("Expected pausing to be logged, got %v", entry)
+	}
+
+	w := postEvent("account-a")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for paused account, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header for paused account")
+	}
+	if w := postEvent("account-b"); w.Code != http.StatusCreated {
+		t.Errorf("Expected other account to keep accepting events, got %d", w.Code)
+	}
+
+	if w := do(http.MethodPut, "/accounts/account-a/ingest-pause", `{"paused":false}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 when resuming, got %d", w.Code)
+	}
+	if w := postEvent("account-a"); w.Code != http.StatusCreated {
+		t.Errorf("Expected event to be accepted after resuming, got %d", w.Code)
+	}
+
+	if fmt.Sprint(db.inserted) != "[account-a account-b account-a]" {
+		t.Errorf("Unexpected inserted events %v", db.inserted)
+	}
+}
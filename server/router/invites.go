@@ -0,0 +1,141 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+)
+
+const inviteTokenName = "invite"
+
+type inviteResponse struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+func (rt *router) postInvite(c *gin.Context) {
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusUnauthorized,
+		).Pipe(c)
+		return
+	}
+
+	if ok := accountUser.IsSuperAdmin(); !ok {
+		newJSONError(
+			errors.New("router: account user does not have permissions to create invites"),
+			http.StatusForbidden,
+		).Pipe(c)
+		return
+	}
+
+	invite, err := rt.db.CreateInvite(accountUser.AccountUserID, rt.config.App.InviteExpiry)
+	if err != nil {
+		newJSONError(
+			fmt.Errorf("router: error creating invite: %w", err),
+			http.StatusInternalServerError,
+		).Pipe(c)
+		return
+	}
+
+	token, err := rt.inviteSigner.Encode(inviteTokenName, invite.InviteID)
+	if err != nil {
+		newJSONError(
+			fmt.Errorf("router: error signing invite: %w", err),
+			http.StatusInternalServerError,
+		).Pipe(c)
+		return
+	}
+	c.JSON(http.StatusCreated, inviteResponse{
+		Token:   token,
+		Expires: invite.Expires,
+	})
+}
+
+type redeemInviteRequest struct {
+	Token        string `json:"token"`
+	AccountName  string `json:"accountName"`
+	EmailAddress string `json:"emailAddress"`
+	Password     string `json:"password"`
+}
+
+func (rt *router) postRedeemInvite(c *gin.Context) {
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusUnauthorized,
+		).Pipe(c)
+		return
+	}
+
+	var req redeemInviteRequest
+	if err := c.BindJSON(&req); err != nil {
+		newJSONError(
+			fmt.Errorf("router: error decoding request body: %w", err),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+
+	if l := <-rt.getLimiter().LinearThrottle(time.Second*5, fmt.Sprintf("postRedeemInvite-%s", req.EmailAddress)); l.Error != nil {
+		newJSONError(
+			fmt.Errorf("router: error rate limiting request: %w", l.Error),
+			http.StatusTooManyRequests,
+		).Pipe(c)
+		return
+	}
+
+	var inviteID string
+	if err := rt.inviteSigner.Decode(inviteTokenName, req.Token, &inviteID); err != nil {
+		newJSONError(
+			fmt.Errorf("router: error decoding signed invite: %w", err),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+
+	accountInRequest, err := rt.db.Login(req.EmailAddress, req.Password)
+	if err != nil {
+		newJSONError(
+			fmt.Errorf("router: error validating given credentials: %w", err),
+			http.StatusUnauthorized,
+		).Pipe(c)
+		return
+	}
+	// the given credentials might be valid, but belong to a different user
+	// than the one who is calling this
+	if accountInRequest.AccountUserID != accountUser.AccountUserID {
+		newJSONError(
+			fmt.Errorf("router: given credentials belong to user other than requester with id %s", accountUser.AccountUserID),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+
+	if err := rt.db.RedeemInvite(inviteID, html.UnescapeString(rt.sanitizer.Sanitize(req.AccountName)), req.EmailAddress, req.Password); err != nil {
+		if errors.Is(err, persistence.ErrInviteUnavailable) {
+			newJSONError(
+				fmt.Errorf("router: error redeeming invite: %w", err),
+				http.StatusGone,
+			).Pipe(c)
+			return
+		}
+		newJSONError(
+			fmt.Errorf("router: error redeeming invite: %w", err),
+			http.StatusInternalServerError,
+		).Pipe(c)
+		return
+	}
+	c.JSON(http.StatusCreated, nil)
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/securecookie"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/offen/offen/server/config"
+	"github.com/offen/offen/server/persistence"
+	"github.com/offen/offen/server/ratelimiter"
+)
+
+type mockInvitesDatabase struct {
+	persistence.Service
+	invites  map[string]time.Time
+	created  []string
+	sequence int
+}
+
+func (m *mockInvitesDatabase) CreateInvite(createdBy string, ttl time.Duration) (persistence.Invite, error) {
+	m.sequence++
+	invite := persistence.Invite{
+		InviteID:  fmt.Sprintf("invite-%d", m.sequence),
+		CreatedBy: createdBy,
+		Expires:   time.Now().Add(ttl),
+	}
+	m.invites[invite.InviteID] = invite.Expires
+	return invite, nil
+}
+
+func (m *mockInvitesDatabase) RedeemInvite(inviteID, accountName, emailAddress, password string) error {
+	expires, ok := m.invites[inviteID]
+	if !ok || expires.Before(time.Now()) {
+		return persistence.ErrInviteUnavailable
+	}
+	delete(m.invites, inviteID)
+	m.created = append(m.created, accountName)
+	return nil
+}
+
+func (m *mockInvitesDatabase) Login(emailAddress, password string) (persistence.LoginResult, error) {
+	return persistence.LoginResult{AccountUserID: "user-" + emailAddress}, nil
+}
+
+func TestRouter_invites(t *testing.T) {
+	db := &mockInvitesDatabase{invites: map[string]time.Time{}}
+	cfg := &config.Config{}
+	cfg.App.InviteExpiry = time.Hour
+	rt := router{
+		db:           db,
+		config:       cfg,
+		authSigner:   securecookie.New([]byte("abc"), nil),
+		inviteSigner: newTokenSigner([]byte("abc"), cfg.App.InviteExpiry),
+		sanitizer:    bluemonday.StrictPolicy(),
+		limiter:      ratelimiter.NewNoopRateLimiter(),
+	}
+
+	users := map[string]persistence.LoginResult{
+		"admin": {AccountUserID: "user-admin@offen.dev", AdminLevel: persistence.AccountUserAdminLevelSuperAdmin},
+		"user":  {AccountUserID: "user-develop@offen.dev"},
+	}
+	m := gin.New()
+	m.Use(func(c *gin.Context) {
+		c.Set(contextKeyAuth, users[c.GetHeader("X-User")])
+	})
+	m.POST("/invites", rt.postInvite)
+	m.POST("/redeem", rt.postRedeemInvite)
+
+	mint := func(user string) (int, string) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/invites", nil)
+		r.Header.Set("X-User", user)
+		m.ServeHTTP(w, r)
+		var res inviteResponse
+		json.NewDecoder(w.Body).Decode(&res)
+		return w.Code, res.Token
+	}
+	redeem := func(token string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(
+			http.MethodPost, "/redeem",
+			strings.NewReader(fmt.Sprintf(`{"token":"%s","accountName":"new","emailAddress":"develop@offen.dev","password":"pass"}`, token)),
+		)
+		r.Header.Set("X-User", "user")
+		m.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	t.Run("no admin", func(t *testing.T) {
+		if status, _ := mint("user"); status != http.StatusForbidden {
+			t.Errorf("Unexpected status code %v", status)
+		}
+	})
+
+	t.Run("valid token", func(t *testing.T) {
+		status, token := mint("admin")
+		if status != http.StatusCreated {
+			t.Fatalf("Unexpected status code %v", status)
+		}
+		if status := redeem(token); status != http.StatusCreated {
+			t.Errorf("Unexpected status code %v", status)
+		}
+		if len(db.created) != 1 || db.created[0] != "new" {
+			t.Errorf("Unexpected accounts %v", db.created)
+		}
+
+		t.Run("reuse", func(t *testing.T) {
+			if status := redeem(token); status != http.StatusGone {
+				t.Errorf("Unexpected status code %v", status)
+			}
+			if len(db.created) != 1 {
+				t.Errorf("Unexpected accounts %v", db.created)
+			}
+		})
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		status, token := mint("admin")
+		if status != http.StatusCreated {
+			t.Fatalf("Unexpected status code %v", status)
+		}
+		db.invites[fmt.Sprintf("invite-%d", db.sequence)] = time.Now().Add(-time.Minute)
+		if status := redeem(token); status != http.StatusGone {
+			t.Errorf("Unexpected status code %v", status)
+		}
+	})
+
+	t.Run("forged token", func(t *testing.T) {
+		token, _ := securecookie.New([]byte("xyz"), nil).Encode(inviteTokenName, "invite-1")
+		if status := redeem(token); status != http.StatusBadRequest {
+			t.Errorf("Unexpected status code %v", status)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/ratelimiter"
+)
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// ipRateLimiter keeps a token bucket per client IP. Each bucket holds up to
+// burst tokens and is refilled at rps tokens per second. Buckets are held in
+// a bounded cache, so clients cycling through many addresses cannot exhaust
+// memory.
+type ipRateLimiter struct {
+	sync.Mutex
+	rps     float64
+	burst   float64
+	buckets *ratelimiter.BoundedCache
+	now     func() time.Time
+}
+
+func newIPRateLimiter(rps float64, burst int) *ipRateLimiter {
+	return &ipRateLimiter{
+		rps:     rps,
+		burst:   float64(burst),
+		buckets: ratelimiter.NewBoundedCache(rateLimitMaxEntries),
+		now:     time.Now,
+	}
+}
+
+// allow takes a token from the bucket of the given ip. In case the bucket
+// is empty, it returns the time the client has to wait for the next token.
+func (l *ipRateLimiter) allow(ip string) (time.Duration, bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	now := l.now()
+	bucket := tokenBucket{tokens: l.burst, last: now}
+	if value, ok := l.buckets.Get(ip); ok {
+		bucket = value.(tokenBucket)
+	}
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rps)
+	bucket.last = now
+
+	var wait time.Duration
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	} else {
+		wait = time.Duration((1 - bucket.tokens) / l.rps * float64(time.Second))
+	}
+	// Once refilled completely, a bucket is indistinguishable from a newly
+	// created one, so it can expire from the cache.
+	refill := time.Duration((l.burst - bucket.tokens) / l.rps * float64(time.Second))
+	l.buckets.Set(ip, bucket, refill)
+	return wait, allowed
+}
+
+// ipRateLimitMiddleware rejects requests with 429 in case the client IP
+// has exceeded the configured rate. In case no rate has been configured,
+// all requests are passed through.
+func (rt *router) ipRateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rt.ipLimiter == nil {
+			c.Next()
+			return
+		}
+		if retryAfter, ok := rt.ipLimiter.allow(c.ClientIP()); !ok {
+			c.Header("Retry-After", fmt.Sprintf("%d", int(retryAfter.Seconds())+1))
+			newJSONError(
+				errors.New("router: too many requests from client, please try again later"),
+				http.StatusTooManyRequests,
+			).Pipe(c)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/ratelimiter"
+)
+
+func TestIPRateLimiter(t *testing.T) {
+	now := time.Now()
+	l := newIPRateLimiter(0.5, 2)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if _, ok := l.allow("127.0.0.1"); !ok {
+			t.Fatalf("Expected request %d within burst to be allowed", i)
+		}
+	}
+	wait, ok := l.allow("127.0.0.1")
+	if ok {
+		t.Fatal("Expected request exceeding burst to be rejected")
+	}
+	if wait != 2*time.Second {
+		t.Errorf("Unexpected wait time %v", wait)
+	}
+	if _, ok := l.allow("127.0.0.2"); !ok {
+		t.Error("Expected other ip to be allowed")
+	}
+
+	now = now.Add(2 * time.Second)
+	if _, ok := l.allow("127.0.0.1"); !ok {
+		t.Error("Expected request to be allowed after bucket has been refilled")
+	}
+	if _, ok := l.allow("127.0.0.1"); ok {
+		t.Error("Expected bucket to be empty again")
+	}
+}
+
+func TestIPRateLimiter_Bounded(t *testing.T) {
+	l := newIPRateLimiter(0.5, 2)
+	l.buckets = ratelimiter.NewBoundedCache(2)
+	for i := 0; i < 10; i++ {
+		l.allow(fmt.Sprintf("127.0.0.%d", i))
+	}
+	if n := l.buckets.Len(); n != 2 {
+		t.Errorf("Expected number of buckets to be bounded, got %d buckets", n)
+	}
+}
+
+func TestIPRateLimiter_Expiry(t *testing.T) {
+	l := newIPRateLimiter(1000, 2)
+	l.allow("127.0.0.1")
+	time.Sleep(10 * time.Millisecond)
+	l.allow("127.0.0.2")
+	if n := l.buckets.Len(); n != 1 {
+		t.Errorf("Expected refilled bucket to expire, got %d buckets", n)
+	}
+}
+
+func TestRouter_ipRateLimitMiddleware(t *testing.T) {
+	tests := []struct {
+		name             string
+		rt               router
+		expectedStatuses []int
+	}{
+		{
+			"disabled",
+			router{},
+			[]int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+		{
+			"enabled",
+			router{ipLimiter: newIPRateLimiter(0.01, 2)},
+			[]int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := gin.New()
+			m.POST("/", test.rt.ipRateLimitMiddleware(), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			for i, expected := range test.expectedStatuses {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(http.MethodPost, "/", nil)
+				m.ServeHTTP(w, r)
+				if w.Code != expected {
+					t.Errorf("Expected status %d for request %d, got %d", expected, i, w.Code)
+				}
+				if expected == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+					t.Error("Expected Retry-After header to be set")
+				}
+			}
+		})
+	}
+}
+
+func TestWithRateLimit(t *testing.T) {
+	rt := router{}
+	WithRateLimit(0, 5)(&rt)
+	if rt.ipLimiter != nil {
+		t.Error("Expected zero rate to disable the limit")
+	}
+	WithRateLimit(1, 5)(&rt)
+	if rt.ipLimiter == nil {
+		t.Error("Expected limiter to be set")
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	defaultMaxJSONDepth  = 16
+	defaultMaxJSONTokens = 1024
+)
+
+// WithJSONDecodeLimits limits the nesting depth and the number of tokens of
+// JSON documents sent to the event and secret endpoints. Documents exceeding
+// either limit are rejected before being decoded. Passing zero for a limit
+// uses its default value.
+func WithJSONDecodeLimits(maxDepth, maxTokens int) Config {
+	return func(r *router) {
+		r.maxJSONDepth = maxDepth
+		r.maxJSONTokens = maxTokens
+	}
+}
+
+func (rt *router) jsonLimits() (int, int) {
+	maxDepth, maxTokens := rt.maxJSONDepth, rt.maxJSONTokens
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxJSONDepth
+	}
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxJSONTokens
+	}
+	return maxDepth, maxTokens
+}
+
+// checkJSONLimits walks the tokens of the given document without decoding
+// it into any value, so that pathologically nested or oversized documents
+// can be rejected while only a small amount of memory has been used.
+func checkJSONLimits(body []byte, maxDepth, maxTokens int) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	var depth, tokens int
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("router: error reading json token: %w", err)
+		}
+		tokens++
+		if tokens > maxTokens {
+			return fmt.Errorf("router: json document exceeds the maximum of %d tokens", maxTokens)
+		}
+		if delim, ok := token.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
+				depth++
+				if depth > maxDepth {
+					return fmt.Errorf("router: json document exceeds the maximum nesting depth of %d", maxDepth)
+				}
+			case '}', ']':
+				depth--
+			}
+		}
+	}
+}
+
+// decodeJSON unmarshals the given body into v after checking it against
+// the configured decode limits.
+func (rt *router) decodeJSON(body []byte, v interface{}) error {
+	maxDepth, maxTokens := rt.jsonLimits()
+	if err := checkJSONLimits(body, maxDepth, maxTokens); err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,53 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckJSONLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		expectError bool
+	}{
+		{"flat object", `{"accountId": "account-a", "payload": "encrypted"}`, false},
+		{"at depth limit", `{"a": {"b": {"c": [1]}}}`, false},
+		{"exceeding depth limit", `{"a": {"b": {"c": [[1]]}}}`, true},
+		{"deeply nested", strings.Repeat("[", 100000) + strings.Repeat("]", 100000), true},
+		{"sibling objects", `[{}, {}, {}, {}, {}, {}]`, false},
+		{"exceeding token limit", `[` + strings.Repeat(`1, `, 32) + `1]`, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkJSONLimits([]byte(test.body), 4, 32)
+			if (err != nil) != test.expectError {
+				t.Errorf("Unexpected error value %v", err)
+			}
+		})
+	}
+}
+
+func TestRouter_decodeJSON(t *testing.T) {
+	rt := &router{}
+	nested := `{"accountId": "account-a", "payload": ` + strings.Repeat(`{"a": `, defaultMaxJSONDepth) + `1` + strings.Repeat(`}`, defaultMaxJSONDepth) + `}`
+	var evt inboundEventPayload
+	if err := rt.decodeJSON([]byte(nested), &evt); err == nil {
+		t.Error("Expected error decoding deeply nested document, got nil")
+	}
+
+	if err := rt.decodeJSON([]byte(`{"accountId": "account-a", "payload": "encrypted"}`), &evt); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if evt.AccountID != "account-a" || evt.Payload != "encrypted" {
+		t.Errorf("Unexpected result %v", evt)
+	}
+
+	rt = &router{maxJSONDepth: 1}
+	if err := rt.decodeJSON([]byte(`{"accountId": "account-a", "payload": {}}`), &evt); err == nil {
+		t.Error("Expected error when exceeding custom depth limit, got nil")
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+)
+
+func (rt *router) getLegalHold(c *gin.Context) {
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+
+	accountID := c.Param("accountID")
+	if ok := accountUser.CanAccessAccount(accountID) && accountUser.IsSuperAdmin(); !ok {
+		newJSONError(
+			fmt.Errorf("router: account user does not have permissions to access legal hold of account %s", accountID),
+			http.StatusForbidden,
+		).Pipe(c)
+		return
+	}
+
+	hold, err := rt.db.GetLegalHold(accountID)
+	if err != nil {
+		accountError(c, accountID, fmt.Errorf("router: error looking up legal hold: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, hold)
+}
+
+type legalHoldRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+func (rt *router) putLegalHold(c *gin.Context) {
+	var req legalHoldRequest
+	if err := c.BindJSON(&req); err != nil {
+		newJSONError(
+			fmt.Errorf("router: error decoding request payload: %w", err),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+	if req.Enabled == nil {
+		newJSONError(
+			errors.New("router: expected request payload to contain a value for enabled"),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+
+	accountID := c.Param("accountID")
+	if ok := accountUser.CanAccessAccount(accountID) && accountUser.IsSuperAdmin(); !ok {
+		newJSONError(
+			fmt.Errorf("router: account user does not have permissions to update legal hold of account %s", accountID),
+			http.StatusForbidden,
+		).Pipe(c)
+		return
+	}
+
+	result, err := rt.db.UpdateLegalHold(accountID, accountUser.AccountUserID, *req.Enabled)
+	if err != nil {
+		accountError(c, accountID, fmt.Errorf("router: error updating legal hold: %w", err))
+		return
+	}
+	if rt.logger != nil {
+		rt.logger.
+			WithField("accountID", accountID).
+			WithField("accountUserID", accountUser.AccountUserID).
+			WithField("enabled", result.Enabled).
+			Warn("Updated legal hold for account")
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+type mockLegalHoldService struct {
+	persistence.Service
+	hold persistence.LegalHold
+}
+
+func (m *mockLegalHoldService) GetLegalHold(string) (persistence.LegalHold, error) {
+	return m.hold, nil
+}
+
+func (m *mockLegalHoldService) UpdateLegalHold(accountID, accountUserID string, enabled bool) (persistence.LegalHold, error) {
+	m.hold = persistence.LegalHold{Enabled: enabled, UpdatedBy: accountUserID}
+	return m.hold, nil
+}
+
+func TestRouter_legalHold(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	db := &mockLegalHoldService{}
+	rt := router{db: db, logger: logger}
+	setUser := func(user interface{}) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Set(contextKeyAuth, user)
+		}
+	}
+
+	m := gin.New()
+	admin := setUser(persistence.LoginResult{
+		AccountUserID: "account-user-a",
+		AdminLevel:    persistence.AccountUserAdminLevelSuperAdmin,
+		Accounts:      []persistence.LoginAccountResult{{AccountID: "account-a"}},
+	})
+	m.GET("/accounts/:accountID/legal-hold", admin, rt.getLegalHold)
+	m.PUT("/accounts/:accountID/legal-hold", admin, rt.putLegalHold)
+	m.PUT("/readonly/:accountID/legal-hold", setUser(persistence.LoginResult{
+		Accounts: []persistence.LoginAccountResult{{AccountID: "account-a"}},
+	}), rt.putLegalHold)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		m.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := do(http.MethodPut, "/accounts/account-a/legal-hold", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for missing value, got %d", w.Code)
+	}
+	if w := do(http.MethodPut, "/readonly/account-a/legal-hold", `{"enabled":true}`); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for non admin, got %d", w.Code)
+	}
+	if w := do(http.MethodPut, "/accounts/account-b/legal-hold", `{"enabled":true}`); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for account out of scope, got %d", w.Code)
+	}
+	if len(hook.Entries) != 0 {
+		t.Errorf("Expected rejected requests not to be logged, got %v", hook.Entries)
+	}
+
+	if w := do(http.MethodPut, "/accounts/account-a/legal-hold", `{"enabled":true}`); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 when updating legal hold, got %d", w.Code)
+	}
+	if !db.hold.Enabled || db.hold.UpdatedBy != "account-user-a" {
+		t.Errorf("Unexpected legal hold %v", db.hold)
+	}
+	entry := hook.LastEntry()
+	if entry == nil || entry.Level != logrus.WarnLevel || entry.Data["accountUserID"] != "account-user-a" || entry.Data["enabled"] != true {
+		t.Errorf("Expected update to be logged, got %v", entry)
+	}
+
+	w := do(http.MethodGet, "/accounts/account-a/legal-hold", "")
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 when reading legal hold, got %d", w.Code)
+	}
+	var hold persistence.LegalHold
+	if err := json.Unmarshal(w.Body.Bytes(), &hold); err != nil || !hold.Enabled {
+		t.Errorf("Unexpected response %s", w.Body.String())
+	}
+}
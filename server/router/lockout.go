@@ -0,0 +1,158 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+	"github.com/offen/offen/server/ratelimiter"
+)
+
+func loginFailuresCacheKey(username string) string {
+	return fmt.Sprintf("login-failures-%s", strings.ToLower(username))
+}
+
+func loginLockoutCacheKey(username string) string {
+	return fmt.Sprintf("login-lockout-%s", strings.ToLower(username))
+}
+
+// loginLockouts keeps track of failed logins and locked usernames. As
+// failures are recorded for arbitrary usernames, they are held in a bounded
+// cache, so that trying many distinct usernames cannot exhaust memory. Locks
+// are kept apart from failures, so that recording failures cannot evict
+// active locks.
+type loginLockouts struct {
+	sync.Mutex
+	failures *ratelimiter.BoundedCache
+	locks    map[string]time.Time
+}
+
+type loginFailures struct {
+	count     int
+	windowEnd time.Time
+}
+
+func newLoginLockouts() *loginLockouts {
+	return &loginLockouts{
+		failures: ratelimiter.NewBoundedCache(rateLimitMaxEntries),
+		locks:    map[string]time.Time{},
+	}
+}
+
+func (rt *router) getLoginLockouts() *loginLockouts {
+	if rt.loginLockouts == nil {
+		rt.loginLockouts = newLoginLockouts()
+	}
+	return rt.loginLockouts
+}
+
+// loginLockedOut reports whether logins for the given username are currently
+// locked and for how long the lock will remain in place.
+func (rt *router) loginLockedOut(username string) (time.Duration, bool) {
+	if rt.config.App.LoginLockoutThreshold <= 0 {
+		return 0, false
+	}
+	lockouts, lockKey := rt.getLoginLockouts(), loginLockoutCacheKey(username)
+	lockouts.Lock()
+	defer lockouts.Unlock()
+	until, ok := lockouts.locks[lockKey]
+	if !ok {
+		return 0, false
+	}
+	if remaining := time.Until(until); remaining > 0 {
+		return remaining, true
+	}
+	delete(lockouts.locks, lockKey)
+	return 0, false
+}
+
+// recordLoginFailure counts a failed login for the given username. Once the
+// configured number of failures within the configured window is reached,
+// logins for the username are locked for the configured cooldown. Failures
+// are tracked irrespective of whether the username exists so that the lock
+// does not leak information about existing account users.
+func (rt *router) recordLoginFailure(username string) {
+	threshold := rt.config.App.LoginLockoutThreshold
+	if threshold <= 0 {
+		return
+	}
+	lockouts, failuresKey := rt.getLoginLockouts(), loginFailuresCacheKey(username)
+	lockouts.Lock()
+	defer lockouts.Unlock()
+
+	now := time.Now()
+	failures := loginFailures{windowEnd: now.Add(rt.config.App.LoginLockoutWindow)}
+	if value, ok := lockouts.failures.Get(failuresKey); ok {
+		failures = value.(loginFailures)
+	}
+	failures.count++
+	if failures.count < threshold {
+		lockouts.failures.Set(failuresKey, failures, time.Until(failures.windowEnd))
+		return
+	}
+	lockouts.failures.Delete(failuresKey)
+	// expired locks are dropped whenever a new one is added, so they do not
+	// accumulate in memory
+	for key, until := range lockouts.locks {
+		if !now.Before(until) {
+			delete(lockouts.locks, key)
+		}
+	}
+	cooldown := rt.config.App.LoginLockoutCooldown
+	lockouts.locks[loginLockoutCacheKey(username)] = now.Add(cooldown)
+	if rt.logger != nil {
+		rt.logger.
+			WithField("cooldown", cooldown.String()).
+			Warn("Locking logins for account user after repeated failed attempts")
+	}
+}
+
+func (rt *router) resetLoginFailures(username string) {
+	lockouts := rt.getLoginLockouts()
+	lockouts.Lock()
+	defer lockouts.Unlock()
+	lockouts.failures.Delete(loginFailuresCacheKey(username))
+	delete(lockouts.locks, loginLockoutCacheKey(username))
+}
+
+type unlockLoginRequest struct {
+	EmailAddress string `json:"emailAddress"`
+}
+
+func (rt *router) postUnlockLogin(c *gin.Context) {
+	var req unlockLoginRequest
+	if err := c.BindJSON(&req); err != nil {
+		newJSONError(
+			fmt.Errorf("router: error decoding request payload: %w", err),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+	if !accountUser.IsSuperAdmin() {
+		newJSONError(
+			errors.New("router: account user does not have permissions to unlock logins"),
+			http.StatusForbidden,
+		).Pipe(c)
+		return
+	}
+
+	rt.resetLoginFailures(req.EmailAddress)
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,237 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/securecookie"
+	"github.com/offen/offen/server/config"
+	"github.com/offen/offen/server/persistence"
+	"github.com/offen/offen/server/ratelimiter"
+)
+
+type mockLockoutLoginDatabase struct {
+	persistence.Service
+	password string
+	err      error
+}
+
+func (m *mockLockoutLoginDatabase) Login(username, password string) (persistence.LoginResult, error) {
+	if m.err != nil {
+		return persistence.LoginResult{}, m.err
+	}
+	if password != m.password {
+		return persistence.LoginResult{}, fmt.Errorf("%w: bad password", persistence.ErrBadCredentials)
+	}
+	return persistence.LoginResult{AccountUserID: "user-a"}, nil
+}
+
+func (m *mockLockoutLoginDatabase) CreateSession(accountUserID string, ttl time.Duration) (persistence.Session, error) {
+	return persistence.Session{SessionID: "session-a", AccountUserID: accountUserID}, nil
+}
+
+func TestRouter_postLogin_Lockout(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.App.LoginLockoutThreshold = 3
+	cfg.App.LoginLockoutWindow = time.Minute
+	cfg.App.LoginLockoutCooldown = time.Millisecond * 100
+	rt := router{
+		config:     cfg,
+		db:         &mockLockoutLoginDatabase{password: "secret"},
+		authSigner: securecookie.New([]byte("abc"), nil),
+		limiter:    ratelimiter.NewNoopRateLimiter(),
+	}
+	m := gin.New()
+	m.POST("/", rt.postLogin)
+
+	login := func(password string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(
+			http.MethodPost, "/",
+			strings.NewReader(`{"username":"develop@offen.dev","password":"`+password+`"}`),
+		)
+		m.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := login("wrong"); code != http.StatusUnauthorized {
+			t.Fatalf("Unexpected status code %d for attempt %d", code, i)
+		}
+	}
+	if code := login("secret"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected correct password to be rejected while locked, got %d", code)
+	}
+
+	time.Sleep(cfg.App.LoginLockoutCooldown)
+	if code := login("secret"); code != http.StatusOK {
+		t.Errorf("Expected login to succeed after cooldown, got %d", code)
+	}
+}
+
+func TestRouter_postLogin_LockoutDatabaseError(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.App.LoginLockoutThreshold = 2
+	cfg.App.LoginLockoutWindow = time.Minute
+	cfg.App.LoginLockoutCooldown = time.Minute
+	db := &mockLockoutLoginDatabase{password: "secret", err: errors.New("did not work")}
+	rt := router{
+		config:     cfg,
+		db:         db,
+		authSigner: securecookie.New([]byte("abc"), nil),
+		limiter:    ratelimiter.NewNoopRateLimiter(),
+	}
+	m := gin.New()
+	m.POST("/", rt.postLogin)
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(
+			http.MethodPost, "/",
+			strings.NewReader(`{"username":"develop@offen.dev","password":"secret"}`),
+		)
+		m.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Unexpected status code %d for attempt %d", w.Code, i)
+		}
+	}
+	if _, locked := rt.loginLockedOut("develop@offen.dev"); locked {
+		t.Error("Expected database errors not to lock logins")
+	}
+}
+
+func TestRouter_recordLoginFailure_Bounded(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.App.LoginLockoutThreshold = 3
+	cfg.App.LoginLockoutWindow = time.Minute
+	cfg.App.LoginLockoutCooldown = time.Minute
+	rt := router{
+		config: cfg,
+		loginLockouts: &loginLockouts{
+			failures: ratelimiter.NewBoundedCache(10),
+			locks:    map[string]time.Time{},
+		},
+	}
+	for i := 0; i < 3; i++ {
+		rt.recordLoginFailure("develop@offen.dev")
+	}
+	for i := 0; i < 100; i++ {
+		rt.recordLoginFailure(fmt.Sprintf("user-%d@offen.dev", i))
+	}
+	if n := rt.loginLockouts.failures.Len(); n != 10 {
+		t.Errorf("Expected number of failures to be bounded, got %d", n)
+	}
+	if _, locked := rt.loginLockedOut("develop@offen.dev"); !locked {
+		t.Error("Expected lock to survive failures for other usernames")
+	}
+}
+
+func TestRouter_postLogin_SuccessResetsFailures(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.App.LoginLockoutThreshold = 2
+	cfg.App.LoginLockoutWindow = time.Minute
+	cfg.App.LoginLockoutCooldown = time.Minute
+	rt := router{
+		config:     cfg,
+		db:         &mockLockoutLoginDatabase{password: "secret"},
+		authSigner: securecookie.New([]byte("abc"), nil),
+		limiter:    ratelimiter.NewNoopRateLimiter(),
+	}
+	m := gin.New()
+	m.POST("/", rt.postLogin)
+
+	for i, attempt := range []struct {
+		password       string
+		expectedStatus int
+	}{
+		{"wrong", http.StatusUnauthorized},
+		{"secret", http.StatusOK},
+		{"wrong", http.StatusUnauthorized},
+		{"secret", http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(
+			http.MethodPost, "/",
+			strings.NewReader(`{"username":"develop@offen.dev","password":"`+attempt.password+`"}`),
+		)
+		m.ServeHTTP(w, r)
+		if w.Code != attempt.expectedStatus {
+			t.Errorf("Unexpected status code %d for attempt %d", w.Code, i)
+		}
+	}
+}
+
+func TestRouter_postUnlockLogin(t *testing.T) {
+	tests := []struct {
+		name               string
+		user               interface{}
+		body               string
+		expectedStatusCode int
+		expectUnlocked     bool
+	}{
+		{
+			"bad payload",
+			persistence.LoginResult{AdminLevel: persistence.AccountUserAdminLevelSuperAdmin},
+			"{{",
+			http.StatusBadRequest,
+			false,
+		},
+		{
+			"no user",
+			nil,
+			`{"emailAddress":"develop@offen.dev"}`,
+			http.StatusNotFound,
+			false,
+		},
+		{
+			"no admin",
+			persistence.LoginResult{},
+			`{"emailAddress":"develop@offen.dev"}`,
+			http.StatusForbidden,
+			false,
+		},
+		{
+			"ok",
+			persistence.LoginResult{AdminLevel: persistence.AccountUserAdminLevelSuperAdmin},
+			`{"emailAddress":"Develop@offen.dev"}`,
+			http.StatusNoContent,
+			true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.App.LoginLockoutThreshold = 1
+			cfg.App.LoginLockoutWindow = time.Minute
+			cfg.App.LoginLockoutCooldown = time.Minute
+			rt := router{config: cfg}
+			rt.recordLoginFailure("develop@offen.dev")
+			if _, locked := rt.loginLockedOut("develop@offen.dev"); !locked {
+				t.Fatal("Expected login to be locked")
+			}
+
+			m := gin.New()
+			m.POST("/", func(c *gin.Context) {
+				c.Set(contextKeyAuth, test.user)
+			}, rt.postUnlockLogin)
+			w := httptest.NewRecorder()
+			m.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body)))
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("Unexpected status code %v", w.Code)
+			}
+			if _, locked := rt.loginLockedOut("develop@offen.dev"); locked == test.expectUnlocked {
+				t.Errorf("Unexpected lock state %v", locked)
+			}
+		})
+	}
+}
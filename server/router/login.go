@@ -16,8 +16,14 @@ import (
 )
 
 type loginCredentials struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	ReturnToken bool   `json:"returnToken"`
+}
+
+type loginResponse struct {
+	persistence.LoginResult
+	Token string `json:"token,omitempty"`
 }
 
 func (rt *router) postLogout(c *gin.Context) {
@@ -31,9 +37,40 @@ func (rt *router) postLogout(c *gin.Context) {
 	}
 
 	http.SetCookie(c.Writer, authCookie)
+	if err := rt.endSession(c); err != nil {
+		newJSONError(
+			fmt.Errorf("router: error ending session: %w", err),
+			http.StatusInternalServerError,
+		).Pipe(c)
+		return
+	}
 	c.JSON(http.StatusNoContent, nil)
 }
 
+// authToken returns the signed auth token sent with the request, either in
+// the auth cookie or in the Authorization header.
+func (rt *router) authToken(c *gin.Context) (string, bool) {
+	if authCookie, err := c.Request.Cookie(rt.authCookieName()); err == nil {
+		return authCookie.Value, true
+	}
+	return rt.bearerToken(c)
+}
+
+// endSession deletes the session referenced by the auth token of the given
+// request, so the token stops working even if it has been copied before.
+// Requests without a valid token do not reference a session and are ignored.
+func (rt *router) endSession(c *gin.Context) error {
+	token, ok := rt.authToken(c)
+	if !ok {
+		return nil
+	}
+	sessionID, err := rt.decodeAuthToken(token)
+	if err != nil {
+		return nil
+	}
+	return rt.db.DeleteSession(sessionID)
+}
+
 func (rt *router) postLogin(c *gin.Context) {
 	var credentials loginCredentials
 	if err := c.BindJSON(&credentials); err != nil {
@@ -61,8 +98,22 @@ func (rt *router) postLogin(c *gin.Context) {
 		return
 	}
 
+	if retryAfter, locked := rt.loginLockedOut(credentials.Username); locked {
+		c.Header("Retry-After", fmt.Sprintf("%d", int(retryAfter.Seconds())+1))
+		newJSONError(
+			errors.New("router: login is temporarily locked after repeated failed attempts"),
+			http.StatusTooManyRequests,
+		).Pipe(c)
+		return
+	}
+
 	result, err := rt.db.Login(credentials.Username, credentials.Password)
 	if err != nil {
+		// Only mismatching credentials count towards locking the username,
+		// so that failures of the database cannot lock out account users.
+		if errors.Is(err, persistence.ErrBadCredentials) {
+			rt.recordLoginFailure(credentials.Username)
+		}
 		newJSONError(
 			fmt.Errorf("router: error logging in: %w", err),
 			http.StatusUnauthorized,
@@ -70,7 +121,18 @@ func (rt *router) postLogin(c *gin.Context) {
 		return
 	}
 
-	authCookie, authCookieErr := rt.authCookie(result.AccountUserID, c.GetBool(contextKeySecureContext))
+	rt.resetLoginFailures(credentials.Username)
+
+	session, err := rt.db.CreateSession(result.AccountUserID, rt.authCookieMaxAge())
+	if err != nil {
+		newJSONError(
+			fmt.Errorf("router: error creating session: %w", err),
+			http.StatusInternalServerError,
+		).Pipe(c)
+		return
+	}
+
+	authCookie, authCookieErr := rt.authCookie(session.SessionID, c.GetBool(contextKeySecureContext))
 	if authCookieErr != nil {
 		newJSONError(
 			fmt.Errorf("router: error creating auth cookie: %w", authCookieErr),
@@ -80,6 +142,13 @@ func (rt *router) postLogin(c *gin.Context) {
 	}
 
 	http.SetCookie(c.Writer, authCookie)
+
+	// Clients that cannot rely on cookies can ask for the session token to be
+	// included in the response and send it in an Authorization header instead.
+	if credentials.ReturnToken && rt.config.App.AllowBearerAuth {
+		c.JSON(http.StatusOK, loginResponse{LoginResult: result, Token: authCookie.Value})
+		return
+	}
 	c.JSON(http.StatusOK, result)
 }
 
@@ -187,6 +256,22 @@ func (rt *router) postChangeEmail(c *gin.Context) {
 type forgotPasswordRequest struct {
 	EmailAddress string `json:"emailAddress"`
 	URLTemplate  string `json:"urlTemplate"`
+	CaptchaToken string `json:"captchaToken"`
+}
+
+// resetTokenMaxAge is the duration for which tokens for resetting a password
+// are valid.
+const resetTokenMaxAge = 24 * time.Hour
+
+// resetTokenDecodeMaxAge returns the max age reset tokens are checked
+// against. Tokens might have been signed by another instance whose clock
+// drifts from the one of this instance, so the given tolerance is added to
+// the max age to handle tokens close to expiry consistently.
+func resetTokenDecodeMaxAge(skew time.Duration) time.Duration {
+	if skew > 0 {
+		return resetTokenMaxAge + skew
+	}
+	return resetTokenMaxAge
 }
 
 type forgotPasswordCredentials struct {
@@ -224,18 +309,28 @@ func (rt *router) postForgotPassword(c *gin.Context) {
 		return
 	}
 
+	if rt.captcha != nil {
+		if err := rt.captcha.Verify(req.CaptchaToken, c.ClientIP()); err != nil {
+			newJSONError(
+				fmt.Errorf("router: error verifying captcha: %w", err),
+				http.StatusBadRequest,
+			).Pipe(c)
+			return
+		}
+	}
+
 	token, err := rt.db.GenerateOneTimeKey(req.EmailAddress)
 	if err != nil {
-		rt.logError(err, "error generating one time key")
+		rt.logError(c.Request.Context(), err, "error generating one time key")
 		c.Status(http.StatusNoContent)
 		return
 	}
-	signedCredentials, signErr := rt.cookieSigner.MaxAge(24*60*60).Encode("credentials", forgotPasswordCredentials{
+	signedCredentials, signErr := rt.resetSigner.Encode("credentials", forgotPasswordCredentials{
 		Token:        token,
 		EmailAddress: req.EmailAddress,
 	})
 	if signErr != nil {
-		rt.logError(signErr, "error signing token")
+		rt.logError(c.Request.Context(), signErr, "error signing token")
 		c.Status(http.StatusNoContent)
 		return
 	}
@@ -258,7 +353,14 @@ func (rt *router) postForgotPassword(c *gin.Context) {
 		return
 	}
 
-	if err := rt.mailer.Send(rt.config.SMTP.Sender, req.EmailAddress, subject.String(), body.String()); err != nil {
+	sender := rt.config.SMTP.Sender
+	if accountSender, err := rt.db.EmailSender(req.EmailAddress); err != nil {
+		rt.logError(c.Request.Context(), err, "error looking up account specific email sender")
+	} else if accountSender != "" {
+		sender = accountSender
+	}
+
+	if err := rt.mailer.Send(sender, req.EmailAddress, subject.String(), body.String()); err != nil {
 		newJSONError(
 			fmt.Errorf("error sending email message: %v", err),
 			http.StatusInternalServerError,
@@ -284,7 +386,7 @@ func (rt *router) postResetPassword(c *gin.Context) {
 		return
 	}
 	var credentials forgotPasswordCredentials
-	if err := rt.cookieSigner.Decode("credentials", req.Token, &credentials); err != nil {
+	if err := rt.resetSigner.Decode("credentials", req.Token, &credentials); err != nil {
 		newJSONError(
 			fmt.Errorf("error decoding signed token: %w", err),
 			http.StatusBadRequest,
@@ -311,7 +413,7 @@ func (rt *router) postResetPassword(c *gin.Context) {
 	if err := rt.db.ResetPassword(req.EmailAddress, req.Password, credentials.Token); err != nil {
 		// on error a successful status is sent in order not to leak information
 		// to attackers
-		rt.logError(err, "error resetting password")
+		rt.logError(c.Request.Context(), err, "error resetting password")
 	}
 	c.Status(http.StatusNoContent)
 }
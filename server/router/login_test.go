@@ -4,6 +4,10 @@
 package router
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
@@ -18,12 +22,43 @@ import (
 	"github.com/gorilla/securecookie"
 	"github.com/offen/offen/server/config"
 	"github.com/offen/offen/server/persistence"
+	"github.com/offen/offen/server/ratelimiter"
 )
 
+type mockSessionDatabase struct {
+	persistence.Service
+	sessions map[string]string
+}
+
+func (m *mockSessionDatabase) Login(string, string) (persistence.LoginResult, error) {
+	return persistence.LoginResult{AccountUserID: "account-user-a"}, nil
+}
+
+func (m *mockSessionDatabase) CreateSession(accountUserID string, ttl time.Duration) (persistence.Session, error) {
+	sessionID := fmt.Sprintf("session-%d", len(m.sessions))
+	m.sessions[sessionID] = accountUserID
+	return persistence.Session{SessionID: sessionID, AccountUserID: accountUserID}, nil
+}
+
+func (m *mockSessionDatabase) LookupSession(sessionID string) (persistence.LoginResult, error) {
+	accountUserID, ok := m.sessions[sessionID]
+	if !ok {
+		return persistence.LoginResult{}, persistence.ErrUnknownSession
+	}
+	return persistence.LoginResult{AccountUserID: accountUserID}, nil
+}
+
+func (m *mockSessionDatabase) DeleteSession(sessionID string) error {
+	delete(m.sessions, sessionID)
+	return nil
+}
+
 func TestRouter_postLogout(t *testing.T) {
 	m := gin.New()
 	rt := router{
-		config: &config.Config{},
+		config:     &config.Config{},
+		db:         &mockSessionDatabase{sessions: map[string]string{}},
+		authSigner: securecookie.New([]byte("abc"), nil),
 	}
 	m.POST("/", rt.postLogout)
 	r := httptest.NewRequest(http.MethodPost, "/", nil)
@@ -35,6 +70,9 @@ func TestRouter_postLogout(t *testing.T) {
 	})
 
 	m.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Unexpected status code %v", w.Code)
+	}
 	cookies := w.Result().Cookies()
 	if len(cookies) != 1 {
 		t.Errorf("Unexpected additional cookies in response: %v", cookies)
@@ -51,29 +89,132 @@ func TestRouter_postLogout(t *testing.T) {
 	}
 }
 
+func TestRouter_postLogout_EndsSession(t *testing.T) {
+	for _, bearer := range []bool{false, true} {
+		t.Run(fmt.Sprintf("bearer %v", bearer), func(t *testing.T) {
+			db := &mockSessionDatabase{sessions: map[string]string{}}
+			cfg := &config.Config{}
+			cfg.App.AllowBearerAuth = true
+			rt := router{
+				config:     cfg,
+				db:         db,
+				authSigner: securecookie.New([]byte("abc"), nil),
+				limiter:    ratelimiter.NewNoopRateLimiter(),
+			}
+			m := gin.New()
+			m.POST("/login", rt.postLogin)
+			m.POST("/logout", rt.postLogout)
+			m.GET("/login", rt.accountUserMiddleware(authKey, contextKeyAuth), rt.getLogin)
+
+			w := httptest.NewRecorder()
+			m.ServeHTTP(w, httptest.NewRequest(
+				http.MethodPost, "/login",
+				strings.NewReader(`{"username":"develop@offen.dev","password":"secret","returnToken":true}`),
+			))
+			if w.Code != http.StatusOK {
+				t.Fatalf("Unexpected status code %v", w.Code)
+			}
+			var response loginResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if response.Token == "" || strings.Contains(response.Token, "account-user-a") {
+				t.Fatalf("Expected token to reference session, got %v", response.Token)
+			}
+
+			// the token is copied before logging out and reused afterwards
+			send := func(method, path string) int {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(method, path, nil)
+				if bearer {
+					r.Header.Set("Authorization", "Bearer "+response.Token)
+				} else {
+					r.AddCookie(&http.Cookie{Name: authKey, Value: response.Token})
+				}
+				m.ServeHTTP(w, r)
+				return w.Code
+			}
+			if code := send(http.MethodGet, "/login"); code != http.StatusOK {
+				t.Errorf("Expected token to be accepted before logout, got %v", code)
+			}
+			if code := send(http.MethodPost, "/logout"); code != http.StatusNoContent {
+				t.Errorf("Unexpected status code %v", code)
+			}
+			if len(db.sessions) != 0 {
+				t.Errorf("Expected session to be deleted, got %v", db.sessions)
+			}
+			if code := send(http.MethodGet, "/login"); code != http.StatusUnauthorized {
+				t.Errorf("Expected token to be rejected after logout, got %v", code)
+			}
+		})
+	}
+}
+
 type mockPostLoginDatabase struct {
 	persistence.Service
 	result persistence.LoginResult
 	err    error
+	ttl    time.Duration
 }
 
 func (m *mockPostLoginDatabase) Login(string, string) (persistence.LoginResult, error) {
 	return m.result, m.err
 }
+
+func (m *mockPostLoginDatabase) CreateSession(accountUserID string, ttl time.Duration) (persistence.Session, error) {
+	m.ttl = ttl
+	return persistence.Session{SessionID: "session-" + accountUserID, AccountUserID: accountUserID}, nil
+}
+
+func TestRouter_postLogin_AuthCookieMaxAge(t *testing.T) {
+	db := &mockPostLoginDatabase{result: persistence.LoginResult{AccountUserID: "user-a"}}
+	rt := router{
+		config:     &config.Config{},
+		db:         db,
+		authSigner: securecookie.New([]byte("abc"), nil),
+		limiter:    ratelimiter.NewNoopRateLimiter(),
+	}
+	WithAuthCookieMaxAge(time.Hour * 24 * 7)(&rt)
+	m := gin.New()
+	m.POST("/", rt.postLogin)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"username":"mail@offen.dev","password":"secret!"}`))
+	m.ServeHTTP(w, r)
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Expected 1 cookie in response, received %v", len(cookies))
+	}
+	if expires := time.Until(cookies[0].Expires); expires < 167*time.Hour || expires > 168*time.Hour {
+		t.Errorf("Expected cookie to expire in a week, got %v", cookies[0].Expires)
+	}
+	if db.ttl != time.Hour*24*7 {
+		t.Errorf("Expected session to expire with the cookie, got %v", db.ttl)
+	}
+	sessionID, err := rt.decodeAuthToken(cookies[0].Value)
+	if err != nil || sessionID != "session-user-a" {
+		t.Errorf("Unexpected decoding result %v, %v", sessionID, err)
+	}
+}
+
 func TestRouter_postLogin(t *testing.T) {
 	tests := []struct {
 		name               string
 		db                 mockPostLoginDatabase
 		body               io.Reader
+		allowBearerAuth    bool
 		expectedStatusCode int
 		expectCookie       bool
+		expectToken        bool
 	}{
 		{
 			"bad payload",
 			mockPostLoginDatabase{},
 			strings.NewReader("{{88+++#"),
+			false,
 			http.StatusBadRequest,
 			false,
+			false,
 		},
 		{
 			"bad login",
@@ -81,8 +222,10 @@ func TestRouter_postLogin(t *testing.T) {
 				err: errors.New("bad login"),
 			},
 			strings.NewReader(`{"username":"mail@offen.dev","password":"secret!"}`),
+			false,
 			http.StatusUnauthorized,
 			false,
+			false,
 		},
 		{
 			"ok",
@@ -92,17 +235,47 @@ func TestRouter_postLogin(t *testing.T) {
 				},
 			},
 			strings.NewReader(`{"username":"mail@offen.dev","password":"secret!"}`),
+			false,
+			http.StatusOK,
+			true,
+			false,
+		},
+		{
+			"token requested but not allowed",
+			mockPostLoginDatabase{
+				result: persistence.LoginResult{
+					AccountUserID: "user-a",
+				},
+			},
+			strings.NewReader(`{"username":"mail@offen.dev","password":"secret!","returnToken":true}`),
+			false,
 			http.StatusOK,
 			true,
+			false,
+		},
+		{
+			"token requested",
+			mockPostLoginDatabase{
+				result: persistence.LoginResult{
+					AccountUserID: "user-a",
+				},
+			},
+			strings.NewReader(`{"username":"mail@offen.dev","password":"secret!","returnToken":true}`),
+			true,
+			http.StatusOK,
+			true,
+			true,
 		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			m := gin.New()
+			cfg := &config.Config{}
+			cfg.App.AllowBearerAuth = test.allowBearerAuth
 			rt := router{
-				config:       &config.Config{},
-				db:           &test.db,
-				cookieSigner: securecookie.New([]byte("abc"), nil),
+				config:     cfg,
+				db:         &test.db,
+				authSigner: securecookie.New([]byte("abc"), nil),
 			}
 			m.POST("/", rt.postLogin)
 			r := httptest.NewRequest(http.MethodPost, "/", test.body)
@@ -122,14 +295,25 @@ func TestRouter_postLogin(t *testing.T) {
 				if authCookie.Name != "auth" {
 					t.Errorf("Unexpected cookie name %v", authCookie.Name)
 				}
-				if !authCookie.Expires.IsZero() {
-					t.Errorf("Expected session cookie, got %v", authCookie.Expires)
+				if expires := time.Until(authCookie.Expires); expires < 23*time.Hour || expires > 24*time.Hour {
+					t.Errorf("Expected cookie to expire in 24 hours, got %v", authCookie.Expires)
 				}
 			} else {
 				if len(cookies) != 0 {
 					t.Errorf("Expected no cookie in response, received %v", len(cookies))
 				}
 			}
+
+			var body struct {
+				Token string `json:"token"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if (body.Token != "") != test.expectToken {
+				t.Errorf("Unexpected token value %v", body.Token)
+			}
+			if test.expectToken && body.Token != cookies[0].Value {
+				t.Errorf("Expected token to match cookie value, got %v", body.Token)
+			}
 		})
 	}
 }
@@ -441,9 +625,9 @@ func TestRouter_postResetPassword(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			m := gin.New()
 			rt := router{
-				config:       &config.Config{},
-				db:           &test.db,
-				cookieSigner: signer,
+				config:      &config.Config{},
+				db:          &test.db,
+				resetSigner: signer,
 			}
 			m.POST("/", rt.postResetPassword)
 			r := httptest.NewRequest(http.MethodPost, "/", test.body)
@@ -457,21 +641,88 @@ func TestRouter_postResetPassword(t *testing.T) {
 	}
 }
 
+// encodeAt signs the given value the same way securecookie does, but using
+// the given time as the timestamp, so tokens issued in the past can be
+// created.
+func encodeAt(hashKey []byte, name string, value interface{}, at time.Time) (string, error) {
+	b, err := securecookie.GobEncoder{}.Serialize(value)
+	if err != nil {
+		return "", err
+	}
+	b = []byte(fmt.Sprintf("%s|%d|%s|", name, at.UTC().Unix(), base64.URLEncoding.EncodeToString(b)))
+	mac := hmac.New(sha256.New, hashKey)
+	mac.Write(b[:len(b)-1])
+	b = append(b, mac.Sum(nil)...)[len(name)+1:]
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
+func TestRouter_postResetPassword_ClockSkew(t *testing.T) {
+	tests := []struct {
+		name               string
+		age                time.Duration
+		skew               time.Duration
+		expectedStatusCode int
+	}{
+		{"valid", resetTokenMaxAge - time.Minute, 0, http.StatusNoContent},
+		{"expired", resetTokenMaxAge + 30*time.Second, 0, http.StatusBadRequest},
+		{"expired within tolerance", resetTokenMaxAge + 30*time.Second, time.Minute, http.StatusNoContent},
+		{"expired beyond tolerance", resetTokenMaxAge + 2*time.Minute, time.Minute, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token, err := encodeAt([]byte("abc"), "credentials", &forgotPasswordCredentials{
+				EmailAddress: "hioffen@posteo.de",
+			}, time.Now().Add(-test.age))
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+
+			cfg := &config.Config{}
+			cfg.App.ResetTokenClockSkew = test.skew
+			m := gin.New()
+			rt := router{
+				config:      cfg,
+				db:          &mockPostResetPasswordDatabase{},
+				resetSigner: newTokenSigner([]byte("abc"), resetTokenDecodeMaxAge(test.skew)),
+				limiter:     ratelimiter.NewNoopRateLimiter(),
+			}
+			m.POST("/", rt.postResetPassword)
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(
+				fmt.Sprintf(`{"emailAddress":"hioffen@posteo.de","password":"new","token":"%s"}`, token),
+			))
+			w := httptest.NewRecorder()
+			m.ServeHTTP(w, r)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("Unexpected status code %v", w.Code)
+			}
+		})
+	}
+}
+
 type mockPostForgotPasswordDatabase struct {
 	persistence.Service
-	result []byte
-	err    error
+	result    []byte
+	err       error
+	sender    string
+	senderErr error
 }
 
 func (m *mockPostForgotPasswordDatabase) GenerateOneTimeKey(string) ([]byte, error) {
 	return m.result, m.err
 }
 
+func (m *mockPostForgotPasswordDatabase) EmailSender(string) (string, error) {
+	return m.sender, m.senderErr
+}
+
 type mockMailer struct {
-	err error
+	err  error
+	from string
 }
 
 func (m *mockMailer) Send(from, to, subject, body string) error {
+	m.from = from
 	return m.err
 }
 
@@ -482,6 +733,7 @@ func TestRouter_postForgotPassword(t *testing.T) {
 		mailer         mockMailer
 		body           io.Reader
 		expectedStatus int
+		expectedFrom   string
 	}{
 		{
 			"bad payload",
@@ -489,6 +741,7 @@ func TestRouter_postForgotPassword(t *testing.T) {
 			mockMailer{},
 			strings.NewReader(`{]%%&(!)`),
 			http.StatusBadRequest,
+			"",
 		},
 		{
 			"key error",
@@ -498,6 +751,7 @@ func TestRouter_postForgotPassword(t *testing.T) {
 			mockMailer{},
 			strings.NewReader(`{"emailAddress":"mail@offen.dev","urlTemplate":"/{token}/"}`),
 			http.StatusNoContent,
+			"",
 		},
 		{
 			"error sending email",
@@ -509,6 +763,7 @@ func TestRouter_postForgotPassword(t *testing.T) {
 			},
 			strings.NewReader(`{"emailAddress":"mail@offen.dev","urlTemplate":"/reset/{token}/"}`),
 			http.StatusInternalServerError,
+			"no-reply@offen.dev",
 		},
 		{
 			"ok",
@@ -518,17 +773,42 @@ func TestRouter_postForgotPassword(t *testing.T) {
 			mockMailer{},
 			strings.NewReader(`{"emailAddress":"mail@offen.dev","urlTemplate":"/reset/{token}/"}`),
 			http.StatusNoContent,
+			"no-reply@offen.dev",
+		},
+		{
+			"account specific sender",
+			mockPostForgotPasswordDatabase{
+				result: []byte("i'm a token"),
+				sender: "Brand <hello@brand.example>",
+			},
+			mockMailer{},
+			strings.NewReader(`{"emailAddress":"mail@offen.dev","urlTemplate":"/reset/{token}/"}`),
+			http.StatusNoContent,
+			"Brand <hello@brand.example>",
+		},
+		{
+			"error looking up sender",
+			mockPostForgotPasswordDatabase{
+				result:    []byte("i'm a token"),
+				senderErr: errors.New("did not work"),
+			},
+			mockMailer{},
+			strings.NewReader(`{"emailAddress":"mail@offen.dev","urlTemplate":"/reset/{token}/"}`),
+			http.StatusNoContent,
+			"no-reply@offen.dev",
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			m := gin.New()
+			cfg := &config.Config{}
+			cfg.SMTP.Sender = "no-reply@offen.dev"
 			rt := router{
-				config:       &config.Config{},
-				db:           &test.db,
-				cookieSigner: securecookie.New([]byte("abc"), nil),
-				mailer:       &test.mailer,
+				config:      cfg,
+				db:          &test.db,
+				resetSigner: securecookie.New([]byte("abc"), nil),
+				mailer:      &test.mailer,
 				emails: func() *template.Template {
 					t := template.New("emails")
 					t, _ = t.Parse(`
@@ -546,6 +826,77 @@ func TestRouter_postForgotPassword(t *testing.T) {
 			if w.Code != test.expectedStatus {
 				t.Errorf("Unexpected status code %v", w.Body)
 			}
+			if test.mailer.from != test.expectedFrom {
+				t.Errorf("Expected sender %s, got %s", test.expectedFrom, test.mailer.from)
+			}
+		})
+	}
+}
+
+func TestRouter_postForgotPassword_Captcha(t *testing.T) {
+	tests := []struct {
+		name           string
+		verifier       CaptchaVerifier
+		expectedStatus int
+		expectMail     bool
+	}{
+		{
+			"passing captcha",
+			CaptchaVerifierFunc(func(token, remoteIP string) error {
+				if token != "solved" {
+					return errors.New("unexpected token")
+				}
+				return nil
+			}),
+			http.StatusNoContent,
+			true,
+		},
+		{
+			"failing captcha",
+			CaptchaVerifierFunc(func(token, remoteIP string) error {
+				return errors.New("did not work")
+			}),
+			http.StatusBadRequest,
+			false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := gin.New()
+			cfg := &config.Config{}
+			cfg.SMTP.Sender = "no-reply@offen.dev"
+			mailer := &mockMailer{}
+			rt := router{
+				config: cfg,
+				db: &mockPostForgotPasswordDatabase{
+					result: []byte("i'm a token"),
+				},
+				resetSigner: securecookie.New([]byte("abc"), nil),
+				mailer:      mailer,
+				captcha:     test.verifier,
+				emails: func() *template.Template {
+					t := template.New("emails")
+					t, _ = t.Parse(`
+{{ define "subject_reset_password" }}subject{{ end }}
+{{ define "body_reset_password" }}body{{ end }}
+					`)
+					return t
+				}(),
+			}
+			m.POST("/", rt.postForgotPassword)
+			r := httptest.NewRequest(
+				http.MethodPost, "/",
+				strings.NewReader(`{"emailAddress":"mail@offen.dev","urlTemplate":"/reset/{token}/","captchaToken":"solved"}`),
+			)
+			w := httptest.NewRecorder()
+			m.ServeHTTP(w, r)
+
+			if w.Code != test.expectedStatus {
+				t.Errorf("Unexpected status code %v", w.Code)
+			}
+			if (mailer.from != "") != test.expectMail {
+				t.Errorf("Unexpected mail state, sender was %q", mailer.from)
+			}
 		})
 	}
 }
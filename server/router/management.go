@@ -184,7 +184,7 @@ func (rt *router) postShareAccount(c *gin.Context) {
 	} else {
 		signedCredentials, signErr := rt.cookieSigner.MaxAge(7*24*60*60).Encode("credentials", req.InviteeEmailAddress)
 		if signErr != nil {
-			rt.logError(signErr, "error signing token")
+			rt.logError(c.Request.Context(), signErr, "error signing token")
 			c.Status(http.StatusNoContent)
 			return
 		}
@@ -261,7 +261,7 @@ func (rt *router) postJoin(c *gin.Context) {
 	}
 
 	if err := rt.db.Join(req.EmailAddress, req.Password); err != nil {
-		rt.logError(err, "error joining")
+		rt.logError(c.Request.Context(), err, "error joining")
 	}
 	c.Status(http.StatusNoContent)
 }
@@ -0,0 +1,66 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// methodNotAllowedHandler responds to requests for paths that are registered
+// using other methods only. The methods that are supported for the path
+// are listed in the Allow header.
+func methodNotAllowedHandler(routes gin.RoutesInfo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Allow", strings.Join(allowedMethods(routes, c.Request.URL.Path), ", "))
+		newJSONError(
+			fmt.Errorf("router: method %s is not allowed for %s", c.Request.Method, c.Request.URL.Path),
+			http.StatusMethodNotAllowed,
+		).Pipe(c)
+	}
+}
+
+func allowedMethods(routes gin.RoutesInfo, path string) []string {
+	seen := map[string]bool{}
+	var methods []string
+	for _, route := range routes {
+		if seen[route.Method] || !matchRoute(route.Path, path) {
+			continue
+		}
+		seen[route.Method] = true
+		methods = append(methods, route.Method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// matchRoute checks whether the given path matches a route pattern as used
+// by gin, where `:param` matches a single segment and `*param` matches
+// the remainder of the path.
+func matchRoute(pattern, path string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, "*") {
+			return true
+		}
+		if i >= len(pathSegments) {
+			return false
+		}
+		if strings.HasPrefix(segment, ":") {
+			if pathSegments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return len(patternSegments) == len(pathSegments)
+}
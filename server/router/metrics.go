@@ -0,0 +1,109 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+)
+
+const defaultMetricsInterval = time.Second * 5
+
+// requestMetrics keeps counters for all requests handled by the router.
+type requestMetrics struct {
+	requests uint64
+	errors   uint64
+	byClass  [numRequestClasses]uint64
+}
+
+func (m *requestMetrics) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		atomic.AddUint64(&m.requests, 1)
+		atomic.AddUint64(&m.byClass[classifyRequest(c.Request.URL.Path)], 1)
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			atomic.AddUint64(&m.errors, 1)
+		}
+	}
+}
+
+type metricsSnapshot struct {
+	Requests        uint64            `json:"requests"`
+	Errors          uint64            `json:"errors"`
+	RequestRate     float64           `json:"requestRate"`
+	ErrorRate       float64           `json:"errorRate"`
+	RequestsByClass map[string]uint64 `json:"requestsByClass"`
+}
+
+// snapshot returns the current counters, as well as the rate of requests and
+// errors per second since the given previous snapshot was taken.
+func (m *requestMetrics) snapshot(previous metricsSnapshot, elapsed time.Duration) metricsSnapshot {
+	s := metricsSnapshot{
+		Requests:        atomic.LoadUint64(&m.requests),
+		Errors:          atomic.LoadUint64(&m.errors),
+		RequestsByClass: map[string]uint64{},
+	}
+	for class := requestClass(0); class < numRequestClasses; class++ {
+		s.RequestsByClass[class.String()] = atomic.LoadUint64(&m.byClass[class])
+	}
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		s.RequestRate = float64(s.Requests-previous.Requests) / seconds
+		s.ErrorRate = float64(s.Errors-previous.Errors) / seconds
+	}
+	return s
+}
+
+func (rt *router) getMetrics() *requestMetrics {
+	if rt.metrics == nil {
+		rt.metrics = &requestMetrics{}
+	}
+	return rt.metrics
+}
+
+func (rt *router) getMetricsStream(c *gin.Context) {
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+	if !accountUser.IsSuperAdmin() {
+		newJSONError(
+			errors.New("router: account user does not have permissions to access metrics"),
+			http.StatusForbidden,
+		).Pipe(c)
+		return
+	}
+
+	interval := rt.metricsInterval
+	if interval <= 0 {
+		interval = defaultMetricsInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	metrics := rt.getMetrics()
+	last, lastTaken := metrics.snapshot(metricsSnapshot{}, 0), time.Now()
+	c.SSEvent("metrics", last)
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case now := <-ticker.C:
+			last, lastTaken = metrics.snapshot(last, now.Sub(lastTaken)), now
+			c.SSEvent("metrics", last)
+			return true
+		}
+	})
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+)
+
+func TestRequestMetrics_Middleware(t *testing.T) {
+	metrics := &requestMetrics{}
+	m := gin.New()
+	m.Use(metrics.middleware())
+	m.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+	m.GET("/fail", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+
+	for _, path := range []string{"/ok", "/ok", "/fail"} {
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	snapshot := metrics.snapshot(metricsSnapshot{Requests: 1}, time.Second)
+	if snapshot.Requests != 3 || snapshot.Errors != 1 {
+		t.Errorf("Unexpected counters %v", snapshot)
+	}
+	if snapshot.RequestRate != 2 || snapshot.ErrorRate != 1 {
+		t.Errorf("Unexpected rates %v", snapshot)
+	}
+}
+
+func TestRouter_getMetricsStream(t *testing.T) {
+	tests := []struct {
+		name               string
+		user               interface{}
+		expectedStatusCode int
+		expectedEvent      bool
+	}{
+		{
+			"no user",
+			nil,
+			http.StatusNotFound,
+			false,
+		},
+		{
+			"no admin",
+			persistence.LoginResult{},
+			http.StatusForbidden,
+			false,
+		},
+		{
+			"ok",
+			persistence.LoginResult{AdminLevel: persistence.AccountUserAdminLevelSuperAdmin},
+			http.StatusOK,
+			true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := router{metricsInterval: time.Millisecond * 10}
+			m := gin.New()
+			m.GET("/", func(c *gin.Context) {
+				c.Set(contextKeyAuth, test.user)
+			}, rt.getMetricsStream)
+			server := httptest.NewServer(m)
+			defer server.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != test.expectedStatusCode {
+				t.Errorf("Unexpected status code %v", res.StatusCode)
+			}
+			if !test.expectedEvent {
+				return
+			}
+
+			scanner := bufio.NewScanner(res.Body)
+			var event, data string
+			for scanner.Scan() && data == "" {
+				line := scanner.Text()
+				if strings.HasPrefix(line, "event:") {
+					event = strings.TrimPrefix(line, "event:")
+				}
+				if strings.HasPrefix(line, "data:") {
+					data = strings.TrimPrefix(line, "data:")
+				}
+			}
+			if event != "metrics" {
+				t.Errorf("Unexpected event name %s", event)
+			}
+			if !strings.Contains(data, `"requests":`) {
+				t.Errorf("Unexpected event data %s", data)
+			}
+		})
+	}
+}
@@ -38,9 +38,15 @@ func optinMiddleware(cookieName, passWhen string) gin.HandlerFunc {
 
 // userCookieMiddleware ensures a cookie of the given name is present and
 // attaches its value to the request's context using the given key, before
-// passing it on to the wrapped handler.
+// passing it on to the wrapped handler. Requests that have passed without
+// consent are not associated with any user.
 func userCookieMiddleware(cookieKey, contextKey string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if c.GetBool(contextKeyConsentExempt) {
+			c.Set(contextKey, "")
+			c.Next()
+			return
+		}
 		ck, err := c.Request.Cookie(cookieKey)
 		if err != nil {
 			newJSONError(
@@ -56,8 +62,13 @@ func userCookieMiddleware(cookieKey, contextKey string) gin.HandlerFunc {
 
 func (rt *router) accountUserMiddleware(cookieKey, contextKey string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		var token string
 		authCookie, authCookieErr := c.Request.Cookie(cookieKey)
-		if authCookieErr != nil {
+		if authCookieErr == nil {
+			token = authCookie.Value
+		} else if bearer, ok := rt.bearerToken(c); ok {
+			token = bearer
+		} else {
 			newJSONError(
 				errors.New("router: missing authentication token"),
 				http.StatusUnauthorized,
@@ -65,10 +76,18 @@ func (rt *router) accountUserMiddleware(cookieKey, contextKey string) gin.Handle
 			return
 		}
 
-		var userID string
-		if err := rt.cookieSigner.Decode(authKey, authCookie.Value, &userID); err != nil {
-			authCookie, _ = rt.authCookie("", c.GetBool(contextKeySecureContext))
-			http.SetCookie(c.Writer, authCookie)
+		// stale cookies are cleared so clients do not keep on sending them,
+		// tokens passed in a header are managed by the client
+		clearCookie := func() {
+			if authCookieErr == nil {
+				authCookie, _ = rt.authCookie("", c.GetBool(contextKeySecureContext))
+				http.SetCookie(c.Writer, authCookie)
+			}
+		}
+
+		sessionID, err := rt.decodeAuthToken(token)
+		if err != nil {
+			clearCookie()
 			newJSONError(
 				fmt.Errorf("error decoding cookie value: %v", err),
 				http.StatusUnauthorized,
@@ -76,12 +95,11 @@ func (rt *router) accountUserMiddleware(cookieKey, contextKey string) gin.Handle
 			return
 		}
 
-		user, userErr := rt.db.LookupAccountUser(userID)
+		user, userErr := rt.db.LookupSession(sessionID)
 		if userErr != nil {
-			authCookie, _ = rt.authCookie("", c.GetBool(contextKeySecureContext))
-			http.SetCookie(c.Writer, authCookie)
+			clearCookie()
 			newJSONError(
-				fmt.Errorf("user with id %s does not exist: %v", userID, userErr),
+				fmt.Errorf("no valid session found: %v", userErr),
 				http.StatusUnauthorized,
 			).Pipe(c)
 			return
@@ -91,6 +109,20 @@ func (rt *router) accountUserMiddleware(cookieKey, contextKey string) gin.Handle
 	}
 }
 
+// bearerToken returns the token sent in the Authorization header of the
+// request in case the application allows authenticating this way.
+func (rt *router) bearerToken(c *gin.Context) (string, bool) {
+	if rt.config == nil || !rt.config.App.AllowBearerAuth {
+		return "", false
+	}
+	header := c.GetHeader("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	return token, token != ""
+}
+
 func headerMiddleware(valueProvider map[string]func() string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		for key, provider := range valueProvider {
@@ -13,6 +13,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/securecookie"
+	"github.com/offen/offen/server/config"
 	"github.com/offen/offen/server/persistence"
 )
 
@@ -125,20 +126,20 @@ type mockUserLookupDatabase struct {
 	persistence.Service
 }
 
-func (*mockUserLookupDatabase) LookupAccountUser(accountUserID string) (persistence.LoginResult, error) {
-	if accountUserID == "account-user-id-1" {
+func (*mockUserLookupDatabase) LookupSession(sessionID string) (persistence.LoginResult, error) {
+	if sessionID == "session-id-1" {
 		return persistence.LoginResult{
 			AccountUserID: "account-user-id-1",
 		}, nil
 	}
-	return persistence.LoginResult{}, fmt.Errorf("account user with id %s not found", accountUserID)
+	return persistence.LoginResult{}, fmt.Errorf("session with id %s not found: %w", sessionID, persistence.ErrUnknownSession)
 }
 
 func TestAccountUserMiddleware(t *testing.T) {
 	cookieSigner := securecookie.New([]byte("keyboard cat"), nil)
 	rt := router{
-		cookieSigner: cookieSigner,
-		db:           &mockUserLookupDatabase{},
+		authSigner: cookieSigner,
+		db:         &mockUserLookupDatabase{},
 	}
 	m := gin.New()
 	m.GET("/", rt.accountUserMiddleware("auth", "1"), func(c *gin.Context) {
@@ -166,12 +167,21 @@ func TestAccountUserMiddleware(t *testing.T) {
 		if w.Code != http.StatusUnauthorized {
 			t.Errorf("Unexpected status code %v", w.Code)
 		}
+		var cleared bool
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == "auth" && cookie.Value == "" && cookie.Expires.Before(time.Now()) {
+				cleared = true
+			}
+		}
+		if !cleared {
+			t.Errorf("Expected response to clear auth cookie, got %v", w.Header().Get("Set-Cookie"))
+		}
 	})
 
 	t.Run("bad db lookup", func(t *testing.T) {
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/", nil)
-		cookieValue, _ := cookieSigner.Encode("auth", "account-user-id-2")
+		cookieValue, _ := cookieSigner.Encode("auth", "session-id-2")
 		r.AddCookie(&http.Cookie{
 			Name:  "auth",
 			Value: cookieValue,
@@ -185,7 +195,7 @@ func TestAccountUserMiddleware(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/", nil)
-		cookieValue, _ := cookieSigner.Encode("auth", "account-user-id-1")
+		cookieValue, _ := cookieSigner.Encode("auth", "session-id-1")
 		r.AddCookie(&http.Cookie{
 			Name:  "auth",
 			Value: cookieValue,
@@ -197,6 +207,85 @@ func TestAccountUserMiddleware(t *testing.T) {
 	})
 }
 
+func TestAccountUserMiddleware_BearerToken(t *testing.T) {
+	cookieSigner := securecookie.New([]byte("keyboard cat"), nil)
+	validToken, _ := cookieSigner.Encode("auth", "session-id-1")
+	tests := []struct {
+		name               string
+		allowBearerAuth    bool
+		header             string
+		cookie             *http.Cookie
+		expectedStatusCode int
+	}{
+		{
+			"header ok",
+			true,
+			"Bearer " + validToken,
+			nil,
+			http.StatusOK,
+		},
+		{
+			"header not allowed",
+			false,
+			"Bearer " + validToken,
+			nil,
+			http.StatusUnauthorized,
+		},
+		{
+			"bad header token",
+			true,
+			"Bearer somethingsomething",
+			nil,
+			http.StatusUnauthorized,
+		},
+		{
+			"other scheme",
+			true,
+			"Basic " + validToken,
+			nil,
+			http.StatusUnauthorized,
+		},
+		{
+			"cookie ok",
+			true,
+			"",
+			&http.Cookie{Name: "auth", Value: validToken},
+			http.StatusOK,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.App.AllowBearerAuth = test.allowBearerAuth
+			rt := router{
+				config:     cfg,
+				authSigner: cookieSigner,
+				db:         &mockUserLookupDatabase{},
+			}
+			m := gin.New()
+			m.GET("/", rt.accountUserMiddleware("auth", "1"), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.header != "" {
+				r.Header.Set("Authorization", test.header)
+			}
+			if test.cookie != nil {
+				r.AddCookie(test.cookie)
+			}
+			m.ServeHTTP(w, r)
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("Unexpected status code %v", w.Code)
+			}
+			if test.cookie == nil && len(w.Result().Cookies()) != 0 {
+				t.Errorf("Unexpected cookies in response %v", w.Result().Cookies())
+			}
+		})
+	}
+}
+
 func TestHeaderMiddleware(t *testing.T) {
 	m := gin.New()
 	m.GET("/", headerMiddleware(map[string]func() string{
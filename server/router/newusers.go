@@ -0,0 +1,43 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+)
+
+// newUserCountCacheKey hashes the source of a request so raw addresses are
+// not kept in memory.
+func newUserCountCacheKey(accountID, source string) string {
+	return fmt.Sprintf("new-users-%x", sha256.Sum256([]byte(accountID+"\x00"+source)))
+}
+
+// checkNewUserRate counts a new user being created for the given account from
+// the given source and reports how long the source has to wait before it
+// can create more users in case it has exceeded the configured limit. This
+// keeps a leaked account id from being used to create an unlimited number
+// of users.
+func (rt *router) checkNewUserRate(accountID, source string) (time.Duration, bool) {
+	limit, window := rt.config.App.NewUserRateLimit, rt.config.App.NewUserRateWindow
+	if limit <= 0 || window <= 0 {
+		return 0, false
+	}
+	cache, countKey := rt.getCache(), newUserCountCacheKey(accountID, source)
+	if err := cache.Add(countKey, 1, window); err == nil {
+		return 0, false
+	}
+	count, err := cache.IncrementInt(countKey, 1)
+	if err != nil {
+		// the window has expired in between calls
+		cache.Set(countKey, 1, window)
+		return 0, false
+	}
+	if count <= limit {
+		return 0, false
+	}
+	_, until, _ := cache.GetWithExpiration(countKey)
+	return time.Until(until), true
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// normalizeOrigin returns the scheme and host of the given URL in the format
+// used by the Origin header.
+func normalizeOrigin(s string) (string, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("router: error parsing origin %s: %w", s, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("router: origin %s is not an absolute http(s) url", s)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// requestOrigin returns the origin the request has been sent from, preferring
+// the Origin header and falling back to the Referer header.
+func requestOrigin(r *http.Request) string {
+	if origin := r.Header.Get("Origin"); origin != "" && origin != "null" {
+		if normalized, err := normalizeOrigin(origin); err == nil {
+			return normalized
+		}
+	}
+	if referrer := r.Referer(); referrer != "" {
+		if normalized, err := normalizeOrigin(referrer); err == nil {
+			return normalized
+		}
+	}
+	return ""
+}
+
+// isOwnOrigin checks whether the given normalized origin is the origin
+// Offen itself is served from.
+func isOwnOrigin(r *http.Request, origin string) bool {
+	_, host, _ := strings.Cut(origin, "://")
+	return host != "" && host == r.Host
+}
+
+// eventOrigin returns the origin an event has been sent from. As the vault
+// sends events from the Offen origin, the origin it reports for its
+// embedding page is used for requests that have been sent from the Offen
+// origin. For all other requests, the reported origin cannot be trusted and
+// the Origin or Referer header is used instead.
+func eventOrigin(r *http.Request, reported string) string {
+	origin := requestOrigin(r)
+	if reported != "" && isOwnOrigin(r, origin) {
+		if normalized, err := normalizeOrigin(reported); err == nil {
+			return normalized
+		}
+	}
+	return origin
+}
+
+// originAllowed checks whether the given origin is contained in the list of
+// allowed origins. In case no origins are given, all origins are allowed.
+func originAllowed(origin string, allowedOrigins []string) bool {
+	if len(allowedOrigins) == 0 {
+		return true
+	}
+	if origin == "" {
+		return false
+	}
+	for _, allowed := range allowedOrigins {
+		if allowed == origin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// InboundEvent is an event as received by the router before it is persisted.
+type InboundEvent struct {
+	AccountID   string
+	Payload     string
+	ContentHash string
+	Exempt      bool
+}
+
+// Transform is a single stage of the ingest pipeline. It can modify the given
+// event in place. Returning an error will cause the event to be rejected.
+type Transform interface {
+	Apply(r *http.Request, evt *InboundEvent) error
+}
+
+// TransformFunc allows using an ordinary function as a Transform.
+type TransformFunc func(r *http.Request, evt *InboundEvent) error
+
+// Apply calls f(r, evt).
+func (f TransformFunc) Apply(r *http.Request, evt *InboundEvent) error {
+	return f(r, evt)
+}
+
+// WithIngestPipeline sets the transforms that will be applied to each
+// inbound event before it is persisted. Transforms are applied in the
+// given order.
+func WithIngestPipeline(transforms ...Transform) Config {
+	return func(r *router) {
+		r.ingestPipeline = transforms
+	}
+}
+
+func (rt *router) applyIngestPipeline(r *http.Request, evt *InboundEvent) error {
+	for i, transform := range rt.ingestPipeline {
+		if err := transform.Apply(r, evt); err != nil {
+			return fmt.Errorf("router: error applying ingest transform at index %d: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/config"
+	"github.com/offen/offen/server/persistence"
+)
+
+type mockIngestPipelineService struct {
+	persistence.Service
+	insertedPayload string
+}
+
+func (m *mockIngestPipelineService) Insert(userID, accountID, payload, contentHash string, exempt bool, eventID *string) error {
+	m.insertedPayload = payload
+	return nil
+}
+
+func (m *mockIngestPipelineService) GetAccountRetention(string) (time.Duration, error) {
+	return 0, nil
+}
+
+func (m *mockIngestPipelineService) GetAccountSettings(string) (persistence.AccountSettings, error) {
+	return persistence.AccountSettings{}, nil
+}
+
+func (m *mockIngestPipelineService) GetIngestPause(string) (persistence.IngestPause, error) {
+	return persistence.IngestPause{}, nil
+}
+
+func appendTransform(suffix string) Transform {
+	return TransformFunc(func(r *http.Request, evt *InboundEvent) error {
+		evt.Payload = evt.Payload + suffix
+		return nil
+	})
+}
+
+func TestRouter_postEvents_IngestPipeline(t *testing.T) {
+	tests := []struct {
+		name            string
+		pipeline        []Transform
+		expectedStatus  int
+		expectedPayload string
+	}{
+		{
+			"no transforms",
+			nil,
+			http.StatusCreated,
+			"payload",
+		},
+		{
+			"two stages applied in order",
+			[]Transform{appendTransform("-a"), appendTransform("-b")},
+			http.StatusCreated,
+			"payload-a-b",
+		},
+		{
+			"failing stage",
+			[]Transform{
+				appendTransform("-a"),
+				TransformFunc(func(*http.Request, *InboundEvent) error {
+					return errors.New("did not work")
+				}),
+			},
+			http.StatusBadRequest,
+			"",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := &mockIngestPipelineService{}
+			rt := router{
+				db:     db,
+				config: &config.Config{},
+			}
+			WithIngestPipeline(test.pipeline...)(&rt)
+
+			m := gin.New()
+			m.POST("/", func(c *gin.Context) {
+				c.Set(contextKeyCookie, "user-id")
+				c.Next()
+			}, rt.postEvents)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"accountId":"account-a","payload":"payload"}`))
+			m.ServeHTTP(w, r)
+
+			if w.Code != test.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", test.expectedStatus, w.Code)
+			}
+			if db.insertedPayload != test.expectedPayload {
+				t.Errorf("Expected payload %s, got %s", test.expectedPayload, db.insertedPayload)
+			}
+		})
+	}
+}
+
+func TestRouter_postEvents_Echo(t *testing.T) {
+	anonymizeIP := TransformFunc(func(r *http.Request, evt *InboundEvent) error {
+		evt.Payload = strings.ReplaceAll(evt.Payload, "192.0.2.123", "192.0.2.0")
+		return nil
+	})
+	tests := []struct {
+		name             string
+		development      bool
+		query            string
+		expectedStatus   int
+		expectedBody     string
+		expectedInserted string
+	}{
+		{
+			"echo",
+			true,
+			"?echo=1",
+			http.StatusCreated,
+			`{"ack":true,"echo":{"accountId":"account-a","payload":"ip=192.0.2.0","exempt":false}}`,
+			"ip=192.0.2.0",
+		},
+		{
+			"echo dry run",
+			true,
+			"?echo=1&dryRun=1",
+			http.StatusOK,
+			`{"ack":true,"echo":{"accountId":"account-a","payload":"ip=192.0.2.0","exempt":false},"dryRun":true}`,
+			"",
+		},
+		{
+			"not in development",
+			false,
+			"?echo=1&dryRun=1",
+			http.StatusForbidden,
+			"",
+			"",
+		},
+		{
+			"plain",
+			false,
+			"",
+			http.StatusCreated,
+			`{"ack":true}`,
+			"ip=192.0.2.0",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := &mockIngestPipelineService{}
+			cfg := &config.Config{}
+			cfg.App.Development = test.development
+			rt := router{db: db, config: cfg}
+			WithIngestPipeline(anonymizeIP)(&rt)
+
+			m := gin.New()
+			m.POST("/", func(c *gin.Context) {
+				c.Set(contextKeyCookie, "user-id")
+				c.Next()
+			}, rt.postEvents)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/"+test.query, strings.NewReader(`{"accountId":"account-a","payload":"ip=192.0.2.123"}`))
+			m.ServeHTTP(w, r)
+			if w.Code != test.expectedStatus {
+				t.Errorf("Unexpected status code %d", w.Code)
+			}
+			if test.expectedBody != "" && strings.TrimSpace(w.Body.String()) != test.expectedBody {
+				t.Errorf("Unexpected response body %s", w.Body.String())
+			}
+			if db.insertedPayload != test.expectedInserted {
+				t.Errorf("Expected %q to be stored, got %q", test.expectedInserted, db.insertedPayload)
+			}
+		})
+	}
+}
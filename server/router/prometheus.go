@@ -0,0 +1,166 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WithMetrics exposes metrics about handled requests and ingested events in
+// the Prometheus text format at /metrics. The endpoint is not protected in
+// any way, so access should be restricted when enabling it.
+func WithMetrics(enabled bool) Config {
+	return func(r *router) {
+		if enabled {
+			r.prometheus = newPrometheusMetrics()
+		}
+	}
+}
+
+// prometheusLatencyBuckets are the upper bounds in seconds used for the
+// request duration histogram. They match the defaults of the official
+// Prometheus client.
+var prometheusLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// unmatchedRoute is used as the route label for requests that did not match
+// any registered route, e.g. static assets.
+const unmatchedRoute = "unmatched"
+
+type routeStatus struct {
+	route  string
+	status int
+}
+
+type latencyHistogram struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+func (h *latencyHistogram) observe(seconds float64) {
+	for i, bound := range prometheusLatencyBuckets {
+		if seconds <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// prometheusMetrics keeps the values exposed at /metrics. Requests are
+// labeled with the template of the route they matched instead of the raw
+// path, so the number of series does not depend on the ids used in paths.
+type prometheusMetrics struct {
+	sync.Mutex
+	requests       map[routeStatus]*latencyHistogram
+	eventsIngested uint64
+	optouts        uint64
+}
+
+func newPrometheusMetrics() *prometheusMetrics {
+	return &prometheusMetrics{requests: map[routeStatus]*latencyHistogram{}}
+}
+
+func (m *prometheusMetrics) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		key := routeStatus{route: route, status: c.Writer.Status()}
+
+		m.Lock()
+		defer m.Unlock()
+		h, ok := m.requests[key]
+		if !ok {
+			h = &latencyHistogram{buckets: make([]uint64, len(prometheusLatencyBuckets))}
+			m.requests[key] = h
+		}
+		h.observe(time.Since(start).Seconds())
+	}
+}
+
+func (m *prometheusMetrics) countEventIngested() {
+	atomic.AddUint64(&m.eventsIngested, 1)
+}
+
+func (m *prometheusMetrics) countOptout() {
+	atomic.AddUint64(&m.optouts, 1)
+}
+
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// write writes all metrics to w using the Prometheus text format. Series are
+// sorted, so the output is stable.
+func (m *prometheusMetrics) write(w io.Writer) {
+	m.Lock()
+	keys := make([]routeStatus, 0, len(m.requests))
+	histograms := map[routeStatus]latencyHistogram{}
+	for key, h := range m.requests {
+		keys = append(keys, key)
+		histograms[key] = latencyHistogram{
+			buckets: append([]uint64(nil), h.buckets...),
+			count:   h.count,
+			sum:     h.sum,
+		}
+	}
+	m.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].status < keys[j].status
+	})
+
+	fmt.Fprintln(w, "# HELP offen_http_requests_total Number of HTTP requests handled, by route and status.")
+	fmt.Fprintln(w, "# TYPE offen_http_requests_total counter")
+	for _, key := range keys {
+		fmt.Fprintf(w, "offen_http_requests_total{route=\"%s\",status=\"%d\"} %d\n", escapeLabelValue(key.route), key.status, histograms[key].count)
+	}
+
+	fmt.Fprintln(w, "# HELP offen_http_request_duration_seconds Duration of HTTP requests, by route and status.")
+	fmt.Fprintln(w, "# TYPE offen_http_request_duration_seconds histogram")
+	for _, key := range keys {
+		h := histograms[key]
+		labels := fmt.Sprintf("route=\"%s\",status=\"%d\"", escapeLabelValue(key.route), key.status)
+		for i, bound := range prometheusLatencyBuckets {
+			fmt.Fprintf(w, "offen_http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, formatFloat(bound), h.buckets[i])
+		}
+		fmt.Fprintf(w, "offen_http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(w, "offen_http_request_duration_seconds_sum{%s} %s\n", labels, formatFloat(h.sum))
+		fmt.Fprintf(w, "offen_http_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	fmt.Fprintln(w, "# HELP offen_events_ingested_total Number of events that have been persisted.")
+	fmt.Fprintln(w, "# TYPE offen_events_ingested_total counter")
+	fmt.Fprintf(w, "offen_events_ingested_total %d\n", atomic.LoadUint64(&m.eventsIngested))
+
+	fmt.Fprintln(w, "# HELP offen_optouts_total Number of times the user cookie has been cleared because a user opted out.")
+	fmt.Fprintln(w, "# TYPE offen_optouts_total counter")
+	fmt.Fprintf(w, "offen_optouts_total %d\n", atomic.LoadUint64(&m.optouts))
+}
+
+func (rt *router) getPrometheusMetrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	rt.prometheus.write(c.Writer)
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/config"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	metrics := newPrometheusMetrics()
+	m := gin.New()
+	m.Use(metrics.middleware())
+	m.GET("/api/accounts/:accountID", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	for _, path := range []string{"/api/accounts/account-a", "/api/accounts/account-b", "/unknown"} {
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	metrics.countEventIngested()
+	metrics.countOptout()
+
+	var b strings.Builder
+	metrics.write(&b)
+	output := b.String()
+	for _, expected := range []string{
+		"# TYPE offen_http_requests_total counter\n",
+		`offen_http_requests_total{route="/api/accounts/:accountID",status="200"} 2` + "\n",
+		`offen_http_requests_total{route="unmatched",status="404"} 1` + "\n",
+		"# TYPE offen_http_request_duration_seconds histogram\n",
+		`offen_http_request_duration_seconds_bucket{route="/api/accounts/:accountID",status="200",le="10"} 2` + "\n",
+		`offen_http_request_duration_seconds_bucket{route="/api/accounts/:accountID",status="200",le="+Inf"} 2` + "\n",
+		`offen_http_request_duration_seconds_count{route="/api/accounts/:accountID",status="200"} 2` + "\n",
+		"offen_events_ingested_total 1\n",
+		"offen_optouts_total 1\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got %s", expected, output)
+		}
+	}
+	if strings.Contains(output, "account-a") {
+		t.Error("Expected raw paths not to be used as labels")
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	if escaped := escapeLabelValue("a\"b\\c\nd"); escaped != `a\"b\\c\nd` {
+		t.Errorf("Unexpected escaped value %s", escaped)
+	}
+}
+
+func TestNew_Metrics(t *testing.T) {
+	fs := http.FS(fstest.MapFS{})
+	for _, enabled := range []bool{true, false} {
+		handler := New(
+			WithDatabase(&mockDatabase{}),
+			WithConfig(&config.Config{}),
+			WithTemplate(template.New("a test")),
+			WithFS(fs),
+			WithMetrics(enabled),
+		)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		exposed := w.Code == http.StatusOK && strings.Contains(w.Body.String(), `offen_http_requests_total{route="/healthz"`)
+		if exposed != enabled {
+			t.Errorf("Expected metrics to be exposed: %v, got status %d and body %s", enabled, w.Code, w.Body.String())
+		}
+	}
+}
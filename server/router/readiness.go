@@ -0,0 +1,52 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WithReadinessGate makes the application report as not ready until the
+// given channel is closed, e.g. once database migrations have been applied.
+// Until then, all requests other than liveness and readiness checks are
+// answered with 503.
+func WithReadinessGate(ready <-chan struct{}) Config {
+	return func(r *router) {
+		r.readinessGate = ready
+	}
+}
+
+var errStartupPending = errors.New("router: application has not completed starting up")
+
+func (rt *router) startupPending() bool {
+	if rt.readinessGate == nil {
+		return false
+	}
+	select {
+	case <-rt.readinessGate:
+		return false
+	default:
+		return true
+	}
+}
+
+// readinessGateMiddleware rejects requests while startup is pending. The
+// given paths are always passed through.
+func (rt *router) readinessGateMiddleware(skip ...string) gin.HandlerFunc {
+	skipped := map[string]bool{}
+	for _, path := range skip {
+		skipped[path] = true
+	}
+	return func(c *gin.Context) {
+		if skipped[c.Request.URL.Path] || !rt.startupPending() {
+			c.Next()
+			return
+		}
+		c.Header("Retry-After", "1")
+		newJSONError(errStartupPending, http.StatusServiceUnavailable).Pipe(c)
+	}
+}
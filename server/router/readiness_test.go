@@ -0,0 +1,52 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/config"
+)
+
+func TestRouter_readinessGate(t *testing.T) {
+	ready := make(chan struct{})
+	db := &mockHealthChecker{}
+	rt := router{db: db, config: &config.Config{}}
+	WithReadinessGate(ready)(&rt)
+
+	m := gin.New()
+	m.Use(rt.readinessGateMiddleware("/healthz", "/readyz"))
+	m.GET("/healthz", rt.getHealth)
+	m.GET("/readyz", rt.getReady)
+	m.GET("/api/events", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	expectStatus := func(t *testing.T, path string, expected int) {
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != expected {
+			t.Errorf("Expected status %d for %s, got %d", expected, path, w.Code)
+		}
+	}
+
+	t.Run("pending", func(t *testing.T) {
+		expectStatus(t, "/healthz", http.StatusOK)
+		expectStatus(t, "/readyz", http.StatusServiceUnavailable)
+		expectStatus(t, "/api/events", http.StatusServiceUnavailable)
+		if db.checked {
+			t.Error("Expected dependencies not to be checked while starting up")
+		}
+	})
+
+	close(ready)
+	t.Run("ready", func(t *testing.T) {
+		expectStatus(t, "/healthz", http.StatusOK)
+		expectStatus(t, "/readyz", http.StatusOK)
+		expectStatus(t, "/api/events", http.StatusOK)
+	})
+}
@@ -0,0 +1,48 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"path"
+	"strings"
+)
+
+// requestClass segments requests for logs and metrics, so that e.g. page
+// loads of the Auditorium can be told apart from API traffic. API requests
+// are further divided into the route classes used for timeouts.
+type requestClass int
+
+const (
+	requestClassOther requestClass = iota
+	requestClassAPI
+	requestClassAuditorium
+	requestClassStatic
+	numRequestClasses
+)
+
+var requestClassLabels = [numRequestClasses]string{
+	requestClassOther:      "other",
+	requestClassAPI:        "api",
+	requestClassAuditorium: "auditorium",
+	requestClassStatic:     "static",
+}
+
+func (c requestClass) String() string {
+	return requestClassLabels[c]
+}
+
+// classifyRequest assigns the request for the given path to a class. Files
+// are classified as static assets, even if they are part of the Auditorium.
+func classifyRequest(urlPath string) requestClass {
+	switch {
+	case urlPath == "/api" || strings.HasPrefix(urlPath, "/api/"):
+		return requestClassAPI
+	case path.Ext(urlPath) != "":
+		return requestClassStatic
+	case urlPath == "/auditorium" || strings.HasPrefix(urlPath, "/auditorium/"):
+		return requestClassAuditorium
+	default:
+		return requestClassOther
+	}
+}
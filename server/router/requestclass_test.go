@@ -0,0 +1,67 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestClassifyRequest(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected requestClass
+	}{
+		{"/api/events", requestClassAPI},
+		{"/api/v1/login", requestClassAPI},
+		{"/api", requestClassAPI},
+		{"/auditorium/", requestClassAuditorium},
+		{"/auditorium/account-a", requestClassAuditorium},
+		{"/auditorium", requestClassAuditorium},
+		{"/auditorium/index-abcdef1234.js", requestClassStatic},
+		{"/fonts/font.woff2", requestClassStatic},
+		{"/vault", requestClassOther},
+		{"/", requestClassOther},
+		{"/auditoriums", requestClassOther},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			if class := classifyRequest(test.path); class != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, class)
+			}
+		})
+	}
+}
+
+func TestRequestClass_Tagging(t *testing.T) {
+	logger, hook := logtest.NewNullLogger()
+	metrics := &requestMetrics{}
+	m := gin.New()
+	m.Use(requestIDMiddleware(), accessLogMiddleware(logger), metrics.middleware())
+	m.GET("/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, path := range []string{"/auditorium/account-a", "/api/events", "/auditorium/index.js"} {
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	entries := hook.AllEntries()
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 log entries, got %d", len(entries))
+	}
+	for i, expected := range []string{"auditorium", "api", "static"} {
+		if class := entries[i].Data["class"]; class != expected {
+			t.Errorf("Expected entry %d to be tagged %s, got %v", i, expected, class)
+		}
+	}
+
+	snapshot := metrics.snapshot(metricsSnapshot{}, time.Second)
+	if snapshot.RequestsByClass["auditorium"] != 1 || snapshot.RequestsByClass["api"] != 1 || snapshot.RequestsByClass["static"] != 1 {
+		t.Errorf("Unexpected counters by class %v", snapshot.RequestsByClass)
+	}
+}
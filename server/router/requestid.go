@@ -0,0 +1,60 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	requestIDHeader     = "X-Request-Id"
+	contextKeyRequestID = "contextKeyRequestID"
+)
+
+// requestIDContextKey is used for storing the request id in the context of
+// the underlying *http.Request, so it is also available to code that does
+// not have access to the gin context.
+type requestIDContextKey struct{}
+
+// validRequestID matches request ids sent by clients that are safe to be
+// echoed back and written to logs.
+var validRequestID = regexp.MustCompile(`^[a-zA-Z0-9._:-]{1,128}$`)
+
+// requestIDMiddleware assigns an id to each request, so that log entries and
+// error responses can be correlated. Ids passed in the X-Request-Id header are
+// reused, otherwise a new one is generated. The id is echoed back in the
+// response headers.
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			id, err := uuid.NewV4()
+			if err != nil {
+				c.Next()
+				return
+			}
+			requestID = id.String()
+		}
+		c.Set(contextKeyRequestID, requestID)
+		c.Request = c.Request.WithContext(
+			context.WithValue(c.Request.Context(), requestIDContextKey{}, requestID),
+		)
+		c.Header(requestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// requestIDFromContext returns the id of the request the given context
+// belongs to, or an empty string if there is none.
+func requestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
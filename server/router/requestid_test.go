@@ -0,0 +1,73 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	tests := []struct {
+		name              string
+		header            string
+		expectPassThrough bool
+	}{
+		{"given id", "request-a", true},
+		{"no id", "", false},
+		{"invalid id", "request\nwith line break", false},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logger, hook := logtest.NewNullLogger()
+			rt := router{logger: logger}
+
+			m := gin.New()
+			m.Use(requestIDMiddleware())
+			m.GET("/", func(c *gin.Context) {
+				err := errors.New("did not work")
+				rt.logError(c.Request.Context(), err, "error handling request")
+				newJSONError(err, http.StatusInternalServerError).Pipe(c)
+			})
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.header != "" {
+				r.Header.Set("X-Request-Id", test.header)
+			}
+			w := httptest.NewRecorder()
+			m.ServeHTTP(w, r)
+
+			requestID := w.Header().Get("X-Request-Id")
+			if test.expectPassThrough && requestID != test.header {
+				t.Errorf("Expected request id %q to be echoed, got %q", test.header, requestID)
+			}
+			if !test.expectPassThrough && (requestID == "" || requestID == test.header) {
+				t.Errorf("Expected request id to be generated, got %q", requestID)
+			}
+
+			var body errorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Unexpected error decoding response %v", err)
+			}
+			if body.RequestID != requestID {
+				t.Errorf("Expected error response to contain request id %q, got %q", requestID, body.RequestID)
+			}
+
+			entry := hook.LastEntry()
+			if entry == nil {
+				t.Fatal("Expected error to be logged")
+			}
+			if entry.Data["requestID"] != requestID {
+				t.Errorf("Expected log entry to contain request id %q, got %v", requestID, entry.Data["requestID"])
+			}
+		})
+	}
+}
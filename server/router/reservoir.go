@@ -0,0 +1,227 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+)
+
+// sampledEvent carries the identifiers of the event and its user's secret
+// so account users can decrypt the payload like they would for any other
+// event of the account.
+type sampledEvent struct {
+	EventID     string    `json:"eventId"`
+	SecretID    string    `json:"secretId,omitempty"`
+	Payload     string    `json:"payload"`
+	ContentHash string    `json:"contentHash,omitempty"`
+	Received    time.Time `json:"received"`
+}
+
+// eventReservoir keeps a uniform random sample of fixed size of all events
+// it has been offered, using reservoir sampling (Algorithm R).
+type eventReservoir struct {
+	mu     sync.Mutex
+	size   int
+	seen   int
+	events []sampledEvent
+	rand   *rand.Rand
+}
+
+func newEventReservoir(size int, source rand.Source) *eventReservoir {
+	return &eventReservoir{
+		size:   size,
+		events: make([]sampledEvent, 0, size),
+		rand:   rand.New(source),
+	}
+}
+
+func (e *eventReservoir) offer(evt sampledEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.seen++
+	if len(e.events) < e.size {
+		e.events = append(e.events, evt)
+		return
+	}
+	if i := e.rand.Intn(e.seen); i < e.size {
+		e.events[i] = evt
+	}
+}
+
+// drop removes all sampled events of the user with the given secret id.
+func (e *eventReservoir) drop(secretID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	kept := e.events[:0]
+	for _, evt := range e.events {
+		if evt.SecretID != secretID {
+			kept = append(kept, evt)
+		}
+	}
+	e.events = kept
+}
+
+func (e *eventReservoir) sample() (int, []sampledEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	events := make([]sampledEvent, len(e.events))
+	copy(events, e.events)
+	return e.seen, events
+}
+
+// eventReservoirs holds a reservoir for each account that has received
+// events. As event payloads are encrypted, the server cannot tell event
+// types apart, which is why events are sampled per account.
+type eventReservoirs struct {
+	mu         sync.Mutex
+	size       int
+	reservoirs map[string]*eventReservoir
+}
+
+func (e *eventReservoirs) get(accountID string) *eventReservoir {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	r, ok := e.reservoirs[accountID]
+	if !ok {
+		r = newEventReservoir(e.size, rand.NewSource(time.Now().UnixNano()))
+		e.reservoirs[accountID] = r
+	}
+	return r
+}
+
+func (e *eventReservoirs) remove(accountID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.reservoirs, accountID)
+}
+
+func (e *eventReservoirs) accountIDs() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var accountIDs []string
+	for accountID := range e.reservoirs {
+		accountIDs = append(accountIDs, accountID)
+	}
+	return accountIDs
+}
+
+// WithEventReservoir keeps an in-memory sample of the given size of the
+// events received by each account, which can be inspected by account users
+// for debugging purposes. A size of 0 disables sampling.
+func WithEventReservoir(size int) Config {
+	return func(r *router) {
+		if size > 0 {
+			r.reservoirs = &eventReservoirs{
+				size:       size,
+				reservoirs: map[string]*eventReservoir{},
+			}
+		}
+	}
+}
+
+// newSampledEventID creates the identifier for an event that is about to be
+// stored, so it can be added to the sample. In case sampling is disabled,
+// nil is returned and the event id is left to the persistence layer.
+func (rt *router) newSampledEventID() (*string, error) {
+	if rt.reservoirs == nil {
+		return nil, nil
+	}
+	eventID, err := persistence.NewULID()
+	if err != nil {
+		return nil, fmt.Errorf("router: error creating event id: %w", err)
+	}
+	return &eventID, nil
+}
+
+func (rt *router) sampleEvent(r *http.Request, userID string, evt InboundEvent, eventID *string) {
+	if rt.reservoirs == nil || eventID == nil {
+		return
+	}
+	var secretID string
+	if userID != "" {
+		var err error
+		if secretID, err = rt.db.SecretID(evt.AccountID, userID); err != nil {
+			rt.logError(r.Context(), err, "error looking up secret id of sampled event")
+			return
+		}
+	}
+	rt.reservoirs.get(evt.AccountID).offer(sampledEvent{
+		EventID:     *eventID,
+		SecretID:    secretID,
+		Payload:     evt.Payload,
+		ContentHash: evt.ContentHash,
+		Received:    time.Now(),
+	})
+}
+
+// dropSampledEvents removes the events of the given user from the samples
+// of all accounts, so purged events are not kept in memory.
+func (rt *router) dropSampledEvents(r *http.Request, userID string) {
+	if rt.reservoirs == nil || userID == "" {
+		return
+	}
+	for _, accountID := range rt.reservoirs.accountIDs() {
+		secretID, err := rt.db.SecretID(accountID, userID)
+		if err != nil {
+			rt.logError(r.Context(), err, "error looking up secret id for dropping sampled events")
+			continue
+		}
+		rt.reservoirs.get(accountID).drop(secretID)
+	}
+}
+
+// dropEventSample removes the sample of the given account.
+func (rt *router) dropEventSample(accountID string) {
+	if rt.reservoirs == nil {
+		return
+	}
+	rt.reservoirs.remove(accountID)
+}
+
+type eventSampleResponse struct {
+	Seen   int            `json:"seen"`
+	Events []sampledEvent `json:"events"`
+}
+
+func (rt *router) getEventSample(c *gin.Context) {
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+
+	accountID := c.Param("accountID")
+	if ok := accountUser.CanAccessAccount(accountID); !ok {
+		newJSONError(
+			fmt.Errorf("router: account user does not have permissions to access account %s", accountID),
+			http.StatusForbidden,
+		).Pipe(c)
+		return
+	}
+
+	if rt.reservoirs == nil {
+		newJSONError(
+			errors.New("router: event sampling is not enabled"),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+
+	seen, events := rt.reservoirs.get(accountID).sample()
+	c.JSON(http.StatusOK, eventSampleResponse{
+		Seen:   seen,
+		Events: events,
+	})
+}
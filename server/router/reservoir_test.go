@@ -0,0 +1,255 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+)
+
+func TestEventReservoir(t *testing.T) {
+	t.Run("bounded size", func(t *testing.T) {
+		r := newEventReservoir(10, rand.NewSource(1))
+		offered := map[string]bool{}
+		for i := 0; i < 10000; i++ {
+			payload := fmt.Sprintf("payload-%d", i)
+			offered[payload] = true
+			r.offer(sampledEvent{Payload: payload})
+		}
+		seen, events := r.sample()
+		if seen != 10000 {
+			t.Errorf("Expected 10000 events to be seen, got %d", seen)
+		}
+		if len(events) != 10 {
+			t.Fatalf("Expected sample of size 10, got %d", len(events))
+		}
+		sampled := map[string]bool{}
+		for _, evt := range events {
+			if !offered[evt.Payload] {
+				t.Errorf("Unexpected event %s in sample", evt.Payload)
+			}
+			if sampled[evt.Payload] {
+				t.Errorf("Event %s sampled more than once", evt.Payload)
+			}
+			sampled[evt.Payload] = true
+		}
+	})
+	t.Run("uniform sample", func(t *testing.T) {
+		// Each of the 100 events is expected to be included in 10% of
+		// all samples.
+		source := rand.NewSource(1)
+		counts := make([]int, 100)
+		for trial := 0; trial < 5000; trial++ {
+			r := newEventReservoir(10, source)
+			for i := 0; i < 100; i++ {
+				r.offer(sampledEvent{Payload: fmt.Sprintf("%d", i)})
+			}
+			_, events := r.sample()
+			for _, evt := range events {
+				var i int
+				fmt.Sscanf(evt.Payload, "%d", &i)
+				counts[i]++
+			}
+		}
+		for i, count := range counts {
+			if count < 400 || count > 600 {
+				t.Errorf("Event %d was sampled %d times, expected about 500", i, count)
+			}
+		}
+	})
+}
+
+func TestRouter_getEventSample(t *testing.T) {
+	tests := []struct {
+		name               string
+		opts               []Config
+		user               interface{}
+		expectedStatusCode int
+		expectedSeen       int
+	}{
+		{
+			"no user",
+			[]Config{WithEventReservoir(2)},
+			nil,
+			http.StatusNotFound,
+			0,
+		},
+		{
+			"no access",
+			[]Config{WithEventReservoir(2)},
+			persistence.LoginResult{
+				Accounts: []persistence.LoginAccountResult{{AccountID: "account-b"}},
+			},
+			http.StatusForbidden,
+			0,
+		},
+		{
+			"sampling disabled",
+			nil,
+			persistence.LoginResult{
+				Accounts: []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			http.StatusNotFound,
+			0,
+		},
+		{
+			"ok",
+			[]Config{WithEventReservoir(2)},
+			persistence.LoginResult{
+				Accounts: []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			http.StatusOK,
+			5,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := router{}
+			for _, opt := range test.opts {
+				opt(&rt)
+			}
+			for i := 0; i < 5; i++ {
+				eventID := fmt.Sprintf("event-%d", i)
+				rt.sampleEvent(httptest.NewRequest(http.MethodPost, "/", nil), "", InboundEvent{AccountID: "account-a", Payload: "payload"}, &eventID)
+				rt.sampleEvent(httptest.NewRequest(http.MethodPost, "/", nil), "", InboundEvent{AccountID: "account-b", Payload: "payload"}, &eventID)
+			}
+
+			m := gin.New()
+			m.GET("/:accountID", func(c *gin.Context) {
+				c.Set(contextKeyAuth, test.user)
+			}, rt.getEventSample)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/account-a", nil)
+			m.ServeHTTP(w, r)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("Unexpected status code %v", w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var response eventSampleResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Unexpected error decoding response: %v", err)
+			}
+			if response.Seen != test.expectedSeen {
+				t.Errorf("Expected %d events to be seen, got %d", test.expectedSeen, response.Seen)
+			}
+			if len(response.Events) != 2 {
+				t.Errorf("Expected sample of size 2, got %d", len(response.Events))
+			}
+		})
+	}
+}
+
+type mockSampledEventsService struct {
+	persistence.Service
+}
+
+func (m *mockSampledEventsService) SecretID(accountID, userID string) (string, error) {
+	return fmt.Sprintf("%s-%s", accountID, userID), nil
+}
+
+func (m *mockSampledEventsService) Purge(string) error {
+	return nil
+}
+
+func (m *mockSampledEventsService) GetAccount(accountID string, events, styles bool, since string) (persistence.AccountResult, error) {
+	return persistence.AccountResult{AccountID: accountID, Name: "Account A"}, nil
+}
+
+func (m *mockSampledEventsService) RetireAccount(string) error {
+	return nil
+}
+
+func newSampledEventsRouter() *router {
+	rt := &router{db: &mockSampledEventsService{}}
+	WithEventReservoir(10)(rt)
+	for _, accountID := range []string{"account-a", "account-b"} {
+		for _, userID := range []string{"user-a", "user-b"} {
+			eventID := fmt.Sprintf("event-%s-%s", accountID, userID)
+			rt.sampleEvent(
+				httptest.NewRequest(http.MethodPost, "/", nil),
+				userID,
+				InboundEvent{AccountID: accountID, Payload: "payload"},
+				&eventID,
+			)
+		}
+	}
+	return rt
+}
+
+func TestRouter_sampleEvent_Identifiers(t *testing.T) {
+	rt := newSampledEventsRouter()
+	_, events := rt.reservoirs.get("account-a").sample()
+	if len(events) != 2 {
+		t.Fatalf("Expected two sampled events, got %d", len(events))
+	}
+	for _, evt := range events {
+		userID := strings.TrimPrefix(evt.EventID, "event-account-a-")
+		if evt.SecretID != "account-a-"+userID {
+			t.Errorf("Unexpected identifiers %s and %s", evt.EventID, evt.SecretID)
+		}
+	}
+}
+
+func TestRouter_purgeEvents_DropsSampledEvents(t *testing.T) {
+	rt := newSampledEventsRouter()
+	m := gin.New()
+	m.POST("/", func(c *gin.Context) {
+		c.Set(contextKeyCookie, "user-a")
+		c.Next()
+	}, rt.purgeEvents)
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Unexpected status code %v", w.Code)
+	}
+
+	for _, accountID := range []string{"account-a", "account-b"} {
+		_, events := rt.reservoirs.get(accountID).sample()
+		if len(events) != 1 || events[0].SecretID != accountID+"-user-b" {
+			t.Errorf("Expected only events of user-b to be kept for %s, got %v", accountID, events)
+		}
+	}
+}
+
+func TestRouter_deleteAccount_DropsEventSample(t *testing.T) {
+	rt := newSampledEventsRouter()
+	m := gin.New()
+	m.DELETE("/:accountID", func(c *gin.Context) {
+		c.Set(contextKeyAuth, persistence.LoginResult{
+			AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+			Accounts: []persistence.LoginAccountResult{
+				{AccountID: "account-a"},
+			},
+		})
+		c.Next()
+	}, rt.deleteAccount)
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/account-a", strings.NewReader(`{"accountName":"Account A"}`)))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Unexpected status code %v", w.Code)
+	}
+
+	for _, accountID := range rt.reservoirs.accountIDs() {
+		if accountID == "account-a" {
+			t.Error("Expected sample of retired account to be removed")
+		}
+	}
+	if _, events := rt.reservoirs.get("account-b").sample(); len(events) != 2 {
+		t.Errorf("Expected sample of other account to be kept, got %v", events)
+	}
+}
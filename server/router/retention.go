@@ -0,0 +1,160 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/config"
+	"github.com/offen/offen/server/persistence"
+)
+
+func accountRetentionCacheKey(accountID string) string {
+	return fmt.Sprintf("account-retention-%s", accountID)
+}
+
+// lookupAccountRetention returns the retention period for events of the
+// given account, falling back to the global retention period in case the
+// account does not define one or it cannot be looked up. Results are cached
+// for a short amount of time as this is called on every event that is
+// ingested.
+func (rt *router) lookupAccountRetention(accountID string) time.Duration {
+	cache, cacheKey := rt.getCache(), accountRetentionCacheKey(accountID)
+	if cachedItem, ok := cache.Get(cacheKey); ok {
+		if retention, castOk := cachedItem.(time.Duration); castOk {
+			return retention
+		}
+	}
+
+	retention, err := rt.db.GetAccountRetention(accountID)
+	if err != nil || retention <= 0 {
+		retention = config.EventRetention
+	}
+	if err == nil {
+		cache.Set(cacheKey, retention, time.Minute)
+	}
+	return retention
+}
+
+type accountRetentionResponse struct {
+	Retention string `json:"retention"`
+	// Default signals the account uses the globally configured retention
+	// period.
+	Default bool `json:"default"`
+}
+
+func (rt *router) accountRetentionResponse(retention time.Duration) accountRetentionResponse {
+	if r, ok := config.RetentionFor(retention); ok && retention > 0 {
+		return accountRetentionResponse{Retention: r.String()}
+	}
+	return accountRetentionResponse{Retention: rt.globalRetention(), Default: true}
+}
+
+func (rt *router) globalRetention() string {
+	if rt.config == nil {
+		return ""
+	}
+	return rt.config.App.Retention.String()
+}
+
+func (rt *router) getAccountRetention(c *gin.Context) {
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+
+	accountID := c.Param("accountID")
+	if ok := accountUser.CanAccessAccount(accountID); !ok {
+		newJSONError(
+			fmt.Errorf("router: account user does not have permissions to access retention of account %s", accountID),
+			http.StatusForbidden,
+		).Pipe(c)
+		return
+	}
+
+	retention, err := rt.db.GetAccountRetention(accountID)
+	if err != nil {
+		accountError(c, accountID, fmt.Errorf("router: error looking up retention: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, rt.accountRetentionResponse(retention))
+}
+
+type accountRetentionRequest struct {
+	// Retention is one of the supported retention periods. An empty value
+	// makes the account use the global retention period again.
+	Retention *string `json:"retention"`
+}
+
+func (rt *router) putAccountRetention(c *gin.Context) {
+	var req accountRetentionRequest
+	if err := c.BindJSON(&req); err != nil {
+		newJSONError(
+			fmt.Errorf("router: error decoding request payload: %w", err),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+	if req.Retention == nil {
+		newJSONError(
+			errors.New("router: expected request payload to contain a value for retention"),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+	var retention time.Duration
+	if *req.Retention != "" {
+		var r config.Retention
+		if err := r.Decode(*req.Retention); err != nil {
+			newJSONError(
+				fmt.Errorf("router: error decoding retention: %w", err),
+				http.StatusBadRequest,
+			).Pipe(c)
+			return
+		}
+		retention = r.Duration()
+	}
+
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+
+	accountID := c.Param("accountID")
+	if ok := accountUser.CanAccessAccount(accountID) && accountUser.IsSuperAdmin(); !ok {
+		newJSONError(
+			fmt.Errorf("router: account user does not have permissions to update retention of account %s", accountID),
+			http.StatusForbidden,
+		).Pipe(c)
+		return
+	}
+
+	if err := rt.db.SetAccountRetention(accountID, retention); err != nil {
+		accountError(c, accountID, fmt.Errorf("router: error updating retention: %w", err))
+		return
+	}
+	rt.getCache().Delete(accountRetentionCacheKey(accountID))
+
+	response := rt.accountRetentionResponse(retention)
+	if rt.logger != nil {
+		rt.logger.
+			WithField("accountID", accountID).
+			WithField("accountUserID", accountUser.AccountUserID).
+			WithField("retention", response.Retention).
+			Warn("Updated retention period for account")
+	}
+	c.JSON(http.StatusOK, response)
+}
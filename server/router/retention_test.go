@@ -0,0 +1,124 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/config"
+	"github.com/offen/offen/server/persistence"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+type mockAccountRetentionService struct {
+	persistence.Service
+	retention time.Duration
+}
+
+func (m *mockAccountRetentionService) GetAccountRetention(string) (time.Duration, error) {
+	return m.retention, nil
+}
+
+func (m *mockAccountRetentionService) SetAccountRetention(accountID string, d time.Duration) error {
+	m.retention = d
+	return nil
+}
+
+func TestRouter_accountRetention(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	db := &mockAccountRetentionService{}
+	cfg := &config.Config{}
+	cfg.App.Retention.Decode("6months")
+	rt := router{db: db, logger: logger, config: cfg}
+	setUser := func(user interface{}) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Set(contextKeyAuth, user)
+		}
+	}
+
+	m := gin.New()
+	admin := setUser(persistence.LoginResult{
+		AccountUserID: "account-user-a",
+		AdminLevel:    persistence.AccountUserAdminLevelSuperAdmin,
+		Accounts:      []persistence.LoginAccountResult{{AccountID: "account-a"}},
+	})
+	m.GET("/accounts/:accountID/retention", admin, rt.getAccountRetention)
+	m.PUT("/accounts/:accountID/retention", admin, rt.putAccountRetention)
+	m.PUT("/readonly/:accountID/retention", setUser(persistence.LoginResult{
+		Accounts: []persistence.LoginAccountResult{{AccountID: "account-a"}},
+	}), rt.putAccountRetention)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		m.ServeHTTP(w, r)
+		return w
+	}
+	read := func() accountRetentionResponse {
+		w := do(http.MethodGet, "/accounts/account-a/retention", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200 when reading retention, got %d", w.Code)
+		}
+		var response accountRetentionResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Unexpected response %s", w.Body.String())
+		}
+		return response
+	}
+
+	if response := read(); response.Retention != "6months" || !response.Default {
+		t.Errorf("Expected global retention by default, got %v", response)
+	}
+
+	if w := do(http.MethodPut, "/accounts/account-a/retention", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for missing value, got %d", w.Code)
+	}
+	if w := do(http.MethodPut, "/accounts/account-a/retention", `{"retention":"3days"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unsupported value, got %d", w.Code)
+	}
+	if w := do(http.MethodPut, "/readonly/account-a/retention", `{"retention":"7days"}`); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for non admin, got %d", w.Code)
+	}
+	if w := do(http.MethodPut, "/accounts/account-b/retention", `{"retention":"7days"}`); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for account out of scope, got %d", w.Code)
+	}
+	if len(hook.Entries) != 0 {
+		t.Errorf("Expected rejected requests not to be logged, got %v", hook.Entries)
+	}
+
+	if retention := rt.lookupAccountRetention("account-a"); retention != config.EventRetention {
+		t.Errorf("Expected global retention for cookie, got %v", retention)
+	}
+
+	if w := do(http.MethodPut, "/accounts/account-a/retention", `{"retention":"7days"}`); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 when updating retention, got %d", w.Code)
+	}
+	if db.retention != time.Hour*24*7 {
+		t.Errorf("Unexpected retention %v", db.retention)
+	}
+	entry := hook.LastEntry()
+	if entry == nil || entry.Level != logrus.WarnLevel || entry.Data["retention"] != "7days" {
+		t.Errorf("Expected update to be logged, got %v", entry)
+	}
+	if response := read(); response.Retention != "7days" || response.Default {
+		t.Errorf("Unexpected retention %v", response)
+	}
+	if retention := rt.lookupAccountRetention("account-a"); retention != time.Hour*24*7 {
+		t.Errorf("Expected cached retention to be invalidated, got %v", retention)
+	}
+
+	if w := do(http.MethodPut, "/accounts/account-a/retention", `{"retention":""}`); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 when clearing retention, got %d", w.Code)
+	}
+	if response := read(); !response.Default {
+		t.Errorf("Expected global retention after clearing, got %v", response)
+	}
+}
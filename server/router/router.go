@@ -4,6 +4,7 @@
 package router
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"html/template"
@@ -18,6 +19,7 @@ import (
 	"github.com/gorilla/securecookie"
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/offen/offen/server/config"
+	"github.com/offen/offen/server/keys"
 	"github.com/offen/offen/server/mailer"
 	"github.com/offen/offen/server/persistence"
 	ratelimiter "github.com/offen/offen/server/ratelimiter"
@@ -26,25 +28,59 @@ import (
 )
 
 type router struct {
-	db           persistence.Service
-	mailer       mailer.Mailer
-	fs           http.FileSystem
-	logger       *logrus.Logger
-	cookieSigner *securecookie.SecureCookie
-	template     *template.Template
-	emails       *template.Template
-	config       *config.Config
-	sanitizer    *bluemonday.Policy
-	limiter      ratelimiter.Throttler
-	cache        *cache.Cache
+	db              persistence.Service
+	mailer          mailer.Mailer
+	fs              http.FileSystem
+	logger          *logrus.Logger
+	cookieSigner    *securecookie.SecureCookie
+	authSigner      *securecookie.SecureCookie
+	featuresSigner  *securecookie.SecureCookie
+	inviteSigner    *securecookie.SecureCookie
+	resetSigner     *securecookie.SecureCookie
+	template        *template.Template
+	emails          *template.Template
+	config          *config.Config
+	sanitizer       *bluemonday.Policy
+	limiter         ratelimiter.Throttler
+	cache           *cache.Cache
+	ingestPipeline  []Transform
+	metrics         *requestMetrics
+	metricsInterval time.Duration
+	cookieSecret    []byte
+	captcha         CaptchaVerifier
+	fallbackAccount string
+	reservoirs      *eventReservoirs
+	geo             GeoLookup
+	corsOrigins     []string
+	frameAncestors  []string
+	contentPolicy   string
+	maxJSONDepth    int
+	maxJSONTokens   int
+	gzip            *bool
+	healthCacheTTL  time.Duration
+	readinessGate   <-chan struct{}
+	cookieSameSite  http.SameSite
+	cookieDomain    string
+	hostPrefix      bool
+	authMaxAge      time.Duration
+	ipLimiter       *ipRateLimiter
+	loginLockouts   *loginLockouts
+	maxBodyBytes    int64
+	maxGzipRatio    int64
+	prometheus      *prometheusMetrics
 }
 
+// rateLimitMaxEntries caps the number of identifiers the rate limiter keeps
+// track of, so that requests with many distinct identifiers cannot exhaust
+// memory.
+const rateLimitMaxEntries = 100000
+
 func (rt *router) getLimiter() ratelimiter.Throttler {
 	if rt.limiter == nil {
 		if rt.config != nil && rt.config.Server.ReverseProxy {
 			rt.limiter = ratelimiter.NewNoopRateLimiter()
 		} else {
-			rt.limiter = ratelimiter.New(time.Second*30, cache.New(time.Minute, time.Minute*2))
+			rt.limiter = ratelimiter.New(time.Second*30, ratelimiter.NewBoundedCache(rateLimitMaxEntries))
 		}
 	}
 	return rt.limiter
@@ -57,13 +93,28 @@ func (rt *router) getCache() *cache.Cache {
 	return rt.cache
 }
 
-func (rt *router) logError(err error, message string) {
+// logError logs the given error. In case the given context belongs to a
+// request, the request id is added to the entry, so it can be correlated with
+// the error response the client has received.
+func (rt *router) logError(ctx context.Context, err error, message string) {
 	sanitizedErrorMessage := strings.ReplaceAll(err.Error(), "\n", " ")
 	if rt.logger != nil {
-		rt.logger.WithError(errors.New(sanitizedErrorMessage)).Error(message)
+		entry := rt.logger.WithError(errors.New(sanitizedErrorMessage))
+		if requestID := requestIDFromContext(ctx); requestID != "" {
+			entry = entry.WithField("requestID", requestID)
+		}
+		entry.Error(message)
 	}
 }
 
+// cookieSigningKeySize is the recommended size for HMAC keys used
+// by package securecookie.
+const cookieSigningKeySize = 64
+
+// defaultAuthCookieMaxAge is the duration a login session and its auth token
+// are valid for unless configured otherwise.
+const defaultAuthCookieMaxAge = time.Hour * 24
+
 const (
 	cookieKey               = "user"
 	optinKey                = "consent"
@@ -74,11 +125,42 @@ const (
 	contextKeySecureContext = "contextKeySecure"
 )
 
-func (rt *router) userCookie(userID string, secure bool) *http.Cookie {
+// hostCookiePrefix is prepended to the name of the auth cookie when
+// configured, so browsers only accept the cookie when it is secure, scoped
+// to the root path and not shared with other hosts.
+const hostCookiePrefix = "__Host-"
+
+// errHostPrefixInsecure is returned when the host cookie prefix is requested
+// for cookies that cannot be secure or are shared across hosts.
+var errHostPrefixInsecure = errors.New("router: the __Host- cookie prefix can only be used for secure cookies without a domain")
+
+// errSameSiteNoneInsecure is returned when SameSite=None is requested for
+// cookies that are not marked as secure, which browsers would reject.
+var errSameSiteNoneInsecure = errors.New("router: SameSite=None can only be used for secure cookies")
+
+// cookieSameSiteMode returns the SameSite mode to use for a cookie, falling back
+// to the given mode in case none has been configured. As browsers reject
+// SameSite=None on insecure cookies, Lax is used for these instead.
+func (rt *router) cookieSameSiteMode(secure bool, fallback http.SameSite) http.SameSite {
+	switch {
+	case rt.cookieSameSite == 0:
+		return fallback
+	case rt.cookieSameSite == http.SameSiteNoneMode && !secure:
+		return http.SameSiteLaxMode
+	default:
+		return rt.cookieSameSite
+	}
+}
+
+// userCookie returns the cookie identifying the given user. The cookie
+// expires together with the user's events, i.e. after the given retention
+// period has passed.
+func (rt *router) userCookie(userID string, retention time.Duration, secure bool) *http.Cookie {
 	sameSite := http.SameSiteNoneMode
 	if !secure {
 		sameSite = http.SameSiteLaxMode
 	}
+	sameSite = rt.cookieSameSiteMode(secure, sameSite)
 
 	c := &http.Cookie{
 		Name:     cookieKey,
@@ -88,29 +170,75 @@ func (rt *router) userCookie(userID string, secure bool) *http.Cookie {
 		Secure:   secure,
 		SameSite: sameSite,
 		Path:     "/api",
+		Domain:   rt.cookieDomain,
 	}
 	if userID != "" {
-		c.Expires = time.Now().Add(config.EventRetention)
+		c.Expires = time.Now().Add(retention)
 	}
 	return c
 }
 
-func (rt *router) authCookie(userID string, secure bool) (*http.Cookie, error) {
+// authCookieMaxAge returns the duration login sessions and the auth tokens
+// referencing them are valid for.
+func (rt *router) authCookieMaxAge() time.Duration {
+	if rt.authMaxAge > 0 {
+		return rt.authMaxAge
+	}
+	return defaultAuthCookieMaxAge
+}
+
+// newTokenSigner creates a signer that rejects tokens older than the given
+// max age.
+func newTokenSigner(secret []byte, maxAge time.Duration) *securecookie.SecureCookie {
+	return securecookie.New(secret, nil).MaxAge(int(maxAge.Seconds()))
+}
+
+// decodeAuthToken returns the id of the session the given signed auth token
+// references. Tokens older than the configured max age are rejected.
+func (rt *router) decodeAuthToken(token string) (string, error) {
+	var sessionID string
+	if err := rt.authSigner.Decode(authKey, token, &sessionID); err != nil {
+		return "", err
+	}
+	return sessionID, nil
+}
+
+// authCookieName returns the name used for the auth cookie.
+func (rt *router) authCookieName() string {
+	if rt.hostPrefix {
+		return hostCookiePrefix + authKey
+	}
+	return authKey
+}
+
+// authCookie returns a cookie referencing the session of the given id. In
+// case the session id is empty, the cookie clears any existing auth cookie.
+func (rt *router) authCookie(sessionID string, secure bool) (*http.Cookie, error) {
 	c := http.Cookie{
-		Name:     authKey,
+		Name:     rt.authCookieName(),
 		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
+		SameSite: rt.cookieSameSiteMode(secure, http.SameSiteLaxMode),
 		Secure:   secure,
 		Path:     "/api",
+		Domain:   rt.cookieDomain,
+	}
+	if rt.hostPrefix {
+		// browsers treat requests to localhost as secure, so the prefixed
+		// cookie can be used in this case too
+		c.Secure = true
+		c.Path = "/"
+		c.Domain = ""
 	}
-	if userID == "" {
+	if sessionID == "" {
 		c.Expires = time.Unix(0, 0)
 	} else {
-		value, err := rt.cookieSigner.MaxAge(24*60*60).Encode(authKey, userID)
+		maxAge := rt.authCookieMaxAge()
+		value, err := rt.authSigner.Encode(authKey, sessionID)
 		if err != nil {
 			return nil, err
 		}
 		c.Value = value
+		c.Expires = time.Now().Add(maxAge)
 	}
 	return &c, nil
 
@@ -170,6 +298,87 @@ func WithMailer(m mailer.Mailer) Config {
 	}
 }
 
+// WithGzip sets whether responses are compressed for clients accepting gzip
+// encoding. Responses smaller than gziphandler.DefaultMinSize are never
+// compressed. By default, responses are compressed unless the application
+// is running behind a reverse proxy.
+func WithGzip(enabled bool) Config {
+	return func(r *router) {
+		r.gzip = &enabled
+	}
+}
+
+// WithCookieSameSite sets the SameSite mode used for the user and auth
+// cookies, e.g. SameSiteNoneMode when the vault is embedded on a third party
+// site. By default, the user cookie uses None and the auth cookie uses Lax.
+// SameSiteNoneMode cannot be used when running in development mode, as
+// cookies are not secure then.
+func WithCookieSameSite(mode http.SameSite) Config {
+	return func(r *router) {
+		r.cookieSameSite = mode
+	}
+}
+
+// WithCookieDomain scopes the user and auth cookies to the given domain, so
+// they are shared across its subdomains. By default, cookies are only sent
+// to the host that has set them.
+func WithCookieDomain(domain string) Config {
+	return func(r *router) {
+		r.cookieDomain = domain
+	}
+}
+
+// WithHostCookiePrefix prepends __Host- to the name of the auth cookie, which
+// makes browsers reject the cookie unless it is secure, scoped to the root
+// path and set without a domain. The prefix cannot be used in development
+// mode or together with WithCookieDomain.
+func WithHostCookiePrefix(enabled bool) Config {
+	return func(r *router) {
+		r.hostPrefix = enabled
+	}
+}
+
+// WithAuthCookieMaxAge sets the duration account users stay logged in for.
+// The auth cookie expires and the session it references is removed after
+// the given duration. Defaults to 24 hours.
+func WithAuthCookieMaxAge(d time.Duration) Config {
+	return func(r *router) {
+		r.authMaxAge = d
+	}
+}
+
+// WithRateLimit limits the number of login and forgot password requests
+// each client IP can make to rps requests per second, allowing bursts of up
+// to burst requests. Passing a non-positive rate or burst disables the limit.
+func WithRateLimit(rps float64, burst int) Config {
+	return func(r *router) {
+		if rps > 0 && burst > 0 {
+			r.ipLimiter = newIPRateLimiter(rps, burst)
+		}
+	}
+}
+
+// WithFallbackAccount routes events and public key requests for unknown
+// account ids to the account with the given id. Passing an empty string
+// keeps rejecting unknown account ids.
+func WithFallbackAccount(accountID string) Config {
+	return func(r *router) {
+		r.fallbackAccount = accountID
+	}
+}
+
+// WithCookieSecretFromPassphrase derives the key used for signing cookies
+// from the given passphrase and salt instead of using the configured secret
+// as is. In case no key can be derived from the given values, the configured
+// secret continues to be used.
+func WithCookieSecretFromPassphrase(pass, salt []byte) Config {
+	return func(r *router) {
+		if key, err := keys.DeriveSigningKey(pass, salt, cookieSigningKeySize); err == nil {
+			r.cookieSecret = key
+		}
+	}
+}
+
 // New creates a new application router that reads and writes data
 // to the given database implementation. In the context of the application
 // this expects to be the only top level router in charge of handling all
@@ -181,23 +390,77 @@ func New(opts ...Config) http.Handler {
 	}
 
 	rt.sanitizer = bluemonday.StrictPolicy()
-	rt.cookieSigner = securecookie.New(rt.config.Secret.Bytes(), nil)
+	// lockouts are created upfront as handlers access them concurrently
+	rt.loginLockouts = newLoginLockouts()
+	cookieSecret := rt.config.Secret.Bytes()
+	if rt.cookieSecret != nil {
+		cookieSecret = rt.cookieSecret
+	}
+	rt.cookieSigner = securecookie.New(cookieSecret, nil)
+	// Each type of token uses a signer of its own, as changing the max age
+	// of a signer that is shared between requests is not safe.
+	rt.authSigner = newTokenSigner(cookieSecret, rt.authCookieMaxAge())
+	rt.featuresSigner = newTokenSigner(cookieSecret, featuresTokenMaxAge)
+	rt.inviteSigner = newTokenSigner(cookieSecret, rt.config.App.InviteExpiry)
+	rt.resetSigner = newTokenSigner(cookieSecret, resetTokenDecodeMaxAge(rt.config.App.ResetTokenClockSkew))
+
+	if rt.hostPrefix && (rt.config.App.Development || rt.cookieDomain != "") {
+		rt.logError(context.Background(), errHostPrefixInsecure, "error configuring host cookie prefix")
+		rt.hostPrefix = false
+	}
 
-	optin := optinMiddleware(optinKey, optinValue)
+	optinOrExempt := rt.optinOrExemptMiddleware(optinKey, optinValue)
 	userCookie := userCookieMiddleware(cookieKey, contextKeyCookie)
-	accountAuth := rt.accountUserMiddleware(authKey, contextKeyAuth)
+	accountAuth := rt.accountUserMiddleware(rt.authCookieName(), contextKeyAuth)
 	noStore := headerMiddleware(map[string]func() string{
 		"Cache-Control": func() string {
 			return "no-store"
 		},
 	})
 
+	policy := defaultCSP
+	if rt.contentPolicy != "" {
+		policy = withConnectSelf(rt.contentPolicy)
+	}
 	csp := headerMiddleware(map[string]func() string{
 		"Content-Security-Policy": func() string {
-			return defaultCSP
+			return policy
 		},
 	})
 	etag := etagMiddleware()
+	ipLimit := rt.ipRateLimitMiddleware()
+
+	corsAllowed, corsErr := corsAllowlist(rt.corsOrigins)
+	if corsErr != nil {
+		rt.logError(context.Background(), corsErr, "error configuring cors origins")
+	}
+	cors := corsMiddleware(corsAllowed)
+
+	frameAncestors, frameAncestorsErr := frameAncestorsDirective(rt.frameAncestors)
+	if frameAncestorsErr != nil {
+		rt.logError(context.Background(), frameAncestorsErr, "error configuring frame ancestors")
+	}
+	vaultCSP := csp
+	if frameAncestors != "" {
+		vaultCSP = headerMiddleware(map[string]func() string{
+			"Content-Security-Policy": func() string {
+				return policy + "; " + frameAncestors
+			},
+		})
+	}
+
+	if rt.cookieSameSite == http.SameSiteNoneMode && rt.config.App.Development {
+		rt.logError(context.Background(), errSameSiteNoneInsecure, "error configuring cookie same site mode")
+		rt.cookieSameSite = 0
+	}
+
+	if rt.cookieDomain != "" {
+		domain, err := validateCookieDomain(rt.cookieDomain, rt.config.Server.AutoTLS)
+		if err != nil {
+			rt.logError(context.Background(), err, "error configuring cookie domain, falling back to host only cookies")
+		}
+		rt.cookieDomain = domain
+	}
 
 	if !rt.config.App.Development {
 		gin.SetMode(gin.ReleaseMode)
@@ -205,40 +468,80 @@ func New(opts ...Config) http.Handler {
 
 	app := gin.New()
 	app.SetHTMLTemplate(rt.template)
+	app.Use(requestIDMiddleware(), rt.featureOverridesMiddleware())
+	if rt.logger != nil {
+		// The access log is installed before recovering from panics so that
+		// requests failing this way are logged as well.
+		app.Use(accessLogMiddleware(rt.logger, "/healthz", "/readyz"))
+	}
 	app.Use(
+		rt.getMetrics().middleware(),
 		gin.Recovery(),
 		location.Default(),
 		secureContextMiddleware(contextKeySecureContext, rt.config.App.Development),
+		securityHeadersMiddleware(),
+		apiVersionMiddleware("/api", supportedAPIVersions),
 	)
+	if rt.prometheus != nil {
+		app.Use(rt.prometheus.middleware())
+	}
+	if rt.readinessGate != nil {
+		app.Use(rt.readinessGateMiddleware("/healthz", "/readyz"))
+	}
 
 	app.Any("/healthz", noStore, rt.getHealth)
+	app.Any("/readyz", noStore, rt.getReady)
 	app.GET("/versionz", noStore, rt.getVersion)
+	if rt.prometheus != nil {
+		app.GET("/metrics", noStore, rt.getPrometheusMetrics)
+	}
 
-	app.GET("/vault", etag, csp, rt.getVault)
+	app.GET(vaultPath, etag, vaultCSP, rt.getVault)
 	if rt.config.App.DemoAccount != "" {
 		app.GET("/intro", etag, csp, rt.getIntro)
 	}
 
-	{
-		api := app.Group("/api")
+	registerAPI := func(api *gin.RouterGroup) {
 		api.Use(noStore)
+		if len(corsAllowed) != 0 {
+			api.Use(cors)
+		}
 		api.GET("/exchange", rt.getPublicKey)
 		api.POST("/exchange", rt.postUserSecret)
 
 		api.GET("/accounts/:accountID", accountAuth, rt.getAccount)
 		api.DELETE("/accounts/:accountID", accountAuth, rt.deleteAccount)
 		api.PUT("/accounts/:accountID/account-styles", accountAuth, rt.putAccountStyles)
+		api.GET("/accounts/:accountID/settings", accountAuth, rt.getAccountSettings)
+		api.PUT("/accounts/:accountID/settings", accountAuth, rt.putAccountSettings)
+		api.GET("/accounts/:accountID/stats", accountAuth, rt.getAccountStats)
+		api.GET("/accounts/:accountID/sample", accountAuth, rt.getEventSample)
+		api.GET("/accounts/:accountID/export", accountAuth, rt.getAccountExport)
+		api.GET("/accounts/:accountID/schema", accountAuth, rt.getEventSchema)
+		api.PUT("/accounts/:accountID/schema", accountAuth, rt.putEventSchema)
+		api.GET("/accounts/:accountID/consent-audit", accountAuth, rt.getConsentAudit)
+		api.GET("/accounts/:accountID/legal-hold", accountAuth, rt.getLegalHold)
+		api.PUT("/accounts/:accountID/legal-hold", accountAuth, rt.putLegalHold)
+		api.GET("/accounts/:accountID/retention", accountAuth, rt.getAccountRetention)
+		api.PUT("/accounts/:accountID/retention", accountAuth, rt.putAccountRetention)
+		api.DELETE("/accounts/:accountID/suspension", accountAuth, rt.deleteIngestSuspension)
+		api.GET("/accounts/:accountID/ingest-pause", accountAuth, rt.getIngestPause)
+		api.PUT("/accounts/:accountID/ingest-pause", accountAuth, rt.putIngestPause)
 		api.POST("/accounts", accountAuth, rt.postAccount)
+		api.POST("/accounts/redeem", accountAuth, rt.postRedeemInvite)
+		api.POST("/invites", accountAuth, rt.postInvite)
 
 		api.POST("/purge", userCookie, rt.purgeEvents)
+		api.GET("/user/export", userCookie, rt.getUserExport)
 
 		api.GET("/login", accountAuth, rt.getLogin)
-		api.POST("/login", rt.postLogin)
+		api.POST("/login", ipLimit, rt.postLogin)
 		api.POST("/logout", rt.postLogout)
+		api.POST("/unlock-login", accountAuth, rt.postUnlockLogin)
 
 		api.POST("/change-password", accountAuth, rt.postChangePassword)
 		api.POST("/change-email", accountAuth, rt.postChangeEmail)
-		api.POST("/forgot-password", rt.postForgotPassword)
+		api.POST("/forgot-password", ipLimit, rt.postForgotPassword)
 		api.POST("/reset-password", rt.postResetPassword)
 		api.POST("/share-account/:accountID", accountAuth, rt.postShareAccount)
 		api.POST("/share-account", accountAuth, rt.postShareAccount)
@@ -246,25 +549,60 @@ func New(opts ...Config) http.Handler {
 		api.GET("/setup", rt.getSetup)
 		api.POST("/setup", rt.postSetup)
 
+		api.GET("/admin/metrics/stream", accountAuth, rt.getMetricsStream)
+		api.POST("/admin/features", accountAuth, rt.postFeatureToken)
+
 		api.GET("/events", userCookie, rt.getEvents)
-		api.POST("/events", optin, userCookie, rt.postEvents)
+		api.POST("/events", optinOrExempt, userCookie, rt.postEvents)
+		api.GET("/events/ws", optinOrExempt, userCookie, rt.getEventsWebSocket(corsAllowed))
+	}
+
+	// Unversioned routes are kept as an alias for the first API version.
+	registerAPI(app.Group("/api"))
+	for _, version := range supportedAPIVersions {
+		registerAPI(app.Group("/api/" + version))
+	}
+
+	if len(corsAllowed) != 0 {
+		// Preflight requests are answered by a single catch-all route that
+		// also covers all versioned paths.
+		app.OPTIONS("/api/*path", noStore, cors, corsPreflightHandler(app.Routes(), corsAllowed))
 	}
 
 	root := gin.New()
 	root.SetHTMLTemplate(rt.template)
 	root.GET("/*any", etag, csp, rt.getIndex)
 
-	app.Use(staticMiddleware(http.FileServer(rt.fs), root))
+	app.NoRoute(staticMiddleware(http.FileServer(rt.fs), root, policy))
+
+	// Paths that exist but do not support the requested method respond with
+	// 405 instead of falling through to the static file server.
+	app.HandleMethodNotAllowed = true
+	app.NoMethod(methodNotAllowedHandler(app.Routes()))
+
+	handler := routeTimeoutHandler(app, map[routeClass]time.Duration{
+		routeClassIngest: rt.config.Server.IngestTimeout,
+		routeClassRead:   rt.config.Server.ReadTimeout,
+		routeClassAdmin:  rt.config.Server.AdminTimeout,
+		routeClassExport: rt.config.Server.ExportTimeout,
+	})
+
+	gzipEnabled := !rt.config.Server.ReverseProxy
+	if rt.gzip != nil {
+		gzipEnabled = *rt.gzip
+	}
+	if gzipEnabled {
+		handler = gziphandler.GzipHandler(handler)
+	}
 
 	if rt.config.Server.ReverseProxy {
-		return app
+		return handler
 	}
 
-	withGzip := gziphandler.GzipHandler(app)
 	// HTTP logging is only added when the reverse proxy setting is not
 	// enabled
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		metrics := httpsnoop.CaptureMetrics(withGzip, w, r)
+		metrics := httpsnoop.CaptureMetrics(handler, w, r)
 		fmt.Printf(
 			"%s %s %s [%s] \"%s %s %s\" %d %s\n",
 			"-",
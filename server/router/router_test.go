@@ -5,10 +5,15 @@ package router
 
 import (
 	"html/template"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/securecookie"
 	"github.com/offen/offen/server/config"
 	"github.com/offen/offen/server/persistence"
 )
@@ -29,3 +34,285 @@ func TestNew(t *testing.T) {
 		WithTemplate(template.New("a test")),
 	)
 }
+
+type mockCacheControlDatabase struct {
+	persistence.Service
+}
+
+func (m *mockCacheControlDatabase) GetAccount(string, bool, bool, string) (persistence.AccountResult, error) {
+	return persistence.AccountResult{AccountID: "account-a"}, nil
+}
+
+func TestNew_CacheControl(t *testing.T) {
+	handler := New(
+		WithDatabase(&mockCacheControlDatabase{}),
+		WithConfig(&config.Config{}),
+		WithTemplate(template.New("a test")),
+	)
+	tests := []struct {
+		name                 string
+		path                 string
+		expectedCacheControl string
+	}{
+		{
+			"user specific data",
+			"/api/events",
+			"no-store",
+		},
+		{
+			"public key",
+			"/api/exchange?accountId=account-a",
+			"public, max-age=3600",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, test.path, nil)
+			handler.ServeHTTP(w, r)
+			if found := w.Header().Get("Cache-Control"); found != test.expectedCacheControl {
+				t.Errorf("Expected Cache-Control %s, got %s", test.expectedCacheControl, found)
+			}
+		})
+	}
+}
+
+func TestNew_MethodNotAllowed(t *testing.T) {
+	handler := New(
+		WithDatabase(&mockCacheControlDatabase{}),
+		WithConfig(&config.Config{}),
+		WithTemplate(template.New("a test")),
+	)
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expectedAllow  string
+	}{
+		{
+			"static path",
+			http.MethodDelete,
+			"/api/login",
+			http.StatusMethodNotAllowed,
+			"GET, POST",
+		},
+		{
+			"parameterized path",
+			http.MethodPost,
+			"/api/accounts/account-a",
+			http.StatusMethodNotAllowed,
+			"DELETE, GET",
+		},
+		{
+			"versioned path",
+			http.MethodPut,
+			"/api/v1/login",
+			http.StatusMethodNotAllowed,
+			"GET, POST",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, test.path, nil)
+			handler.ServeHTTP(w, r)
+			if w.Code != test.expectedStatus {
+				t.Errorf("Expected status %d, got %d", test.expectedStatus, w.Code)
+			}
+			if found := w.Header().Get("Allow"); found != test.expectedAllow {
+				t.Errorf("Expected Allow %s, got %s", test.expectedAllow, found)
+			}
+		})
+	}
+}
+
+func TestWithCookieSecretFromPassphrase(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		a, b := router{}, router{}
+		WithCookieSecretFromPassphrase([]byte("passphrase"), []byte("salt"))(&a)
+		WithCookieSecretFromPassphrase([]byte("passphrase"), []byte("salt"))(&b)
+		if len(a.cookieSecret) != cookieSigningKeySize {
+			t.Fatalf("Unexpected key length %d", len(a.cookieSecret))
+		}
+
+		encoded, err := securecookie.New(a.cookieSecret, nil).Encode("auth", "user-a")
+		if err != nil {
+			t.Fatalf("Unexpected error encoding value %v", err)
+		}
+		var decoded string
+		if err := securecookie.New(b.cookieSecret, nil).Decode("auth", encoded, &decoded); err != nil {
+			t.Errorf("Unexpected error decoding value %v", err)
+		}
+		if decoded != "user-a" {
+			t.Errorf("Unexpected value %v", decoded)
+		}
+	})
+	t.Run("empty passphrase", func(t *testing.T) {
+		rt := router{}
+		WithCookieSecretFromPassphrase(nil, []byte("salt"))(&rt)
+		if rt.cookieSecret != nil {
+			t.Errorf("Expected no cookie secret, got %v", rt.cookieSecret)
+		}
+	})
+}
+
+func TestWithCookieSameSite(t *testing.T) {
+	tests := []struct {
+		name             string
+		mode             http.SameSite
+		secure           bool
+		expectedUserMode http.SameSite
+		expectedAuthMode http.SameSite
+	}{
+		{"default secure", 0, true, http.SameSiteNoneMode, http.SameSiteLaxMode},
+		{"default insecure", 0, false, http.SameSiteLaxMode, http.SameSiteLaxMode},
+		{"strict", http.SameSiteStrictMode, true, http.SameSiteStrictMode, http.SameSiteStrictMode},
+		{"none secure", http.SameSiteNoneMode, true, http.SameSiteNoneMode, http.SameSiteNoneMode},
+		{"none insecure", http.SameSiteNoneMode, false, http.SameSiteLaxMode, http.SameSiteLaxMode},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := router{}
+			WithCookieSameSite(test.mode)(&rt)
+			if mode := rt.userCookie("user-a", config.EventRetention, test.secure).SameSite; mode != test.expectedUserMode {
+				t.Errorf("Expected user cookie to use %v, got %v", test.expectedUserMode, mode)
+			}
+			authCookie, err := rt.authCookie("", test.secure)
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if authCookie.SameSite != test.expectedAuthMode {
+				t.Errorf("Expected auth cookie to use %v, got %v", test.expectedAuthMode, authCookie.SameSite)
+			}
+		})
+	}
+}
+
+func TestWithCookieDomain(t *testing.T) {
+	rt := router{}
+	WithCookieDomain("example.com")(&rt)
+	if domain := rt.userCookie("user-a", config.EventRetention, true).Domain; domain != "example.com" {
+		t.Errorf("Unexpected user cookie domain %v", domain)
+	}
+	authCookie, err := rt.authCookie("", true)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if authCookie.Domain != "example.com" {
+		t.Errorf("Unexpected auth cookie domain %v", authCookie.Domain)
+	}
+
+	if domain := (&router{}).userCookie("user-a", config.EventRetention, true).Domain; domain != "" {
+		t.Errorf("Expected host only cookie by default, got domain %v", domain)
+	}
+}
+
+func TestWithHostCookiePrefix(t *testing.T) {
+	rt := router{authSigner: securecookie.New([]byte("abc"), nil)}
+	WithHostCookiePrefix(true)(&rt)
+
+	for _, sessionID := range []string{"session-a", ""} {
+		authCookie, err := rt.authCookie(sessionID, false)
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if authCookie.Name != "__Host-auth" {
+			t.Errorf("Unexpected cookie name %v", authCookie.Name)
+		}
+		if !authCookie.Secure || authCookie.Path != "/" || authCookie.Domain != "" {
+			t.Errorf("Unexpected cookie attributes %v", authCookie)
+		}
+	}
+
+	rt.db = &mockUserLookupDatabase{}
+	m := gin.New()
+	m.GET("/", rt.accountUserMiddleware(rt.authCookieName(), contextKeyAuth), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	token, _ := rt.authSigner.Encode(authKey, "session-id-1")
+	for name, expected := range map[string]int{"__Host-auth": http.StatusOK, "auth": http.StatusUnauthorized} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: name, Value: token})
+		m.ServeHTTP(w, r)
+		if w.Code != expected {
+			t.Errorf("Expected status %d for cookie %s, got %d", expected, name, w.Code)
+		}
+	}
+}
+
+func TestNew_HostCookiePrefix(t *testing.T) {
+	tests := []struct {
+		name         string
+		development  bool
+		domain       string
+		expectedName string
+	}{
+		{"ok", false, "", "__Host-auth"},
+		{"development", true, "", "auth"},
+		{"cookie domain", false, "example.com", "auth"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &config.Config{Secret: config.Bytes("abc")}
+			cfg.App.Development = test.development
+			m := New(
+				WithConfig(cfg),
+				WithDatabase(&mockPostLoginDatabase{result: persistence.LoginResult{AccountUserID: "user-a"}}),
+				WithHostCookiePrefix(true),
+				WithCookieDomain(test.domain),
+				WithTemplate(template.New("index")),
+			)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(`{"username":"mail@offen.dev","password":"secret!"}`))
+			m.ServeHTTP(w, r)
+			cookies := w.Result().Cookies()
+			if len(cookies) != 1 || cookies[0].Name != test.expectedName {
+				t.Errorf("Expected cookie named %s, got %v", test.expectedName, cookies)
+			}
+		})
+	}
+}
+
+func TestNew_Gzip(t *testing.T) {
+	fs := http.FS(fstest.MapFS{
+		"script.js": &fstest.MapFile{Data: []byte(strings.Repeat("console.log('offen');\n", 200))},
+	})
+	cfg := &config.Config{}
+	cfg.Server.ReverseProxy = true
+	tests := []struct {
+		name             string
+		gzip             bool
+		path             string
+		expectedEncoding string
+	}{
+		{"enabled", true, "/script.js", "gzip"},
+		{"small response", true, "/healthz", ""},
+		{"disabled", false, "/script.js", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := New(
+				WithDatabase(&mockDatabase{}),
+				WithConfig(cfg),
+				WithTemplate(template.New("a test")),
+				WithFS(fs),
+				WithGzip(test.gzip),
+			)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, test.path, nil)
+			r.Header.Set("Accept-Encoding", "gzip")
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Errorf("Unexpected status code %d", w.Code)
+			}
+			if found := w.Header().Get("Content-Encoding"); found != test.expectedEncoding {
+				t.Errorf("Expected Content-Encoding %q, got %q", test.expectedEncoding, found)
+			}
+			if test.gzip && w.Header().Get("Vary") != "Accept-Encoding" {
+				t.Errorf("Expected Vary header, got %q", w.Header().Get("Vary"))
+			}
+		})
+	}
+}
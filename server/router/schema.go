@@ -0,0 +1,138 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+)
+
+func eventSchemaCacheKey(accountID string) string {
+	return fmt.Sprintf("event-schema-%s", accountID)
+}
+
+// lookupEventSchemaVersion returns the current version of the event schema
+// for the given account, caching results the same way account settings are.
+func (rt *router) lookupEventSchemaVersion(accountID string) (int, error) {
+	cache, cacheKey := rt.getCache(), eventSchemaCacheKey(accountID)
+	if cachedItem, ok := cache.Get(cacheKey); ok {
+		if version, castOk := cachedItem.(int); castOk {
+			return version, nil
+		}
+	}
+
+	schema, err := rt.db.GetEventSchema(accountID)
+	if err != nil {
+		return 0, err
+	}
+	cache.Set(cacheKey, schema.Version, time.Minute)
+	return schema.Version, nil
+}
+
+func (rt *router) getEventSchema(c *gin.Context) {
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+
+	accountID := c.Param("accountID")
+	if ok := accountUser.CanAccessAccount(accountID); !ok {
+		newJSONError(
+			fmt.Errorf("router: account user does not have permissions to access account %s", accountID),
+			http.StatusForbidden,
+		).Pipe(c)
+		return
+	}
+
+	schema, err := rt.db.GetEventSchema(accountID)
+	if err != nil {
+		accountError(c, accountID, fmt.Errorf("router: error looking up event schema: %w", err))
+		return
+	}
+	if schema.Version == 0 {
+		newJSONError(
+			fmt.Errorf("router: no event schema stored for account %s", accountID),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+	c.JSON(http.StatusOK, schema)
+}
+
+type eventSchemaRequest struct {
+	Schema json.RawMessage `json:"schema"`
+}
+
+func (rt *router) putEventSchema(c *gin.Context) {
+	var req eventSchemaRequest
+	if err := c.BindJSON(&req); err != nil {
+		newJSONError(
+			fmt.Errorf("router: error decoding request payload: %w", err),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+
+	accountID := c.Param("accountID")
+	if ok := accountUser.CanAccessAccount(accountID) && accountUser.IsSuperAdmin(); !ok {
+		newJSONError(
+			fmt.Errorf("router: account user does not have permissions to update event schema of account %s", accountID),
+			http.StatusForbidden,
+		).Pipe(c)
+		return
+	}
+
+	var schema map[string]interface{}
+	if err := json.Unmarshal(req.Schema, &schema); err != nil || schema == nil {
+		newJSONError(
+			errors.New("router: event schema is expected to be a JSON object"),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+
+	result, err := rt.db.UpdateEventSchema(accountID, req.Schema)
+	if err != nil {
+		accountError(c, accountID, fmt.Errorf("router: error updating event schema: %w", err))
+		return
+	}
+	rt.getCache().Delete(eventSchemaCacheKey(accountID))
+	c.JSON(http.StatusOK, result)
+}
+
+// accountError responds with 404 in case the given error is caused by the
+// account not being known, and with 500 otherwise.
+func accountError(c *gin.Context, accountID string, err error) {
+	var errUnknown persistence.ErrUnknownAccount
+	if errors.As(err, &errUnknown) {
+		newJSONError(
+			fmt.Errorf("router: account %s not found", accountID),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+	newJSONError(
+		err,
+		http.StatusInternalServerError,
+	).Pipe(c)
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/config"
+	"github.com/offen/offen/server/persistence"
+	"github.com/offen/offen/server/ratelimiter"
+)
+
+type mockEventSchemaService struct {
+	persistence.Service
+	schema   persistence.EventSchema
+	inserted int
+}
+
+func (m *mockEventSchemaService) GetEventSchema(string) (persistence.EventSchema, error) {
+	return m.schema, nil
+}
+
+func (m *mockEventSchemaService) UpdateEventSchema(accountID string, schema json.RawMessage) (persistence.EventSchema, error) {
+	m.schema = persistence.EventSchema{Version: m.schema.Version + 1, Schema: schema}
+	return m.schema, nil
+}
+
+func (m *mockEventSchemaService) GetAccountSettings(string) (persistence.AccountSettings, error) {
+	return persistence.AccountSettings{}, nil
+}
+
+func (m *mockEventSchemaService) GetIngestPause(string) (persistence.IngestPause, error) {
+	return persistence.IngestPause{}, nil
+}
+
+func (m *mockEventSchemaService) Insert(string, string, string, string, bool, *string) error {
+	m.inserted++
+	return nil
+}
+
+func (m *mockEventSchemaService) GetAccountRetention(string) (time.Duration, error) {
+	return 0, nil
+}
+
+func TestRouter_eventSchema(t *testing.T) {
+	db := &mockEventSchemaService{}
+	rt := router{db: db, config: &config.Config{}, limiter: ratelimiter.NewNoopRateLimiter()}
+	admin := persistence.LoginResult{
+		AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+		Accounts:   []persistence.LoginAccountResult{{AccountID: "account-a"}},
+	}
+	setUser := func(user interface{}) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Set(contextKeyAuth, user)
+		}
+	}
+
+	m := gin.New()
+	m.GET("/accounts/:accountID/schema", setUser(admin), rt.getEventSchema)
+	m.PUT("/accounts/:accountID/schema", setUser(admin), rt.putEventSchema)
+	m.PUT("/readonly/:accountID/schema", setUser(persistence.LoginResult{
+		Accounts: []persistence.LoginAccountResult{{AccountID: "account-a"}},
+	}), rt.putEventSchema)
+	m.POST("/events", func(c *gin.Context) {
+		c.Set(contextKeyCookie, "user-id")
+		c.Set(contextKeySecureContext, false)
+	}, rt.postEvents)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		m.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := do(http.MethodGet, "/accounts/account-a/schema", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 before storing a schema, got %d", w.Code)
+	}
+	if w := do(http.MethodPut, "/accounts/account-a/schema", `{"schema":"not-an-object"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid schema, got %d", w.Code)
+	}
+	if w := do(http.MethodPut, "/readonly/account-a/schema", `{"schema":{"type":"object"}}`); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for non admin, got %d", w.Code)
+	}
+
+	// an event declaring a schema version is validated against the cached
+	// version, which is invalidated by updating the schema
+	if w := do(http.MethodPost, "/events", `{"accountId":"account-a","payload":"x","schemaVersion":1}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for unknown schema version, got %d", w.Code)
+	}
+
+	if w := do(http.MethodPut, "/accounts/account-a/schema", `{"schema":{"type":"object"}}`); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 storing schema, got %d", w.Code)
+	}
+	w := do(http.MethodGet, "/accounts/account-a/schema", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 retrieving schema, got %d", w.Code)
+	}
+	if expected := `{"version":1,"schema":{"type":"object"}}`; w.Body.String() != expected {
+		t.Errorf("Expected body %s, got %s", expected, w.Body.String())
+	}
+
+	if w := do(http.MethodPost, "/events", `{"accountId":"account-a","payload":"x","schemaVersion":1}`); w.Code != http.StatusCreated {
+		t.Errorf("Expected 201 for current schema version, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/events", `{"accountId":"account-a","payload":"x","schemaVersion":2}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for stale schema version, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/events", `{"accountId":"account-a","payload":"x"}`); w.Code != http.StatusCreated {
+		t.Errorf("Expected 201 for event without schema version, got %d", w.Code)
+	}
+	if db.inserted != 2 {
+		t.Errorf("Expected 2 events to be inserted, got %d", db.inserted)
+	}
+}
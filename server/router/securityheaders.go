@@ -0,0 +1,81 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// vaultPath is the path of the vault, which is embedded in an iframe on
+	// the pages using Offen and can therefore not deny framing.
+	vaultPath = "/vault"
+	// frameAncestorSelf allows pages served by Offen itself to embed the
+	// vault.
+	frameAncestorSelf = "'self'"
+)
+
+// WithFrameAncestors restricts the sites that are allowed to embed the vault
+// to the given origins by adding a frame-ancestors directive to its
+// Content-Security-Policy. Each origin is expected to be an absolute http(s)
+// URL or 'self'. By default, the vault can be embedded by any site. All other
+// pages cannot be embedded at all.
+func WithFrameAncestors(origins []string) Config {
+	return func(r *router) {
+		r.frameAncestors = origins
+	}
+}
+
+// frameAncestorsDirective normalizes the given origins into a CSP
+// frame-ancestors directive. Origins that cannot be used are skipped and
+// reported in the returned error. In case no origin can be used, an empty
+// directive is returned.
+func frameAncestorsDirective(origins []string) (string, error) {
+	var sources []string
+	var errs []string
+	for _, origin := range origins {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+		if origin == frameAncestorSelf {
+			sources = append(sources, origin)
+			continue
+		}
+		normalized, err := normalizeOrigin(origin)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		sources = append(sources, normalized)
+	}
+	var directive string
+	if len(sources) != 0 {
+		directive = "frame-ancestors " + strings.Join(sources, " ")
+	}
+	if len(errs) != 0 {
+		return directive, fmt.Errorf("router: skipped invalid frame ancestors: %s", strings.Join(errs, "; "))
+	}
+	return directive, nil
+}
+
+// securityHeadersMiddleware sets the security related headers that apply
+// to all responses. Strict-Transport-Security is only sent in secure
+// contexts. Framing is denied for all paths but the vault.
+func securityHeadersMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Header("Referrer-Policy", "no-referrer")
+		if c.GetBool(contextKeySecureContext) {
+			c.Header("Strict-Transport-Security", defaultSTS)
+		}
+		if c.Request.URL.Path != vaultPath {
+			c.Header("X-Frame-Options", "DENY")
+		}
+		c.Next()
+	}
+}
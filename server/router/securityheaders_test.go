@@ -0,0 +1,115 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/offen/offen/server/config"
+)
+
+func TestFrameAncestorsDirective(t *testing.T) {
+	tests := []struct {
+		name              string
+		origins           []string
+		expectedDirective string
+		expectError       bool
+	}{
+		{"empty", nil, "", false},
+		{"ok", []string{"'self'", " https://www.example.com/path", "http://blog.example.com:8080"}, "frame-ancestors 'self' https://www.example.com http://blog.example.com:8080", false},
+		{"invalid", []string{"https://www.example.com", "www.example.net; script-src *"}, "frame-ancestors https://www.example.com", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			directive, err := frameAncestorsDirective(test.origins)
+			if (err != nil) != test.expectError {
+				t.Errorf("Unexpected error value %v", err)
+			}
+			if directive != test.expectedDirective {
+				t.Errorf("Expected %s, got %s", test.expectedDirective, directive)
+			}
+		})
+	}
+}
+
+func TestNew_SecurityHeaders(t *testing.T) {
+	tests := []struct {
+		name           string
+		development    bool
+		frameAncestors []string
+		path           string
+		expected       map[string]string
+	}{
+		{
+			"api",
+			false,
+			nil,
+			"/versionz",
+			map[string]string{
+				"X-Content-Type-Options":    "nosniff",
+				"Referrer-Policy":           "no-referrer",
+				"Strict-Transport-Security": defaultSTS,
+				"X-Frame-Options":           "DENY",
+			},
+		},
+		{
+			"insecure context",
+			true,
+			nil,
+			"/versionz",
+			map[string]string{
+				"X-Content-Type-Options":    "nosniff",
+				"Strict-Transport-Security": "",
+				"X-Frame-Options":           "DENY",
+			},
+		},
+		{
+			"vault",
+			false,
+			nil,
+			"/vault",
+			map[string]string{
+				"X-Content-Type-Options":  "nosniff",
+				"X-Frame-Options":         "",
+				"Content-Security-Policy": defaultCSP,
+			},
+		},
+		{
+			"vault with frame ancestors",
+			false,
+			[]string{"https://www.example.com"},
+			"/vault",
+			map[string]string{
+				"X-Frame-Options":         "",
+				"Content-Security-Policy": defaultCSP + "; frame-ancestors https://www.example.com",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.App.Development = test.development
+			handler := New(
+				WithDatabase(&mockDatabase{}),
+				WithConfig(cfg),
+				WithTemplate(template.Must(template.New("vault").Parse("vault"))),
+				WithFrameAncestors(test.frameAncestors),
+			)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, test.path, nil)
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Errorf("Unexpected status code %v", w.Code)
+			}
+			for key, value := range test.expected {
+				if header := w.Header().Get(key); header != value {
+					t.Errorf("Expected header %s to be %q, got %q", key, value, header)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// defaultShutdownGracePeriod is the time active requests are given to
+// complete on shutdown in case no grace period has been configured.
+const defaultShutdownGracePeriod = time.Second * 5
+
+// Closer is implemented by resources that need to be released after the
+// server has stopped handling requests, e.g. persistence.Service.
+type Closer interface {
+	Close() error
+}
+
+// Server owns the http.Server that is serving the application and takes
+// care of draining active requests before shutting down.
+type Server struct {
+	srv         *http.Server
+	listener    net.Listener
+	certFile    string
+	keyFile     string
+	certs       *certReloader
+	onReload    func(error)
+	gracePeriod time.Duration
+	db          Closer
+	hooks       []func()
+	// http.Server does not keep track of hijacked connections, e.g. the
+	// ones used for WebSockets, so the server does this itself in order to
+	// close them on shutdown.
+	hijackedLock sync.Mutex
+	hijacked     map[net.Conn]struct{}
+	handlers     sync.WaitGroup
+}
+
+type connContextKey struct{}
+
+// ServerConfig adds a configuration value to the server
+type ServerConfig func(*Server)
+
+// WithShutdownGracePeriod sets the time active requests are given to
+// complete when the server is shut down. Requests that are still running
+// after this period has passed are cut off.
+func WithShutdownGracePeriod(d time.Duration) ServerConfig {
+	return func(s *Server) {
+		if d > 0 {
+			s.gracePeriod = d
+		}
+	}
+}
+
+// WithServerDatabase sets the database that is closed after all active
+// requests have completed.
+func WithServerDatabase(db Closer) ServerConfig {
+	return func(s *Server) {
+		s.db = db
+	}
+}
+
+// WithServerTLS makes the server use the given certificate and key files
+// for serving requests via TLS.
+func WithServerTLS(certFile, keyFile string) ServerConfig {
+	return func(s *Server) {
+		s.certFile = certFile
+		s.keyFile = keyFile
+	}
+}
+
+// WithServerCertReload makes the server re-read the certificate and key files
+// passed to WithServerTLS when SIGHUP is received, e.g. after a certificate
+// has been renewed. The given function is called with the result of each
+// reload. In case reloading fails, the previous certificate continues to be
+// used. This has no effect unless WithServerTLS is used too.
+func WithServerCertReload(fn func(error)) ServerConfig {
+	return func(s *Server) {
+		s.onReload = fn
+	}
+}
+
+// WithServerListener makes the server accept connections from the given
+// listener instead of listening on its address.
+func WithServerListener(l net.Listener) ServerConfig {
+	return func(s *Server) {
+		s.listener = l
+	}
+}
+
+// WithShutdownHook registers a function that is called after all active
+// requests have completed and before the database is closed. This can be
+// used for stopping background jobs that access the database.
+func WithShutdownHook(fn func()) ServerConfig {
+	return func(s *Server) {
+		s.hooks = append(s.hooks, fn)
+	}
+}
+
+// NewServer creates a server that serves the given handler on the given
+// address.
+func NewServer(addr string, handler http.Handler, configs ...ServerConfig) *Server {
+	s := &Server{
+		gracePeriod: defaultShutdownGracePeriod,
+		hijacked:    map[net.Conn]struct{}{},
+	}
+	s.srv = &http.Server{
+		Addr:    addr,
+		Handler: s.trackHandler(handler),
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, connContextKey{}, c)
+		},
+		ConnState: s.trackConnState,
+	}
+	for _, cfg := range configs {
+		cfg(s)
+	}
+	if s.onReload != nil && s.certFile != "" {
+		s.certs = newCertReloader(s.certFile, s.keyFile)
+	}
+	return s
+}
+
+// Start serves requests until the server is shut down. Contrary to the
+// methods of http.Server, it does not return an error when being shut down.
+func (s *Server) Start() error {
+	certFile, keyFile := s.certFile, s.keyFile
+	if s.certs != nil {
+		if err := s.certs.reload(); err != nil {
+			return err
+		}
+		s.srv.TLSConfig = &tls.Config{GetCertificate: s.certs.getCertificate}
+		// certificates are provided by the callback instead
+		certFile, keyFile = "", ""
+	}
+
+	var err error
+	switch {
+	case s.listener != nil && s.certFile != "":
+		err = s.srv.ServeTLS(s.listener, certFile, keyFile)
+	case s.listener != nil:
+		err = s.srv.Serve(s.listener)
+	case s.certFile != "":
+		err = s.srv.ListenAndServeTLS(certFile, keyFile)
+	default:
+		err = s.srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("router: error serving requests: %w", err)
+	}
+	return nil
+}
+
+// trackHandler wraps the given handler so that the server knows about all
+// handlers that are still running, including the ones that have hijacked
+// their connection.
+func (s *Server) trackHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handlers.Add(1)
+		defer s.handlers.Done()
+		defer func() {
+			if conn, ok := r.Context().Value(connContextKey{}).(net.Conn); ok {
+				s.hijackedLock.Lock()
+				delete(s.hijacked, conn)
+				s.hijackedLock.Unlock()
+			}
+		}()
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) trackConnState(conn net.Conn, state http.ConnState) {
+	if state != http.StateHijacked {
+		return
+	}
+	s.hijackedLock.Lock()
+	defer s.hijackedLock.Unlock()
+	s.hijacked[conn] = struct{}{}
+}
+
+// closeHijacked closes all hijacked connections whose handlers are still
+// running. Handlers are expected to return once their connection fails.
+func (s *Server) closeHijacked() {
+	s.hijackedLock.Lock()
+	defer s.hijackedLock.Unlock()
+	for conn := range s.hijacked {
+		conn.Close()
+		delete(s.hijacked, conn)
+	}
+}
+
+// Shutdown stops accepting new connections and waits for active requests
+// to complete until the given context is done. Hijacked connections, which
+// are not handled by http.Server, are closed and their handlers are given
+// the remaining time to return. Afterwards, shutdown hooks are called and
+// the database is closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	shutdownErr := s.srv.Shutdown(ctx)
+	s.closeHijacked()
+	returned := make(chan struct{})
+	go func() {
+		s.handlers.Wait()
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-ctx.Done():
+		if shutdownErr == nil {
+			shutdownErr = ctx.Err()
+		}
+	}
+	for _, hook := range s.hooks {
+		hook()
+	}
+	if s.db != nil {
+		if err := s.db.Close(); err != nil {
+			return fmt.Errorf("router: error closing database: %w", err)
+		}
+	}
+	if shutdownErr != nil {
+		return fmt.Errorf("router: error draining active requests: %w", shutdownErr)
+	}
+	return nil
+}
+
+// Run starts the server and blocks until SIGINT or SIGTERM is received or
+// the given context is canceled. The server is then shut down, giving active
+// requests the configured grace period to complete. In case certificate
+// reloading is enabled, receiving SIGHUP makes the running server re-read its
+// certificate and key files without interrupting active connections.
+func (s *Server) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	startErr := make(chan error, 1)
+	go func() {
+		startErr <- s.Start()
+	}()
+
+	if s.certs != nil {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+		go func() {
+			for {
+				select {
+				case <-hup:
+					s.onReload(s.certs.reload())
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	select {
+	case err := <-startErr:
+		if err != nil {
+			// The server never started, but hooks and database are expected
+			// to be released nonetheless.
+			s.Shutdown(context.Background())
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.gracePeriod)
+	defer cancel()
+	return s.Shutdown(shutdownCtx)
+}
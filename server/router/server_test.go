@@ -0,0 +1,167 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockCloser struct {
+	sync.Mutex
+	closed bool
+}
+
+func (m *mockCloser) Close() error {
+	m.Lock()
+	defer m.Unlock()
+	m.closed = true
+	return nil
+}
+
+func (m *mockCloser) isClosed() bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.closed
+}
+
+func TestServer_Run(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error creating listener: %v", err)
+	}
+
+	db := &mockCloser{}
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(time.Millisecond * 100)
+		if db.isClosed() {
+			t.Error("Expected database to be open while handling request")
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	var hookCalled bool
+	srv := NewServer("", handler,
+		WithServerListener(l),
+		WithServerDatabase(db),
+		WithShutdownGracePeriod(time.Second),
+		WithShutdownHook(func() {
+			if db.isClosed() {
+				t.Error("Expected hook to be called before closing the database")
+			}
+			hookCalled = true
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- srv.Run(ctx)
+	}()
+
+	status := make(chan int)
+	go func() {
+		res, err := http.Post("http://"+l.Addr().String()+"/api/events", "application/json", nil)
+		if err != nil {
+			t.Errorf("Unexpected error performing request: %v", err)
+			status <- 0
+			return
+		}
+		res.Body.Close()
+		status <- res.StatusCode
+	}()
+
+	<-started
+	cancel()
+
+	if code := <-status; code != http.StatusCreated {
+		t.Errorf("Expected in-flight request to complete, got status %d", code)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if !hookCalled {
+		t.Error("Expected shutdown hook to be called")
+	}
+	if !db.isClosed() {
+		t.Error("Expected database to be closed")
+	}
+}
+
+func TestServer_Run_StartError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error creating listener: %v", err)
+	}
+	defer l.Close()
+
+	db := &mockCloser{}
+	srv := NewServer(l.Addr().String(), http.NotFoundHandler(), WithServerDatabase(db))
+	if err := srv.Run(context.Background()); err == nil {
+		t.Error("Expected error when address is in use")
+	}
+	if !db.isClosed() {
+		t.Error("Expected database to be closed")
+	}
+}
+
+func TestServer_Shutdown_Hijacked(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error creating listener: %v", err)
+	}
+
+	db := &mockCloser{}
+	started := make(chan struct{})
+	returned := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Unexpected error hijacking connection: %v", err)
+			return
+		}
+		defer close(returned)
+		defer conn.Close()
+		close(started)
+		// block like a WebSocket handler waiting for the next message
+		conn.Read(make([]byte, 1))
+		time.Sleep(time.Millisecond * 50)
+		if db.isClosed() {
+			t.Error("Expected database to be open while handler is running")
+		}
+	})
+
+	srv := NewServer("", handler, WithServerListener(l), WithServerDatabase(db))
+	go srv.Start()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Unexpected error dialing: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("GET /api/events/ws HTTP/1.1\r\nHost: localhost\r\n\r\n")); err != nil {
+		t.Fatalf("Unexpected error writing request: %v", err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	select {
+	case <-returned:
+	default:
+		t.Error("Expected handler to have returned on shutdown")
+	}
+	if !db.isClosed() {
+		t.Error("Expected database to be closed")
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+)
+
+func accountSettingsCacheKey(accountID string) string {
+	return fmt.Sprintf("account-settings-%s", accountID)
+}
+
+// lookupAccountSettings returns the settings for the given account. As this
+// is called on hot paths like event ingestion, results are cached for a short
+// amount of time. Updating the settings through the API invalidates the cache.
+func (rt *router) lookupAccountSettings(accountID string) (persistence.AccountSettings, error) {
+	cache, cacheKey := rt.getCache(), accountSettingsCacheKey(accountID)
+	if cachedItem, ok := cache.Get(cacheKey); ok {
+		if settings, castOk := cachedItem.(persistence.AccountSettings); castOk {
+			return settings, nil
+		}
+	}
+
+	settings, err := rt.db.GetAccountSettings(accountID)
+	if err != nil {
+		return persistence.AccountSettings{}, err
+	}
+	cache.Set(cacheKey, settings, time.Minute)
+	return settings, nil
+}
+
+// resolveAccountID returns the configured fallback account in case the given
+// account id does not belong to an active account. In all other cases, the
+// given id is returned.
+func (rt *router) resolveAccountID(accountID string) string {
+	if rt.fallbackAccount == "" || accountID == rt.fallbackAccount {
+		return accountID
+	}
+	if _, err := rt.lookupAccountSettings(accountID); err != nil {
+		var unknownAccountErr persistence.ErrUnknownAccount
+		if errors.As(err, &unknownAccountErr) {
+			return rt.fallbackAccount
+		}
+	}
+	return accountID
+}
+
+func (rt *router) getAccountSettings(c *gin.Context) {
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+
+	accountID := c.Param("accountID")
+	if ok := accountUser.CanAccessAccount(accountID); !ok {
+		newJSONError(
+			fmt.Errorf("router: account user does not have permissions to access account %s", accountID),
+			http.StatusForbidden,
+		).Pipe(c)
+		return
+	}
+
+	settings, err := rt.db.GetAccountSettings(accountID)
+	if err != nil {
+		var errUnknown persistence.ErrUnknownAccount
+		if errors.As(err, &errUnknown) {
+			newJSONError(
+				fmt.Errorf("router: account %s not found", accountID),
+				http.StatusNotFound,
+			).Pipe(c)
+			return
+		}
+		newJSONError(
+			fmt.Errorf("router: error looking up account settings: %w", err),
+			http.StatusInternalServerError,
+		).Pipe(c)
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+// putAccountSettings updates the settings of the given account. The request
+// payload is applied on top of the current settings, so fields that are left
+// out by the client keep their value.
+func (rt *router) putAccountSettings(c *gin.Context) {
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+
+	accountID := c.Param("accountID")
+	if ok := accountUser.CanAccessAccount(accountID) && accountUser.IsSuperAdmin(); !ok {
+		newJSONError(
+			fmt.Errorf("router: account user does not have permissions to update settings of account %s", accountID),
+			http.StatusForbidden,
+		).Pipe(c)
+		return
+	}
+
+	previous, err := rt.db.GetAccountSettings(accountID)
+	if err != nil {
+		var errUnknown persistence.ErrUnknownAccount
+		if errors.As(err, &errUnknown) {
+			newJSONError(
+				fmt.Errorf("router: account %s not found", accountID),
+				http.StatusNotFound,
+			).Pipe(c)
+			return
+		}
+		newJSONError(
+			fmt.Errorf("router: error looking up account settings: %w", err),
+			http.StatusInternalServerError,
+		).Pipe(c)
+		return
+	}
+
+	req := previous
+	// Slices are copied so decoding does not write to the ones of previous.
+	req.AllowedOrigins = append([]string(nil), previous.AllowedOrigins...)
+	req.AllowedCountries = append([]string(nil), previous.AllowedCountries...)
+	req.DeniedCountries = append([]string(nil), previous.DeniedCountries...)
+	if err := c.BindJSON(&req); err != nil {
+		newJSONError(
+			fmt.Errorf("router: error decoding request payload: %w", err),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+
+	if req.EmailSender != "" {
+		if _, err := mail.ParseAddress(req.EmailSender); err != nil {
+			newJSONError(
+				fmt.Errorf("router: invalid email sender %s: %w", req.EmailSender, err),
+				http.StatusBadRequest,
+			).Pipe(c)
+			return
+		}
+	}
+
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		newJSONError(
+			fmt.Errorf("router: invalid timezone %s: %w", req.Timezone, err),
+			http.StatusBadRequest,
+		).Pipe(c)
+		return
+	}
+
+	for i, origin := range req.AllowedOrigins {
+		normalized, err := normalizeOrigin(origin)
+		if err != nil {
+			newJSONError(
+				fmt.Errorf("router: invalid allowed origin: %w", err),
+				http.StatusBadRequest,
+			).Pipe(c)
+			return
+		}
+		req.AllowedOrigins[i] = normalized
+	}
+
+	for _, countries := range [][]string{req.AllowedCountries, req.DeniedCountries} {
+		for i, country := range countries {
+			normalized, err := normalizeCountryCode(country)
+			if err != nil {
+				newJSONError(
+					fmt.Errorf("router: invalid country: %w", err),
+					http.StatusBadRequest,
+				).Pipe(c)
+				return
+			}
+			countries[i] = normalized
+		}
+	}
+
+	if err := rt.db.UpdateAccountSettings(accountID, req); err != nil {
+		var errUnknown persistence.ErrUnknownAccount
+		if errors.As(err, &errUnknown) {
+			newJSONError(
+				fmt.Errorf("router: account %s not found", accountID),
+				http.StatusNotFound,
+			).Pipe(c)
+			return
+		}
+		newJSONError(
+			fmt.Errorf("router: error updating account settings: %w", err),
+			http.StatusInternalServerError,
+		).Pipe(c)
+		return
+	}
+	rt.getCache().Delete(accountSettingsCacheKey(accountID))
+	if req.ConsentExempt != previous.ConsentExempt && rt.logger != nil {
+		rt.logger.
+			WithField("accountID", accountID).
+			WithField("accountUserID", accountUser.AccountUserID).
+			WithField("enabled", req.ConsentExempt).
+			Warn("Updated consent exemption for account")
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,329 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+)
+
+type mockAccountSettingsDatabase struct {
+	persistence.Service
+	settings  persistence.AccountSettings
+	err       error
+	updateErr error
+	updatedTo *persistence.AccountSettings
+}
+
+func (m *mockAccountSettingsDatabase) GetAccountSettings(string) (persistence.AccountSettings, error) {
+	return m.settings, m.err
+}
+
+func (m *mockAccountSettingsDatabase) UpdateAccountSettings(accountID string, s persistence.AccountSettings) error {
+	m.updatedTo = &s
+	return m.updateErr
+}
+
+func TestRouter_getAccountSettings(t *testing.T) {
+	tests := []struct {
+		name               string
+		db                 *mockAccountSettingsDatabase
+		user               interface{}
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			"no user",
+			&mockAccountSettingsDatabase{},
+			nil,
+			http.StatusNotFound,
+			"",
+		},
+		{
+			"no access",
+			&mockAccountSettingsDatabase{},
+			persistence.LoginResult{
+				Accounts: []persistence.LoginAccountResult{{AccountID: "account-b"}},
+			},
+			http.StatusForbidden,
+			"",
+		},
+		{
+			"unknown account",
+			&mockAccountSettingsDatabase{
+				err: persistence.ErrUnknownAccount("did not work"),
+			},
+			persistence.LoginResult{
+				Accounts: []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			http.StatusNotFound,
+			"",
+		},
+		{
+			"database error",
+			&mockAccountSettingsDatabase{
+				err: errors.New("did not work"),
+			},
+			persistence.LoginResult{
+				Accounts: []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			http.StatusInternalServerError,
+			"",
+		},
+		{
+			"ok",
+			&mockAccountSettingsDatabase{
+				settings: persistence.AccountSettings{StrictEventDecoding: true},
+			},
+			persistence.LoginResult{
+				Accounts: []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			http.StatusOK,
+			`"strictEventDecoding":true`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := router{db: test.db}
+			m := gin.New()
+			m.GET("/:accountID", func(c *gin.Context) {
+				c.Set(contextKeyAuth, test.user)
+			}, rt.getAccountSettings)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/account-a", nil)
+			m.ServeHTTP(w, r)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("Unexpected status code %v", w.Code)
+			}
+			if !strings.Contains(w.Body.String(), test.expectedBody) {
+				t.Errorf("Expected response body %s to contain %s", w.Body.String(), test.expectedBody)
+			}
+		})
+	}
+}
+
+func TestRouter_putAccountSettings(t *testing.T) {
+	tests := []struct {
+		name               string
+		db                 *mockAccountSettingsDatabase
+		user               interface{}
+		body               string
+		expectedStatusCode int
+		expectUpdate       bool
+	}{
+		{
+			"bad payload",
+			&mockAccountSettingsDatabase{},
+			persistence.LoginResult{
+				AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+				Accounts:   []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			"{{",
+			http.StatusBadRequest,
+			false,
+		},
+		{
+			"no admin",
+			&mockAccountSettingsDatabase{},
+			persistence.LoginResult{
+				Accounts: []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			`{"strictEventDecoding":true}`,
+			http.StatusForbidden,
+			false,
+		},
+		{
+			"invalid email sender",
+			&mockAccountSettingsDatabase{},
+			persistence.LoginResult{
+				AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+				Accounts:   []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			`{"strictEventDecoding":true,"emailSender":"not an address"}`,
+			http.StatusBadRequest,
+			false,
+		},
+		{
+			"invalid timezone",
+			&mockAccountSettingsDatabase{},
+			persistence.LoginResult{
+				AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+				Accounts:   []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			`{"strictEventDecoding":true,"timezone":"Mars/Olympus_Mons"}`,
+			http.StatusBadRequest,
+			false,
+		},
+		{
+			"invalid allowed origin",
+			&mockAccountSettingsDatabase{},
+			persistence.LoginResult{
+				AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+				Accounts:   []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			`{"strictEventDecoding":true,"allowedOrigins":["example.net"]}`,
+			http.StatusBadRequest,
+			false,
+		},
+		{
+			"allowed origins",
+			&mockAccountSettingsDatabase{},
+			persistence.LoginResult{
+				AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+				Accounts:   []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			`{"strictEventDecoding":true,"allowedOrigins":["https://example.net/some/page"]}`,
+			http.StatusNoContent,
+			true,
+		},
+		{
+			"invalid country",
+			&mockAccountSettingsDatabase{},
+			persistence.LoginResult{
+				AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+				Accounts:   []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			`{"strictEventDecoding":true,"deniedCountries":["Germany"]}`,
+			http.StatusBadRequest,
+			false,
+		},
+		{
+			"countries",
+			&mockAccountSettingsDatabase{},
+			persistence.LoginResult{
+				AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+				Accounts:   []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			`{"strictEventDecoding":true,"allowedCountries":["de","AT"]}`,
+			http.StatusNoContent,
+			true,
+		},
+		{
+			"lookup error",
+			&mockAccountSettingsDatabase{
+				err: errors.New("did not work"),
+			},
+			persistence.LoginResult{
+				AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+				Accounts:   []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			`{"strictEventDecoding":true}`,
+			http.StatusInternalServerError,
+			false,
+		},
+		{
+			"unknown account",
+			&mockAccountSettingsDatabase{
+				err: persistence.ErrUnknownAccount("did not work"),
+			},
+			persistence.LoginResult{
+				AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+				Accounts:   []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			`{"strictEventDecoding":true}`,
+			http.StatusNotFound,
+			false,
+		},
+		{
+			"database error",
+			&mockAccountSettingsDatabase{
+				updateErr: errors.New("did not work"),
+			},
+			persistence.LoginResult{
+				AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+				Accounts:   []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			`{"strictEventDecoding":true}`,
+			http.StatusInternalServerError,
+			true,
+		},
+		{
+			"ok",
+			&mockAccountSettingsDatabase{},
+			persistence.LoginResult{
+				AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+				Accounts:   []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			`{"strictEventDecoding":true}`,
+			http.StatusNoContent,
+			true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := router{db: test.db}
+			m := gin.New()
+			m.PUT("/:accountID", func(c *gin.Context) {
+				c.Set(contextKeyAuth, test.user)
+			}, rt.putAccountSettings)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPut, "/account-a", strings.NewReader(test.body))
+			m.ServeHTTP(w, r)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("Unexpected status code %v", w.Code)
+			}
+			if (test.db.updatedTo != nil) != test.expectUpdate {
+				t.Errorf("Unexpected update %v", test.db.updatedTo)
+			}
+			if test.expectUpdate && !test.db.updatedTo.StrictEventDecoding {
+				t.Errorf("Unexpected settings %v", test.db.updatedTo)
+			}
+		})
+	}
+}
+
+func TestRouter_putAccountSettings_Partial(t *testing.T) {
+	db := &mockAccountSettingsDatabase{
+		settings: persistence.AccountSettings{
+			Timezone:         "Europe/Berlin",
+			AllowedOrigins:   []string{"https://example.net"},
+			DeniedCountries:  []string{"XX"},
+			ConsentExempt:    true,
+			EmailSender:      "offen@example.net",
+			AllowedCountries: []string{"DE"},
+		},
+	}
+	rt := router{db: db}
+	m := gin.New()
+	m.PUT("/:accountID", func(c *gin.Context) {
+		c.Set(contextKeyAuth, persistence.LoginResult{
+			AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+			Accounts:   []persistence.LoginAccountResult{{AccountID: "account-a"}},
+		})
+	}, rt.putAccountSettings)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPut, "/account-a", strings.NewReader(`{"strictEventDecoding":true,"allowedCountries":["at"]}`))
+	m.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Unexpected status code %v", w.Code)
+	}
+	expected := persistence.AccountSettings{
+		StrictEventDecoding: true,
+		Timezone:            "Europe/Berlin",
+		AllowedOrigins:      []string{"https://example.net"},
+		DeniedCountries:     []string{"XX"},
+		ConsentExempt:       true,
+		EmailSender:         "offen@example.net",
+		AllowedCountries:    []string{"AT"},
+	}
+	if !reflect.DeepEqual(*db.updatedTo, expected) {
+		t.Errorf("Unexpected settings %v", db.updatedTo)
+	}
+	if !reflect.DeepEqual(db.settings.AllowedCountries, []string{"DE"}) {
+		t.Errorf("Expected previous settings to be left untouched, got %v", db.settings.AllowedCountries)
+	}
+}
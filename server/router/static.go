@@ -17,7 +17,7 @@ import (
 )
 
 var (
-	defaultCSP             = "default-src 'self'; style-src 'self' 'unsafe-inline'; script-src 'self' 'unsafe-inline'; img-src 'self' data:"
+	defaultCSP             = "default-src 'self'; style-src 'self' 'unsafe-inline'; script-src 'self' 'unsafe-inline'; img-src 'self' data:; connect-src 'self'; object-src 'none'; base-uri 'self'; form-action 'self'"
 	defaultSTS             = "max-age=15768000"
 	revisionedJSRe         = regexp.MustCompile("-[0-9a-z]{10}\\.js$")
 	webfontRe              = regexp.MustCompile("\\.(woff|woff2|ttf)$")
@@ -25,9 +25,7 @@ var (
 	stylesheetRe           = regexp.MustCompile("\\.css$")
 	assetRe                = regexp.MustCompile("\\.svg$")
 	defaultResponseHeaders = map[string]string{
-		"Referrer-Policy":        "origin-when-cross-origin",
-		"X-Content-Type-Options": "no-sniff",
-		"X-XSS-Protection":       "1; mode=block",
+		"X-XSS-Protection": "1; mode=block",
 	}
 )
 
@@ -39,7 +37,7 @@ func muteRequest(r *http.Request) *http.Request {
 	}))
 }
 
-func staticMiddleware(fileServer, fallback http.Handler) gin.HandlerFunc {
+func staticMiddleware(fileServer, fallback http.Handler, policy string) gin.HandlerFunc {
 	tryStatic := func(method, url string) (int, string) {
 		r := httptest.NewRequest(method, url, nil)
 		w := httptest.NewRecorder()
@@ -48,7 +46,6 @@ func staticMiddleware(fileServer, fallback http.Handler) gin.HandlerFunc {
 	}
 
 	return func(c *gin.Context) {
-		secureContext := c.GetBool(contextKeySecureContext)
 		status, contentType := tryStatic(c.Request.Method, c.Request.URL.String())
 		// Right now, we manually trigger an error when trying to read a directory
 		// so we can skip the directory listings provided by the Go FileServer.
@@ -60,10 +57,7 @@ func staticMiddleware(fileServer, fallback http.Handler) gin.HandlerFunc {
 
 		if strings.HasPrefix(contentType, "text/html") {
 			c.Header("Cache-Control", "no-cache")
-			c.Header("Content-Security-Policy", defaultCSP)
-			if secureContext {
-				c.Header("Strict-Transport-Security", defaultSTS)
-			}
+			c.Header("Content-Security-Policy", policy)
 		}
 
 		switch uri := c.Request.URL.Path; {
@@ -74,9 +68,6 @@ func staticMiddleware(fileServer, fallback http.Handler) gin.HandlerFunc {
 			c.Header("Cache-Control", "no-cache")
 		case scriptRe.MatchString(uri):
 			c.Header("Cache-Control", "no-cache")
-			if secureContext {
-				c.Header("Strict-Transport-Security", defaultSTS)
-			}
 		}
 
 		for key, value := range defaultResponseHeaders {
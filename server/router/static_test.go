@@ -20,7 +20,7 @@ func TestStaticMiddleware(t *testing.T) {
 		w.Header().Set("Content-Type", "text/plain")
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("ok"))
-	}))
+	}), defaultCSP)
 
 	m.Use(middleware)
 
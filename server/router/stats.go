@@ -0,0 +1,68 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+)
+
+type accountStatsResponse struct {
+	StorageBytes int64          `json:"storageBytes"`
+	EventsPerDay map[string]int `json:"eventsPerDay"`
+}
+
+func (rt *router) getAccountStats(c *gin.Context) {
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+
+	accountID := c.Param("accountID")
+	if ok := accountUser.CanAccessAccount(accountID); !ok {
+		newJSONError(
+			fmt.Errorf("router: account user does not have permissions to access account %s", accountID),
+			http.StatusForbidden,
+		).Pipe(c)
+		return
+	}
+
+	size, err := rt.db.AccountStorageBytes(accountID)
+	if err != nil {
+		statsError(c, accountID, fmt.Errorf("router: error measuring account storage: %w", err))
+		return
+	}
+	eventsPerDay, err := rt.db.AccountEventsPerDay(accountID)
+	if err != nil {
+		statsError(c, accountID, fmt.Errorf("router: error counting events per day: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, accountStatsResponse{
+		StorageBytes: size,
+		EventsPerDay: eventsPerDay,
+	})
+}
+
+func statsError(c *gin.Context, accountID string, err error) {
+	var errUnknown persistence.ErrUnknownAccount
+	if errors.As(err, &errUnknown) {
+		newJSONError(
+			fmt.Errorf("router: account %s not found", accountID),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+	newJSONError(
+		err,
+		http.StatusInternalServerError,
+	).Pipe(c)
+}
@@ -0,0 +1,123 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+)
+
+type mockAccountStatsDatabase struct {
+	persistence.Service
+	size         int64
+	err          error
+	eventsPerDay map[string]int
+	eventsErr    error
+}
+
+func (m *mockAccountStatsDatabase) AccountStorageBytes(string) (int64, error) {
+	return m.size, m.err
+}
+
+func (m *mockAccountStatsDatabase) AccountEventsPerDay(string) (map[string]int, error) {
+	return m.eventsPerDay, m.eventsErr
+}
+
+func TestRouter_getAccountStats(t *testing.T) {
+	tests := []struct {
+		name               string
+		db                 *mockAccountStatsDatabase
+		user               interface{}
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			"no user",
+			&mockAccountStatsDatabase{},
+			nil,
+			http.StatusNotFound,
+			"",
+		},
+		{
+			"no access",
+			&mockAccountStatsDatabase{},
+			persistence.LoginResult{
+				Accounts: []persistence.LoginAccountResult{{AccountID: "account-b"}},
+			},
+			http.StatusForbidden,
+			"",
+		},
+		{
+			"unknown account",
+			&mockAccountStatsDatabase{
+				err: persistence.ErrUnknownAccount("did not work"),
+			},
+			persistence.LoginResult{
+				Accounts: []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			http.StatusNotFound,
+			"",
+		},
+		{
+			"database error",
+			&mockAccountStatsDatabase{
+				err: errors.New("did not work"),
+			},
+			persistence.LoginResult{
+				Accounts: []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			http.StatusInternalServerError,
+			"",
+		},
+		{
+			"error counting events",
+			&mockAccountStatsDatabase{
+				eventsErr: errors.New("did not work"),
+			},
+			persistence.LoginResult{
+				Accounts: []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			http.StatusInternalServerError,
+			"",
+		},
+		{
+			"ok",
+			&mockAccountStatsDatabase{
+				size:         1234,
+				eventsPerDay: map[string]int{"2022-03-01": 12},
+			},
+			persistence.LoginResult{
+				Accounts: []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			http.StatusOK,
+			`{"storageBytes":1234,"eventsPerDay":{"2022-03-01":12}}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := router{db: test.db}
+			m := gin.New()
+			m.GET("/:accountID", func(c *gin.Context) {
+				c.Set(contextKeyAuth, test.user)
+			}, rt.getAccountStats)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/account-a", nil)
+			m.ServeHTTP(w, r)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("Unexpected status code %v", w.Code)
+			}
+			if !strings.Contains(w.Body.String(), test.expectedBody) {
+				t.Errorf("Expected response body %s to contain %s", w.Body.String(), test.expectedBody)
+			}
+		})
+	}
+}
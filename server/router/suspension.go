@@ -0,0 +1,91 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/persistence"
+)
+
+const ingestSpikeWindow = time.Minute
+
+func ingestCountCacheKey(accountID string) string {
+	return fmt.Sprintf("ingest-count-%s", accountID)
+}
+
+func ingestSuspensionCacheKey(accountID string) string {
+	return fmt.Sprintf("ingest-suspended-%s", accountID)
+}
+
+// checkIngestSuspension counts an inbound event for the given account and
+// reports whether ingestion for the account is currently suspended. In case
+// the account receives more events in a single window than the configured
+// threshold, ingestion is suspended for the configured cooldown, as such a
+// spike is likely caused by a leaked account id being abused.
+func (rt *router) checkIngestSuspension(accountID string) (time.Duration, bool) {
+	threshold := rt.config.App.IngestSuspendThreshold
+	if threshold <= 0 {
+		return 0, false
+	}
+	cache := rt.getCache()
+	if _, until, ok := cache.GetWithExpiration(ingestSuspensionCacheKey(accountID)); ok {
+		return time.Until(until), true
+	}
+
+	countKey := ingestCountCacheKey(accountID)
+	if err := cache.Add(countKey, 1, ingestSpikeWindow); err == nil {
+		return 0, false
+	}
+	count, err := cache.IncrementInt(countKey, 1)
+	if err != nil {
+		// the window has expired in between calls
+		cache.Set(countKey, 1, ingestSpikeWindow)
+		return 0, false
+	}
+	if count <= threshold {
+		return 0, false
+	}
+
+	cooldown := rt.config.App.IngestSuspendCooldown
+	cache.Set(ingestSuspensionCacheKey(accountID), true, cooldown)
+	cache.Delete(countKey)
+	if rt.logger != nil {
+		rt.logger.
+			WithField("accountID", accountID).
+			WithField("threshold", threshold).
+			WithField("cooldown", cooldown.String()).
+			Error("Suspending event ingestion for account after receiving an unusual amount of events")
+	}
+	return cooldown, true
+}
+
+func (rt *router) deleteIngestSuspension(c *gin.Context) {
+	accountUser, ok := c.Value(contextKeyAuth).(persistence.LoginResult)
+	if !ok {
+		newJSONError(
+			errors.New("router: could not find account user object in request context"),
+			http.StatusNotFound,
+		).Pipe(c)
+		return
+	}
+
+	accountID := c.Param("accountID")
+	if ok := accountUser.CanAccessAccount(accountID) && accountUser.IsSuperAdmin(); !ok {
+		newJSONError(
+			fmt.Errorf("router: account user does not have permissions to lift suspension of account %s", accountID),
+			http.StatusForbidden,
+		).Pipe(c)
+		return
+	}
+
+	cache := rt.getCache()
+	cache.Delete(ingestSuspensionCacheKey(accountID))
+	cache.Delete(ingestCountCacheKey(accountID))
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/config"
+	"github.com/offen/offen/server/persistence"
+	"github.com/offen/offen/server/ratelimiter"
+)
+
+func TestRouter_postEvents_IngestSpike(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.App.IngestSuspendThreshold = 3
+	cfg.App.IngestSuspendCooldown = time.Hour
+	rt := router{
+		db:      &mockPostEventsService{},
+		config:  cfg,
+		limiter: ratelimiter.NewNoopRateLimiter(),
+	}
+	m := gin.New()
+	m.POST("/", func(c *gin.Context) {
+		c.Set(contextKeyCookie, "user-id")
+		c.Next()
+	}, rt.postEvents)
+	m.DELETE("/:accountID", func(c *gin.Context) {
+		c.Set(contextKeyAuth, persistence.LoginResult{
+			AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+			Accounts:   []persistence.LoginAccountResult{{AccountID: "account-a"}},
+		})
+	}, rt.deleteIngestSuspension)
+
+	post := func(accountID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(
+			http.MethodPost, "/",
+			strings.NewReader(`{"accountId":"`+accountID+`","payload":"some-payload"}`),
+		)
+		m.ServeHTTP(w, r)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		if w := post("account-a"); w.Code != http.StatusCreated {
+			t.Fatalf("Unexpected status code %d for event %d", w.Code, i)
+		}
+	}
+
+	w := post("account-a")
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected spike to suspend ingestion, got status code %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header to be set")
+	}
+	if w := post("account-a"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected account to stay suspended, got status code %d", w.Code)
+	}
+	if w := post("account-b"); w.Code != http.StatusCreated {
+		t.Errorf("Expected other accounts not to be affected, got status code %d", w.Code)
+	}
+
+	lift := httptest.NewRecorder()
+	m.ServeHTTP(lift, httptest.NewRequest(http.MethodDelete, "/account-a", nil))
+	if lift.Code != http.StatusNoContent {
+		t.Errorf("Unexpected status code %d lifting suspension", lift.Code)
+	}
+	if w := post("account-a"); w.Code != http.StatusCreated {
+		t.Errorf("Expected ingestion to resume, got status code %d", w.Code)
+	}
+}
+
+func TestRouter_deleteIngestSuspension(t *testing.T) {
+	tests := []struct {
+		name               string
+		user               interface{}
+		expectedStatusCode int
+	}{
+		{
+			"no user",
+			nil,
+			http.StatusNotFound,
+		},
+		{
+			"no admin",
+			persistence.LoginResult{
+				Accounts: []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			http.StatusForbidden,
+		},
+		{
+			"ok",
+			persistence.LoginResult{
+				AdminLevel: persistence.AccountUserAdminLevelSuperAdmin,
+				Accounts:   []persistence.LoginAccountResult{{AccountID: "account-a"}},
+			},
+			http.StatusNoContent,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := router{}
+			m := gin.New()
+			m.DELETE("/:accountID", func(c *gin.Context) {
+				c.Set(contextKeyAuth, test.user)
+			}, rt.deleteIngestSuspension)
+
+			w := httptest.NewRecorder()
+			m.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/account-a", nil))
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("Unexpected status code %v", w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type routeClass int
+
+const (
+	routeClassNone routeClass = iota
+	routeClassIngest
+	routeClassRead
+	routeClassAdmin
+	routeClassExport
+)
+
+// streamingRoutes are kept open for an arbitrary time and require access to
+// the underlying connection, so they are never subject to a timeout. The
+// writer passed by http.TimeoutHandler cannot be flushed or hijacked, and
+// would buffer the entire response until the handler returns.
+var streamingRoutes = []*regexp.Regexp{
+	regexp.MustCompile(`^/events/ws$`),
+	regexp.MustCompile(`^/admin/metrics/stream$`),
+	regexp.MustCompile(`^/accounts/[^/]+/export$`),
+}
+
+func isStreamingRoute(path string) bool {
+	for _, route := range streamingRoutes {
+		if route.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyRoute assigns a request to the class of routes whose timeout
+// applies. It refines the classification of classifyRequest, so only
+// requests in requestClassAPI are assigned a route class.
+func classifyRoute(r *http.Request) routeClass {
+	if classifyRequest(r.URL.Path) != requestClassAPI {
+		return routeClassNone
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/api")
+	if segments := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2); versionSegment.MatchString(segments[0]) {
+		if len(segments) == 1 {
+			return routeClassNone
+		}
+		path = "/" + segments[1]
+	}
+	path = strings.TrimSuffix(path, "/")
+
+	switch {
+	case isStreamingRoute(path):
+		return routeClassNone
+	case path == "/events" && r.Method == http.MethodPost:
+		return routeClassIngest
+	case strings.HasSuffix(path, "/export"):
+		return routeClassExport
+	case r.Method == http.MethodGet:
+		return routeClassRead
+	default:
+		return routeClassAdmin
+	}
+}
+
+// routeTimeoutHandler cuts off requests that take longer than the timeout
+// configured for their route class, responding with 503. A timeout of 0
+// disables cutting off requests of the respective class.
+func routeTimeoutHandler(h http.Handler, timeouts map[routeClass]time.Duration) http.Handler {
+	body, _ := json.Marshal(errorResponse{
+		Error:  "router: request timed out",
+		Status: http.StatusServiceUnavailable,
+	})
+	handlers := map[routeClass]http.Handler{}
+	for class, timeout := range timeouts {
+		if timeout > 0 {
+			handlers[class] = http.TimeoutHandler(h, timeout, string(body))
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handler, ok := handlers[classifyRoute(r)]; ok {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
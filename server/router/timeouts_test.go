@@ -0,0 +1,75 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClassifyRoute(t *testing.T) {
+	tests := []struct {
+		method        string
+		path          string
+		expectedClass routeClass
+	}{
+		{http.MethodPost, "/api/events", routeClassIngest},
+		{http.MethodPost, "/api/v1/events", routeClassIngest},
+		{http.MethodGet, "/api/events", routeClassRead},
+		{http.MethodGet, "/api/accounts/account-a", routeClassRead},
+		{http.MethodPut, "/api/accounts/account-a/settings", routeClassAdmin},
+		{http.MethodPost, "/api/login", routeClassAdmin},
+		{http.MethodGet, "/api/events/ws", routeClassNone},
+		{http.MethodGet, "/api/v1/admin/metrics/stream", routeClassNone},
+		{http.MethodGet, "/api/accounts/account-a/export", routeClassNone},
+		{http.MethodGet, "/api/user/export", routeClassExport},
+		{http.MethodGet, "/api/v1/user/export/", routeClassExport},
+		{http.MethodGet, "/vault", routeClassNone},
+		{http.MethodPost, "/apis/events", routeClassNone},
+		{http.MethodGet, "/auditorium/", routeClassNone},
+	}
+	for _, test := range tests {
+		t.Run(test.method+" "+test.path, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, test.path, nil)
+			if class := classifyRoute(r); class != test.expectedClass {
+				t.Errorf("Expected class %d, got %d", test.expectedClass, class)
+			}
+		})
+	}
+}
+
+func TestRouteTimeoutHandler(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := routeTimeoutHandler(slow, map[routeClass]time.Duration{
+		routeClassIngest: 10 * time.Millisecond,
+		routeClassRead:   time.Second,
+		routeClassExport: 10 * time.Millisecond,
+	})
+	tests := []struct {
+		name               string
+		method             string
+		path               string
+		expectedStatusCode int
+	}{
+		{"ingest is cut off", http.MethodPost, "/api/events", http.StatusServiceUnavailable},
+		{"read exceeds ingest timeout", http.MethodGet, "/api/events", http.StatusOK},
+		{"export is cut off", http.MethodGet, "/api/user/export", http.StatusServiceUnavailable},
+		{"no timeout configured", http.MethodPost, "/api/login", http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, test.path, nil)
+			handler.ServeHTTP(w, r)
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("Expected status code %d, got %d", test.expectedStatusCode, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxEventPayloadLength is the maximum length of the encrypted payload of a
+// single event.
+const maxEventPayloadLength = 64 << 10
+
+// fieldError describes why the value of a single field is invalid.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validationErrors collects all invalid fields of a payload, so clients can
+// fix all of them at once.
+type validationErrors []fieldError
+
+func (v validationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, err := range v {
+		messages[i] = fmt.Sprintf("%s: %s", err.Field, err.Message)
+	}
+	return fmt.Sprintf("router: invalid event payload: %s", strings.Join(messages, ", "))
+}
+
+// validateEventPayload checks the envelope of an inbound event before it is
+// processed any further. The payload itself is encrypted, so only its
+// length can be checked.
+func validateEventPayload(evt inboundEventPayload) validationErrors {
+	var errs validationErrors
+	if evt.AccountID == "" {
+		errs = append(errs, fieldError{"accountId", "is required"})
+	}
+	switch {
+	case evt.Payload == "":
+		errs = append(errs, fieldError{"payload", "is required"})
+	case len(evt.Payload) > maxEventPayloadLength:
+		errs = append(errs, fieldError{"payload", fmt.Sprintf("exceeds the maximum length of %d", maxEventPayloadLength)})
+	}
+	if evt.ContentHash != "" {
+		if decoded, err := hex.DecodeString(evt.ContentHash); err != nil || len(decoded) != 32 {
+			errs = append(errs, fieldError{"contentHash", "must be a hex encoded SHA-256 hash"})
+		}
+	}
+	if evt.SchemaVersion < 0 {
+		errs = append(errs, fieldError{"schemaVersion", "must not be negative"})
+	}
+	return errs
+}
+
+// newValidationError creates an error response listing all invalid fields.
+func newValidationError(errs validationErrors) *errorResponse {
+	resp := newJSONError(errs, http.StatusBadRequest)
+	resp.Fields = errs
+	return resp
+}
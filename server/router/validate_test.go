@@ -0,0 +1,99 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/config"
+	"github.com/offen/offen/server/ratelimiter"
+)
+
+func TestValidateEventPayload(t *testing.T) {
+	validHash := "ef5b8e0b6d2fd0ab2c1b1e89b0c73a3d9a6b8e9cba4bb3fda3f7e2e3d6a0d1e8"
+	tests := []struct {
+		name           string
+		payload        inboundEventPayload
+		expectedFields []string
+	}{
+		{
+			"ok",
+			inboundEventPayload{AccountID: "account-a", Payload: "payload", ContentHash: validHash, SchemaVersion: 2},
+			nil,
+		},
+		{
+			"empty",
+			inboundEventPayload{},
+			[]string{"accountId", "payload"},
+		},
+		{
+			"payload too long",
+			inboundEventPayload{AccountID: "account-a", Payload: strings.Repeat("x", maxEventPayloadLength+1)},
+			[]string{"payload"},
+		},
+		{
+			"bad content hash",
+			inboundEventPayload{AccountID: "account-a", Payload: "payload", ContentHash: "not-a-hash"},
+			[]string{"contentHash"},
+		},
+		{
+			"short content hash",
+			inboundEventPayload{AccountID: "account-a", Payload: "payload", ContentHash: "abcd"},
+			[]string{"contentHash"},
+		},
+		{
+			"negative schema version",
+			inboundEventPayload{AccountID: "account-a", Payload: "payload", SchemaVersion: -1},
+			[]string{"schemaVersion"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var fields []string
+			for _, err := range validateEventPayload(test.payload) {
+				fields = append(fields, err.Field)
+			}
+			if !reflect.DeepEqual(test.expectedFields, fields) {
+				t.Errorf("Expected invalid fields %v, got %v", test.expectedFields, fields)
+			}
+		})
+	}
+}
+
+func TestRouter_postEvents_Validation(t *testing.T) {
+	rt := router{
+		db:      &mockPostEventsService{},
+		config:  &config.Config{},
+		limiter: ratelimiter.NewNoopRateLimiter(),
+	}
+	m := gin.New()
+	m.POST("/", func(c *gin.Context) {
+		c.Set(contextKeyCookie, "user-id")
+		c.Next()
+	}, rt.postEvents)
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"contentHash":"xyz"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+	var response errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Unexpected error decoding response: %v", err)
+	}
+	expected := []fieldError{
+		{"accountId", "is required"},
+		{"payload", "is required"},
+		{"contentHash", "must be a hex encoded SHA-256 hash"},
+	}
+	if !reflect.DeepEqual(expected, response.Fields) {
+		t.Errorf("Unexpected field errors %v", response.Fields)
+	}
+}
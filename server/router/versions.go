@@ -0,0 +1,67 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// supportedAPIVersions lists the versions of the API that are served.
+// Unversioned routes are an alias for the first version in this list.
+var supportedAPIVersions = []string{"v1"}
+
+var versionSegment = regexp.MustCompile(`^v[0-9]+$`)
+
+type unsupportedVersionResponse struct {
+	errorResponse
+	SupportedVersions []string `json:"supportedVersions"`
+}
+
+// apiVersionMiddleware responds with 406 when a request to the API asks for
+// a version that is not supported, either by using a versioned path or by
+// sending an Accept-Version header. The response lists all supported versions.
+func apiVersionMiddleware(prefix string, supported []string) gin.HandlerFunc {
+	isSupported := func(version string) bool {
+		for _, s := range supported {
+			if s == version {
+				return true
+			}
+		}
+		return false
+	}
+	return func(c *gin.Context) {
+		if !strings.HasPrefix(c.Request.URL.Path, prefix+"/") {
+			c.Next()
+			return
+		}
+
+		var requested string
+		segment := strings.SplitN(strings.TrimPrefix(c.Request.URL.Path, prefix+"/"), "/", 2)[0]
+		if versionSegment.MatchString(segment) {
+			requested = segment
+		} else if header := c.GetHeader("Accept-Version"); header != "" {
+			requested = header
+			if !strings.HasPrefix(requested, "v") {
+				requested = "v" + requested
+			}
+		}
+
+		if requested != "" && !isSupported(requested) {
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, unsupportedVersionResponse{
+				errorResponse: errorResponse{
+					Error:  fmt.Sprintf("router: api version %s is not supported", requested),
+					Status: http.StatusNotAcceptable,
+				},
+				SupportedVersions: supported,
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/offen/offen/server/config"
+)
+
+func TestApiVersionMiddleware(t *testing.T) {
+	handler := New(
+		WithDatabase(&mockCacheControlDatabase{}),
+		WithConfig(&config.Config{}),
+		WithTemplate(template.New("a test")),
+	)
+	tests := []struct {
+		name           string
+		path           string
+		acceptVersion  string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			"unversioned",
+			"/api/exchange?accountId=account-a",
+			"",
+			http.StatusOK,
+			`"accountId":"account-a"`,
+		},
+		{
+			"supported path version",
+			"/api/v1/exchange?accountId=account-a",
+			"",
+			http.StatusOK,
+			`"accountId":"account-a"`,
+		},
+		{
+			"supported header version",
+			"/api/exchange?accountId=account-a",
+			"1",
+			http.StatusOK,
+			`"accountId":"account-a"`,
+		},
+		{
+			"unsupported path version",
+			"/api/v2/exchange?accountId=account-a",
+			"",
+			http.StatusNotAcceptable,
+			`"supportedVersions":["v1"]`,
+		},
+		{
+			"unsupported header version",
+			"/api/exchange?accountId=account-a",
+			"v3",
+			http.StatusNotAcceptable,
+			`"supportedVersions":["v1"]`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if test.acceptVersion != "" {
+				r.Header.Set("Accept-Version", test.acceptVersion)
+			}
+			handler.ServeHTTP(w, r)
+			if w.Code != test.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", test.expectedStatus, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), test.expectedBody) {
+				t.Errorf("Expected response body %s to contain %s", w.Body.String(), test.expectedBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+type websocketAck struct {
+	Ack      bool   `json:"ack"`
+	Error    string `json:"error,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Sequence int64  `json:"sequence,omitempty"`
+}
+
+// getEventsWebSocket upgrades the request to a WebSocket connection that
+// accepts a stream of event messages. Each message is the same payload that
+// would be sent to `POST /api/events` and is acknowledged in order.
+func (rt *router) getEventsWebSocket(allowlist []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString(contextKeyCookie)
+		exempt := c.GetBool(contextKeyConsentExempt)
+		server := websocket.Server{
+			Handshake: func(_ *websocket.Config, r *http.Request) error {
+				return websocketOriginAllowed(r, allowlist)
+			},
+			Handler: func(ws *websocket.Conn) {
+				defer ws.Close()
+				ws.MaxPayloadBytes = int(rt.bodyLimit())
+				for {
+					var body []byte
+					if err := websocket.Message.Receive(ws, &body); err != nil {
+						return
+					}
+					// Connections opened without consent only accept events
+					// for consent exempt accounts. Other events are dropped,
+					// just like they would be when being posted.
+					if exempt && !rt.consentExempt(body) {
+						if err := websocket.JSON.Send(ws, websocketAck{Status: http.StatusNoContent}); err != nil {
+							rt.logError(c.Request.Context(), err, "error sending websocket ack")
+							return
+						}
+						continue
+					}
+					result, errResp := rt.ingestEvent(c.Request, userID, body, false)
+					ack := websocketAck{Ack: true, Sequence: result.userSequence}
+					if errResp != nil {
+						ack = websocketAck{Error: errResp.Error, Status: errResp.Status}
+					}
+					if err := websocket.JSON.Send(ws, ack); err != nil {
+						rt.logError(c.Request.Context(), err, "error sending websocket ack")
+						return
+					}
+				}
+			},
+		}
+		server.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// websocketOriginAllowed checks the Origin header of a WebSocket handshake.
+// Browsers do not apply CORS to WebSocket connections, so connections are
+// only accepted from the Offen origin itself or any of the origins allowed
+// to make cross origin requests. Clients that do not send an Origin header
+// are not browsers and are accepted.
+func websocketOriginAllowed(r *http.Request, allowlist []string) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	normalized, err := normalizeOrigin(origin)
+	if err != nil {
+		return fmt.Errorf("router: error checking websocket origin: %w", err)
+	}
+	if isOwnOrigin(r, normalized) {
+		return nil
+	}
+	if corsOriginAllowed(normalized, allowlist) {
+		return nil
+	}
+	return fmt.Errorf("router: origin %s is not allowed to open websocket connections", origin)
+}
@@ -0,0 +1,243 @@
+// Copyright 2022 - Offen Authors <hioffen@posteo.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offen/offen/server/config"
+	"github.com/offen/offen/server/persistence"
+	"github.com/offen/offen/server/ratelimiter"
+	"golang.org/x/net/websocket"
+)
+
+type mockWebSocketEventsService struct {
+	persistence.Service
+	inserted []string
+	settings map[string]persistence.AccountSettings
+}
+
+func (m *mockWebSocketEventsService) Insert(userID, accountID, payload, contentHash string, exempt bool, bucket *string) error {
+	if accountID == "account-z" {
+		return persistence.ErrUnknownAccount("unknown account")
+	}
+	m.inserted = append(m.inserted, userID+":"+accountID+":"+payload)
+	return nil
+}
+
+func (m *mockWebSocketEventsService) GetAccountRetention(string) (time.Duration, error) {
+	return 0, nil
+}
+
+func (m *mockWebSocketEventsService) GetAccountSettings(accountID string) (persistence.AccountSettings, error) {
+	return m.settings[accountID], nil
+}
+
+func (m *mockWebSocketEventsService) GetIngestPause(string) (persistence.IngestPause, error) {
+	return persistence.IngestPause{}, nil
+}
+
+func TestRouter_getEventsWebSocket(t *testing.T) {
+	db := &mockWebSocketEventsService{}
+	rt := router{
+		db:      db,
+		config:  &config.Config{},
+		limiter: ratelimiter.NewNoopRateLimiter(),
+	}
+	m := gin.New()
+	m.GET("/", func(c *gin.Context) {
+		c.Set(contextKeyCookie, "user-id")
+		c.Next()
+	}, rt.getEventsWebSocket(nil))
+
+	server := httptest.NewServer(m)
+	defer server.Close()
+
+	ws, err := websocket.Dial(strings.Replace(server.URL, "http", "ws", 1), "", server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error dialing: %v", err)
+	}
+	defer ws.Close()
+
+	messages := []string{
+		`{"accountId":"account-a","payload":"payload-a"}`,
+		`o hai!`,
+		`{"accountId":"account-z","payload":"payload-z"}`,
+		`{"accountId":"account-b","payload":"payload-b"}`,
+	}
+	expectedAcks := []websocketAck{
+		{Ack: true},
+		{Status: 400},
+		{Status: 404},
+		{Ack: true},
+	}
+	for i, message := range messages {
+		if err := websocket.Message.Send(ws, message); err != nil {
+			t.Fatalf("Unexpected error sending message: %v", err)
+		}
+		var ack websocketAck
+		if err := websocket.JSON.Receive(ws, &ack); err != nil {
+			t.Fatalf("Unexpected error receiving ack: %v", err)
+		}
+		ack.Error = ""
+		if !reflect.DeepEqual(expectedAcks[i], ack) {
+			t.Errorf("Unexpected ack for message %d: %v", i, ack)
+		}
+	}
+
+	expectedInserts := []string{"user-id:account-a:payload-a", "user-id:account-b:payload-b"}
+	if !reflect.DeepEqual(expectedInserts, db.inserted) {
+		t.Errorf("Unexpected inserts %v", db.inserted)
+	}
+}
+
+func TestRouter_getEventsWebSocket_ConsentExempt(t *testing.T) {
+	db := &mockWebSocketEventsService{
+		settings: map[string]persistence.AccountSettings{
+			"account-a": {ConsentExempt: true},
+		},
+	}
+	rt := router{
+		db:      db,
+		config:  &config.Config{},
+		limiter: ratelimiter.NewNoopRateLimiter(),
+	}
+	m := gin.New()
+	m.GET(
+		"/",
+		rt.optinOrExemptMiddleware("consent", "allow"),
+		userCookieMiddleware("user", contextKeyCookie),
+		rt.getEventsWebSocket(nil),
+	)
+
+	server := httptest.NewServer(m)
+	defer server.Close()
+
+	ws, err := websocket.Dial(strings.Replace(server.URL, "http", "ws", 1), "", server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error dialing: %v", err)
+	}
+	defer ws.Close()
+
+	messages := []string{
+		`{"accountId":"account-a","payload":"payload-a"}`,
+		`{"accountId":"account-b","payload":"payload-b"}`,
+		`[{"accountId":"account-a","payload":"payload-a"},{"accountId":"account-b","payload":"payload-b"}]`,
+	}
+	expectedAcks := []websocketAck{
+		{Ack: true},
+		{Status: 204},
+		{Status: 204},
+	}
+	for i, message := range messages {
+		if err := websocket.Message.Send(ws, message); err != nil {
+			t.Fatalf("Unexpected error sending message: %v", err)
+		}
+		var ack websocketAck
+		if err := websocket.JSON.Receive(ws, &ack); err != nil {
+			t.Fatalf("Unexpected error receiving ack: %v", err)
+		}
+		ack.Error = ""
+		if !reflect.DeepEqual(expectedAcks[i], ack) {
+			t.Errorf("Unexpected ack for message %d: %v", i, ack)
+		}
+	}
+
+	expectedInserts := []string{":account-a:payload-a"}
+	if !reflect.DeepEqual(expectedInserts, db.inserted) {
+		t.Errorf("Unexpected inserts %v", db.inserted)
+	}
+}
+
+func TestRouter_getEventsWebSocket_Origin(t *testing.T) {
+	tests := []struct {
+		name        string
+		origin      func(serverURL string) string
+		expectError bool
+	}{
+		{
+			"same origin",
+			func(serverURL string) string { return serverURL },
+			false,
+		},
+		{
+			"allowed cross origin",
+			func(string) string { return "https://www.example.net" },
+			false,
+		},
+		{
+			"disallowed cross origin",
+			func(string) string { return "https://attacker.example.com" },
+			true,
+		},
+		{
+			"invalid origin",
+			func(string) string { return "null" },
+			true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := router{
+				db:      &mockWebSocketEventsService{},
+				config:  &config.Config{},
+				limiter: ratelimiter.NewNoopRateLimiter(),
+			}
+			m := gin.New()
+			m.GET("/", rt.getEventsWebSocket([]string{"https://www.example.net"}))
+
+			server := httptest.NewServer(m)
+			defer server.Close()
+
+			ws, err := websocket.Dial(strings.Replace(server.URL, "http", "ws", 1), "", test.origin(server.URL))
+			if (err != nil) != test.expectError {
+				t.Errorf("Unexpected error value %v", err)
+			}
+			if ws != nil {
+				ws.Close()
+			}
+		})
+	}
+}
+
+func TestRouter_getEventsWebSocket_BodyLimit(t *testing.T) {
+	db := &mockWebSocketEventsService{}
+	rt := router{
+		db:           db,
+		config:       &config.Config{},
+		limiter:      ratelimiter.NewNoopRateLimiter(),
+		maxBodyBytes: 64,
+	}
+	m := gin.New()
+	m.GET("/", func(c *gin.Context) {
+		c.Set(contextKeyCookie, "user-id")
+		c.Next()
+	}, rt.getEventsWebSocket(nil))
+
+	server := httptest.NewServer(m)
+	defer server.Close()
+
+	ws, err := websocket.Dial(strings.Replace(server.URL, "http", "ws", 1), "", server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error dialing: %v", err)
+	}
+	defer ws.Close()
+
+	message := `{"accountId":"account-a","payload":"` + strings.Repeat("x", 64) + `"}`
+	if err := websocket.Message.Send(ws, message); err != nil {
+		t.Fatalf("Unexpected error sending message: %v", err)
+	}
+	var ack websocketAck
+	if err := websocket.JSON.Receive(ws, &ack); err == nil {
+		t.Errorf("Expected connection to be closed, received ack %v", ack)
+	}
+	if len(db.inserted) != 0 {
+		t.Errorf("Unexpected inserts %v", db.inserted)
+	}
+}
@@ -49,10 +49,10 @@ function getEventsWith (accountsUrl) {
   }
 }
 
-exports.postEvent = postEventWith(window.location.origin + '/api/events')
+exports.postEvent = postEventWith(window.location.origin + '/api/events', embeddingOrigin)
 exports.postEventWith = postEventWith
 
-function postEventWith (eventsUrl) {
+function postEventWith (eventsUrl, getOrigin) {
   return function (accountId, payload) {
     var url = new window.URL(eventsUrl)
     return window
@@ -61,13 +61,29 @@ function postEventWith (eventsUrl) {
         credentials: 'include',
         body: JSON.stringify({
           accountId: accountId,
-          payload: payload
+          payload: payload,
+          origin: getOrigin ? getOrigin() : undefined
         })
       })
       .then(handleFetchResponse)
   }
 }
 
+// embeddingOrigin returns the origin of the page the vault is embedded in.
+// As events are sent from the vault's own origin, the server cannot infer
+// this from the request's headers, so it is sent as part of the event.
+function embeddingOrigin () {
+  var ancestorOrigins = window.location.ancestorOrigins
+  if (ancestorOrigins && ancestorOrigins.length) {
+    return ancestorOrigins[0]
+  }
+  try {
+    return new window.URL(document.referrer).origin
+  } catch (err) {
+    return undefined
+  }
+}
+
 exports.getPublicKey = getPublicKeyWith(window.location.origin + '/api/exchange')
 exports.getPublicKeyWith = getPublicKeyWith
 
@@ -298,11 +314,14 @@ exports.retireAccount = retireAccountWith(window.location.origin + '/api/account
 exports.retireAccountWith = retireAccountWith
 
 function retireAccountWith (deleteUrl) {
-  return function (accountId) {
+  return function (accountId, accountName) {
     return window
       .fetch(deleteUrl + '/' + accountId, {
         method: 'DELETE',
-        credentials: 'include'
+        credentials: 'include',
+        body: JSON.stringify({
+          accountName: accountName
+        })
       })
       .then(handleFetchResponse)
   }
@@ -50,4 +50,31 @@ describe('src/api.js', function () {
         })
     })
   })
+
+  describe('postEvent', function () {
+    before(function () {
+      fetchMock.post('https://server.offen.dev/events', {
+        status: 201,
+        body: { ack: true }
+      })
+    })
+
+    after(function () {
+      fetchMock.restore()
+    })
+
+    it('sends the origin of the embedding page', function () {
+      var post = api.postEventWith('https://server.offen.dev/events', function () {
+        return 'https://www.example.net'
+      })
+      return post('foo-bar', 'some-payload')
+        .then(function (result) {
+          assert.deepStrictEqual(result, { ack: true })
+          assert.deepStrictEqual(
+            JSON.parse(fetchMock.lastOptions().body),
+            { accountId: 'foo-bar', payload: 'some-payload', origin: 'https://www.example.net' }
+          )
+        })
+    })
+  })
 })
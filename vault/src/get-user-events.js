@@ -31,34 +31,43 @@ function getUserEventsWith (queries, eventStore, api) {
 
 function ensureSyncWith (eventStore, api) {
   return function () {
-    var retentionPeriod
     return eventStore.getLastKnownCheckpoint(null)
-      .then(function (checkpoint) {
-        var params = checkpoint
-          ? { since: checkpoint }
-          : null
-        return api.getEvents(params)
-          .catch(function (err) {
-            // in case a user without a cookie tries to query for events a 400
-            // will be returned
-            if (err.status === 400) {
-              return { events: [] }
-            }
-            throw err
-          })
-          .then(function (payload) {
-            retentionPeriod = payload.retentionPeriod
-            var events = payload.events
-            return Promise.all([
-              decryptUserEventsWith(eventStore)(events),
-              payload.sequence
-                ? eventStore.updateLastKnownCheckpoint(null, payload.sequence)
-                : null,
-              payload.deletedEvents
-                ? eventStore.deleteEvents(null, payload.deletedEvents)
-                : null
-            ])
-          })
+      .then(syncPageWith(eventStore, api))
+  }
+}
+
+// syncPageWith fetches and stores the events following the given checkpoint.
+// In case the server responds with a partial result, the next page is synced
+// until all events have been received.
+function syncPageWith (eventStore, api) {
+  return function syncPage (checkpoint) {
+    var retentionPeriod
+    var nextCursor
+    var params = checkpoint
+      ? { since: checkpoint }
+      : null
+    return api.getEvents(params)
+      .catch(function (err) {
+        // in case a user without a cookie tries to query for events a 400
+        // will be returned
+        if (err.status === 400) {
+          return { events: [] }
+        }
+        throw err
+      })
+      .then(function (payload) {
+        retentionPeriod = payload.retentionPeriod
+        nextCursor = payload.nextCursor
+        var events = payload.events
+        return Promise.all([
+          decryptUserEventsWith(eventStore)(events),
+          payload.sequence
+            ? eventStore.updateLastKnownCheckpoint(null, payload.sequence)
+            : null,
+          payload.deletedEvents
+            ? eventStore.deleteEvents(null, payload.deletedEvents)
+            : null
+        ])
       })
       .then(function (results) {
         var events = results[0].map(function (event) {
@@ -72,12 +81,15 @@ function ensureSyncWith (eventStore, api) {
           )
         })
         return eventStore.putEvents(null, events)
-          .then(function (result) {
-            if (!result) {
-              return { retentionPeriod: retentionPeriod }
-            }
-            return Object.assign(result, { retentionPeriod: retentionPeriod })
-          })
+      })
+      .then(function (result) {
+        if (nextCursor) {
+          return syncPage(nextCursor)
+        }
+        if (!result) {
+          return { retentionPeriod: retentionPeriod }
+        }
+        return Object.assign(result, { retentionPeriod: retentionPeriod })
       })
   }
 }
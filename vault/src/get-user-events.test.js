@@ -101,5 +101,56 @@ describe('src/get-user-events', function () {
           assert.deepStrictEqual(result, { mock: 'result', account: { retentionPeriod: '30days' } })
         })
     })
+
+    it('follows the next cursor of partial results', function () {
+      var mockStorage = {
+        deleteEvents: sinon.stub().resolves(true),
+        putEvents: sinon.stub().resolves(true),
+        getLastKnownCheckpoint: sinon.stub().resolves('sequence-a'),
+        updateLastKnownCheckpoint: sinon.stub().resolves(),
+        getUserSecret: sinon.stub().resolves(window.crypto.subtle.exportKey('jwk', userSecret))
+      }
+      var mockQueries = {
+        getDefaultStats: sinon.stub().resolves({ mock: 'result' })
+      }
+      var getEvents = sinon.stub()
+      getEvents.onFirstCall().resolves({
+        events: {
+          'account-a': [{
+            eventId: 'y',
+            accountId: 'account-a',
+            payload: encryptedPayload
+          }]
+        },
+        sequence: 'sequence-b',
+        nextCursor: 'sequence-b',
+        retentionPeriod: '30days'
+      })
+      getEvents.onSecondCall().resolves({
+        events: {
+          'account-a': [{
+            eventId: 'z',
+            accountId: 'account-a',
+            payload: encryptedPayload
+          }]
+        },
+        sequence: 'sequence-c',
+        retentionPeriod: '30days'
+      })
+      var mockApi = { getEvents: getEvents }
+      var getUserEvents = getUserEventsWith(mockQueries, mockStorage, mockApi)
+      return getUserEvents()
+        .then(function (result) {
+          assert(mockApi.getEvents.calledTwice)
+          assert(mockApi.getEvents.firstCall.calledWith({ since: 'sequence-a' }))
+          assert(mockApi.getEvents.secondCall.calledWith({ since: 'sequence-b' }))
+
+          assert(mockStorage.updateLastKnownCheckpoint.calledTwice)
+          assert(mockStorage.updateLastKnownCheckpoint.secondCall.calledWith(null, 'sequence-c'))
+
+          assert(mockStorage.putEvents.calledTwice)
+          assert.deepStrictEqual(result, { mock: 'result', account: { retentionPeriod: '30days' } })
+        })
+    })
   })
 })
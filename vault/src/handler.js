@@ -268,7 +268,7 @@ exports.handleRetireAccountWith = handleRetireAccountWith
 
 function handleRetireAccountWith (api) {
   return proxyThunk(function (payload) {
-    return api.retireAccount(payload.accountId)
+    return api.retireAccount(payload.accountId, payload.accountName)
   })
 }
 